@@ -0,0 +1,40 @@
+DNSMASQ_VERSION="2.90"
+DNSMASQ_LINK="git://thekelleys.org.uk/dnsmasq.git"
+
+download_dnsmasq() {
+    download_git $DNSMASQ_LINK "v${DNSMASQ_VERSION}"
+}
+
+prepare_dnsmasq() {
+    echo "[+] prepare dnsmasq"
+    github_name "dnsmasq-${DNSMASQ_VERSION}"
+}
+
+compile_dnsmasq() {
+    echo "[+] compiling dnsmasq"
+    make
+}
+
+install_dnsmasq() {
+    echo "[+] installing dnsmasq"
+    mkdir -p "${ROOTDIR}/usr/sbin"
+    cp src/dnsmasq "${ROOTDIR}/usr/sbin/dnsmasq"
+}
+
+build_dnsmasq() {
+    apt-get install -y \
+        build-essential \
+        git
+
+    pushd "${WORKDIR}"
+
+    download_dnsmasq
+    prepare_dnsmasq
+
+    pushd "dnsmasq"
+    compile_dnsmasq
+    install_dnsmasq
+    popd
+
+    popd
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go"
 	"github.com/threefoldtech/zos/pkg"
@@ -155,6 +156,57 @@ func (n *NodeClient) GPUs(ctx context.Context) (gpus []GPU, err error) {
 	return
 }
 
+// VMGuestInfo returns information about a zmachine, including the guest agent
+// information if the agent is running inside the vm
+func (n *NodeClient) VMGuestInfo(ctx context.Context, contractID uint64, name string) (info pkg.VMInfo, err error) {
+	const cmd = "zos.vm.guest_info"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &info)
+	return
+}
+
+// VMMetrics returns a zmachine resources usage over the given window. The window
+// is bounded by the node metrics retention (24 hours)
+func (n *NodeClient) VMMetrics(ctx context.Context, contractID uint64, name string, window time.Duration) (usage pkg.MachineUsageReport, err error) {
+	const cmd = "zos.vm.metrics"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+		"window":      uint64(window / time.Second),
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &usage)
+	return
+}
+
+// VMGuestShutdown gracefully shutdown a zmachine using the guest agent
+func (n *NodeClient) VMGuestShutdown(ctx context.Context, contractID uint64, name string) error {
+	const cmd = "zos.vm.guest_shutdown"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+	}
+
+	return n.bus.Call(ctx, n.nodeTwin, cmd, in, nil)
+}
+
+// VMGuestPasswordSet sets a user password inside a zmachine using the guest agent
+func (n *NodeClient) VMGuestPasswordSet(ctx context.Context, contractID uint64, name, user, password string) error {
+	const cmd = "zos.vm.guest_password_set"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+		"user":        user,
+		"password":    password,
+	}
+
+	return n.bus.Call(ctx, n.nodeTwin, cmd, in, nil)
+}
+
 // NetworkListWGPorts return a list of all "taken" ports on the node. A new deployment
 // should be careful to use a free port for its network setup.
 func (n *NodeClient) NetworkListWGPorts(ctx context.Context) ([]uint16, error) {
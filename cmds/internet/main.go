@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"net"
 	"os"
@@ -18,12 +19,15 @@ import (
 	"github.com/threefoldtech/zos/pkg/network/dhcp"
 	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
 	"github.com/threefoldtech/zos/pkg/network/options"
+	"github.com/threefoldtech/zos/pkg/network/static"
 	"github.com/threefoldtech/zos/pkg/network/types"
 	"github.com/threefoldtech/zos/pkg/zinit"
 
 	"github.com/threefoldtech/zos/pkg/version"
 )
 
+const resolvConf = "/etc/resolv.conf"
+
 func main() {
 	app.Initialize()
 
@@ -122,6 +126,54 @@ func check() error {
 	return backoff.RetryNotify(f, backoff.NewExponentialBackOff(), errHandler)
 }
 
+// selectZOS returns the name of the link to attach to the zos bridge. With a
+// static config it's the configured nic (or bond of nics), otherwise it's
+// the physical nic that gets an IP over DHCP
+func selectZOS(cfg *static.Config, vlan *uint16) (string, error) {
+	if cfg != nil {
+		link, err := cfg.Uplink()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to setup static network nics")
+		}
+
+		return link.Attrs().Name, nil
+	}
+
+	ifaceConfigs, err := bootstrap.AnalyzeLinks(
+		bootstrap.RequiresIPv4.WithVlan(vlan),
+		bootstrap.PhysicalFilter,
+		bootstrap.PluggedFilter)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to gather network interfaces configuration")
+		return "", err
+	}
+
+	log.Info().Int("count", len(ifaceConfigs)).Msg("found interfaces with internet access")
+	log.Info().Msgf("found interfaces: %+v", ifaceConfigs)
+	zosChild, err := bootstrap.SelectZOS(ifaceConfigs)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to select a valid interface for zos bridge")
+		return "", err
+	}
+
+	return zosChild, nil
+}
+
+// configureStatic sets the static address and dns servers of the zos bridge
+// instead of running a DHCP client
+func configureStatic(cfg *static.Config) error {
+	log.Info().
+		Stringer("address", cfg.Address).
+		Str("gateway", cfg.Gateway.String()).
+		Msg("configure static address on zos bridge")
+
+	if err := static.SetAddress(types.DefaultBridge, cfg.Address.IPNet, cfg.Gateway); err != nil {
+		return err
+	}
+
+	return cfg.WriteResolv(resolvConf)
+}
+
 /*
 *
 configureZOS bootstraps the private zos network (private subnet) it goes as follows:
@@ -135,32 +187,34 @@ before but with the next twist:
 - During probing of the interface, probing done on that vlan
 - ZOS is added to vlan as `bridge vlan add vid <id> dev zos pvid self untagged`
 - link is added to vlan as `bridge vlan add vid <id> dev <link>`
+
+In case a static network config is set (kernel params or boot usb, see pkg/network/static) the
+configured nics are attached to zos without probing, and the static address is set on zos instead
+of starting a DHCP daemon.
 */
 func configureZOS() error {
 	env := environment.MustGet()
 
+	cfg, err := static.Load(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "failed to load static network config")
+	}
+
+	privVlan := env.PrivVlan
+	if cfg != nil && cfg.Vlan != nil {
+		privVlan = cfg.Vlan
+	}
+
 	f := func() error {
 		log.Info().Msg("Start network bootstrap")
 
-		ifaceConfigs, err := bootstrap.AnalyzeLinks(
-			bootstrap.RequiresIPv4.WithVlan(env.PrivVlan),
-			bootstrap.PhysicalFilter,
-			bootstrap.PluggedFilter)
+		zosChild, err := selectZOS(cfg, privVlan)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to gather network interfaces configuration")
-			return err
-		}
-
-		log.Info().Int("count", len(ifaceConfigs)).Msg("found interfaces with internet access")
-		log.Info().Msgf("found interfaces: %+v", ifaceConfigs)
-		zosChild, err := bootstrap.SelectZOS(ifaceConfigs)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to select a valid interface for zos bridge")
 			return err
 		}
 
 		log.Info().Str("interface", zosChild).Msg("selecting interface")
-		br, err := bootstrap.CreateDefaultBridge(types.DefaultBridge, env.PrivVlan)
+		br, err := bootstrap.CreateDefaultBridge(types.DefaultBridge, privVlan)
 		if err != nil {
 			return err
 		}
@@ -193,7 +247,7 @@ func configureZOS() error {
 			return errors.Wrapf(err, "could not bring %s up", zosChild)
 		}
 
-		if env.PrivVlan != nil && env.PubVlan != nil {
+		if privVlan != nil && env.PubVlan != nil {
 			// if both priv and pub vlan are configured it means
 			// that we can remove the default tagging of vlan 1
 			// remove default
@@ -202,9 +256,9 @@ func configureZOS() error {
 			}
 		}
 
-		if env.PrivVlan != nil {
+		if privVlan != nil {
 			// add new vlan
-			if err := netlink.BridgeVlanAdd(link, *env.PrivVlan, false, false, false, false); err != nil {
+			if err := netlink.BridgeVlanAdd(link, *privVlan, false, false, false, false); err != nil {
 				return errors.Wrapf(err, "failed to set vlan on device '%s'", link.Attrs().Name)
 			}
 		}
@@ -216,6 +270,10 @@ func configureZOS() error {
 			}
 		}
 
+		if cfg != nil {
+			return configureStatic(cfg)
+		}
+
 		dhcpService := dhcp.NewService(types.DefaultBridge, "", zinit.Default())
 		if err := dhcpService.DestroyOlderService(); err != nil {
 			log.Error().Err(err).Msgf("failed to destory older %s service", dhcpService.Name)
@@ -12,6 +12,7 @@ import (
 	"github.com/threefoldtech/zos/pkg/network/dhcp"
 	"github.com/threefoldtech/zos/pkg/network/mycelium"
 	"github.com/threefoldtech/zos/pkg/network/public"
+	"github.com/threefoldtech/zos/pkg/network/static"
 	"github.com/threefoldtech/zos/pkg/network/types"
 	"github.com/threefoldtech/zos/pkg/zinit"
 	"github.com/urfave/cli/v2"
@@ -106,7 +107,12 @@ func action(cli *cli.Context) error {
 		return errors.Wrap(err, "failed to setup public bridge")
 	}
 
-	dmz := ndmz.New(nodeID.Identity(), master)
+	staticCfg, err := static.Load(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load static network config")
+	}
+
+	dmz := ndmz.New(nodeID.Identity(), master, staticCfg)
 
 	if err := dmz.Create(ctx); err != nil {
 		return errors.Wrap(err, "failed to create ndmz")
@@ -129,7 +135,10 @@ func action(cli *cli.Context) error {
 		return err
 	}
 
-	networker, err := network.NewNetworker(identity, dmz, ygg, mycelium)
+	// keep the mycelium peers healthy
+	go mycelium.Monitor(ctx)
+
+	networker, err := network.NewNetworker(identity, stubs.NewGatewayStub(client), dmz, ygg, mycelium, root)
 	if err != nil {
 		return errors.Wrap(err, "error creating network manager")
 	}
@@ -2,59 +2,104 @@ package networkd
 
 import (
 	"context"
-	"os/exec"
+	"fmt"
 
+	"github.com/google/nftables"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"golang.org/x/sys/unix"
 )
 
+// smtpPorts are blocked for hidden nodes
+var smtpPorts = []uint16{25, 587, 465}
+
+func filterChain(name string, hook *nftables.ChainHook, rules ...nft.Rule) nft.Chain {
+	return nft.BaseChain(name, nftables.ChainTypeFilter, hook, 0, nftables.ChainPolicyAccept, rules...)
+}
+
+// hostFwTables are the base filter chains of the host, other components
+// (like the public ips filters) add their own rules to these chains.
+func hostFwTables() []nft.Table {
+	return []nft.Table{
+		{
+			Family: nftables.TableFamilyINet,
+			Name:   "filter",
+			Chains: []nft.Chain{
+				filterChain("input", nftables.ChainHookInput),
+				filterChain("forward", nftables.ChainHookForward),
+				filterChain("output", nftables.ChainHookOutput),
+				filterChain("prerouting", nftables.ChainHookPrerouting),
+			},
+		},
+		{
+			Family: nftables.TableFamilyARP,
+			Name:   "filter",
+			Chains: []nft.Chain{
+				filterChain("input", nftables.ChainHookInput),
+				filterChain("output", nftables.ChainHookOutput),
+			},
+		},
+		{
+			Family: nftables.TableFamilyBridge,
+			Name:   "filter",
+			Chains: []nft.Chain{
+				filterChain("input", nftables.ChainHookInput),
+				filterChain("forward", nftables.ChainHookForward),
+				filterChain("prerouting", nftables.ChainHookPrerouting),
+				filterChain("postrouting", nftables.ChainHookPostrouting),
+				filterChain("output", nftables.ChainHookOutput),
+			},
+		},
+	}
+}
+
+// hostFwRules are the chains that are owned by networkd, their rules are
+// replaced on start.
+func hostFwRules() []nft.Table {
+	var smtp []nft.Rule
+	for _, port := range smtpPorts {
+		smtp = append(smtp, nft.NewRule(
+			fmt.Sprintf("drop smtp %d for hidden nodes", port),
+			nft.IIfName("b-*"),
+			nft.NFProto(unix.NFPROTO_IPV4),
+			nft.TCPDPort(port, 0),
+			nft.RejectAdminProhibited(),
+		))
+	}
+
+	return []nft.Table{
+		{
+			Family: nftables.TableFamilyINet,
+			Name:   "filter",
+			Chains: []nft.Chain{
+				filterChain("forward", nftables.ChainHookForward),
+				filterChain("prerouting", nftables.ChainHookPrerouting, smtp...),
+			},
+		},
+		{
+			Family: nftables.TableFamilyBridge,
+			Name:   "filter",
+			Chains: []nft.Chain{
+				filterChain("forward", nftables.ChainHookForward),
+			},
+		},
+	}
+}
+
 func ensureHostFw(ctx context.Context) error {
 	log.Info().Msg("ensuring existing host nft rules")
 
-	cmd := exec.CommandContext(ctx, "/bin/sh", "-c",
-		`
-nft 'add table inet filter'
-nft 'add table arp filter'
-nft 'add table bridge filter'
-
-# duo to a bug we had we need to make sure those chains are
-# deleted and then recreated later
-nft 'delete chain inet filter input'
-nft 'delete chain inet filter forward'
-nft 'delete chain inet filter output'
-
-nft 'delete chain bridge filter input'
-nft 'delete chain bridge filter forward'
-nft 'delete chain bridge filter output'
-
-nft 'delete chain arp filter input'
-nft 'delete chain arp filter output'
-
-# recreate chains correctly
-nft 'add chain inet filter input   { type filter hook input priority filter; policy accept; }'
-nft 'add chain inet filter forward { type filter hook forward priority filter; policy accept; }'
-nft 'add chain inet filter output  { type filter hook output priority filter; policy accept; }'
-nft 'add chain inet filter prerouting  { type filter hook prerouting priority filter; policy accept; }'
-
-nft 'add chain arp filter input  { type filter hook input priority filter; policy accept; }'
-nft 'add chain arp filter output { type filter hook output priority filter; policy accept; }'
-
-nft 'add chain bridge filter input   { type filter hook input priority filter; policy accept; }'
-nft 'add chain bridge filter forward { type filter hook forward priority filter; policy accept; }'
-nft 'add chain bridge filter prerouting { type filter hook prerouting priority filter; policy accept; }'
-nft 'add chain bridge filter postrouting { type filter hook postrouting priority filter; policy accept; }'
-nft 'add chain bridge filter output  { type filter hook output priority filter; policy accept; }'
-
-nft 'flush chain bridge filter forward'
-nft 'flush chain inet filter forward'
-nft 'flush chain inet filter prerouting'
-
-# drop smtp traffic for hidden nodes
-nft 'add rule inet filter prerouting iifname "b-*" tcp dport {25, 587, 465} reject with icmp type admin-prohibited'
-`)
-
-	if err := cmd.Run(); err != nil {
-		return errors.Wrap(err, "could not set up host nft rules")
+	for _, table := range hostFwTables() {
+		if err := nft.EnsureChains("", table); err != nil {
+			return errors.Wrap(err, "could not set up host nft chains")
+		}
+	}
+
+	for _, table := range hostFwRules() {
+		if err := nft.ApplyChains("", table); err != nil {
+			return errors.Wrap(err, "could not set up host nft rules")
+		}
 	}
 
 	return nil
@@ -38,6 +38,7 @@ const (
 	serverName       = "provision"
 	provisionModule  = "provision"
 	statisticsModule = "statistics"
+	usageModule      = "usage"
 	gib              = 1024 * 1024 * 1024
 
 	boltStorageDB = "workloads.bolt"
@@ -45,6 +46,8 @@ const (
 	metricsStorageDBOld = "metrics.bolt"
 	// new style db after rrd implementation change
 	metricsStorageDB = "metrics-diff.bolt"
+	// vms resources usage db
+	usageStorageDB = "usage.bolt"
 
 	// deprecated, kept for migration
 	fsStorageDB = "workloads"
@@ -69,6 +72,10 @@ var Module cli.Command = cli.Command{
 			Name:  "integrity",
 			Usage: "run some integrity checks on some files",
 		},
+		&cli.StringFlag{
+			Name:  "db",
+			Usage: "only check the `DB` file with --integrity",
+		},
 	},
 	Action: action,
 }
@@ -79,25 +86,41 @@ var Module cli.Command = cli.Command{
 // while we can catch the sigbus and handle it ourselves i thought
 // it's better to do it in a separate process to always have a clean
 // state
-func integrityChecks(ctx context.Context, rootDir string) error {
-	err := ReportChecks(filepath.Join(rootDir, metricsStorageDB))
+func integrityChecks(ctx context.Context, rootDir string, only string) error {
+	for _, db := range []string{metricsStorageDB, usageStorageDB} {
+		if len(only) != 0 && db != only {
+			continue
+		}
 
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if err := ReportChecks(filepath.Join(rootDir, db)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return err
+		}
 	}
 
-	return err
+	return nil
 }
 
-// runChecks starts provisiond with the special flag `--integrity` which runs some
-// checks and return an error if checks did not pass.
-// if an error is received the db files are cleaned
+// runChecks checks each of the db files with runCheck
 func runChecks(ctx context.Context, rootDir string, cl zbus.Client) error {
 	log.Info().Msg("run integrity checks")
+	for _, db := range []string{metricsStorageDB, usageStorageDB} {
+		if err := runCheck(ctx, rootDir, db, cl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runCheck starts provisiond with the special flag `--integrity` which runs some
+// checks on the db file and return an error if checks did not pass.
+// if an error is received the db file is cleaned, other db files are kept.
+func runCheck(ctx context.Context, rootDir, db string, cl zbus.Client) error {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, os.Args[0], "--root", rootDir, "--integrity")
+	cmd := exec.CommandContext(ctx, os.Args[0], "--root", rootDir, "--integrity", "--db", db)
 	var buf bytes.Buffer
 	cmd.Stderr = &buf
 
@@ -109,17 +132,17 @@ func runChecks(ctx context.Context, rootDir string, cl zbus.Client) error {
 		return nil
 	}
 
-	log.Error().Str("stderr", buf.String()).Err(err).Msg("integrity check failed, resetting rrd db")
+	log.Error().Str("db", db).Str("stderr", buf.String()).Err(err).Msg("integrity check failed, resetting db")
 
 	zui := stubs.NewZUIStub(cl)
 	if er := zui.PushErrors(ctx, "integrity", []string{
-		fmt.Sprintf("integrity check failed, resetting rrd db stderr=%s: %v", buf.String(), err),
+		fmt.Sprintf("integrity check of %s failed, resetting db stderr=%s: %v", db, buf.String(), err),
 	}); er != nil {
 		log.Error().Err(er).Msg("failed to push errors to zui")
 	}
 
 	// other error, we can try to clean up and continue
-	return os.RemoveAll(filepath.Join(rootDir, metricsStorageDB))
+	return os.RemoveAll(filepath.Join(rootDir, db))
 }
 
 func action(cli *cli.Context) error {
@@ -127,6 +150,7 @@ func action(cli *cli.Context) error {
 		msgBrokerCon string = cli.String("broker")
 		rootDir      string = cli.String("root")
 		integrity    bool   = cli.Bool("integrity")
+		integrityDB  string = cli.String("db")
 	)
 
 	server, err := zbus.NewRedisServer(serverName, msgBrokerCon, 1)
@@ -141,7 +165,7 @@ func action(cli *cli.Context) error {
 	ctx, _ := utils.WithSignal(context.Background())
 
 	if integrity {
-		return integrityChecks(ctx, rootDir)
+		return integrityChecks(ctx, rootDir, integrityDB)
 	}
 
 	utils.OnDone(ctx, func(_ error) {
@@ -331,6 +355,7 @@ func action(cli *cli.Context) error {
 			zos.PublicIPType,
 			zos.ZMachineType,
 			zos.ZLogsType, //make sure zlogs comes after zmachine
+			zos.PortForwardType,
 		),
 		// if this is a node reboot, the node needs to
 		// recreate all reservations. so we set rerun = true
@@ -391,11 +416,20 @@ func action(cli *cli.Context) error {
 	// clean up old rrd db that uses previous style reporting
 	_ = os.Remove(filepath.Join(rootDir, metricsStorageDBOld))
 
-	reporter, err := NewReporter(filepath.Join(rootDir, metricsStorageDB), cl, queues)
+	reporter, err := NewReporter(
+		filepath.Join(rootDir, metricsStorageDB),
+		filepath.Join(rootDir, usageStorageDB),
+		cl, queues,
+	)
 	if err != nil {
 		return errors.Wrap(err, "failed to setup capacity reporter")
 	}
 
+	server.Register(
+		zbus.ObjectID{Name: usageModule, Version: "0.0.1"},
+		pkg.VMUsage(reporter),
+	)
+
 	// also spawn the capacity reporter
 	go func() {
 		defer reporter.Close()
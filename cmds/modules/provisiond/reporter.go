@@ -3,6 +3,7 @@ package provisiond
 import (
 	"context"
 	"crypto/ed25519"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -28,10 +29,33 @@ type Report struct {
 	Consumption []substrate.NruConsumption
 }
 
+const (
+	// usage metrics keys
+	usageCPUTime        = "cpu_time"
+	usageMemoryRSS      = "memory_rss"
+	usageMemoryActual   = "memory_actual"
+	usageDiskReadBytes  = "disk_read_bytes"
+	usageDiskWriteBytes = "disk_write_bytes"
+	usageDiskReadOps    = "disk_read_ops"
+	usageDiskWriteOps   = "disk_write_ops"
+	usageNetRxBytes     = "net_rx_bytes"
+	usageNetTxBytes     = "net_tx_bytes"
+
+	usageRetention = 24 * time.Hour
+)
+
+var (
+	_ pkg.VMUsage = (*Reporter)(nil)
+)
+
 // Reporter structure
 type Reporter struct {
 	cl  zbus.Client
 	rrd rrd.RRD
+	// usage holds the vms resources usage. it's kept separate
+	// from the rrd since all the values in rrd are used
+	// for the NU consumption reports
+	usage rrd.RRD
 
 	identity         substrate.Identity
 	queue            *dque.DQue
@@ -56,7 +80,7 @@ func ReportChecks(metricsPath string) error {
 }
 
 // NewReporter creates a new capacity reporter
-func NewReporter(metricsPath string, cl zbus.Client, root string) (*Reporter, error) {
+func NewReporter(metricsPath, usagePath string, cl zbus.Client, root string) (*Reporter, error) {
 	idMgr := stubs.NewIdentityManagerStub(cl)
 	sk := ed25519.PrivateKey(idMgr.PrivateKey(context.TODO()))
 	id, err := substrate.NewIdentityFromEd25519Key(sk)
@@ -81,14 +105,21 @@ func NewReporter(metricsPath string, cl zbus.Client, root string) (*Reporter, er
 
 	substrateGateway := stubs.NewSubstrateGatewayStub(cl)
 
-	rrd, err := rrd.NewRRDBolt(metricsPath, 5*time.Minute, 24*time.Hour)
+	metrics, err := rrd.NewRRDBolt(metricsPath, 5*time.Minute, 24*time.Hour)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create metrics database")
 	}
 
+	usage, err := rrd.NewRRDBolt(usagePath, 5*time.Minute, usageRetention)
+	if err != nil {
+		_ = metrics.Close()
+		return nil, errors.Wrap(err, "failed to create usage database")
+	}
+
 	return &Reporter{
 		cl:               cl,
-		rrd:              rrd,
+		rrd:              metrics,
+		usage:            usage,
 		identity:         id,
 		queue:            queue,
 		substrateGateway: substrateGateway,
@@ -163,9 +194,111 @@ func (r *Reporter) getVmMetrics(ctx context.Context, slot rrd.Slot) error {
 		}
 	}
 
+	if err := r.setVmUsage(metrics); err != nil {
+		log.Error().Err(err).Msg("failed to store vms usage")
+	}
+
+	return nil
+}
+
+func usageKey(id, metric string) string {
+	return fmt.Sprintf("%s/%s", id, metric)
+}
+
+// setVmUsage stores the vms usage in the usage rrd
+func (r *Reporter) setVmUsage(metrics pkg.MachineMetrics) error {
+	slot, err := r.usage.Slot()
+	if err != nil {
+		return err
+	}
+
+	for vm, metric := range metrics {
+		usage := metric.Usage
+		counters := map[string]float64{
+			usageNetRxBytes: float64(metric.Private.NetRxBytes + metric.Public.NetRxBytes),
+			usageNetTxBytes: float64(metric.Private.NetTxBytes + metric.Public.NetTxBytes),
+		}
+
+		// the usage is empty if it could not be collected, storing zeros
+		// would reset the counters
+		collected := usage != (pkg.MachineUsage{})
+		if collected {
+			counters[usageCPUTime] = usage.CPUTime
+			counters[usageDiskReadBytes] = float64(usage.DiskReadBytes)
+			counters[usageDiskWriteBytes] = float64(usage.DiskWriteBytes)
+			counters[usageDiskReadOps] = float64(usage.DiskReadOps)
+			counters[usageDiskWriteOps] = float64(usage.DiskWriteOps)
+		}
+
+		for key, value := range counters {
+			if err := slot.Counter(usageKey(vm, key), value); err != nil {
+				return errors.Wrapf(err, "failed to store usage for '%s'", vm)
+			}
+		}
+
+		if !collected {
+			continue
+		}
+
+		// memory is a gauge, only the current value is kept
+		gauges := map[string]float64{
+			usageMemoryRSS:    float64(usage.MemoryRSS),
+			usageMemoryActual: float64(usage.MemoryActual),
+		}
+
+		for key, value := range gauges {
+			if err := slot.Gauge(usageKey(vm, key), value); err != nil {
+				return errors.Wrapf(err, "failed to store usage for '%s'", vm)
+			}
+		}
+	}
+
 	return nil
 }
 
+// MachineUsage implements pkg.VMUsage interface
+func (r *Reporter) MachineUsage(id string, window time.Duration) (pkg.MachineUsageReport, error) {
+	if window <= 0 || window > usageRetention {
+		window = usageRetention
+	}
+
+	if _, ok, err := r.usage.Last(usageKey(id, usageCPUTime)); err != nil {
+		return pkg.MachineUsageReport{}, err
+	} else if !ok {
+		return pkg.MachineUsageReport{}, fmt.Errorf("no usage found for machine '%s'", id)
+	}
+
+	counters, err := r.usage.Counters(time.Now().Add(-window))
+	if err != nil {
+		return pkg.MachineUsageReport{}, errors.Wrap(err, "failed to get machine usage")
+	}
+
+	counter := func(metric string) uint64 {
+		return uint64(counters[usageKey(id, metric)])
+	}
+
+	gauge := func(metric string) uint64 {
+		value, _, err := r.usage.Last(usageKey(id, metric))
+		if err != nil {
+			log.Error().Err(err).Str("metric", metric).Msg("failed to get last metric value")
+		}
+		return uint64(value)
+	}
+
+	return pkg.MachineUsageReport{
+		Window:         uint64(window / time.Second),
+		CPUTime:        counters[usageKey(id, usageCPUTime)],
+		MemoryRSS:      gauge(usageMemoryRSS),
+		MemoryActual:   gauge(usageMemoryActual),
+		DiskReadBytes:  counter(usageDiskReadBytes),
+		DiskWriteBytes: counter(usageDiskWriteBytes),
+		DiskReadOps:    counter(usageDiskReadOps),
+		DiskWriteOps:   counter(usageDiskWriteOps),
+		NetRxBytes:     counter(usageNetRxBytes),
+		NetTxBytes:     counter(usageNetTxBytes),
+	}, nil
+}
+
 // getNetworkMetrics will collect network consumption for network resource and store it in the given slot
 func (r *Reporter) getNetworkMetrics(ctx context.Context, slot rrd.Slot) error {
 	log.Debug().Msg("collecting networking metrics")
@@ -288,6 +421,7 @@ func (r *Reporter) getLastReportTime() (int64, bool, error) {
 
 func (r *Reporter) Close() {
 	_ = r.rrd.Close()
+	_ = r.usage.Close()
 	_ = r.queue.Close()
 }
 
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
+	"time"
 
 	ui "github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
@@ -105,12 +107,77 @@ func addressRender(ctx context.Context, table *widgets.Table, client zbus.Client
 	return nil
 }
 
+// myceliumRender shows the active mycelium peers sorted by latency
+func myceliumRender(ctx context.Context, table *widgets.Table, client zbus.Client, render *signalFlag) error {
+	table.Title = "Mycelium Peers"
+	table.FillRow = true
+	table.RowSeparator = false
+
+	table.Rows = [][]string{
+		{loading, ""},
+	}
+
+	stub := stubs.NewNetworkerStub(client)
+
+	update := func() {
+		peers, err := stub.MyceliumPeers(ctx)
+		if err != nil {
+			table.Rows = [][]string{{"error", err.Error()}}
+			return
+		}
+
+		var active []pkg.MyceliumPeer
+		for _, peer := range peers {
+			if peer.Active {
+				active = append(active, peer)
+			}
+		}
+
+		sort.Slice(active, func(i, j int) bool {
+			return active[i].Latency < active[j].Latency
+		})
+
+		rows := [][]string{}
+		for _, peer := range active {
+			latency := red("down")
+			if peer.Connected {
+				latency = green(peer.Latency.Round(time.Millisecond).String())
+			}
+			rows = append(rows, []string{peer.Endpoint, latency})
+		}
+
+		if len(rows) == 0 {
+			rows = append(rows, []string{"no peers", ""})
+		}
+
+		table.Rows = rows
+	}
+
+	go func() {
+		for {
+			update()
+			table.ColumnWidths = []int{table.Size().X - 12, 10}
+			render.Signal()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+		}
+	}()
+
+	return nil
+}
+
 func netRender(client zbus.Client, grid *ui.Grid, render *signalFlag) error {
 	addresses := widgets.NewTable()
+	peers := widgets.NewTable()
 
 	grid.Set(
 		ui.NewRow(1,
-			ui.NewCol(1, addresses),
+			ui.NewCol(.6, addresses),
+			ui.NewCol(.4, peers),
 		),
 	)
 	ctx := context.Background()
@@ -119,5 +186,9 @@ func netRender(client zbus.Client, grid *ui.Grid, render *signalFlag) error {
 		return err
 	}
 
+	if err := myceliumRender(ctx, peers, client, render); err != nil {
+		return err
+	}
+
 	return nil
 }
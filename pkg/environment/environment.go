@@ -1,14 +1,18 @@
 package environment
 
 import (
+	"fmt"
+	"math"
 	"os"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
 
 	"github.com/threefoldtech/zos/pkg/kernel"
 )
@@ -63,6 +67,78 @@ type Environment struct {
 
 	// PubMac value from environment
 	PubMac PubMac
+
+	// VMQoS default io limits for vms by capacity tier
+	// as configured by the farmer
+	VMQoS []VMQoSTier
+}
+
+// VMQoSTier default io limits applied on vms that have
+// at least MinCPU cores.
+//
+// Tiers are set with the `vm:qos` kernel param in the format
+// `vm:qos=<min-cpu>:<disk-iops>:<disk-mbps>:<net-mbps>`. The param
+// can be provided multiple times (one per tier). Disk bandwidth is in
+// megabytes per second and network bandwidth in megabits per second.
+// A zero value means no limit.
+type VMQoSTier struct {
+	MinCPU uint8
+	QoS    pkg.VMQoS
+}
+
+// VMQoSDefaults returns the default io limits for a vm with given
+// number of cpus. The tier with the highest MinCPU that is less or equal
+// to cpu is used. If no tier is matching, a zero QoS (no limits) is returned
+func (e *Environment) VMQoSDefaults(cpu uint8) pkg.VMQoS {
+	var (
+		found bool
+		tier  VMQoSTier
+	)
+
+	for _, t := range e.VMQoS {
+		if t.MinCPU > cpu {
+			continue
+		}
+
+		if !found || t.MinCPU > tier.MinCPU {
+			tier = t
+			found = true
+		}
+	}
+
+	return tier.QoS
+}
+
+// megabit is the number of bits in a network megabit
+const megabit = 1_000_000
+
+func parseVMQoSTier(value string) (VMQoSTier, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return VMQoSTier{}, fmt.Errorf("invalid vm qos tier '%s' expecting <min-cpu>:<disk-iops>:<disk-mbps>:<net-mbps>", value)
+	}
+
+	var values [4]uint64
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return VMQoSTier{}, errors.Wrapf(err, "invalid vm qos tier '%s'", value)
+		}
+		values[i] = v
+	}
+
+	if values[0] > math.MaxUint8 {
+		return VMQoSTier{}, fmt.Errorf("invalid vm qos tier '%s': invalid min cpu", value)
+	}
+
+	return VMQoSTier{
+		MinCPU: uint8(values[0]),
+		QoS: pkg.VMQoS{
+			DiskIOPS:      values[1],
+			DiskBandwidth: gridtypes.Unit(values[2]) * gridtypes.Megabyte,
+			NetBandwidth:  gridtypes.Unit(values[3] * megabit / 8),
+		},
+	}, nil
 }
 
 // RunMode type
@@ -340,6 +416,16 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 		env.PubMac = PubMacRandom
 	}
 
+	if tiers, found := params.Get("vm:qos"); found {
+		for _, value := range tiers {
+			tier, err := parseVMQoSTier(value)
+			if err != nil {
+				return env, err
+			}
+			env.VMQoS = append(env.VMQoS, tier)
+		}
+	}
+
 	// Checking if there environment variable
 	// override default settings
 
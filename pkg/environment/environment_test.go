@@ -7,6 +7,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
 	"github.com/threefoldtech/zos/pkg/kernel"
 )
 
@@ -59,3 +61,26 @@ func TestEnvironmentOverride(t *testing.T) {
 
 	assert.Equal(t, []string{"localhost:1234"}, value.SubstrateURL)
 }
+
+func TestVMQoSTiers(t *testing.T) {
+	params := kernel.Params{"vm:qos": {"1:1000:100:100", "4:4000:400:1000"}}
+	value, err := getEnvironmentFromParams(params)
+	require.NoError(t, err)
+
+	require.Len(t, value.VMQoS, 2)
+	require.Equal(t, pkg.VMQoS{}, value.VMQoSDefaults(0))
+	require.Equal(t, pkg.VMQoS{
+		DiskIOPS:      1000,
+		DiskBandwidth: 100 * gridtypes.Megabyte,
+		NetBandwidth:  12_500_000,
+	}, value.VMQoSDefaults(2))
+	require.Equal(t, pkg.VMQoS{
+		DiskIOPS:      4000,
+		DiskBandwidth: 400 * gridtypes.Megabyte,
+		NetBandwidth:  125_000_000,
+	}, value.VMQoSDefaults(8))
+
+	params = kernel.Params{"vm:qos": {"1:1000:100"}}
+	_, err = getEnvironmentFromParams(params)
+	require.Error(t, err)
+}
@@ -28,4 +28,7 @@ type Gateway interface {
 	SetFQDNProxy(wlID string, config zos.GatewayFQDNProxy) error
 	DeleteNamedProxy(wlID string) error
 	Metrics() (GatewayMetrics, error)
+	// UpdateDomains applies changes of the node gateway domains to the
+	// gateway right away instead of waiting for the next validation round
+	UpdateDomains() error
 }
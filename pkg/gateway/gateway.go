@@ -39,6 +39,7 @@ const (
 	validationPeriod = 1 * time.Hour
 
 	configDir = "proxy"
+	typesDir  = "types"
 	metaDir   = "traefik"
 	zinitDir  = "zinit"
 )
@@ -47,17 +48,19 @@ var (
 	ErrTwinIDMismatch       = fmt.Errorf("twin id mismatch")
 	ErrContractNotReserved  = fmt.Errorf("a name contract with the given name must be reserved first")
 	ErrInvalidContractState = fmt.Errorf("the name contract must be in Created state")
+	ErrDomainNotServed      = fmt.Errorf("the domain is not served by this node")
 
 	_ pkg.Gateway = (*gatewayModule)(nil)
 )
 
 type gatewayModule struct {
+	root             string
 	volatile         string
 	cl               zbus.Client
 	resolver         *net.Resolver
 	substrateGateway *stubs.SubstrateGatewayStub
-	// maps domain to workload id
-	reservedDomains map[string]string
+	// maps domain to the workload that reserved it
+	reservedDomains map[string]reservedDomain
 	domainLock      sync.RWMutex
 
 	staticConfigPath string
@@ -65,6 +68,12 @@ type gatewayModule struct {
 	certScriptPath   string
 }
 
+// reservedDomain is the workload that reserved a domain
+type reservedDomain struct {
+	ID   string
+	Type gridtypes.WorkloadType
+}
+
 type ProxyConfig struct {
 	Http *HTTPConfig `yaml:"http,omitempty"`
 	TCP  *HTTPConfig `yaml:"tcp,omitempty"`
@@ -146,8 +155,12 @@ func domainFromConfig(path string) (string, string, error) {
 	return "", "", fmt.Errorf("no routes defined in: %s", path)
 }
 
-func loadDomains(ctx context.Context, dir string) (map[string]string, error) {
-	domains := make(map[string]string)
+// loadDomains loads the reserved domains from the proxy config files in the
+// volatile dir. The workload type is loaded from the types dir, configs that
+// have no type recorded get an empty type.
+func loadDomains(ctx context.Context, volatile string) (map[string]reservedDomain, error) {
+	domains := make(map[string]reservedDomain)
+	dir := filepath.Join(volatile, configDir)
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read dir")
@@ -161,7 +174,11 @@ func loadDomains(ctx context.Context, dir string) (map[string]string, error) {
 				log.Warn().Err(err).Str("path", path).Msg("failed to load domain from config file")
 				continue
 			}
-			domains[domain] = wlID
+			typ, err := os.ReadFile(filepath.Join(volatile, typesDir, wlID))
+			if err != nil && !os.IsNotExist(err) {
+				log.Warn().Err(err).Str("id", wlID).Msg("failed to load gateway workload type")
+			}
+			domains[domain] = reservedDomain{ID: wlID, Type: gridtypes.WorkloadType(typ)}
 		}
 	}
 	return domains, nil
@@ -232,7 +249,7 @@ func New(ctx context.Context, cl zbus.Client, root string) (pkg.Gateway, error)
 	}
 
 	// create volatile directories
-	for _, dir := range []string{configDir, zinitDir} {
+	for _, dir := range []string{configDir, typesDir, zinitDir} {
 		dir = filepath.Join(volatile, dir)
 		if err := os.MkdirAll(dir, 0644); err != nil {
 			return nil, errors.Wrapf(err, "failed to create directory '%s'", dir)
@@ -259,11 +276,9 @@ func New(ctx context.Context, cl zbus.Client, root string) (pkg.Gateway, error)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create cert script")
 	}
+	// the static config is written by ensureGateway since it depends on the
+	// domains of the public config
 	staticCfgPath := filepath.Join(root, "traefik.yaml")
-	updated, err := staticConfig(staticCfgPath, root, volatile, letsEncryptEmail)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create static config")
-	}
 
 	// we create the resolver to avoid the cache
 	resolver := &net.Resolver{
@@ -276,7 +291,7 @@ func New(ctx context.Context, cl zbus.Client, root string) (pkg.Gateway, error)
 		},
 	}
 
-	domains, err := loadDomains(ctx, filepath.Join(volatile, configDir))
+	domains, err := loadDomains(ctx, volatile)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load old domains")
 	}
@@ -286,6 +301,7 @@ func New(ctx context.Context, cl zbus.Client, root string) (pkg.Gateway, error)
 		cl:               cl,
 		resolver:         resolver,
 		substrateGateway: substrateGateway,
+		root:             root,
 		volatile:         volatile,
 		staticConfigPath: staticCfgPath,
 		certScriptPath:   certScriptPath,
@@ -295,7 +311,7 @@ func New(ctx context.Context, cl zbus.Client, root string) (pkg.Gateway, error)
 	}
 
 	// in case there are already active configurations we should always try to ensure running traefik
-	if _, err := gw.ensureGateway(ctx, updated); err != nil {
+	if _, err := gw.ensureGateway(ctx, false); err != nil {
 		log.Error().Err(err).Msg("gateway is not supported")
 		// this is not a failure because supporting of the gateway can happen
 		// later if the farmer set the correct network configuration!
@@ -304,17 +320,18 @@ func New(ctx context.Context, cl zbus.Client, root string) (pkg.Gateway, error)
 	return gw, nil
 }
 
-func (g *gatewayModule) getReservedDomain(domain string) (string, bool) {
+func (g *gatewayModule) getReservedDomain(domain string) (reservedDomain, bool) {
 	v, ok := g.reservedDomains[domain]
 	return v, ok
 }
 
-func (g *gatewayModule) setReservedDomain(domain string, wlID string) {
+func (g *gatewayModule) setReservedDomain(domain string, wlID string, typ gridtypes.WorkloadType) {
 	log.Debug().
 		Str("domain", domain).
 		Str("wlID", wlID).
+		Str("type", typ.String()).
 		Msg("setting domain")
-	g.reservedDomains[domain] = wlID
+	g.reservedDomains[domain] = reservedDomain{ID: wlID, Type: typ}
 }
 
 func (g *gatewayModule) deleteReservedDomain(domain string) {
@@ -324,29 +341,51 @@ func (g *gatewayModule) deleteReservedDomain(domain string) {
 	delete(g.reservedDomains, domain)
 }
 
-func (g *gatewayModule) copyReservedDomain() map[string]string {
+func (g *gatewayModule) copyReservedDomain() map[string]reservedDomain {
 	g.domainLock.Lock()
 	defer g.domainLock.Unlock()
 
-	res := make(map[string]string, len(g.reservedDomains))
+	res := make(map[string]reservedDomain, len(g.reservedDomains))
 	for k, v := range g.reservedDomains {
 		res[k] = v
 	}
 	return res
 }
 
+// proxyName returns the name and the base domain of the domain of a name
+// proxy, ok is false if the domain is not a name proxy. Proxies deployed
+// before the workload types were recorded have no type, those are name
+// proxies if the domain is a name under one of the served domains since
+// fqdn proxies can't use a subdomain of a served domain.
+func proxyName(domain string, typ gridtypes.WorkloadType, served []pkg.GatewayDomain) (name, base string, ok bool) {
+	switch typ {
+	case zos.GatewayNameProxyType:
+		// names can't have dots so the name proxy domain is the rest of
+		// the fqdn
+		return strings.Cut(domain, ".")
+	case "":
+		for _, gw := range served {
+			name, ok := strings.CutSuffix(domain, "."+gw.Domain)
+			if ok && len(name) != 0 && !strings.Contains(name, ".") {
+				return name, gw.Domain, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
 func (g *gatewayModule) validateNameContracts() error {
 	ctx, cancel := context.WithTimeout(context.Background(), validationPeriod/2)
 	defer cancel()
 	e := stubs.NewProvisionStub(g.cl)
-	networker := stubs.NewNetworkerStub(g.cl)
-	cfg, err := networker.GetPublicConfig(ctx)
+	// this also applies changes of the gateway domains to traefik
+	cfg, err := g.ensureGateway(ctx, false)
 	if err != nil {
 		return nil
 	}
 
-	baseDomain := cfg.Domain
-	if baseDomain == "" {
+	if len(cfg.ServedDomains()) == 0 {
 		// domain doesn't exist so no name workloads exist
 		// or the domain was unset and name workloads will never be deleted
 		// should iterate over workloads instead?
@@ -354,7 +393,14 @@ func (g *gatewayModule) validateNameContracts() error {
 	}
 	reservedDomains := g.copyReservedDomain()
 
-	for domain, id := range reservedDomains {
+	for domain, reserved := range reservedDomains {
+		// only name proxies are backed by name contracts
+		name, baseDomain, ok := proxyName(domain, reserved.Type, cfg.ServedDomains())
+		if !ok {
+			continue
+		}
+
+		id := reserved.ID
 		wlID := gridtypes.WorkloadID(id)
 		twinID, _, _, err := wlID.Parts()
 		if err != nil {
@@ -363,12 +409,12 @@ func (g *gatewayModule) validateNameContracts() error {
 				Msgf("failed to parse wlID %s parts", id)
 			continue
 		}
-		if !strings.HasSuffix(domain, baseDomain) {
-			// a fqdn workload, skip validating it
+		err = g.validateNameContract(&cfg, baseDomain, name, twinID)
+		if errors.Is(err, ErrDomainNotServed) {
+			// the domain was removed from the node in which case the
+			// workload is not deleted
 			continue
 		}
-		name := strings.TrimSuffix(domain, fmt.Sprintf(".%s", baseDomain))
-		err = g.validateNameContract(name, twinID)
 		if errors.Is(err, ErrContractNotReserved) || errors.Is(err, ErrInvalidContractState) || errors.Is(err, ErrTwinIDMismatch) {
 			log.Debug().
 				Str("reason", err.Error()).
@@ -391,6 +437,15 @@ func (g *gatewayModule) validateNameContracts() error {
 	return nil
 }
 
+// UpdateDomains implements pkg.Gateway interface
+func (g *gatewayModule) UpdateDomains() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	_, err := g.ensureGateway(ctx, false)
+	return err
+}
+
 func (g *gatewayModule) nameContractsValidator() {
 	// no context?
 	ticker := time.NewTicker(validationPeriod)
@@ -438,6 +493,13 @@ func (g *gatewayModule) ensureGateway(ctx context.Context, forceResstart bool) (
 		return pkg.PublicConfig{}, errors.Wrap(err, "gateway is not supported on this node")
 	}
 
+	updated, err := staticConfig(g.staticConfigPath, g.root, g.volatile, letsEncryptEmail, cfg.ServedDomains())
+	if err != nil {
+		return pkg.PublicConfig{}, errors.Wrap(err, "failed to update static config")
+	}
+	// traefik only loads the static config (and the certificate resolvers) on start
+	forceResstart = forceResstart || updated
+
 	z := zinit.Default()
 	running, err := g.isTraefikStarted(z)
 	if err != nil {
@@ -530,7 +592,17 @@ func (g *gatewayModule) configPath(name string) string {
 	return filepath.Join(g.volatile, configDir, fmt.Sprintf("%s.yaml", name))
 }
 
-func (g *gatewayModule) validateNameContract(name string, twinID uint32) error {
+func (g *gatewayModule) typePath(name string) string {
+	return filepath.Join(g.volatile, typesDir, name)
+}
+
+// validateNameContract checks that the domain is served by the node and that
+// the name is owned by the twin with a name contract. Name contracts are
+// global, a name contract reserves the name on all the domains of the node.
+func (g *gatewayModule) validateNameContract(cfg *pkg.PublicConfig, domain, name string, twinID uint32) error {
+	if _, err := cfg.GatewayDomain(domain); err != nil {
+		return errors.Wrapf(ErrDomainNotServed, "domain '%s'", domain)
+	}
 
 	contractID, subErr := g.substrateGateway.GetContractIDByNameRegistration(context.Background(), name)
 	if subErr.IsCode(pkg.CodeNotFound) {
@@ -570,26 +642,32 @@ func (g *gatewayModule) SetNamedProxy(wlID string, config zos.GatewayNameProxy)
 	if err != nil {
 		return "", err
 	}
-	if cfg.Domain == "" {
-		return "", errors.New("node doesn't support name proxy (doesn't have a domain)")
+	domain, err := cfg.GatewayDomain(config.Domain)
+	if err != nil {
+		return "", err
 	}
 
-	if err := g.validateNameContract(config.Name, twinID); err != nil {
+	if err := g.validateNameContract(&cfg, domain.Domain, config.Name, twinID); err != nil {
 		return "", errors.Wrap(err, "failed to verify name contract")
 	}
 
-	fqdn := fmt.Sprintf("%s.%s", config.Name, cfg.Domain)
+	fqdn := fmt.Sprintf("%s.%s", config.Name, domain.Domain)
 
 	gatewayTLSConfig := TlsConfig{
-		CertResolver: dnsCertResolver,
+		CertResolver: resolverName(domain),
 		Domains: []Domain{
 			{
-				Sans: []string{fmt.Sprintf("*.%s", cfg.Domain)},
+				Sans: []string{fmt.Sprintf("*.%s", domain.Domain)},
 			},
 		},
 	}
 
-	if err := g.setupRouting(ctx, wlID, fqdn, gatewayTLSConfig, config.GatewayBase); err != nil {
+	if domain.ACME.Challenge == pkg.ACMEChallengeHTTP {
+		// wildcard certificates can only be issued with the dns challenge
+		gatewayTLSConfig.Domains = []Domain{{Main: fqdn}}
+	}
+
+	if err := g.setupRouting(ctx, wlID, zos.GatewayNameProxyType, fqdn, gatewayTLSConfig, config.GatewayBase); err != nil {
 		return "", err
 	}
 
@@ -609,8 +687,10 @@ func (g *gatewayModule) SetFQDNProxy(wlID string, config zos.GatewayFQDNProxy) e
 		return err
 	}
 
-	if cfg.Domain != "" && strings.HasSuffix(config.FQDN, cfg.Domain) {
-		return errors.New("can't create a fqdn workload with a subdomain of the gateway's managed domain")
+	for _, domain := range cfg.ServedDomains() {
+		if strings.HasSuffix(config.FQDN, domain.Domain) {
+			return errors.New("can't create a fqdn workload with a subdomain of the gateway's managed domain")
+		}
 	}
 	if err := g.verifyDomainDestination(ctx, cfg, config.FQDN); err != nil {
 		return errors.Wrap(err, "failed to verify domain dns record")
@@ -624,10 +704,10 @@ func (g *gatewayModule) SetFQDNProxy(wlID string, config zos.GatewayFQDNProxy) e
 		},
 	}
 
-	return g.setupRouting(ctx, wlID, config.FQDN, gatewayTLSConfig, config.GatewayBase)
+	return g.setupRouting(ctx, wlID, zos.GatewayFQDNProxyType, config.FQDN, gatewayTLSConfig, config.GatewayBase)
 }
 
-func (g *gatewayModule) setupRouting(ctx context.Context, wlID string, fqdn string, tlsConfig TlsConfig, config zos.GatewayBase) error {
+func (g *gatewayModule) setupRouting(ctx context.Context, wlID string, typ gridtypes.WorkloadType, fqdn string, tlsConfig TlsConfig, config zos.GatewayBase) error {
 	g.domainLock.Lock()
 	defer g.domainLock.Unlock()
 
@@ -643,7 +723,7 @@ func (g *gatewayModule) setupRouting(ctx context.Context, wlID string, fqdn stri
 
 	if config.Network == nil {
 		// not going over user private network
-		return g.setupRoutingGeneric(wlID, fqdn, tlsConfig, config)
+		return g.setupRoutingGeneric(wlID, typ, fqdn, tlsConfig, config)
 	}
 
 	// otherwise we need to configure a nnc process
@@ -673,10 +753,10 @@ func (g *gatewayModule) setupRouting(ctx context.Context, wlID string, fqdn stri
 	}
 
 	config.Backends = []zos.Backend{backend}
-	return g.setupRoutingGeneric(wlID, fqdn, tlsConfig, config)
+	return g.setupRoutingGeneric(wlID, typ, fqdn, tlsConfig, config)
 }
 
-func (g *gatewayModule) setupRoutingGeneric(wlID string, fqdn string, tlsConfig TlsConfig, config zos.GatewayBase) error {
+func (g *gatewayModule) setupRoutingGeneric(wlID string, typ gridtypes.WorkloadType, fqdn string, tlsConfig TlsConfig, config zos.GatewayBase) error {
 	backend := config.Backends[0]
 	var rule string
 	if config.TLSPassthrough {
@@ -724,10 +804,13 @@ func (g *gatewayModule) setupRoutingGeneric(wlID string, fqdn string, tlsConfig
 		return errors.Wrap(err, "failed to convert config to yaml")
 	}
 	log.Debug().Str("yaml-config", string(yamlString)).Msg("configuration file")
+	if err = os.WriteFile(g.typePath(wlID), []byte(typ), 0644); err != nil {
+		return errors.Wrap(err, "couldn't write workload type file")
+	}
 	if err = os.WriteFile(g.configPath(wlID), yamlString, 0644); err != nil {
 		return errors.Wrap(err, "couldn't open config file for writing")
 	}
-	g.setReservedDomain(fqdn, wlID)
+	g.setReservedDomain(fqdn, wlID, typ)
 	return nil
 }
 
@@ -744,6 +827,9 @@ func (g *gatewayModule) DeleteNamedProxy(wlID string) error {
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return errors.Wrap(err, "couldn't remove config file")
 	}
+	if err := os.Remove(g.typePath(wlID)); err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Str("id", wlID).Msg("failed to remove workload type file")
+	}
 
 	if domain != "" {
 		g.deleteReservedDomain(domain)
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+func TestLoadDomains(t *testing.T) {
+	require := require.New(t)
+	volatile := t.TempDir()
+	for _, dir := range []string{configDir, typesDir} {
+		require.NoError(os.MkdirAll(filepath.Join(volatile, dir), 0755))
+	}
+
+	g := gatewayModule{
+		volatile:        volatile,
+		reservedDomains: make(map[string]reservedDomain),
+	}
+
+	backend := []zos.Backend{"http://10.1.2.3:80"}
+	require.NoError(g.setupRoutingGeneric("1-1-name", zos.GatewayNameProxyType, "name.example.com", TlsConfig{}, zos.GatewayBase{Backends: backend}))
+	require.NoError(g.setupRoutingGeneric("1-1-fqdn", zos.GatewayFQDNProxyType, "name.example.org", TlsConfig{}, zos.GatewayBase{Backends: backend}))
+
+	// a config created before the workload types were recorded
+	require.NoError(g.setupRoutingGeneric("1-1-old", zos.GatewayNameProxyType, "old.example.com", TlsConfig{}, zos.GatewayBase{Backends: backend}))
+	require.NoError(os.Remove(g.typePath("1-1-old")))
+
+	domains, err := loadDomains(context.Background(), volatile)
+	require.NoError(err)
+	require.Equal(map[string]reservedDomain{
+		"name.example.com": {ID: "1-1-name", Type: zos.GatewayNameProxyType},
+		"name.example.org": {ID: "1-1-fqdn", Type: zos.GatewayFQDNProxyType},
+		"old.example.com":  {ID: "1-1-old"},
+	}, domains)
+	require.Equal(zos.GatewayNameProxyType, g.reservedDomains["old.example.com"].Type)
+}
+
+func TestProxyName(t *testing.T) {
+	require := require.New(t)
+	served := []pkg.GatewayDomain{{Domain: "example.com"}, {Domain: "gw.example.org"}}
+
+	name, base, ok := proxyName("name.example.com", zos.GatewayNameProxyType, served)
+	require.True(ok)
+	require.Equal("name", name)
+	require.Equal("example.com", base)
+
+	_, _, ok = proxyName("name.example.com", zos.GatewayFQDNProxyType, served)
+	require.False(ok)
+
+	// proxies without a recorded type
+	name, base, ok = proxyName("name.gw.example.org", "", served)
+	require.True(ok)
+	require.Equal("name", name)
+	require.Equal("gw.example.org", base)
+
+	for _, domain := range []string{"name.example.net", "a.name.example.com", "example.com", "nameexample.com"} {
+		_, _, ok = proxyName(domain, "", served)
+		require.False(ok, domain)
+	}
+}
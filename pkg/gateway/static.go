@@ -5,8 +5,11 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg"
 )
 
 //go:embed static/config.yaml
@@ -18,9 +21,54 @@ var dConfig string
 //go:embed static/cert.sh
 var certScript string
 
-// staticConfig write static config to file
-func staticConfig(p, root, volatile, email string) (bool, error) {
+// resolverName returns the name of the certificate resolver of the gateway
+// domain. Domains with the default acme settings share the dns resolver
+func resolverName(domain pkg.GatewayDomain) string {
+	if domain.ACME.IsDefault() {
+		return dnsCertResolver
+	}
+
+	return fmt.Sprintf("domain-%s", strings.ReplaceAll(domain.Domain, ".", "-"))
+}
+
+// resolverConfig renders the certificate resolver of a gateway domain with
+// custom acme settings
+func resolverConfig(root, email string, domain pkg.GatewayDomain) string {
+	if len(domain.ACME.Email) != 0 {
+		email = domain.ACME.Email
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "  %s:\n", resolverName(domain))
+	fmt.Fprintf(&buf, "    acme:\n")
+	fmt.Fprintf(&buf, "      email: %q\n", email)
+	fmt.Fprintf(&buf, "      storage: %q\n", filepath.Join(root, metaDir, fmt.Sprintf("acme-%s.json", domain.Domain)))
+	if len(domain.ACME.Server) != 0 {
+		fmt.Fprintf(&buf, "      caServer: %q\n", domain.ACME.Server)
+	}
+
+	if domain.ACME.Challenge == pkg.ACMEChallengeHTTP {
+		fmt.Fprintf(&buf, "      httpChallenge:\n")
+		fmt.Fprintf(&buf, "        entryPoint: web\n")
+	} else {
+		fmt.Fprintf(&buf, "      dnsChallenge:\n")
+		fmt.Fprintf(&buf, "        provider: exec\n")
+	}
+
+	return buf.String()
+}
+
+// staticConfig write static config to file, a certificate resolver is added
+// for each of the domains with custom acme settings
+func staticConfig(p, root, volatile, email string, domains []pkg.GatewayDomain) (bool, error) {
 	config := fmt.Sprintf(config, root, email, volatile)
+	for _, domain := range domains {
+		if domain.ACME.IsDefault() {
+			continue
+		}
+
+		config += resolverConfig(root, email, domain)
+	}
 
 	var update bool
 	if oldConfig, err := os.ReadFile(p); os.IsNotExist(err) {
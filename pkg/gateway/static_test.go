@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"gopkg.in/yaml.v2"
+)
+
+func TestResolverName(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(dnsCertResolver, resolverName(pkg.GatewayDomain{Domain: "example.com"}))
+	require.Equal(dnsCertResolver, resolverName(pkg.GatewayDomain{
+		Domain: "example.com",
+		ACME:   pkg.GatewayACME{Challenge: pkg.ACMEChallengeDNS},
+	}))
+	require.Equal("domain-example-com", resolverName(pkg.GatewayDomain{
+		Domain: "example.com",
+		ACME:   pkg.GatewayACME{Email: "admin@example.com"},
+	}))
+}
+
+func TestStaticConfigDomains(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traefik.yaml")
+
+	domains := []pkg.GatewayDomain{
+		{Domain: "node.example.com"},
+		{
+			Domain: "apps.example.com",
+			ACME: pkg.GatewayACME{
+				Email:     "admin@example.com",
+				Server:    "https://acme.example.com/directory",
+				Challenge: pkg.ACMEChallengeHTTP,
+			},
+		},
+	}
+
+	updated, err := staticConfig(path, dir, dir, letsEncryptEmail, domains)
+	require.NoError(err)
+	require.True(updated)
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+
+	var cfg struct {
+		Resolvers map[string]struct {
+			ACME map[string]interface{} `yaml:"acme"`
+		} `yaml:"certificatesResolvers"`
+	}
+	require.NoError(yaml.Unmarshal(data, &cfg))
+	require.Len(cfg.Resolvers, 3)
+
+	resolver, ok := cfg.Resolvers["domain-apps-example-com"]
+	require.True(ok)
+	require.Equal("admin@example.com", resolver.ACME["email"])
+	require.Equal("https://acme.example.com/directory", resolver.ACME["caServer"])
+	require.Contains(resolver.ACME, "httpChallenge")
+
+	updated, err = staticConfig(path, dir, dir, letsEncryptEmail, domains)
+	require.NoError(err)
+	require.False(updated)
+}
@@ -0,0 +1,234 @@
+package zos
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+const (
+	// MaxFirewallRules is the max number of rules a single firewall can have
+	MaxFirewallRules = 128
+)
+
+// FirewallDirection is the direction of the traffic a rule applies to
+type FirewallDirection string
+
+// FirewallProtocol is the protocol a rule matches
+type FirewallProtocol string
+
+// FirewallAction is the action taken on matching traffic
+type FirewallAction string
+
+const (
+	// FirewallIngress matches traffic going to the workload
+	FirewallIngress FirewallDirection = "ingress"
+	// FirewallEgress matches traffic leaving the workload
+	FirewallEgress FirewallDirection = "egress"
+
+	// FirewallProtocolAny matches all protocols
+	FirewallProtocolAny FirewallProtocol = "any"
+	// FirewallProtocolTCP matches tcp traffic
+	FirewallProtocolTCP FirewallProtocol = "tcp"
+	// FirewallProtocolUDP matches udp traffic
+	FirewallProtocolUDP FirewallProtocol = "udp"
+	// FirewallProtocolICMP matches both icmp and icmpv6 traffic
+	FirewallProtocolICMP FirewallProtocol = "icmp"
+
+	// FirewallAccept accepts the traffic
+	FirewallAccept FirewallAction = "accept"
+	// FirewallDrop drops the traffic
+	FirewallDrop FirewallAction = "drop"
+)
+
+func (d FirewallDirection) Valid() error {
+	switch d {
+	case FirewallIngress, FirewallEgress:
+		return nil
+	}
+
+	return fmt.Errorf("invalid direction '%s'", d)
+}
+
+func (p FirewallProtocol) Valid() error {
+	switch p {
+	case FirewallProtocolAny, FirewallProtocolTCP, FirewallProtocolUDP, FirewallProtocolICMP:
+		return nil
+	}
+
+	return fmt.Errorf("invalid protocol '%s'", p)
+}
+
+// Valid validates the action. An empty action is only valid
+// as a policy and it means accept.
+func (a FirewallAction) Valid() error {
+	switch a {
+	case FirewallAccept, FirewallDrop:
+		return nil
+	}
+
+	return fmt.Errorf("invalid action '%s'", a)
+}
+
+// FirewallRule is a single security group rule.
+type FirewallRule struct {
+	// Direction of the traffic this rule matches
+	Direction FirewallDirection `json:"direction"`
+	// Protocol of the traffic this rule matches
+	Protocol FirewallProtocol `json:"protocol"`
+	// FromPort and ToPort define the (inclusive) destination port range
+	// of the traffic. Only valid with tcp and udp protocols. If both are
+	// zero all ports are matched. If ToPort is zero only FromPort is matched.
+	FromPort uint16 `json:"from_port"`
+	ToPort   uint16 `json:"to_port"`
+	// CIDR is the remote network this rule matches. For ingress this is
+	// the source of the traffic, for egress it's the destination.
+	// If not set, any remote (ipv4 or ipv6) is matched.
+	CIDR gridtypes.IPNet `json:"cidr"`
+	// Action taken on matching traffic
+	Action FirewallAction `json:"action"`
+}
+
+// Valid validates the firewall rule
+func (r *FirewallRule) Valid() error {
+	if err := r.Direction.Valid(); err != nil {
+		return err
+	}
+
+	if err := r.Protocol.Valid(); err != nil {
+		return err
+	}
+
+	if err := r.Action.Valid(); err != nil {
+		return err
+	}
+
+	if r.FromPort != 0 || r.ToPort != 0 {
+		if r.Protocol != FirewallProtocolTCP && r.Protocol != FirewallProtocolUDP {
+			return fmt.Errorf("port range is only supported with tcp and udp protocols")
+		}
+
+		if r.FromPort == 0 {
+			return fmt.Errorf("port range must have a start port")
+		}
+
+		if r.ToPort != 0 && r.ToPort < r.FromPort {
+			return fmt.Errorf("invalid port range %d-%d", r.FromPort, r.ToPort)
+		}
+	}
+
+	if !r.CIDR.Nil() && r.CIDR.Mask == nil {
+		return fmt.Errorf("invalid cidr '%s'", r.CIDR.IP)
+	}
+
+	return nil
+}
+
+// Challenge implementation
+func (r *FirewallRule) Challenge(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s", r.Direction); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s", r.Protocol); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%d", r.FromPort); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%d", r.ToPort); err != nil {
+		return err
+	}
+
+	if !r.CIDR.Nil() {
+		if _, err := fmt.Fprintf(w, "%s", r.CIDR.String()); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s", r.Action); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Firewall is a security group that can be attached to a public ip or a
+// network resource. Rules are evaluated in order and the first matching rule
+// decides the fate of the traffic. Traffic that does not match any rule is
+// handled according to the direction policy. Replies to allowed connections
+// are always accepted.
+type Firewall struct {
+	// IngressPolicy is the action on incoming traffic that does not match any
+	// rule. Defaults to accept
+	IngressPolicy FirewallAction `json:"ingress_policy,omitempty"`
+	// EgressPolicy is the action on outgoing traffic that does not match any
+	// rule. Defaults to accept
+	EgressPolicy FirewallAction `json:"egress_policy,omitempty"`
+	// Rules list
+	Rules []FirewallRule `json:"rules"`
+}
+
+// Policy returns the policy of the given direction
+func (f *Firewall) Policy(direction FirewallDirection) FirewallAction {
+	policy := f.IngressPolicy
+	if direction == FirewallEgress {
+		policy = f.EgressPolicy
+	}
+
+	if len(policy) == 0 {
+		return FirewallAccept
+	}
+
+	return policy
+}
+
+// Valid validates the firewall
+func (f *Firewall) Valid() error {
+	if len(f.IngressPolicy) != 0 {
+		if err := f.IngressPolicy.Valid(); err != nil {
+			return errors.Wrap(err, "invalid ingress policy")
+		}
+	}
+
+	if len(f.EgressPolicy) != 0 {
+		if err := f.EgressPolicy.Valid(); err != nil {
+			return errors.Wrap(err, "invalid egress policy")
+		}
+	}
+
+	if len(f.Rules) > MaxFirewallRules {
+		return fmt.Errorf("too many firewall rules, max is %d", MaxFirewallRules)
+	}
+
+	for i := range f.Rules {
+		if err := f.Rules[i].Valid(); err != nil {
+			return errors.Wrapf(err, "invalid firewall rule %d", i)
+		}
+	}
+
+	return nil
+}
+
+// Challenge implementation
+func (f *Firewall) Challenge(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s", f.IngressPolicy); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s", f.EgressPolicy); err != nil {
+		return err
+	}
+
+	for i := range f.Rules {
+		if err := f.Rules[i].Challenge(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
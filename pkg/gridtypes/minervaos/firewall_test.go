@@ -0,0 +1,78 @@
+package zos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+func TestFirewallValid(t *testing.T) {
+	require := require.New(t)
+
+	valid := FirewallRule{
+		Direction: FirewallIngress,
+		Protocol:  FirewallProtocolTCP,
+		FromPort:  8000,
+		ToPort:    8080,
+		CIDR:      gridtypes.MustParseIPNet("10.0.0.0/8"),
+		Action:    FirewallAccept,
+	}
+	require.NoError(valid.Valid())
+
+	fw := Firewall{
+		IngressPolicy: FirewallDrop,
+		Rules:         []FirewallRule{valid},
+	}
+	require.NoError(fw.Valid())
+	require.Equal(FirewallDrop, fw.Policy(FirewallIngress))
+	require.Equal(FirewallAccept, fw.Policy(FirewallEgress))
+
+	fw.EgressPolicy = "reject"
+	require.Error(fw.Valid())
+
+	type Case struct {
+		Name  string
+		Rule  func(r *FirewallRule)
+		Error string
+	}
+
+	cases := []Case{
+		{
+			Name:  "invalid direction",
+			Rule:  func(r *FirewallRule) { r.Direction = "both" },
+			Error: "invalid direction 'both'",
+		},
+		{
+			Name:  "invalid protocol",
+			Rule:  func(r *FirewallRule) { r.Protocol = "sctp" },
+			Error: "invalid protocol 'sctp'",
+		},
+		{
+			Name:  "missing action",
+			Rule:  func(r *FirewallRule) { r.Action = "" },
+			Error: "invalid action ''",
+		},
+		{
+			Name:  "reversed port range",
+			Rule:  func(r *FirewallRule) { r.ToPort = 10 },
+			Error: "invalid port range 8000-10",
+		},
+		{
+			Name:  "missing start port",
+			Rule:  func(r *FirewallRule) { r.FromPort = 0 },
+			Error: "port range must have a start port",
+		},
+		{
+			Name:  "ports with icmp",
+			Rule:  func(r *FirewallRule) { r.Protocol = FirewallProtocolICMP },
+			Error: "port range is only supported with tcp and udp protocols",
+		},
+	}
+
+	for _, test := range cases {
+		rule := valid
+		test.Rule(&rule)
+		require.EqualError(rule.Valid(), test.Error, test.Name)
+	}
+}
@@ -15,6 +15,9 @@ type GatewayNameProxy struct {
 	GatewayBase
 	// Name the fully qualified domain name to use (cannot be present with Name)
 	Name string `json:"name"`
+	// Domain is the gateway domain the name is served on, it must be one of
+	// the domains of the node. Defaults to the node domain
+	Domain string `json:"domain,omitempty"`
 }
 
 func (g GatewayNameProxy) Valid(getter gridtypes.WorkloadGetter) error {
@@ -22,6 +25,10 @@ func (g GatewayNameProxy) Valid(getter gridtypes.WorkloadGetter) error {
 		return fmt.Errorf("name %s is invalid", g.Name)
 	}
 
+	if len(g.Domain) != 0 && !fqdnRegex.MatchString(g.Domain) {
+		return fmt.Errorf("domain %s is invalid", g.Domain)
+	}
+
 	return g.GatewayBase.Valid(getter)
 }
 
@@ -30,6 +37,14 @@ func (g GatewayNameProxy) Challenge(w io.Writer) error {
 		return err
 	}
 
+	// the domain is only part of the challenge if set so the signature of
+	// older workloads does not change
+	if len(g.Domain) != 0 {
+		if _, err := fmt.Fprintf(w, "%s", g.Domain); err != nil {
+			return err
+		}
+	}
+
 	return g.GatewayBase.Challenge(w)
 }
 
@@ -36,6 +36,24 @@ type PublicIP struct {
 	// V6 get an ipv6 for the VM. this is for free
 	// but the consumed capacity (network traffic) is not
 	V6 bool `json:"v6"`
+	// Firewall is an optional security group applied to the traffic
+	// of this public ip.
+	Firewall *Firewall `json:"firewall,omitempty"`
+	// FlowLogs enables logging of the connections of this public ip.
+	FlowLogs bool `json:"flow_logs,omitempty"`
+	// Target optionally routes the public ipv4 to another zmachine instead
+	// of the one that uses this public ip in its network config (floating
+	// ip). It's the full workload id `<twin>-<contract>-<name>` of the
+	// zmachine which must be owned by the same twin and have a public ip
+	// of its own. Updating the target moves the ip without releasing it.
+	Target gridtypes.WorkloadID `json:"target,omitempty"`
+	// V6Prefix delegates a routed ipv6 prefix (usually a /64) to the VM. The
+	// prefix is routed to the VM public ipv6, hence it requires V6. It's only
+	// available on nodes where the farmer configured prefix delegation.
+	V6Prefix bool `json:"v6_prefix,omitempty"`
+	// Bandwidth optional rate limits of the public ip traffic. If not set
+	// the public ip follows the limits of the first network of the VM.
+	Bandwidth *NetworkBandwidth `json:"bandwidth,omitempty"`
 }
 
 // Valid validate public ip input
@@ -44,6 +62,32 @@ func (p PublicIP) Valid(getter gridtypes.WorkloadGetter) error {
 		return fmt.Errorf("public ip workload with no selections")
 	}
 
+	if p.Firewall != nil {
+		if err := p.Firewall.Valid(); err != nil {
+			return err
+		}
+	}
+
+	if p.Bandwidth != nil {
+		if err := p.Bandwidth.Valid(); err != nil {
+			return err
+		}
+	}
+
+	if p.V6Prefix && !p.V6 {
+		return fmt.Errorf("public ipv6 prefix requires an ipv6")
+	}
+
+	if len(p.Target) != 0 {
+		if !p.V4 {
+			return fmt.Errorf("public ip target requires an ipv4")
+		}
+
+		if _, _, _, err := p.Target.Parts(); err != nil {
+			return fmt.Errorf("invalid public ip target '%s'", p.Target)
+		}
+	}
+
 	return nil
 }
 
@@ -57,6 +101,36 @@ func (p PublicIP) Challenge(w io.Writer) error {
 		return err
 	}
 
+	if p.Firewall != nil {
+		if err := p.Firewall.Challenge(w); err != nil {
+			return err
+		}
+	}
+
+	if p.FlowLogs {
+		if _, err := fmt.Fprintf(w, "flow_logs%t", p.FlowLogs); err != nil {
+			return err
+		}
+	}
+
+	if len(p.Target) != 0 {
+		if _, err := fmt.Fprintf(w, "target%s", p.Target); err != nil {
+			return err
+		}
+	}
+
+	if p.V6Prefix {
+		if _, err := fmt.Fprintf(w, "v6_prefix%t", p.V6Prefix); err != nil {
+			return err
+		}
+	}
+
+	if p.Bandwidth != nil {
+		if err := p.Bandwidth.Challenge(w); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -76,6 +150,8 @@ type PublicIPResult struct {
 	IP gridtypes.IPNet `json:"ip"`
 	// IPv6 of the VM.
 	IPv6 gridtypes.IPNet `json:"ip6"`
+	// IPv6Prefix is the ipv6 prefix routed to the IPv6 of the VM
+	IPv6Prefix gridtypes.IPNet `json:"ip6_prefix"`
 	// Gateway: this fields is only here because we have no idea what is the
 	// gateway of that ip without consulting the farmer. Currently this
 	// component does not exist. hence as a temporaray solution the user must
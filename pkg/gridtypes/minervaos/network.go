@@ -5,6 +5,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
+	"net"
 
 	"github.com/jbenet/go-base58"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
@@ -56,7 +57,7 @@ func NetworkIDFromWorkloadID(wl gridtypes.WorkloadID) (NetID, error) {
 // This is why this can get complicated.
 type Network struct {
 	// IP range of the network, must be an IPv4 /16
-	// for example a 10.1.0.0/16
+	// for example a 10.1.0.0/16. Can be empty for IPv6 only networks
 	NetworkIPRange gridtypes.IPNet `json:"ip_range"`
 
 	// IPV4 subnet for this network resource
@@ -64,6 +65,15 @@ type Network struct {
 	// for example 10.1.1.0/24
 	Subnet gridtypes.IPNet `json:"subnet"`
 
+	// NetworkIPRangeV6 is an optional user provided IPv6 prefix of the
+	// network (ULA or routed), for example fd12:3456:789a::/48. If not set
+	// IPv6 inside the network is derived from the IPv4 addresses.
+	NetworkIPRangeV6 gridtypes.IPNet `json:"ip_range_v6,omitempty"`
+
+	// SubnetV6 is the IPv6 /64 subnet of this network resource, it must be
+	// part of NetworkIPRangeV6. Required if NetworkIPRangeV6 is set.
+	SubnetV6 gridtypes.IPNet `json:"subnet_v6,omitempty"`
+
 	// The private wg key of this node (this peer) which is installing this
 	// network workload right now.
 	// This has to be filled in by the user (and not generated for example)
@@ -85,6 +95,158 @@ type Network struct {
 	// if no mycelium configuration is provided, vms can't
 	// get mycelium IPs.
 	Mycelium *Mycelium `json:"mycelium,omitempty"`
+
+	// Firewall is an optional security group applied to the traffic
+	// forwarded to (ingress) and from (egress) the network resource.
+	Firewall *Firewall `json:"firewall,omitempty"`
+
+	// Services are optional dhcp and dns services run by the node inside
+	// the network resource.
+	Services *NetworkServices `json:"services,omitempty"`
+
+	// Bandwidth optional rate limits of the network resource traffic
+	// to and from the public internet.
+	Bandwidth *NetworkBandwidth `json:"bandwidth,omitempty"`
+
+	// FlowLogs enables logging of the connections of the network resource
+	// to and from the public internet.
+	FlowLogs bool `json:"flow_logs,omitempty"`
+
+	// Mesh optionally connects the network resources of this network
+	// over tunnels on top of the mycelium overlay, so nodes without a
+	// public endpoint can be part of the same network.
+	Mesh *NetworkMesh `json:"mesh,omitempty"`
+
+	// SelfTest checks the reachability of all the peers after the network
+	// resource is created. The result of the check is set as the workload
+	// result data (see NetworkResult).
+	SelfTest bool `json:"self_test,omitempty"`
+}
+
+// NetworkResult is the result of the self test of a network resource
+type NetworkResult struct {
+	// Peers is the reachability of each peer of the network resource
+	Peers []PeerReachability `json:"peers,omitempty"`
+	// Checked is the time of the last self test
+	Checked gridtypes.Timestamp `json:"checked,omitempty"`
+}
+
+// PeerReachability is the result of the self test of a single peer
+type PeerReachability struct {
+	// Subnet of the peer
+	Subnet gridtypes.IPNet `json:"subnet"`
+	// Endpoint of the peer, empty for peers without an endpoint
+	Endpoint string `json:"endpoint,omitempty"`
+	// Gateway is the ip of the peer that is pinged, the wireguard ip of the
+	// peer (or the gateway of its subnet for mesh peers)
+	Gateway net.IP `json:"gateway"`
+	// Reachable is true if the gateway replied to the ping
+	Reachable bool `json:"reachable"`
+	// RTT is the average round trip time to the gateway in milliseconds
+	RTT float64 `json:"rtt,omitempty"`
+	// Error is set if the peer is not reachable
+	Error string `json:"error,omitempty"`
+}
+
+// MeshUnderlay is the overlay used to carry the mesh tunnels
+type MeshUnderlay string
+
+const (
+	// MeshUnderlayMycelium uses a dedicated mycelium node per network resource
+	MeshUnderlayMycelium MeshUnderlay = "mycelium"
+	// MeshUnderlayYggdrasil uses the node yggdrasil network (not supported yet)
+	MeshUnderlayYggdrasil MeshUnderlay = "yggdrasil"
+)
+
+// NetworkMesh configures the mesh mode of a network. In mesh mode all the
+// network resources of the network share the same mesh key, the node
+// derives the mesh identity of each network resource from it and creates
+// a tunnel to each peer that has no wireguard public key. Only the subnet
+// of such peers is needed.
+//
+// The node also discovers the other network resources of the network by
+// probing every subnet of the network range over the mesh, so peers do
+// not have to be listed. Discovery is limited to network ranges of up to
+// 256 subnets, and only the subnet a peer is discovered with is routed.
+// Dual stack peers must be listed to route their ipv6 subnet.
+type NetworkMesh struct {
+	// Underlay of the mesh tunnels, defaults to mycelium
+	Underlay MeshUnderlay `json:"underlay"`
+	// Key is the secret shared by all the network resources of the
+	// network. It is only used for the mesh and is independent from
+	// the mycelium key of the network resource.
+	Key Bytes `json:"hex_key"`
+}
+
+// Valid checks the mesh configuration
+func (m *NetworkMesh) Valid() error {
+	if len(m.Key) != MyceliumKeyLen {
+		return fmt.Errorf("invalid mesh key length, expected %d", MyceliumKeyLen)
+	}
+
+	switch m.Underlay {
+	case "", MeshUnderlayMycelium:
+		return nil
+	case MeshUnderlayYggdrasil:
+		return fmt.Errorf("mesh over yggdrasil is not supported yet")
+	default:
+		return fmt.Errorf("unknown mesh underlay '%s'", m.Underlay)
+	}
+}
+
+// Challenge implementation
+func (m *NetworkMesh) Challenge(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "mesh%s%x", m.Underlay, m.Key)
+	return err
+}
+
+// MinNetworkBandwidth is the smallest allowed network bandwidth limit
+const MinNetworkBandwidth = 128 * gridtypes.Kilobyte
+
+// NetworkBandwidth rate limits of a network resource in bytes per second.
+// A zero value means no limit.
+type NetworkBandwidth struct {
+	// Ingress limits the traffic coming from the public internet to the
+	// workloads of the network
+	Ingress gridtypes.Unit `json:"ingress"`
+	// Egress limits the traffic going out of the network to the public
+	// internet
+	Egress gridtypes.Unit `json:"egress"`
+}
+
+// Valid checks the bandwidth limits
+func (b *NetworkBandwidth) Valid() error {
+	for _, limit := range []gridtypes.Unit{b.Ingress, b.Egress} {
+		if limit != 0 && limit < MinNetworkBandwidth {
+			return fmt.Errorf("network bandwidth limit must be at least %d bytes per second", MinNetworkBandwidth)
+		}
+	}
+
+	return nil
+}
+
+// Challenge implementation
+func (b *NetworkBandwidth) Challenge(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "ingress%degress%d", b.Ingress, b.Egress)
+	return err
+}
+
+// NetworkServices configures the services that run inside the network
+// resource. The served hosts are the VMs that are attached to the network
+// on this node.
+type NetworkServices struct {
+	// DHCP runs a dhcp server on the network resource subnet. It only
+	// hands out static leases for the ips of the VMs interfaces.
+	DHCP bool `json:"dhcp"`
+	// DNS runs an authoritative dns server for the `<vm>.<network>.internal`
+	// names of the VMs attached to the network.
+	DNS bool `json:"dns"`
+}
+
+// Challenge implementation
+func (s *NetworkServices) Challenge(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%t%t", s.DHCP, s.DNS)
+	return err
 }
 
 type MyceliumPeer string
@@ -127,15 +289,71 @@ func (c *Mycelium) Valid() error {
 	return nil
 }
 
+// HasIPv4 returns true if the network has an IPv4 range
+func (n *Network) HasIPv4() bool {
+	return !n.NetworkIPRange.Nil()
+}
+
+// HasIPv6 returns true if the network has a user provided IPv6 range
+func (n *Network) HasIPv6() bool {
+	return !n.NetworkIPRangeV6.Nil()
+}
+
+func (n *Network) validIPv4() error {
+	if n.NetworkIPRange.IP.To4() == nil {
+		return fmt.Errorf("network IP range must be an IPv4 range")
+	}
+
+	if len(n.Subnet.IP) == 0 {
+		return fmt.Errorf("network resource subnet cannot empty")
+	}
+
+	return nil
+}
+
+func (n *Network) validIPv6() error {
+	if n.NetworkIPRangeV6.IP.To4() != nil {
+		return fmt.Errorf("network IPv6 range must be an IPv6 range")
+	}
+
+	if ones, _ := n.NetworkIPRangeV6.Mask.Size(); ones > 64 {
+		return fmt.Errorf("network IPv6 range must be a /64 or bigger")
+	}
+
+	if n.SubnetV6.Nil() {
+		return fmt.Errorf("network resource IPv6 subnet cannot be empty")
+	}
+
+	if ones, bits := n.SubnetV6.Mask.Size(); ones != 64 || bits != 128 {
+		return fmt.Errorf("network resource IPv6 subnet must be a /64")
+	}
+
+	if !n.NetworkIPRangeV6.Contains(n.SubnetV6.IP) {
+		return fmt.Errorf("network resource IPv6 subnet is not part of the network IPv6 range")
+	}
+
+	return nil
+}
+
 // Valid checks if the network resource is valid.
 func (n Network) Valid(getter gridtypes.WorkloadGetter) error {
 
-	if n.NetworkIPRange.Nil() {
+	if !n.HasIPv4() && !n.HasIPv6() {
 		return fmt.Errorf("network IP range cannot be empty")
 	}
 
-	if len(n.Subnet.IP) == 0 {
-		return fmt.Errorf("network resource subnet cannot empty")
+	if n.HasIPv4() {
+		if err := n.validIPv4(); err != nil {
+			return err
+		}
+	}
+
+	if n.HasIPv6() {
+		if err := n.validIPv6(); err != nil {
+			return err
+		}
+	} else if !n.SubnetV6.Nil() {
+		return fmt.Errorf("network resource IPv6 subnet requires a network IPv6 range")
 	}
 
 	if n.WGPrivateKey == "" {
@@ -143,6 +361,13 @@ func (n Network) Valid(getter gridtypes.WorkloadGetter) error {
 	}
 
 	for _, peer := range n.Peers {
+		if n.Mesh != nil && peer.IsMesh() {
+			if err := peer.validMesh(); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if err := peer.Valid(); err != nil {
 			return err
 		}
@@ -154,6 +379,28 @@ func (n Network) Valid(getter gridtypes.WorkloadGetter) error {
 		}
 	}
 
+	if n.Mesh != nil {
+		if err := n.Mesh.Valid(); err != nil {
+			return err
+		}
+	}
+
+	if n.Firewall != nil {
+		if err := n.Firewall.Valid(); err != nil {
+			return err
+		}
+	}
+
+	if n.Services != nil && n.Services.DHCP && !n.HasIPv4() {
+		return fmt.Errorf("network dhcp requires an IPv4 range")
+	}
+
+	if n.Bandwidth != nil {
+		if err := n.Bandwidth.Valid(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -187,6 +434,52 @@ func (n Network) Challenge(b io.Writer) error {
 		}
 	}
 
+	if n.HasIPv6() {
+		if _, err := fmt.Fprintf(b, "%s", n.NetworkIPRangeV6.String()); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(b, "%s", n.SubnetV6.String()); err != nil {
+			return err
+		}
+	}
+
+	if n.Firewall != nil {
+		if err := n.Firewall.Challenge(b); err != nil {
+			return err
+		}
+	}
+
+	if n.Services != nil {
+		if err := n.Services.Challenge(b); err != nil {
+			return err
+		}
+	}
+
+	if n.Bandwidth != nil {
+		if err := n.Bandwidth.Challenge(b); err != nil {
+			return err
+		}
+	}
+
+	if n.FlowLogs {
+		if _, err := fmt.Fprintf(b, "flow_logs%t", n.FlowLogs); err != nil {
+			return err
+		}
+	}
+
+	if n.Mesh != nil {
+		if err := n.Mesh.Challenge(b); err != nil {
+			return err
+		}
+	}
+
+	if n.SelfTest {
+		if _, err := fmt.Fprintf(b, "self_test%t", n.SelfTest); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -199,6 +492,9 @@ func (n Network) Capacity() (gridtypes.Capacity, error) {
 type Peer struct {
 	// IPV4 subnet of the network resource of the peer
 	Subnet gridtypes.IPNet `json:"subnet"`
+	// IPV6 subnet of the network resource of the peer, only set
+	// if the network has an IPv6 range
+	SubnetV6 gridtypes.IPNet `json:"subnet_v6,omitempty"`
 	// WGPublicKey of the peer (driven from its private key)
 	WGPublicKey string `json:"wireguard_public_key"`
 	// Allowed Ips is related to his subnet.
@@ -210,7 +506,7 @@ type Peer struct {
 
 // Valid checks if peer is valid
 func (p *Peer) Valid() error {
-	if p.Subnet.Nil() {
+	if p.Subnet.Nil() && p.SubnetV6.Nil() {
 		return fmt.Errorf("peer wireguard subnet cannot empty")
 	}
 
@@ -225,6 +521,21 @@ func (p *Peer) Valid() error {
 	return nil
 }
 
+// IsMesh returns true if the peer is reached over the network mesh. In
+// mesh mode those are the peers without a wireguard public key.
+func (p *Peer) IsMesh() bool {
+	return p.WGPublicKey == ""
+}
+
+// validMesh checks a mesh peer, only its subnet is needed
+func (p *Peer) validMesh() error {
+	if p.Subnet.Nil() && p.SubnetV6.Nil() {
+		return fmt.Errorf("mesh peer subnet cannot empty")
+	}
+
+	return nil
+}
+
 // Challenge for peer
 func (p Peer) Challenge(w io.Writer) error {
 	if _, err := fmt.Fprintf(w, "%s", p.WGPublicKey); err != nil {
@@ -236,6 +547,11 @@ func (p Peer) Challenge(w io.Writer) error {
 	if _, err := fmt.Fprintf(w, "%s", p.Subnet.String()); err != nil {
 		return err
 	}
+	if !p.SubnetV6.Nil() {
+		if _, err := fmt.Fprintf(w, "%s", p.SubnetV6.String()); err != nil {
+			return err
+		}
+	}
 	for _, ip := range p.AllowedIPs {
 		if _, err := fmt.Fprintf(w, "%s", ip.String()); err != nil {
 			return err
@@ -0,0 +1,190 @@
+package zos
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+func TestNetworkValid(t *testing.T) {
+	require := require.New(t)
+
+	v4 := Network{
+		NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+		Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+		WGPrivateKey:   "key",
+	}
+	require.NoError(v4.Valid(nil))
+	require.True(v4.HasIPv4())
+	require.False(v4.HasIPv6())
+
+	dual := v4
+	dual.NetworkIPRangeV6 = gridtypes.MustParseIPNet("fd12:3456:789a::/48")
+	dual.SubnetV6 = gridtypes.MustParseIPNet("fd12:3456:789a:2::/64")
+	require.NoError(dual.Valid(nil))
+
+	v6 := dual
+	v6.NetworkIPRange = gridtypes.IPNet{}
+	v6.Subnet = gridtypes.IPNet{}
+	require.NoError(v6.Valid(nil))
+	require.False(v6.HasIPv4())
+
+	type Case struct {
+		Name    string
+		Network func(n *Network)
+		Error   string
+	}
+
+	cases := []Case{
+		{
+			Name:    "missing ipv6 range",
+			Network: func(n *Network) { n.NetworkIPRangeV6 = gridtypes.IPNet{} },
+			Error:   "network IP range cannot be empty",
+		},
+		{
+			Name:    "missing ipv6 subnet",
+			Network: func(n *Network) { n.SubnetV6 = gridtypes.IPNet{} },
+			Error:   "network resource IPv6 subnet cannot be empty",
+		},
+		{
+			Name:    "ipv6 subnet outside range",
+			Network: func(n *Network) { n.SubnetV6 = gridtypes.MustParseIPNet("fd12:3456:789b:2::/64") },
+			Error:   "network resource IPv6 subnet is not part of the network IPv6 range",
+		},
+		{
+			Name:    "ipv6 subnet not a /64",
+			Network: func(n *Network) { n.SubnetV6 = gridtypes.MustParseIPNet("fd12:3456:789a:2::/80") },
+			Error:   "network resource IPv6 subnet must be a /64",
+		},
+		{
+			Name:    "ipv4 as ipv6 range",
+			Network: func(n *Network) { n.NetworkIPRangeV6 = gridtypes.MustParseIPNet("10.2.0.0/16") },
+			Error:   "network IPv6 range must be an IPv6 range",
+		},
+		{
+			Name:    "ipv6 range smaller than /64",
+			Network: func(n *Network) { n.NetworkIPRangeV6 = gridtypes.MustParseIPNet("fd12:3456:789a:2::/96") },
+			Error:   "network IPv6 range must be a /64 or bigger",
+		},
+	}
+
+	for _, test := range cases {
+		network := v6
+		test.Network(&network)
+		require.EqualError(network.Valid(nil), test.Error, test.Name)
+	}
+
+	// ipv4 subnet is still required if the network has an ipv4 range
+	broken := dual
+	broken.Subnet = gridtypes.IPNet{}
+	require.Error(broken.Valid(nil))
+}
+
+func TestNetworkServicesValid(t *testing.T) {
+	require := require.New(t)
+
+	network := Network{
+		NetworkIPRangeV6: gridtypes.MustParseIPNet("fd12:3456:789a::/48"),
+		SubnetV6:         gridtypes.MustParseIPNet("fd12:3456:789a:2::/64"),
+		WGPrivateKey:     "key",
+		Services:         &NetworkServices{DNS: true},
+	}
+	require.NoError(network.Valid(nil))
+
+	// dhcp only serves ipv4
+	network.Services.DHCP = true
+	require.Error(network.Valid(nil))
+
+	network.NetworkIPRange = gridtypes.MustParseIPNet("10.1.0.0/16")
+	network.Subnet = gridtypes.MustParseIPNet("10.1.2.0/24")
+	require.NoError(network.Valid(nil))
+}
+
+func TestNetworkBandwidthValid(t *testing.T) {
+	require := require.New(t)
+
+	network := Network{
+		NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+		Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+		WGPrivateKey:   "key",
+		Bandwidth:      &NetworkBandwidth{Egress: 10 * gridtypes.Megabyte},
+	}
+	require.NoError(network.Valid(nil))
+
+	network.Bandwidth.Ingress = gridtypes.Kilobyte
+	require.Error(network.Valid(nil))
+
+	network.Bandwidth.Ingress = MinNetworkBandwidth
+	require.NoError(network.Valid(nil))
+}
+
+func TestNetworkMeshValid(t *testing.T) {
+	require := require.New(t)
+
+	network := Network{
+		NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+		Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+		WGPrivateKey:   "key",
+		Peers: []Peer{
+			{Subnet: gridtypes.MustParseIPNet("10.1.3.0/24")},
+		},
+	}
+
+	// peers without wireguard keys are only allowed in mesh mode
+	require.Error(network.Valid(nil))
+
+	network.Mesh = &NetworkMesh{}
+	require.Error(network.Valid(nil), "mesh requires a key")
+
+	network.Mesh.Key = make(Bytes, MyceliumKeyLen)
+	require.NoError(network.Valid(nil))
+
+	network.Mesh.Underlay = MeshUnderlayYggdrasil
+	require.Error(network.Valid(nil))
+
+	network.Mesh.Underlay = MeshUnderlayMycelium
+	network.Peers = append(network.Peers, Peer{})
+	require.Error(network.Valid(nil))
+}
+
+func TestNetworkChallenge(t *testing.T) {
+	require := require.New(t)
+
+	challenge := func(n Network) string {
+		var buf bytes.Buffer
+		require.NoError(n.Challenge(&buf))
+		return buf.String()
+	}
+
+	base := Network{
+		NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+		Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+		WGPrivateKey:   "key",
+	}
+
+	flowLogs := base
+	flowLogs.FlowLogs = true
+
+	selfTest := base
+	selfTest.SelfTest = true
+
+	require.NotEqual(challenge(base), challenge(flowLogs))
+	require.NotEqual(challenge(flowLogs), challenge(selfTest))
+}
+
+func TestNetworkBandwidthChallenge(t *testing.T) {
+	require := require.New(t)
+
+	challenge := func(b NetworkBandwidth) string {
+		var buf bytes.Buffer
+		require.NoError(b.Challenge(&buf))
+		return buf.String()
+	}
+
+	// 12|3456 and 123|456 must not sign the same
+	a := NetworkBandwidth{Ingress: 12, Egress: 3456}
+	b := NetworkBandwidth{Ingress: 123, Egress: 456}
+	require.NotEqual(challenge(a), challenge(b))
+}
@@ -0,0 +1,116 @@
+package zos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+// ForwardProtocol is the transport protocol of a port forward
+type ForwardProtocol string
+
+const (
+	// ForwardTCP forwards tcp traffic
+	ForwardTCP ForwardProtocol = "tcp"
+	// ForwardUDP forwards udp traffic
+	ForwardUDP ForwardProtocol = "udp"
+)
+
+// Valid checks if protocol is valid
+func (p ForwardProtocol) Valid() error {
+	switch p {
+	case ForwardTCP, ForwardUDP:
+		return nil
+	default:
+		return fmt.Errorf("invalid forward protocol '%s'", p)
+	}
+}
+
+// PortForward forwards a port on the node public IPv4 to a port of a
+// private IP inside a network resource. This makes services running in
+// VMs without a dedicated public IP reachable from the internet.
+// The node side port is allocated by the node and returned in the workload
+// result.
+type PortForward struct {
+	// Network name of the network the target ip belongs to
+	Network gridtypes.Name `json:"network"`
+	// IP of the target inside the network, must be an IPv4
+	IP net.IP `json:"ip"`
+	// Port on the target ip to forward traffic to
+	Port uint16 `json:"port"`
+	// Protocol to forward (tcp or udp)
+	Protocol ForwardProtocol `json:"protocol"`
+}
+
+// Valid implementation
+func (p PortForward) Valid(getter gridtypes.WorkloadGetter) error {
+	if len(p.Network) == 0 {
+		return fmt.Errorf("network name is required")
+	}
+
+	if p.IP.To4() == nil {
+		return fmt.Errorf("invalid ip '%s' must be an ipv4", p.IP)
+	}
+
+	if p.Port == 0 {
+		return fmt.Errorf("port is required")
+	}
+
+	if err := p.Protocol.Valid(); err != nil {
+		return err
+	}
+
+	// networks are sharable so the network can be part of another
+	// deployment, we can only validate the ip if it's in the same
+	// deployment.
+	wl, err := getter.Get(p.Network)
+	if err != nil || wl.Type != NetworkType {
+		return nil
+	}
+
+	var network Network
+	if err := json.Unmarshal(wl.Data, &network); err != nil {
+		return err
+	}
+
+	if !network.Subnet.Contains(p.IP) {
+		return fmt.Errorf("ip '%s' is not part of network '%s' subnet", p.IP, p.Network)
+	}
+
+	return nil
+}
+
+// Challenge implementation
+func (p PortForward) Challenge(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s", p.Network); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s", p.IP.String()); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%d", p.Port); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s", p.Protocol); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Capacity implementation
+func (p PortForward) Capacity() (gridtypes.Capacity, error) {
+	return gridtypes.Capacity{PortU: 1}, nil
+}
+
+// PortForwardResult result returned by port forward reservation
+type PortForwardResult struct {
+	// PublicPort is the port allocated on the node public ip
+	PublicPort uint16 `json:"public_port"`
+}
@@ -0,0 +1,81 @@
+package zos
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+func TestPortForwardValid(t *testing.T) {
+	require := require.New(t)
+
+	deployment := gridtypes.Deployment{
+		TwinID: 1,
+		Workloads: []gridtypes.Workload{
+			{
+				Name: "net",
+				Type: NetworkType,
+				Data: gridtypes.MustMarshal(Network{
+					NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+					Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+				}),
+			},
+		},
+	}
+
+	valid := PortForward{
+		Network:  "net",
+		IP:       net.ParseIP("10.1.2.10"),
+		Port:     22,
+		Protocol: ForwardTCP,
+	}
+	require.NoError(valid.Valid(&deployment))
+
+	// network from another deployment can't be checked
+	other := valid
+	other.Network = "other"
+	other.IP = net.ParseIP("192.168.1.10")
+	require.NoError(other.Valid(&deployment))
+
+	type Case struct {
+		Name    string
+		Forward func(f *PortForward)
+		Error   string
+	}
+
+	cases := []Case{
+		{
+			Name:    "missing network",
+			Forward: func(f *PortForward) { f.Network = "" },
+			Error:   "network name is required",
+		},
+		{
+			Name:    "ipv6 address",
+			Forward: func(f *PortForward) { f.IP = net.ParseIP("fd00::1") },
+			Error:   "invalid ip 'fd00::1' must be an ipv4",
+		},
+		{
+			Name:    "ip outside subnet",
+			Forward: func(f *PortForward) { f.IP = net.ParseIP("10.1.3.10") },
+			Error:   "ip '10.1.3.10' is not part of network 'net' subnet",
+		},
+		{
+			Name:    "missing port",
+			Forward: func(f *PortForward) { f.Port = 0 },
+			Error:   "port is required",
+		},
+		{
+			Name:    "invalid protocol",
+			Forward: func(f *PortForward) { f.Protocol = "icmp" },
+			Error:   "invalid forward protocol 'icmp'",
+		},
+	}
+
+	for _, test := range cases {
+		forward := valid
+		test.Forward(&forward)
+		require.EqualError(forward.Valid(&deployment), test.Error, test.Name)
+	}
+}
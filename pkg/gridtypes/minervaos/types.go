@@ -31,6 +31,8 @@ const (
 	QuantumSafeFSType gridtypes.WorkloadType = "qsfs"
 	// ZLogsType type
 	ZLogsType gridtypes.WorkloadType = "zlogs"
+	// PortForwardType type
+	PortForwardType gridtypes.WorkloadType = "port-forward"
 )
 
 func init() {
@@ -48,6 +50,7 @@ func init() {
 	gridtypes.RegisterType(GatewayFQDNProxyType, GatewayFQDNProxy{})
 	gridtypes.RegisterType(QuantumSafeFSType, QuantumSafeFS{})
 	gridtypes.RegisterType(ZLogsType, ZLogs{})
+	gridtypes.RegisterType(PortForwardType, PortForward{})
 }
 
 // DeviceType is the actual type of hardware that the storage device runs on,
@@ -10,10 +10,17 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"gopkg.in/yaml.v2"
 )
 
 const (
 	MyceliumIPSeedLen = 6
+
+	// MaxUserDataSize is the max size of the cloud-init user data
+	// that can be attached to a zmachine
+	MaxUserDataSize = 64 * 1024
+	// cloudConfigHeader is the only user data format supported
+	cloudConfigHeader = "#cloud-config"
 )
 
 // MachineInterface structure
@@ -21,8 +28,12 @@ type MachineInterface struct {
 	// Network name (znet name) to join
 	Network gridtypes.Name `json:"network"`
 	// IP of the zmachine on this network must be a valid Ip in the
-	// selected network
+	// selected network. Can be empty on IPv6 only networks.
 	IP net.IP `json:"ip"`
+	// IPv6 of the zmachine on this network. Only valid if the network
+	// has an IPv6 range, and must be part of the network resource IPv6 subnet.
+	// If not set on a dual stack network, it's derived from the IPv4
+	IPv6 net.IP `json:"ipv6,omitempty"`
 }
 
 type MyceliumIP struct {
@@ -81,8 +92,16 @@ func (n *MachineNetwork) Challenge(w io.Writer) error {
 			return err
 		}
 
-		if _, err := fmt.Fprintf(w, "%s", inf.IP.String()); err != nil {
-			return err
+		if len(inf.IP) != 0 {
+			if _, err := fmt.Fprintf(w, "%s", inf.IP.String()); err != nil {
+				return err
+			}
+		}
+
+		if len(inf.IPv6) != 0 {
+			if _, err := fmt.Fprintf(w, "%s", inf.IPv6.String()); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -118,6 +137,35 @@ func (c *MachineCapacity) Challenge(w io.Writer) error {
 	return nil
 }
 
+// MachineQoS structure. Optional IO limits that are applied on the
+// zmachine disks and network interfaces. A zero value means the node
+// default (as configured by the farmer) is used.
+type MachineQoS struct {
+	// DiskIOPS max number of operations per second per disk
+	DiskIOPS uint64 `json:"disk_iops"`
+	// DiskBandwidth max bytes per second per disk
+	DiskBandwidth gridtypes.Unit `json:"disk_bandwidth"`
+	// NetBandwidth max bytes per second per network interface
+	NetBandwidth gridtypes.Unit `json:"net_bandwidth"`
+}
+
+// Challenge builder
+func (q *MachineQoS) Challenge(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%d", q.DiskIOPS); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%d", q.DiskBandwidth); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%d", q.NetBandwidth); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // MachineMount structure
 type MachineMount struct {
 	// Name is name of a zmount. The name must be a valid zmount
@@ -189,6 +237,20 @@ type ZMachine struct {
 	// - Not used by other VMs
 	// - Only possible on `dedicated` nodes
 	GPU []GPU `json:"gpu,omitempty"`
+
+	// QoS optional IO limits for the machine disks and nics. The limits can
+	// only be lower than the node defaults for the machine capacity
+	QoS *MachineQoS `json:"qos,omitempty"`
+
+	// ShutdownTimeout is the grace period in seconds given to the machine
+	// to shutdown (after an ACPI power button event) before it's killed. If not
+	// set, the node default is used. The node also caps this value.
+	ShutdownTimeout uint32 `json:"shutdown_timeout,omitempty"`
+
+	// UserData is an optional cloud-init user data document. It must
+	// be a valid `#cloud-config` yaml document and it is merged with the
+	// configuration generated by zos (users, mounts). Only used in VM mode
+	UserData string `json:"user_data,omitempty"`
 }
 
 func (m *ZMachine) MinRootSize() gridtypes.Unit {
@@ -219,9 +281,17 @@ func (v ZMachine) Valid(getter gridtypes.WorkloadGetter) error {
 	}
 
 	for _, inf := range v.Network.Interfaces {
-		if inf.IP.To4() == nil && inf.IP.To16() == nil {
+		if len(inf.IP) == 0 && len(inf.IPv6) == 0 {
 			return fmt.Errorf("invalid IP")
 		}
+
+		if len(inf.IP) != 0 && inf.IP.To4() == nil && inf.IP.To16() == nil {
+			return fmt.Errorf("invalid IP")
+		}
+
+		if len(inf.IPv6) != 0 && (inf.IPv6.To16() == nil || inf.IPv6.To4() != nil) {
+			return fmt.Errorf("invalid IPv6")
+		}
 	}
 	if v.ComputeCapacity.CPU == 0 {
 		return fmt.Errorf("cpu capacity can't be 0")
@@ -278,6 +348,44 @@ func (v ZMachine) Valid(getter gridtypes.WorkloadGetter) error {
 		}
 	}
 
+	if err := v.validUserData(); err != nil {
+		return errors.Wrap(err, "invalid user data")
+	}
+
+	return nil
+}
+
+func (v *ZMachine) validUserData() error {
+	if len(v.UserData) == 0 {
+		return nil
+	}
+
+	if len(v.UserData) > MaxUserDataSize {
+		return fmt.Errorf("user data can't be more than %d bytes", MaxUserDataSize)
+	}
+
+	if !strings.HasPrefix(v.UserData, cloudConfigHeader+"\n") {
+		return fmt.Errorf("user data must start with '%s' header", cloudConfigHeader)
+	}
+
+	var data map[string]interface{}
+	if err := yaml.Unmarshal([]byte(v.UserData), &data); err != nil {
+		return errors.Wrap(err, "user data is not a valid yaml document")
+	}
+
+	// the node appends its own users and mounts to the user data
+	// so they must be lists if set
+	for _, key := range []string{"users", "mounts"} {
+		value, ok := data[key]
+		if !ok || value == nil {
+			continue
+		}
+
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("'%s' must be a list", key)
+		}
+	}
+
 	return nil
 }
 
@@ -344,6 +452,22 @@ func (v ZMachine) Challenge(b io.Writer) error {
 		}
 	}
 
+	if _, err := fmt.Fprintf(b, "%s", v.UserData); err != nil {
+		return err
+	}
+
+	if v.QoS != nil {
+		if err := v.QoS.Challenge(b); err != nil {
+			return err
+		}
+	}
+
+	if v.ShutdownTimeout != 0 {
+		if _, err := fmt.Fprintf(b, "%d", v.ShutdownTimeout); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -351,6 +475,7 @@ func (v ZMachine) Challenge(b io.Writer) error {
 type ZMachineResult struct {
 	ID          string `json:"id"`
 	IP          string `json:"ip"`
+	IPv6        string `json:"ipv6,omitempty"`
 	PlanetaryIP string `json:"planetary_ip"`
 	MyceliumIP  string `json:"mycelium_ip"`
 	ConsoleURL  string `json:"console_url"`
@@ -360,6 +485,7 @@ func (r *ZMachineResult) UnmarshalJSON(data []byte) error {
 	var deprecated struct {
 		ID          string `json:"id"`
 		IP          string `json:"ip"`
+		IPv6        string `json:"ipv6"`
 		YggIP       string `json:"ygg_ip"`
 		PlanetaryIP string `json:"planetary_ip"`
 		MyceliumIP  string `json:"mycelium_ip"`
@@ -372,6 +498,7 @@ func (r *ZMachineResult) UnmarshalJSON(data []byte) error {
 
 	r.ID = deprecated.ID
 	r.IP = deprecated.IP
+	r.IPv6 = deprecated.IPv6
 	r.PlanetaryIP = deprecated.PlanetaryIP
 	if deprecated.YggIP != "" {
 		r.PlanetaryIP = deprecated.YggIP
@@ -2,6 +2,7 @@ package zos
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -116,3 +117,33 @@ func TestResultDeprecated(t *testing.T) {
 		ConsoleURL:  "10.20.2.0:20002",
 	}, result)
 }
+
+func TestZMachineUserData(t *testing.T) {
+	cases := []struct {
+		Name     string
+		UserData string
+		Valid    bool
+	}{
+		{Name: "empty", UserData: "", Valid: true},
+		{Name: "valid", UserData: "#cloud-config\npackages:\n  - curl\n", Valid: true},
+		{Name: "no header", UserData: "packages:\n  - curl\n", Valid: false},
+		{Name: "script", UserData: "#!/bin/sh\necho hello\n", Valid: false},
+		{Name: "not a map", UserData: "#cloud-config\n- curl\n", Valid: false},
+		{Name: "users list", UserData: "#cloud-config\nusers:\n  - name: test\n", Valid: true},
+		{Name: "users not a list", UserData: "#cloud-config\nusers: root\n", Valid: false},
+		{Name: "mounts not a list", UserData: "#cloud-config\nmounts:\n  data: /data\n", Valid: false},
+		{Name: "too big", UserData: "#cloud-config\n#" + strings.Repeat("a", MaxUserDataSize), Valid: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			vm := ZMachine{UserData: c.UserData}
+			err := vm.validUserData()
+			if c.Valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
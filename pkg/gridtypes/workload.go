@@ -81,11 +81,13 @@ type Capacity struct {
 	HRU   Unit   `json:"hru"`
 	MRU   Unit   `json:"mru"`
 	IPV4U uint64 `json:"ipv4u"`
+	// PortU is the number of node public ports reserved by the workload
+	PortU uint64 `json:"portu"`
 }
 
 // Zero returns true if capacity is zero
 func (c *Capacity) Zero() bool {
-	return c.CRU == 0 && c.SRU == 0 && c.HRU == 0 && c.MRU == 0 && c.IPV4U == 0
+	return c.CRU == 0 && c.SRU == 0 && c.HRU == 0 && c.MRU == 0 && c.IPV4U == 0 && c.PortU == 0
 }
 
 // Add increments value of capacity with o
@@ -95,6 +97,7 @@ func (c *Capacity) Add(o *Capacity) {
 	c.SRU += o.SRU
 	c.HRU += o.HRU
 	c.IPV4U += o.IPV4U
+	c.PortU += o.PortU
 }
 
 // WorkloadData interface
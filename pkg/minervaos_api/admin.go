@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
 )
 
 func (g *ZosAPI) adminInterfacesHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -45,3 +48,84 @@ func (g *ZosAPI) adminSetPublicNICHandler(ctx context.Context, payload []byte) (
 	}
 	return nil, g.networkerStub.SetPublicExitDevice(ctx, iface)
 }
+
+func (g *ZosAPI) adminGetPublicUplinkHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.networkerStub.GetPublicUplink(ctx)
+}
+
+func (g *ZosAPI) adminSetPublicUplinkHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var uplink pkg.PublicUplink
+	if err := json.Unmarshal(payload, &uplink); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting public uplink: %w", err)
+	}
+	return nil, g.networkerStub.SetPublicUplink(ctx, uplink)
+}
+
+func (g *ZosAPI) adminGetPublicIPv6DelegationHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.networkerStub.GetPublicIPv6Delegation(ctx)
+}
+
+func (g *ZosAPI) adminSetPublicIPv6DelegationHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var delegation pkg.PublicIPv6Delegation
+	if err := json.Unmarshal(payload, &delegation); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting ipv6 delegation: %w", err)
+	}
+	return nil, g.networkerStub.SetPublicIPv6Delegation(ctx, delegation)
+}
+
+func (g *ZosAPI) adminGetGatewayDomainsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.networkerStub.GetGatewayDomains(ctx)
+}
+
+func (g *ZosAPI) adminSetGatewayDomainsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var domains []pkg.GatewayDomain
+	if err := json.Unmarshal(payload, &domains); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting list of gateway domains: %w", err)
+	}
+	return nil, g.networkerStub.SetGatewayDomains(ctx, domains)
+}
+
+func (g *ZosAPI) adminGetSNATPoolsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.networkerStub.GetSNATPools(ctx)
+}
+
+func (g *ZosAPI) adminSetSNATPoolsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var pools []pkg.SNATPool
+	if err := json.Unmarshal(payload, &pools); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting list of snat pools: %w", err)
+	}
+
+	// farm ips that are already used by public ip workloads can't be used
+	// as snat addresses
+	used, err := g.provisionStub.ListPublicIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range used {
+		ipNet, err := gridtypes.ParseIPNet(ip)
+		if err != nil {
+			continue
+		}
+
+		for i := range pools {
+			if pools[i].Has(ipNet.IP) {
+				return nil, fmt.Errorf("snat ip '%s' is used by a public ip workload", ipNet.IP)
+			}
+		}
+	}
+
+	return nil, g.networkerStub.SetSNATPools(ctx, pools)
+}
+
+func (g *ZosAPI) adminSNATAssignmentsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.networkerStub.SNATAssignments(ctx)
+}
+
+func (g *ZosAPI) adminDiagnosticsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var diagnostic pkg.NetworkDiagnostic
+	if err := json.Unmarshal(payload, &diagnostic); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting network diagnostic: %w", err)
+	}
+	return g.networkerStub.Diagnose(ctx, diagnostic)
+}
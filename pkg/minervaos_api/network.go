@@ -5,9 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+const (
+	// selfTestRefreshInterval is the min time between two network self tests
+	// requested over the api
+	selfTestRefreshInterval = time.Minute
 )
 
 func (g *ZosAPI) networkListWGPortsHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -61,3 +69,90 @@ func (g *ZosAPI) networkListPrivateIPsHandler(ctx context.Context, payload []byt
 	twin := peer.GetTwinID(ctx)
 	return g.provisionStub.ListPrivateIPs(ctx, twin, args.NetworkName)
 }
+
+func (g *ZosAPI) networkWGStatusHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	twin := peer.GetTwinID(ctx)
+	return g.networkerStub.WireguardStatus(ctx, twin)
+}
+
+// networkCheckHandler returns the self test result of the network resource
+// of one of the twin networks from the network workload result. The peers
+// are pinged again if the network was never checked, or if refresh is set
+// and the last check is older than selfTestRefreshInterval. The new result
+// is recorded in all the workloads of the network.
+func (g *ZosAPI) networkCheckHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		NetworkName gridtypes.Name `json:"network_name"`
+		Refresh     bool           `json:"refresh"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting network name: %w", err)
+	}
+
+	twin := peer.GetTwinID(ctx)
+	deployments, err := g.provisionStub.List(ctx, twin)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []gridtypes.WorkloadID
+	var result zos.NetworkResult
+	for _, deployment := range deployments {
+		for _, wl := range deployment.ByType(zos.NetworkType) {
+			if wl.Name != args.NetworkName || wl.Result.State != gridtypes.StateOk {
+				continue
+			}
+
+			ids = append(ids, gridtypes.NewUncheckedWorkloadID(twin, deployment.ContractID, wl.Name))
+
+			var last zos.NetworkResult
+			if err := wl.Result.Unmarshal(&last); err == nil && last.Checked > result.Checked {
+				result = last
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("network '%s' is not deployed", args.NetworkName)
+	}
+
+	// a recent result is returned as is even if a refresh is requested, since
+	// each check pings all the peers and is recorded in the workloads
+	checked := time.Unix(int64(result.Checked), 0)
+	if result.Checked != 0 && (!args.Refresh || time.Since(checked) < selfTestRefreshInterval) {
+		return result, nil
+	}
+
+	// all the workloads of the same network share the network resource
+	result, err = g.networkerStub.CheckNR(ctx, ids[0])
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := g.provisionStub.UpdateResultData(ctx, string(id), data); err != nil {
+			return nil, fmt.Errorf("failed to record network check result: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+func (g *ZosAPI) networkFlowLogsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		Since int64 `json:"since"`
+	}
+	if len(payload) != 0 {
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return nil, fmt.Errorf("failed to decode input, expecting flow logs arguments: %w", err)
+		}
+	}
+
+	twin := peer.GetTwinID(ctx)
+	return g.networkerStub.FlowLogs(ctx, twin, time.Unix(args.Since, 0))
+}
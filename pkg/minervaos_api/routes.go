@@ -31,6 +31,15 @@ func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 	network.WithHandler("has_ipv6", g.networkHasIPv6Handler)
 	network.WithHandler("list_public_ips", g.networkListPublicIPsHandler)
 	network.WithHandler("list_private_ips", g.networkListPrivateIPsHandler)
+	network.WithHandler("wg_status", g.networkWGStatusHandler)
+	network.WithHandler("flow_logs", g.networkFlowLogsHandler)
+	network.WithHandler("check", g.networkCheckHandler)
+
+	vm := root.SubRoute("vm")
+	vm.WithHandler("guest_info", g.vmGuestInfoHandler)
+	vm.WithHandler("metrics", g.vmMetricsHandler)
+	vm.WithHandler("guest_shutdown", g.vmGuestShutdownHandler)
+	vm.WithHandler("guest_password_set", g.vmGuestPasswordSetHandler)
 
 	statistics := root.SubRoute("statistics")
 	statistics.WithHandler("get", g.statisticsGetHandler)
@@ -48,6 +57,16 @@ func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 	admin.WithHandler("interfaces", g.adminInterfacesHandler)
 	admin.WithHandler("set_public_nic", g.adminSetPublicNICHandler)
 	admin.WithHandler("get_public_nic", g.adminGetPublicNICHandler)
+	admin.WithHandler("set_public_uplink", g.adminSetPublicUplinkHandler)
+	admin.WithHandler("get_public_uplink", g.adminGetPublicUplinkHandler)
+	admin.WithHandler("set_public_ipv6_delegation", g.adminSetPublicIPv6DelegationHandler)
+	admin.WithHandler("get_public_ipv6_delegation", g.adminGetPublicIPv6DelegationHandler)
+	admin.WithHandler("set_gateway_domains", g.adminSetGatewayDomainsHandler)
+	admin.WithHandler("get_gateway_domains", g.adminGetGatewayDomainsHandler)
+	admin.WithHandler("set_snat_pools", g.adminSetSNATPoolsHandler)
+	admin.WithHandler("get_snat_pools", g.adminGetSNATPoolsHandler)
+	admin.WithHandler("snat_assignments", g.adminSNATAssignmentsHandler)
+	admin.WithHandler("diagnostics", g.adminDiagnosticsHandler)
 
 	location := root.SubRoute("location")
 	location.WithHandler("get", g.locationGet)
@@ -0,0 +1,111 @@
+package zosapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+type vmArgs struct {
+	ContractID uint64         `json:"contract_id"`
+	Name       gridtypes.Name `json:"name"`
+}
+
+// vmID finds the zmachine workload id that is owned by the calling twin
+func (g *ZosAPI) vmID(ctx context.Context, args vmArgs) (string, error) {
+	deployment, err := g.provisionStub.Get(ctx, peer.GetTwinID(ctx), args.ContractID)
+	if err != nil {
+		return "", err
+	}
+
+	wl, err := deployment.GetType(args.Name, zos.ZMachineType)
+	if err != nil {
+		return "", err
+	}
+
+	if wl.Result.State != gridtypes.StateOk {
+		return "", fmt.Errorf("zmachine '%s' is not running", args.Name)
+	}
+
+	return wl.ID.String(), nil
+}
+
+func (g *ZosAPI) vmGuestInfoHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args vmArgs
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	id, err := g.vmID(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := g.vmStub.Inspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the guest agent is optional, so failure to reach
+	// it is not an error.
+	if guest, err := g.vmStub.GuestInfo(ctx, id); err == nil {
+		info.Guest = &guest
+	}
+
+	return info, nil
+}
+
+func (g *ZosAPI) vmMetricsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		vmArgs
+		// Window in seconds
+		Window uint64 `json:"window"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	id, err := g.vmID(ctx, args.vmArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.usageStub.MachineUsage(ctx, id, time.Duration(args.Window)*time.Second)
+}
+
+func (g *ZosAPI) vmGuestShutdownHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args vmArgs
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	id, err := g.vmID(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, g.vmStub.GuestShutdown(ctx, id)
+}
+
+func (g *ZosAPI) vmGuestPasswordSetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		vmArgs
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	id, err := g.vmID(ctx, args.vmArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, g.vmStub.GuestPasswordSet(ctx, id, args.User, args.Password)
+}
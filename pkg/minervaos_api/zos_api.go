@@ -26,6 +26,8 @@ type ZosAPI struct {
 	networkerStub          *stubs.NetworkerStub
 	statisticsStub         *stubs.StatisticsStub
 	storageStub            *stubs.StorageModuleStub
+	vmStub                 *stubs.VMModuleStub
+	usageStub              *stubs.VMUsageStub
 	performanceMonitorStub *stubs.PerformanceMonitorStub
 	diagnosticsManager     *diagnostics.DiagnosticsManager
 	farmerID               uint32
@@ -51,6 +53,8 @@ func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon strin
 		networkerStub:          stubs.NewNetworkerStub(client),
 		statisticsStub:         stubs.NewStatisticsStub(client),
 		storageStub:            storageModuleStub,
+		vmStub:                 stubs.NewVMModuleStub(client),
+		usageStub:              stubs.NewVMUsageStub(client),
 		performanceMonitorStub: stubs.NewPerformanceMonitorStub(client),
 		diagnosticsManager:     diagnosticsManager,
 	}
@@ -213,6 +213,7 @@ func (n *systemMonitor) GetNodeFeatures() []pkg.NodeFeature {
 		pkg.NodeFeature(zos.GatewayFQDNProxyType),
 		pkg.NodeFeature(zos.QuantumSafeFSType),
 		pkg.NodeFeature(zos.ZLogsType),
+		pkg.NodeFeature(zos.PortForwardType),
 		pkg.NodeFeature("yggdrasil"),
 		pkg.NodeFeature("mycelium"),
 		pkg.NodeFeature("wireguard"),
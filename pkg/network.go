@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/url"
 	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
@@ -138,6 +142,18 @@ type Networker interface {
 	// interface is returned
 	SetupPubTap(name string) (string, error)
 
+	// SetPubTapBandwidth applies the bandwidth limits of the network to the
+	// public tap of a vm that is attached to that network. The limits of the
+	// tap are updated with the network afterwards. The network id can be empty
+	// if the vm has no private network.
+	SetPubTapBandwidth(name string, networkID NetID) error
+
+	// SetPubIPBandwidth sets the own bandwidth limits of a public ip, they
+	// take precedence over the limits of the network of the vm. Setting nil
+	// limits makes the public tap follow its network again. The limits are
+	// applied once the tap exists.
+	SetPubIPBandwidth(name string, bandwidth *zos.NetworkBandwidth) error
+
 	// PubTapExists checks if the tap device for the public network exists already
 	PubTapExists(name string) (bool, error)
 
@@ -152,6 +168,52 @@ type Networker interface {
 
 	// PubIPFilterExists checks if there is a filter installed with that name
 	PubIPFilterExists(filterName string) bool
+
+	// SetPubIPFirewall sets (or updates) the user firewall rules of an existing
+	// public ip filter
+	SetPubIPFirewall(filterName string, firewall zos.Firewall) error
+
+	// SetPubIPFloating routes the public ipv4 of the filter to the public tap
+	// iface (with given mac) of another vm. If iface is empty the ip is only
+	// removed from the previous tap
+	SetPubIPFloating(filterName string, iface string, ipv4 net.IP, mac string) error
+
+	// AnnouncePubIP sends a gratuitous arp (and neighbor advertisement) for the
+	// public ips from the given mac, after the ips moved to another vm
+	AnnouncePubIP(ipv4 net.IP, ipv6 net.IP, mac string) error
+
+	// SetPubIPFlowLogs enables (or disables) the connection logging of the
+	// ips of a public ip workload
+	SetPubIPFlowLogs(wl gridtypes.WorkloadID, ipv4 net.IP, ipv6 net.IP, enabled bool) error
+
+	// DelegatePrefix allocates an ipv6 prefix from the node delegated prefixes
+	// and routes it to nexthop (the public ipv6 of a vm). Calling it again for
+	// the same workload returns the same prefix
+	DelegatePrefix(wl gridtypes.WorkloadID, nexthop net.IP) (net.IPNet, error)
+
+	// ReleasePrefix removes the route of the prefix delegated to the workload
+	// and releases it
+	ReleasePrefix(wl gridtypes.WorkloadID) error
+
+	// SetupPortForward forwards a port of the node public ipv4 to the given
+	// private ip and port inside a network resource. The allocated public port
+	// is returned. Calling it again for the same workload updates the forward
+	// and keeps the same public port
+	SetupPortForward(wl gridtypes.WorkloadID, forward PortForward) (uint16, error)
+
+	// RemovePortForward removes a port forward set up by SetupPortForward and
+	// releases its public port
+	RemovePortForward(wl gridtypes.WorkloadID) error
+
+	// SetNetworkHost registers (or updates) a workload host in the dhcp and
+	// dns services of a network resource. Hosts are stored even if the
+	// network resource does not run any services.
+	SetNetworkHost(wl gridtypes.WorkloadID, host NetworkHost) error
+
+	// RemoveNetworkHost removes a workload host from the services of the
+	// given network resource
+	RemoveNetworkHost(wl gridtypes.WorkloadID, networkID NetID) error
+
 	// DisconnectPubTap disconnects the public tap from the network. The interface
 	// itself is not removed and will need to be cleaned up later
 	DisconnectPubTap(name string) error
@@ -162,6 +224,14 @@ type Networker interface {
 	// GetNet returns the full network range of the network
 	GetNet(networkID NetID) (net.IPNet, error)
 
+	// GetSubnetV6 of the network with the given ID on the local node. It's
+	// empty if the network has no IPv6 range
+	GetSubnetV6(networkID NetID) (net.IPNet, error)
+
+	// GetNetV6 returns the full IPv6 range of the network. It's empty if
+	// the network has no IPv6 range
+	GetNetV6(networkID NetID) (net.IPNet, error)
+
 	// GetPublicIPv6Subnet returns the IPv6 prefix op the public subnet of the host
 	GetPublicIPv6Subnet() (net.IPNet, error)
 
@@ -171,7 +241,7 @@ type Networker interface {
 
 	// GetDefaultGwIP returns the IPs of the default gateways inside the network
 	// resource identified by the network ID on the local node, for IPv4 and IPv6
-	// respectively
+	// respectively. The IPv4 gateway is nil on IPv6 only networks
 	GetDefaultGwIP(networkID NetID) (net.IP, net.IP, error)
 
 	// GetIPv6From4 generates an IPv6 address from a given IPv4 address in a NR
@@ -190,6 +260,10 @@ type Networker interface {
 
 	WireguardPorts() ([]uint, error)
 
+	// PortLeases returns all the ports reserved by networkd (wireguard
+	// listen ports and port forwards) with the workloads that own them
+	PortLeases() ([]PortLease, error)
+
 	// Public Config
 
 	// Set node public namespace config.
@@ -206,7 +280,60 @@ type Networker interface {
 
 	SetPublicExitDevice(iface string) error
 
+	// SetPublicUplink wires the public bridge to the given uplink nics. It
+	// replaces any exit device set with SetPublicExitDevice. The uplink is
+	// persisted and applied again on boot
+	SetPublicUplink(uplink PublicUplink) error
+
+	// GetPublicUplink returns the configured public uplink, it's empty if the
+	// public bridge uses the default (or exit device) wiring
+	GetPublicUplink() (PublicUplink, error)
+
+	// SetPublicIPv6Delegation sets the ipv6 prefixes the node can delegate to
+	// vms. It requires a public config
+	SetPublicIPv6Delegation(delegation PublicIPv6Delegation) error
+
+	// GetPublicIPv6Delegation returns the configured ipv6 prefix delegation
+	GetPublicIPv6Delegation() (PublicIPv6Delegation, error)
+
+	// SetGatewayDomains sets the domains served by the gateway in addition to
+	// the node domain. It requires a public config
+	SetGatewayDomains(domains []GatewayDomain) error
+
+	// GetGatewayDomains returns the configured gateway domains
+	GetGatewayDomains() ([]GatewayDomain, error)
+
+	// SetSNATPools sets the pools of public ipv4 addresses used as source of
+	// the outbound traffic of network resources. It requires a public config
+	SetSNATPools(pools []SNATPool) error
+
+	// GetSNATPools returns the configured snat pools
+	GetSNATPools() ([]SNATPool, error)
+
+	// SNATAssignments returns the snat pool address assigned to each twin
+	SNATAssignments() ([]SNATAssignment, error)
+
 	Metrics() (NetResourceMetrics, error)
+
+	// WireguardStatus returns the wireguard peers status of all the network
+	// resources of the given twin
+	WireguardStatus(twin uint32) ([]NetworkWGStatus, error)
+
+	// CheckNR pings the gateway of each peer of the network resource of the
+	// given network workload and returns the reachability of the peers
+	CheckNR(wl gridtypes.WorkloadID) (zos.NetworkResult, error)
+
+	// FlowLogs returns the logged connections of the public ips and network
+	// resources of the given twin that ended after since
+	FlowLogs(twin uint32, since time.Time) ([]FlowLog, error)
+
+	// MyceliumPeers returns the health of the mycelium public peers
+	MyceliumPeers() ([]MyceliumPeer, error)
+
+	// Diagnose runs one of the fixed network diagnostics inside a network
+	// namespace and returns its output
+	Diagnose(diagnostic NetworkDiagnostic) (string, error)
+
 	// Monitoring methods
 
 	// ZOSAddresses monitoring streams for ZOS bridge IPs
@@ -230,6 +357,140 @@ type Network struct {
 // NetID type
 type NetID = zos.NetID
 
+// PortForward defines the target of a port forward inside a network resource
+type PortForward struct {
+	NetID    NetID               `json:"net_id"`
+	Protocol zos.ForwardProtocol `json:"protocol"`
+	IP       net.IP              `json:"ip"`
+	Port     uint16              `json:"port"`
+}
+
+// PortLeaseType is the type of a port lease
+type PortLeaseType string
+
+const (
+	// PortLeaseWireguard is a wireguard listen port of a network resource
+	PortLeaseWireguard PortLeaseType = "wireguard"
+	// PortLeasePortForward is a public port of a port forward
+	PortLeasePortForward PortLeaseType = "port-forward"
+)
+
+// PortLease is a port reserved by networkd
+type PortLease struct {
+	Type PortLeaseType `json:"type"`
+	Port uint16        `json:"port"`
+	// Owner is the workload that owns the port, it's empty for ports
+	// reserved before owners were recorded
+	Owner   string    `json:"owner"`
+	Created time.Time `json:"created"`
+}
+
+// WGPeerStatus is the status of a wireguard peer of a network resource
+type WGPeerStatus struct {
+	PublicKey  string   `json:"public_key"`
+	Endpoint   string   `json:"endpoint"`
+	AllowedIPs []string `json:"allowed_ips"`
+	// LastHandshake is the time of the latest handshake with the peer, it's
+	// the zero time if no handshake happened yet
+	LastHandshake time.Time `json:"last_handshake"`
+	RxBytes       int64     `json:"rx_bytes"`
+	TxBytes       int64     `json:"tx_bytes"`
+}
+
+// NetworkWGStatus is the wireguard status of a network resource
+type NetworkWGStatus struct {
+	Network gridtypes.Name `json:"network"`
+	NetID   NetID          `json:"net_id"`
+	Peers   []WGPeerStatus `json:"peers"`
+	// Error is set if the status of this network could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// FlowLog is a connection of a public ip or a network resource. Connections
+// are logged when they end
+type FlowLog struct {
+	// Source is the id of the public ip or network workload
+	Source   string `json:"source"`
+	Protocol string `json:"protocol"`
+	SrcIP    net.IP `json:"src_ip"`
+	SrcPort  uint16 `json:"src_port"`
+	DstIP    net.IP `json:"dst_ip"`
+	DstPort  uint16 `json:"dst_port"`
+	// TxBytes are the bytes sent by the side that started the connection
+	TxBytes uint64 `json:"tx_bytes"`
+	// RxBytes are the bytes received by the side that started the connection
+	RxBytes uint64    `json:"rx_bytes"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// MyceliumPeer is the health of a mycelium public peer
+type MyceliumPeer struct {
+	Endpoint string `json:"endpoint"`
+	// Active is set if mycelium is configured to connect to the peer
+	Active bool `json:"active"`
+	// Connected is set if the connection to the peer is alive
+	Connected bool `json:"connected"`
+	// Latency is the last measured connect latency, 0 if unreachable
+	Latency time.Duration `json:"latency"`
+	// Failures is the number of consecutive failed probes
+	Failures int    `json:"failures"`
+	RxBytes  uint64 `json:"rx_bytes"`
+	TxBytes  uint64 `json:"tx_bytes"`
+}
+
+// DiagnosticType is a network diagnostic that can be run on the node
+type DiagnosticType string
+
+const (
+	DiagnosticRoutes     DiagnosticType = "routes"
+	DiagnosticAddresses  DiagnosticType = "addresses"
+	DiagnosticNeighbors  DiagnosticType = "neighbors"
+	DiagnosticRuleset    DiagnosticType = "nft"
+	DiagnosticPing       DiagnosticType = "ping"
+	DiagnosticTraceroute DiagnosticType = "traceroute"
+	DiagnosticWireguard  DiagnosticType = "wireguard"
+)
+
+// NetworkDiagnostic is a request to run a diagnostic inside a network
+// namespace
+type NetworkDiagnostic struct {
+	// Namespace to run the diagnostic in, empty for the host namespace
+	Namespace string `json:"namespace"`
+	// Type of the diagnostic
+	Type DiagnosticType `json:"type"`
+	// Target is the ip to ping or traceroute
+	Target string `json:"target,omitempty"`
+}
+
+// Valid checks the diagnostic type and target
+func (d *NetworkDiagnostic) Valid() error {
+	switch d.Type {
+	case DiagnosticRoutes, DiagnosticAddresses, DiagnosticNeighbors, DiagnosticRuleset, DiagnosticWireguard:
+		if len(d.Target) != 0 {
+			return fmt.Errorf("diagnostic '%s' does not accept a target", d.Type)
+		}
+	case DiagnosticPing, DiagnosticTraceroute:
+		// only ips are accepted, so no name resolution happens on the node
+		if net.ParseIP(d.Target) == nil {
+			return fmt.Errorf("diagnostic '%s' requires a valid target ip", d.Type)
+		}
+	default:
+		return fmt.Errorf("unknown diagnostic '%s'", d.Type)
+	}
+
+	return nil
+}
+
+// NetworkHost is a host (a VM interface) inside a network resource
+// that is served by the network resource dhcp and dns services
+type NetworkHost struct {
+	NetID NetID    `json:"net_id"`
+	Name  string   `json:"name"`
+	MAC   string   `json:"mac"`
+	IPs   []net.IP `json:"ips"`
+}
+
 // IfaceType define the different public interface supported
 type IfaceType string
 
@@ -258,6 +519,344 @@ type PublicConfig struct {
 	// Domain is the node domain name like gent01.devnet.grid.tf
 	// or similar
 	Domain string `json:"domain"`
+
+	// Uplink is an optional farmer defined wiring of the public bridge, it's
+	// not part of the chain public config and is kept when the public config
+	// is updated
+	Uplink *PublicUplink `json:"uplink,omitempty"`
+
+	// Delegation is an optional farmer defined set of ipv6 prefixes that are
+	// routed to the node to be delegated to vms, like the uplink it's not part
+	// of the chain public config
+	Delegation *PublicIPv6Delegation `json:"delegation,omitempty"`
+
+	// GatewayDomains are optional farmer defined domains served by the
+	// gateway in addition to Domain, like the uplink it's not part of the
+	// chain public config
+	GatewayDomains []GatewayDomain `json:"gateway_domains,omitempty"`
+
+	// SNATPools are optional farmer defined pools of public ipv4 addresses
+	// used as source of the outbound traffic of network resources, like the
+	// uplink it's not part of the chain public config
+	SNATPools []SNATPool `json:"snat_pools,omitempty"`
+}
+
+// ServedDomains returns all the domains served by the gateway, the node
+// domain comes first unless it's overridden by a gateway domain
+func (p *PublicConfig) ServedDomains() []GatewayDomain {
+	var domains []GatewayDomain
+	if len(p.Domain) != 0 && !p.hasGatewayDomain(p.Domain) {
+		domains = append(domains, GatewayDomain{Domain: p.Domain})
+	}
+
+	return append(domains, p.GatewayDomains...)
+}
+
+func (p *PublicConfig) hasGatewayDomain(domain string) bool {
+	for _, d := range p.GatewayDomains {
+		if d.Domain == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GatewayDomain returns the served domain with the given name. If name is
+// empty the default domain is returned which is the node domain if set or
+// the first gateway domain otherwise
+func (p *PublicConfig) GatewayDomain(name string) (GatewayDomain, error) {
+	domains := p.ServedDomains()
+	if len(domains) == 0 {
+		return GatewayDomain{}, fmt.Errorf("node doesn't support name proxy (doesn't have a domain)")
+	}
+
+	if len(name) == 0 {
+		if len(p.Domain) != 0 {
+			name = p.Domain
+		} else {
+			return domains[0], nil
+		}
+	}
+
+	for _, domain := range domains {
+		if domain.Domain == name {
+			return domain, nil
+		}
+	}
+
+	return GatewayDomain{}, fmt.Errorf("domain '%s' is not served by this node", name)
+}
+
+var gatewayDomainRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// ACMEChallenge is the challenge used to get the certificates of the names
+// of a gateway domain
+type ACMEChallenge string
+
+const (
+	// ACMEChallengeDNS gets a single wildcard certificate for the domain. The
+	// _acme-challenge record of the domain must be delegated to the node. It's
+	// the default
+	ACMEChallengeDNS ACMEChallenge = "dns"
+	// ACMEChallengeHTTP gets a certificate for each name over http
+	ACMEChallengeHTTP ACMEChallenge = "http"
+)
+
+// Valid validates the challenge
+func (c ACMEChallenge) Valid() error {
+	switch c {
+	case "", ACMEChallengeDNS, ACMEChallengeHTTP:
+		return nil
+	}
+
+	return fmt.Errorf("invalid acme challenge '%s'", c)
+}
+
+// GatewayACME defines how the certificates of a gateway domain are issued.
+// Empty fields use the gateway defaults.
+type GatewayACME struct {
+	// Email is the email of the acme account
+	Email string `json:"email,omitempty"`
+	// Server is the acme directory url, defaults to lets encrypt
+	Server string `json:"server,omitempty"`
+	// Challenge defaults to dns
+	Challenge ACMEChallenge `json:"challenge,omitempty"`
+}
+
+// IsDefault indicates that the acme settings are the gateway defaults
+func (a *GatewayACME) IsDefault() bool {
+	return len(a.Email) == 0 && len(a.Server) == 0 && a.Challenge != ACMEChallengeHTTP
+}
+
+// GatewayDomain is a domain served by the node gateway, name proxies on
+// that domain are served as <name>.<domain>
+type GatewayDomain struct {
+	Domain string      `json:"domain"`
+	ACME   GatewayACME `json:"acme"`
+}
+
+// Valid validates the gateway domain
+func (d *GatewayDomain) Valid() error {
+	if !gatewayDomainRe.MatchString(d.Domain) {
+		return fmt.Errorf("invalid gateway domain '%s'", d.Domain)
+	}
+
+	if len(d.ACME.Email) != 0 && !strings.Contains(d.ACME.Email, "@") {
+		return fmt.Errorf("invalid acme email '%s' of domain '%s'", d.ACME.Email, d.Domain)
+	}
+
+	if len(d.ACME.Server) != 0 {
+		u, err := url.Parse(d.ACME.Server)
+		if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+			return fmt.Errorf("invalid acme server '%s' of domain '%s' must be an https url", d.ACME.Server, d.Domain)
+		}
+	}
+
+	return d.ACME.Challenge.Valid()
+}
+
+// PublicIPv6Delegation defines the ipv6 prefixes that are routed (by the farm
+// router) to the public ipv6 of the node. Each vm that asks for a prefix
+// gets a sub prefix of size PrefixSize routed to its public ipv6.
+type PublicIPv6Delegation struct {
+	// Prefixes are routed prefixes configured by the farmer
+	Prefixes []gridtypes.IPNet `json:"prefixes,omitempty"`
+	// DHCP requests a prefix with DHCPv6 prefix delegation on the public
+	// interface, it's used in addition to the configured prefixes
+	DHCP bool `json:"dhcp,omitempty"`
+	// Size is the length of the prefix delegated to each vm, defaults to 64
+	Size uint8 `json:"size,omitempty"`
+}
+
+// IsEmpty indicates that no prefixes can be delegated
+func (d *PublicIPv6Delegation) IsEmpty() bool {
+	return len(d.Prefixes) == 0 && !d.DHCP
+}
+
+// PrefixSize returns the length of the prefix delegated to each vm
+func (d *PublicIPv6Delegation) PrefixSize() int {
+	if d.Size == 0 {
+		return 64
+	}
+
+	return int(d.Size)
+}
+
+// Valid validates the delegation
+func (d *PublicIPv6Delegation) Valid() error {
+	size := d.PrefixSize()
+	if size < 48 || size > 128 {
+		return fmt.Errorf("invalid delegated prefix size '%d' must be between 48 and 128", size)
+	}
+
+	for _, prefix := range d.Prefixes {
+		if prefix.Nil() || prefix.IP.To4() != nil {
+			return fmt.Errorf("invalid delegated prefix '%s' must be an ipv6 prefix", prefix.String())
+		}
+
+		if ones, _ := prefix.Mask.Size(); ones > size {
+			return fmt.Errorf("delegated prefix '%s' is smaller than the prefix size '/%d'", prefix.String(), size)
+		}
+	}
+
+	return nil
+}
+
+// SNATPool is a pool of public ipv4 addresses used as source of the outbound
+// traffic of network resources instead of the node address. Each twin gets a
+// dedicated address of a pool that is used by all its network resources.
+type SNATPool struct {
+	// IPs are the addresses of the pool with the prefix of their subnet
+	IPs []gridtypes.IPNet `json:"ips"`
+	// Gateway is the gateway of the pool subnet
+	Gateway net.IP `json:"gateway"`
+	// Twins the pool is reserved for, if empty the pool is shared by all
+	// twins. Reserved pools are used before shared pools.
+	Twins []uint32 `json:"twins,omitempty"`
+}
+
+// Allows indicates if the pool addresses can be assigned to the twin
+func (p *SNATPool) Allows(twin uint32) bool {
+	if len(p.Twins) == 0 {
+		return true
+	}
+
+	for _, id := range p.Twins {
+		if id == twin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Valid validates the snat pool
+func (p *SNATPool) Valid() error {
+	if len(p.IPs) == 0 {
+		return fmt.Errorf("snat pool has no ips")
+	}
+
+	if p.Gateway.To4() == nil {
+		return fmt.Errorf("invalid snat pool gateway '%s' must be an ipv4", p.Gateway)
+	}
+
+	for _, ip := range p.IPs {
+		if ip.Nil() || ip.IP.To4() == nil {
+			return fmt.Errorf("invalid snat pool ip '%s' must be an ipv4", ip.String())
+		}
+
+		if !ip.Contains(p.Gateway) {
+			return fmt.Errorf("snat pool gateway '%s' is not in the subnet of '%s'", p.Gateway, ip.String())
+		}
+
+		if ip.IP.Equal(p.Gateway) {
+			return fmt.Errorf("snat pool ip '%s' is the pool gateway", ip.IP)
+		}
+	}
+
+	return nil
+}
+
+// Has indicates if ip is one of the pool addresses
+func (p *SNATPool) Has(ip net.IP) bool {
+	for _, poolIP := range p.IPs {
+		if poolIP.IP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SNATAssignment is the snat pool address assigned to a twin
+type SNATAssignment struct {
+	Twin uint32          `json:"twin"`
+	IP   gridtypes.IPNet `json:"ip"`
+	// Networks are the network resources of the twin that use the address
+	Networks []NetID `json:"networks"`
+}
+
+// PublicUplink defines the nics that carry the public traffic of the node.
+// It's used by farms that separate the management, public and storage
+// networks on different nics or vlans.
+type PublicUplink struct {
+	// NICs are the physical nics of the uplink. If more than one nic is
+	// set they are bonded together using LACP (802.3ad)
+	NICs []string `json:"nics"`
+	// Vlan is an optional vlan tag of the public traffic on the uplink
+	Vlan *uint16 `json:"vlan,omitempty"`
+	// Pools are optional public ip pools that live on their own vlan on
+	// the uplink. Public IPs that are part of a pool subnet are tagged with
+	// the pool vlan.
+	Pools []PublicIPPool `json:"pools,omitempty"`
+}
+
+// PublicIPPool is a range of public IPs on a separate vlan
+type PublicIPPool struct {
+	Subnet gridtypes.IPNet `json:"subnet"`
+	Vlan   uint16          `json:"vlan"`
+}
+
+// IsEmpty indicates if the uplink is not set
+func (u *PublicUplink) IsEmpty() bool {
+	return len(u.NICs) == 0
+}
+
+// Valid checks if the uplink is valid
+func (u *PublicUplink) Valid() error {
+	if u.IsEmpty() {
+		return fmt.Errorf("at least one uplink nic is required")
+	}
+
+	vlans := make(map[uint16]struct{})
+	check := func(vlan uint16) error {
+		if vlan == 0 || vlan > 4094 {
+			return fmt.Errorf("invalid vlan '%d'", vlan)
+		}
+
+		// vlan 1 is the default vlan (pvid) of the public bridge ports
+		if vlan == 1 {
+			return fmt.Errorf("vlan '1' is reserved")
+		}
+
+		if _, ok := vlans[vlan]; ok {
+			return fmt.Errorf("vlan '%d' is used more than once", vlan)
+		}
+
+		vlans[vlan] = struct{}{}
+		return nil
+	}
+
+	if u.Vlan != nil {
+		if err := check(*u.Vlan); err != nil {
+			return err
+		}
+	}
+
+	for _, pool := range u.Pools {
+		if pool.Subnet.Nil() {
+			return fmt.Errorf("public ip pool subnet is required")
+		}
+
+		if err := check(pool.Vlan); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PoolVlan returns the vlan of the pool the ip is part of. It returns false
+// if the ip is not part of any pool
+func (u *PublicUplink) PoolVlan(ip net.IP) (uint16, bool) {
+	for _, pool := range u.Pools {
+		if pool.Subnet.Contains(ip) {
+			return pool.Vlan, true
+		}
+	}
+
+	return 0, false
 }
 
 func (p *PublicConfig) IsEmpty() bool {
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/tc"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// pubTapsDir keeps the limits of each public tap, so the limits can be
+	// updated with the network or the public ip
+	pubTapsDir = "pub-taps"
+)
+
+// pubTap is the record of the bandwidth limits of a public tap
+type pubTap struct {
+	// Network the tap follows the limits of
+	Network pkg.NetID `json:"network,omitempty"`
+	// Bandwidth are the own limits of the public ip, they take
+	// precedence over the network limits
+	Bandwidth *zos.NetworkBandwidth `json:"bandwidth,omitempty"`
+}
+
+// pubTapLimits returns the ingress and egress limits of the tap
+func (n *networker) pubTapLimits(record pubTap) (ingress, egress uint64, err error) {
+	bw := record.Bandwidth
+	if bw == nil && len(record.Network) != 0 {
+		network, err := n.networkOf(record.Network)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "couldn't load network with id (%s)", record.Network)
+		}
+		bw = network.Bandwidth
+	}
+
+	if bw != nil {
+		ingress, egress = uint64(bw.Ingress), uint64(bw.Egress)
+	}
+
+	return ingress, egress, nil
+}
+
+// loadPubTap loads the record of the tap, older records only have the
+// network id
+func (n *networker) loadPubTap(tapIface string) (record pubTap, err error) {
+	data, err := os.ReadFile(filepath.Join(n.pubTapsDir, tapIface))
+	if os.IsNotExist(err) {
+		return record, nil
+	} else if err != nil {
+		return record, err
+	}
+
+	if err := json.Unmarshal(data, &record); err != nil {
+		return pubTap{Network: pkg.NetID(strings.TrimSpace(string(data)))}, nil
+	}
+
+	return record, nil
+}
+
+func (n *networker) storePubTap(tapIface string, record pubTap) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(n.pubTapsDir, tapIface), data, 0644); err != nil {
+		return errors.Wrap(err, "failed to record public tap limits")
+	}
+
+	return nil
+}
+
+// SetPubTapBandwidth implements pkg.Networker interface
+func (n *networker) SetPubTapBandwidth(name string, networkID pkg.NetID) error {
+	tapIface, err := pubTapName(name)
+	if err != nil {
+		return errors.Wrap(err, "could not get network namespace tap device name")
+	}
+
+	record, err := n.loadPubTap(tapIface)
+	if err != nil {
+		return err
+	}
+
+	record.Network = networkID
+	if err := n.storePubTap(tapIface, record); err != nil {
+		return err
+	}
+
+	return n.setPubTapBandwidth(tapIface, record)
+}
+
+// SetPubIPBandwidth implements pkg.Networker interface
+func (n *networker) SetPubIPBandwidth(name string, bandwidth *zos.NetworkBandwidth) error {
+	tapIface, err := pubTapName(name)
+	if err != nil {
+		return errors.Wrap(err, "could not get network namespace tap device name")
+	}
+
+	record, err := n.loadPubTap(tapIface)
+	if err != nil {
+		return err
+	}
+
+	record.Bandwidth = bandwidth
+	if err := n.storePubTap(tapIface, record); err != nil {
+		return err
+	}
+
+	// the tap is created later with the vm, the limits are
+	// applied then
+	err = n.setPubTapBandwidth(tapIface, record)
+	if _, ok := errors.Cause(err).(netlink.LinkNotFoundError); ok {
+		return nil
+	}
+
+	return err
+}
+
+// setPubTapBandwidth applies the bandwidth limits of the record to the
+// public tap. The tap egress is the traffic to the vm, and its ingress is
+// the traffic from the vm.
+func (n *networker) setPubTapBandwidth(tapIface string, record pubTap) error {
+	ingress, egress, err := n.pubTapLimits(record)
+	if err != nil {
+		return err
+	}
+
+	link, err := netlink.LinkByName(tapIface)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get public tap '%s'", tapIface)
+	}
+
+	log.Info().
+		Str("pubtap", tapIface).
+		Uint64("ingress", ingress).
+		Uint64("egress", egress).
+		Msg("setting public tap bandwidth")
+
+	if err := tc.SetRateLimit(link, ingress); err != nil {
+		return err
+	}
+
+	return tc.SetIngressRateLimit(link, egress)
+}
+
+// applyPubTapsBandwidth updates the bandwidth limits of all the public taps
+// that follow the given network. Records of taps that are gone are removed.
+func (n *networker) applyPubTapsBandwidth(networkID pkg.NetID) error {
+	entries, err := os.ReadDir(n.pubTapsDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		record, err := n.loadPubTap(entry.Name())
+		if err != nil {
+			return err
+		}
+
+		// taps with their own limits do not follow the network
+		if record.Network != networkID || record.Bandwidth != nil {
+			continue
+		}
+
+		err = n.setPubTapBandwidth(entry.Name(), record)
+		if _, ok := errors.Cause(err).(netlink.LinkNotFoundError); ok {
+			_ = os.Remove(filepath.Join(n.pubTapsDir, entry.Name()))
+		} else if err != nil {
+			log.Error().Err(err).Str("pubtap", entry.Name()).Msg("failed to update public tap bandwidth")
+		}
+	}
+
+	return nil
+}
+
+// clearPubTapBandwidth removes the ingress limit (and its ifb device) of the
+// public tap, the egress limit goes away with the tap.
+func (n *networker) clearPubTapBandwidth(tapIface string) error {
+	if err := os.Remove(filepath.Join(n.pubTapsDir, tapIface)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	link, err := netlink.LinkByName(tapIface)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return tc.ClearIngressRateLimit(link)
+}
@@ -0,0 +1,44 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+func TestPubTapRecord(t *testing.T) {
+	require := require.New(t)
+
+	n := &networker{pubTapsDir: t.TempDir()}
+
+	record, err := n.loadPubTap("p-missing")
+	require.NoError(err)
+	require.Equal(pubTap{}, record)
+
+	// older records only have the network id
+	require.NoError(os.WriteFile(filepath.Join(n.pubTapsDir, "p-old"), []byte("net-id"), 0644))
+	record, err = n.loadPubTap("p-old")
+	require.NoError(err)
+	require.Equal(pubTap{Network: "net-id"}, record)
+
+	bw := &zos.NetworkBandwidth{Ingress: 1000000, Egress: 2000000}
+	require.NoError(n.storePubTap("p-new", pubTap{Network: "net-id", Bandwidth: bw}))
+	record, err = n.loadPubTap("p-new")
+	require.NoError(err)
+	require.Equal(pubTap{Network: "net-id", Bandwidth: bw}, record)
+
+	// own limits take precedence over the network limits
+	ingress, egress, err := n.pubTapLimits(record)
+	require.NoError(err)
+	require.EqualValues(1000000, ingress)
+	require.EqualValues(2000000, egress)
+
+	// no network and no limits
+	ingress, egress, err = n.pubTapLimits(pubTap{})
+	require.NoError(err)
+	require.Zero(ingress)
+	require.Zero(egress)
+}
@@ -0,0 +1,338 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/network/public"
+)
+
+const (
+	prefixDelegationDir = "prefix-delegation"
+)
+
+// delegatedPrefix is the stored state of a prefix delegated to a workload
+type delegatedPrefix struct {
+	Prefix  gridtypes.IPNet `json:"prefix"`
+	NextHop net.IP          `json:"next_hop"`
+}
+
+func (n *networker) delegatedPrefixPath(wl gridtypes.WorkloadID) string {
+	return filepath.Join(n.delegationDir, wl.String())
+}
+
+func (n *networker) loadDelegatedPrefix(wl gridtypes.WorkloadID) (prefix delegatedPrefix, err error) {
+	data, err := os.ReadFile(n.delegatedPrefixPath(wl))
+	if err != nil {
+		return prefix, err
+	}
+
+	if err := json.Unmarshal(data, &prefix); err != nil {
+		return prefix, errors.Wrapf(err, "failed to load delegated prefix '%s'", wl)
+	}
+
+	return prefix, nil
+}
+
+func (n *networker) storeDelegatedPrefix(wl gridtypes.WorkloadID, prefix delegatedPrefix) error {
+	data, err := json.Marshal(prefix)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(n.delegatedPrefixPath(wl), data, 0644)
+}
+
+func (n *networker) listDelegatedPrefixes() (map[gridtypes.WorkloadID]delegatedPrefix, error) {
+	entries, err := os.ReadDir(n.delegationDir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make(map[gridtypes.WorkloadID]delegatedPrefix)
+	for _, entry := range entries {
+		wl := gridtypes.WorkloadID(entry.Name())
+		prefix, err := n.loadDelegatedPrefix(wl)
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to load delegated prefix")
+			continue
+		}
+		prefixes[wl] = prefix
+	}
+
+	return prefixes, nil
+}
+
+// delegationPools returns all the prefixes the node can delegate from
+func delegationPools(delegation *pkg.PublicIPv6Delegation) ([]net.IPNet, error) {
+	var pools []net.IPNet
+	for _, prefix := range delegation.Prefixes {
+		pools = append(pools, prefix.IPNet)
+	}
+
+	if delegation.DHCP {
+		prefixes, err := public.DelegatedPrefixes()
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, prefixes...)
+	}
+
+	return pools, nil
+}
+
+// prefixesOverlap checks if one of the prefixes contains the other
+func prefixesOverlap(a, b net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// inPools checks if the prefix is fully inside one of the pools
+func inPools(pools []net.IPNet, prefix net.IPNet) bool {
+	size, _ := prefix.Mask.Size()
+	for _, pool := range pools {
+		ones, _ := pool.Mask.Size()
+		if ones <= size && pool.Contains(prefix.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allocatePrefix returns the first prefix of the given size in the pools
+// that does not overlap with any of the used prefixes. The used prefixes can
+// have a different size if the size of the delegated prefixes was changed.
+func allocatePrefix(pools []net.IPNet, size int, used []net.IPNet) (net.IPNet, error) {
+	mask := net.CIDRMask(size, 128)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(128-size))
+	for _, pool := range pools {
+		ones, bits := pool.Mask.Size()
+		if bits != 128 || ones > size {
+			continue
+		}
+
+		value := new(big.Int).SetBytes(pool.IP.Mask(pool.Mask).To16())
+		end := new(big.Int).Add(value, new(big.Int).Lsh(big.NewInt(1), uint(128-ones)))
+
+		// each overlapping used prefix is skipped as a whole, so at most
+		// len(used)+1 prefixes of each pool are checked
+	next:
+		for value.Cmp(end) < 0 {
+			ip := make(net.IP, net.IPv6len)
+			value.FillBytes(ip)
+
+			prefix := net.IPNet{IP: ip, Mask: mask}
+			for _, u := range used {
+				if !prefixesOverlap(prefix, u) {
+					continue
+				}
+
+				usedOnes, _ := u.Mask.Size()
+				usedEnd := new(big.Int).SetBytes(u.IP.Mask(u.Mask).To16())
+				usedEnd.Add(usedEnd, new(big.Int).Lsh(big.NewInt(1), uint(128-usedOnes)))
+
+				value.Add(value, step)
+				if usedEnd.Cmp(value) > 0 {
+					value.Set(usedEnd)
+				}
+				continue next
+			}
+
+			return prefix, nil
+		}
+	}
+
+	return net.IPNet{}, fmt.Errorf("no free ipv6 prefix to delegate")
+}
+
+// DelegatePrefix implements pkg.Networker interface
+func (n *networker) DelegatePrefix(wl gridtypes.WorkloadID, nexthop net.IP) (net.IPNet, error) {
+	if nexthop.To16() == nil || nexthop.To4() != nil {
+		return net.IPNet{}, fmt.Errorf("invalid delegated prefix next hop '%s' must be an ipv6", nexthop)
+	}
+
+	n.delegationLock.Lock()
+	defer n.delegationLock.Unlock()
+
+	current, err := n.loadDelegatedPrefix(wl)
+	if err != nil && !os.IsNotExist(err) {
+		return net.IPNet{}, err
+	}
+	exists := err == nil
+
+	cfg, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig || (err == nil && (cfg.Delegation == nil || cfg.Delegation.IsEmpty())) {
+		return net.IPNet{}, fmt.Errorf("node does not support ipv6 prefix delegation")
+	} else if err != nil {
+		return net.IPNet{}, errors.Wrap(err, "failed to load public config")
+	}
+
+	pools, err := delegationPools(cfg.Delegation)
+	if err != nil {
+		return net.IPNet{}, errors.Wrap(err, "failed to get delegated prefixes")
+	}
+
+	if exists && inPools(pools, current.Prefix.IPNet) {
+		current.NextHop = nexthop
+		if err := n.storeDelegatedPrefix(wl, current); err != nil {
+			return net.IPNet{}, errors.Wrap(err, "failed to store delegated prefix")
+		}
+
+		return current.Prefix.IPNet, public.SetPrefixRoute(current.Prefix.IPNet, nexthop)
+	} else if exists {
+		// the pool of the prefix was removed, a new prefix is delegated
+		log.Info().Stringer("workload", wl).Str("prefix", current.Prefix.String()).Msg("delegated prefix is not in the node prefixes anymore")
+		if err := public.RemovePrefixRoute(current.Prefix.IPNet); err != nil {
+			return net.IPNet{}, errors.Wrapf(err, "failed to remove delegated prefix '%s' route", current.Prefix.String())
+		}
+
+		if err := os.Remove(n.delegatedPrefixPath(wl)); err != nil {
+			return net.IPNet{}, errors.Wrap(err, "failed to remove delegated prefix")
+		}
+	}
+
+	prefixes, err := n.listDelegatedPrefixes()
+	if err != nil {
+		return net.IPNet{}, errors.Wrap(err, "failed to list delegated prefixes")
+	}
+
+	used := make([]net.IPNet, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		used = append(used, prefix.Prefix.IPNet)
+	}
+
+	prefix, err := allocatePrefix(pools, cfg.Delegation.PrefixSize(), used)
+	if err != nil {
+		return net.IPNet{}, err
+	}
+
+	if err := public.SetPrefixRoute(prefix, nexthop); err != nil {
+		return net.IPNet{}, errors.Wrapf(err, "failed to route delegated prefix '%s'", prefix.String())
+	}
+
+	if err := n.storeDelegatedPrefix(wl, delegatedPrefix{Prefix: gridtypes.NewIPNet(prefix), NextHop: nexthop}); err != nil {
+		return net.IPNet{}, errors.Wrap(err, "failed to store delegated prefix")
+	}
+
+	log.Info().Stringer("workload", wl).Str("prefix", prefix.String()).IPAddr("nexthop", nexthop).Msg("delegated ipv6 prefix")
+	return prefix, nil
+}
+
+// ReleasePrefix implements pkg.Networker interface
+func (n *networker) ReleasePrefix(wl gridtypes.WorkloadID) error {
+	n.delegationLock.Lock()
+	defer n.delegationLock.Unlock()
+
+	prefix, err := n.loadDelegatedPrefix(wl)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if public.HasPublicSetup() {
+		if err := public.RemovePrefixRoute(prefix.Prefix.IPNet); err != nil {
+			return errors.Wrapf(err, "failed to remove delegated prefix '%s' route", prefix.Prefix.String())
+		}
+	}
+
+	return os.Remove(n.delegatedPrefixPath(wl))
+}
+
+// applyDelegation starts the prefix delegation client if needed and routes
+// all the delegated prefixes again. It's a no-op if the node has no public
+// setup
+func (n *networker) applyDelegation(delegation *pkg.PublicIPv6Delegation) error {
+	if !public.HasPublicSetup() {
+		return nil
+	}
+
+	if err := public.EnsurePrefixDelegation(delegation != nil && delegation.DHCP); err != nil {
+		return errors.Wrap(err, "failed to set dhcp prefix delegation")
+	}
+
+	n.delegationLock.Lock()
+	defer n.delegationLock.Unlock()
+
+	prefixes, err := n.listDelegatedPrefixes()
+	if err != nil {
+		return errors.Wrap(err, "failed to list delegated prefixes")
+	}
+
+	// prefixes learned over dhcp can change at any time, so only the prefixes
+	// of the configured pools can be checked
+	var pools []net.IPNet
+	dhcp := delegation != nil && delegation.DHCP
+	if delegation != nil {
+		for _, prefix := range delegation.Prefixes {
+			pools = append(pools, prefix.IPNet)
+		}
+	}
+
+	for wl, prefix := range prefixes {
+		if !dhcp && !inPools(pools, prefix.Prefix.IPNet) {
+			// the pool was removed, the workload gets a new prefix (if any)
+			// the next time it's deployed
+			log.Info().Stringer("workload", wl).Str("prefix", prefix.Prefix.String()).Msg("stop routing delegated prefix that is not in the node prefixes")
+			if err := public.RemovePrefixRoute(prefix.Prefix.IPNet); err != nil {
+				log.Error().Err(err).Stringer("workload", wl).Msg("failed to remove delegated prefix route")
+			}
+			continue
+		}
+
+		if err := public.SetPrefixRoute(prefix.Prefix.IPNet, prefix.NextHop); err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to route delegated prefix")
+		}
+	}
+
+	return nil
+}
+
+// SetPublicIPv6Delegation implements pkg.Networker interface
+func (n *networker) SetPublicIPv6Delegation(delegation pkg.PublicIPv6Delegation) error {
+	if err := delegation.Valid(); err != nil {
+		return err
+	}
+
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig || (err == nil && current.IsEmpty()) {
+		return fmt.Errorf("ipv6 prefix delegation requires a public config")
+	} else if err != nil {
+		return errors.Wrap(err, "failed to load current public configuration")
+	}
+
+	current.Delegation = &delegation
+	if delegation.IsEmpty() {
+		current.Delegation = nil
+	}
+
+	if err := public.SavePublicConfig(*current); err != nil {
+		return errors.Wrap(err, "failed to store public config")
+	}
+
+	return n.applyDelegation(current.Delegation)
+}
+
+// GetPublicIPv6Delegation implements pkg.Networker interface
+func (n *networker) GetPublicIPv6Delegation() (pkg.PublicIPv6Delegation, error) {
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig {
+		return pkg.PublicIPv6Delegation{}, nil
+	} else if err != nil {
+		return pkg.PublicIPv6Delegation{}, err
+	}
+
+	if current.Delegation == nil {
+		return pkg.PublicIPv6Delegation{}, nil
+	}
+
+	return *current.Delegation, nil
+}
@@ -0,0 +1,107 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+func TestAllocatePrefix(t *testing.T) {
+	require := require.New(t)
+
+	_, pool, err := net.ParseCIDR("2001:db8:100::/62")
+	require.NoError(err)
+	pools := []net.IPNet{*pool}
+
+	var used []net.IPNet
+	for _, expected := range []string{"2001:db8:100::/64", "2001:db8:100:1::/64", "2001:db8:100:2::/64", "2001:db8:100:3::/64"} {
+		prefix, err := allocatePrefix(pools, 64, used)
+		require.NoError(err)
+		require.Equal(expected, prefix.String())
+		used = append(used, prefix)
+	}
+
+	// the pool is full
+	_, err = allocatePrefix(pools, 64, used)
+	require.Error(err)
+
+	// released prefixes are allocated again
+	used = append(used[:1], used[2:]...)
+	prefix, err := allocatePrefix(pools, 64, used)
+	require.NoError(err)
+	require.Equal("2001:db8:100:1::/64", prefix.String())
+
+	// pools smaller than the prefix size are skipped
+	_, err = allocatePrefix(pools, 60, nil)
+	require.Error(err)
+}
+
+func TestAllocatePrefixSizeChanged(t *testing.T) {
+	require := require.New(t)
+
+	_, pool, err := net.ParseCIDR("2001:db8:100::/60")
+	require.NoError(err)
+	pools := []net.IPNet{*pool}
+
+	parse := func(value string) net.IPNet {
+		_, prefix, err := net.ParseCIDR(value)
+		require.NoError(err)
+		return *prefix
+	}
+
+	// bigger prefixes delegated before the size was decreased
+	used := []net.IPNet{parse("2001:db8:100::/62"), parse("2001:db8:100:8::/62")}
+	prefix, err := allocatePrefix(pools, 64, used)
+	require.NoError(err)
+	require.Equal("2001:db8:100:4::/64", prefix.String())
+
+	// smaller prefixes delegated before the size was increased
+	used = []net.IPNet{parse("2001:db8:100::/64"), parse("2001:db8:100:5::/64")}
+	prefix, err = allocatePrefix(pools, 62, used)
+	require.NoError(err)
+	require.Equal("2001:db8:100:8::/62", prefix.String())
+}
+
+func TestInPools(t *testing.T) {
+	require := require.New(t)
+
+	_, pool, err := net.ParseCIDR("2001:db8:100::/56")
+	require.NoError(err)
+	pools := []net.IPNet{*pool}
+
+	_, prefix, err := net.ParseCIDR("2001:db8:100:1::/64")
+	require.NoError(err)
+	require.True(inPools(pools, *prefix))
+
+	_, prefix, err = net.ParseCIDR("2001:db8:200::/64")
+	require.NoError(err)
+	require.False(inPools(pools, *prefix))
+
+	// bigger than the pool
+	_, prefix, err = net.ParseCIDR("2001:db8::/48")
+	require.NoError(err)
+	require.False(inPools(pools, *prefix))
+
+	require.False(inPools(nil, *prefix))
+}
+
+func TestPublicIPv6DelegationValid(t *testing.T) {
+	require := require.New(t)
+
+	delegation := pkg.PublicIPv6Delegation{
+		Prefixes: []gridtypes.IPNet{gridtypes.MustParseIPNet("2001:db8:100::/56")},
+	}
+	require.NoError(delegation.Valid())
+	require.Equal(64, delegation.PrefixSize())
+
+	delegation.Size = 48
+	require.Error(delegation.Valid())
+
+	delegation = pkg.PublicIPv6Delegation{
+		Prefixes: []gridtypes.IPNet{gridtypes.MustParseIPNet("185.69.166.0/24")},
+	}
+	require.Error(delegation.Valid())
+}
@@ -0,0 +1,165 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/public"
+	"golang.zx2c4.com/wireguard/wgctrl"
+)
+
+const (
+	// diagnosticTimeout is the max time a diagnostic can run
+	diagnosticTimeout = 30 * time.Second
+)
+
+// diagnosticCommands returns the commands to run for the diagnostic
+func diagnosticCommands(diagnostic pkg.NetworkDiagnostic) [][]string {
+	switch diagnostic.Type {
+	case pkg.DiagnosticRoutes:
+		return [][]string{
+			{"ip", "-4", "route", "show", "table", "all"},
+			{"ip", "-6", "route", "show", "table", "all"},
+		}
+	case pkg.DiagnosticAddresses:
+		return [][]string{{"ip", "address", "show"}}
+	case pkg.DiagnosticNeighbors:
+		return [][]string{{"ip", "neighbor", "show"}}
+	case pkg.DiagnosticRuleset:
+		return [][]string{{"nft", "list", "ruleset"}}
+	case pkg.DiagnosticPing:
+		return [][]string{{"ping", "-c", "4", "-W", "2", diagnostic.Target}}
+	case pkg.DiagnosticTraceroute:
+		return [][]string{{"traceroute", "-n", "-q", "1", "-w", "1", "-m", "20", diagnostic.Target}}
+	}
+
+	return nil
+}
+
+// diagnosticNamespace checks that the diagnostic namespace is one of the
+// namespaces managed by networkd
+func (n *networker) diagnosticNamespace(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+
+	if name != n.ndmz.Namespace() && name != public.PublicNamespace && !strings.HasPrefix(name, "n-") {
+		return fmt.Errorf("diagnostics are not allowed in namespace '%s'", name)
+	}
+
+	if !namespace.Exists(name) {
+		return fmt.Errorf("namespace '%s' does not exist", name)
+	}
+
+	return nil
+}
+
+// Diagnose runs one of the fixed network diagnostics inside a network
+// namespace and returns its output
+func (n *networker) Diagnose(diagnostic pkg.NetworkDiagnostic) (string, error) {
+	if err := diagnostic.Valid(); err != nil {
+		return "", err
+	}
+
+	if err := n.diagnosticNamespace(diagnostic.Namespace); err != nil {
+		return "", err
+	}
+
+	log.Info().
+		Str("namespace", diagnostic.Namespace).
+		Str("type", string(diagnostic.Type)).
+		Str("target", diagnostic.Target).
+		Msg("running network diagnostic")
+
+	if diagnostic.Type == pkg.DiagnosticWireguard {
+		return wireguardDiagnostic(diagnostic.Namespace)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticTimeout)
+	defer cancel()
+
+	var buf strings.Builder
+	for _, args := range diagnosticCommands(diagnostic) {
+		if len(diagnostic.Namespace) != 0 {
+			args = append([]string{"ip", "netns", "exec", diagnostic.Namespace}, args...)
+		}
+
+		output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+		buf.Write(output)
+		// failures (like a failed ping) are part of the diagnostic output
+		if err != nil {
+			fmt.Fprintf(&buf, "command failed: %s\n", err)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// wireguardDiagnostic renders the status of all the wireguard interfaces of
+// the namespace. Private keys are never included
+func wireguardDiagnostic(name string) (string, error) {
+	var buf strings.Builder
+	render := func(_ ns.NetNS) error {
+		client, err := wgctrl.New()
+		if err != nil {
+			return errors.Wrap(err, "failed to open wireguard client")
+		}
+		defer client.Close()
+
+		devices, err := client.Devices()
+		if err != nil {
+			return errors.Wrap(err, "failed to list wireguard devices")
+		}
+
+		for _, device := range devices {
+			fmt.Fprintf(&buf, "interface: %s\n", device.Name)
+			fmt.Fprintf(&buf, "  public key: %s\n", device.PublicKey)
+			fmt.Fprintf(&buf, "  listening port: %d\n", device.ListenPort)
+
+			for _, peer := range device.Peers {
+				fmt.Fprintf(&buf, "\npeer: %s\n", peer.PublicKey)
+				if peer.Endpoint != nil {
+					fmt.Fprintf(&buf, "  endpoint: %s\n", peer.Endpoint)
+				}
+
+				ips := make([]string, 0, len(peer.AllowedIPs))
+				for _, ip := range peer.AllowedIPs {
+					ips = append(ips, ip.String())
+				}
+				fmt.Fprintf(&buf, "  allowed ips: %s\n", strings.Join(ips, ", "))
+
+				if !peer.LastHandshakeTime.IsZero() {
+					fmt.Fprintf(&buf, "  latest handshake: %s\n", peer.LastHandshakeTime.UTC().Format(time.RFC3339))
+				}
+				fmt.Fprintf(&buf, "  transfer: %d B received, %d B sent\n", peer.ReceiveBytes, peer.TransmitBytes)
+			}
+			buf.WriteString("\n")
+		}
+
+		return nil
+	}
+
+	if len(name) == 0 {
+		return buf.String(), render(nil)
+	}
+
+	netNS, err := namespace.GetByName(name)
+	if err != nil {
+		return "", err
+	}
+	defer netNS.Close()
+
+	if err := netNS.Do(render); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
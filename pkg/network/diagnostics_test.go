@@ -0,0 +1,32 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestDiagnosticCommands(t *testing.T) {
+	require := require.New(t)
+
+	require.Len(diagnosticCommands(pkg.NetworkDiagnostic{Type: pkg.DiagnosticRoutes}), 2)
+
+	cmds := diagnosticCommands(pkg.NetworkDiagnostic{Type: pkg.DiagnosticPing, Target: "10.20.2.1"})
+	require.Equal([][]string{{"ping", "-c", "4", "-W", "2", "10.20.2.1"}}, cmds)
+
+	// wireguard does not run a command
+	require.Empty(diagnosticCommands(pkg.NetworkDiagnostic{Type: pkg.DiagnosticWireguard}))
+
+	// targets are only accepted by ping and traceroute
+	for _, diagnostic := range []pkg.NetworkDiagnostic{
+		{Type: pkg.DiagnosticPing, Target: "-f"},
+		{Type: pkg.DiagnosticRoutes, Target: "10.20.2.1"},
+		{Type: "shell"},
+	} {
+		require.Error(diagnostic.Valid())
+	}
+
+	valid := pkg.NetworkDiagnostic{Namespace: "n-test", Type: pkg.DiagnosticRuleset}
+	require.NoError(valid.Valid())
+}
@@ -0,0 +1,200 @@
+package flowlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// nfgenmsgLen is the size of the netfilter header of the messages
+	nfgenmsgLen = 4
+
+	// ctDestroy is the message type of the conntrack destroy events
+	ctDestroy = unix.NFNL_SUBSYS_CTNETLINK<<8 | nl.IPCTNL_MSG_CT_DELETE
+)
+
+func protocolName(proto uint8) string {
+	switch proto {
+	case unix.IPPROTO_TCP:
+		return "tcp"
+	case unix.IPPROTO_UDP:
+		return "udp"
+	case unix.IPPROTO_ICMP:
+		return "icmp"
+	case unix.IPPROTO_ICMPV6:
+		return "icmpv6"
+	default:
+		return strconv.Itoa(int(proto))
+	}
+}
+
+func parseAttrs(data []byte) (map[uint16][]byte, error) {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[uint16][]byte, len(attrs))
+	for _, attr := range attrs {
+		values[attr.Attr.Type&nl.NLA_TYPE_MASK] = attr.Value
+	}
+
+	return values, nil
+}
+
+func be64(data []byte) uint64 {
+	if len(data) < 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+func be32(data []byte) uint32 {
+	if len(data) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(data)
+}
+
+func be16(data []byte) uint16 {
+	if len(data) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(data)
+}
+
+// parseTuple fills the flow addresses, ports and protocol from the original
+// direction tuple of the connection
+func parseTuple(data []byte, flow *pkg.FlowLog) error {
+	tuple, err := parseAttrs(data)
+	if err != nil {
+		return err
+	}
+
+	ips, err := parseAttrs(tuple[nl.CTA_TUPLE_IP])
+	if err != nil {
+		return err
+	}
+
+	if src, ok := ips[nl.CTA_IP_V4_SRC]; ok {
+		flow.SrcIP, flow.DstIP = net.IP(src), net.IP(ips[nl.CTA_IP_V4_DST])
+	} else {
+		flow.SrcIP, flow.DstIP = net.IP(ips[nl.CTA_IP_V6_SRC]), net.IP(ips[nl.CTA_IP_V6_DST])
+	}
+
+	proto, err := parseAttrs(tuple[nl.CTA_TUPLE_PROTO])
+	if err != nil {
+		return err
+	}
+
+	if num := proto[nl.CTA_PROTO_NUM]; len(num) != 0 {
+		flow.Protocol = protocolName(num[0])
+	}
+	flow.SrcPort = be16(proto[nl.CTA_PROTO_SRC_PORT])
+	flow.DstPort = be16(proto[nl.CTA_PROTO_DST_PORT])
+
+	return nil
+}
+
+func parseBytes(data []byte) (uint64, error) {
+	counters, err := parseAttrs(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return be64(counters[nl.CTA_COUNTERS_BYTES]), nil
+}
+
+// parseFlow parses the payload of a conntrack netlink message. Counters and
+// timestamps are only set by the kernel if conntrack accounting and
+// timestamps are enabled
+func parseFlow(data []byte) (flow pkg.FlowLog, err error) {
+	if len(data) < nfgenmsgLen {
+		return flow, fmt.Errorf("conntrack message is too short")
+	}
+
+	attrs, err := parseAttrs(data[nfgenmsgLen:])
+	if err != nil {
+		return flow, err
+	}
+
+	orig, ok := attrs[nl.CTA_TUPLE_ORIG]
+	if !ok {
+		return flow, fmt.Errorf("conntrack message has no tuple")
+	}
+
+	if err := parseTuple(orig, &flow); err != nil {
+		return flow, err
+	}
+
+	if flow.TxBytes, err = parseBytes(attrs[nl.CTA_COUNTERS_ORIG]); err != nil {
+		return flow, err
+	}
+
+	if flow.RxBytes, err = parseBytes(attrs[nl.CTA_COUNTERS_REPLY]); err != nil {
+		return flow, err
+	}
+
+	timestamps, err := parseAttrs(attrs[nl.CTA_TIMESTAMP])
+	if err != nil {
+		return flow, err
+	}
+
+	if start := be64(timestamps[nl.CTA_TIMESTAMP_START]); start != 0 {
+		flow.Start = time.Unix(0, int64(start)).UTC()
+	}
+
+	if stop := be64(timestamps[nl.CTA_TIMESTAMP_STOP]); stop != 0 {
+		flow.End = time.Unix(0, int64(stop)).UTC()
+	} else {
+		flow.End = time.Now().UTC()
+	}
+
+	return flow, nil
+}
+
+// parseMark returns the mark of the connection in a conntrack netlink message
+func parseMark(data []byte) uint32 {
+	if len(data) < nfgenmsgLen {
+		return 0
+	}
+
+	attrs, err := parseAttrs(data[nfgenmsgLen:])
+	if err != nil {
+		return 0
+	}
+
+	return be32(attrs[nl.CTA_MARK])
+}
+
+// parseMessages returns the flows of the conntrack destroy events of msgs. If
+// mark is not zero only the connections with that mark are returned
+func parseMessages(msgs []syscall.NetlinkMessage, mark uint32) ([]pkg.FlowLog, error) {
+	var flows []pkg.FlowLog
+	for _, msg := range msgs {
+		if msg.Header.Type != ctDestroy {
+			continue
+		}
+
+		if mark != 0 && parseMark(msg.Data) != mark {
+			continue
+		}
+
+		flow, err := parseFlow(msg.Data)
+		if err != nil {
+			return flows, err
+		}
+
+		flows = append(flows, flow)
+	}
+
+	return flows, nil
+}
@@ -0,0 +1,107 @@
+package flowlog
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// attr encodes a netlink attribute
+func attr(typ uint16, value []byte) []byte {
+	length := unix.SizeofRtAttr + len(value)
+	buf := make([]byte, (length+3)&^3)
+	binary.LittleEndian.PutUint16(buf[0:2], uint16(length))
+	binary.LittleEndian.PutUint16(buf[2:4], typ)
+	copy(buf[unix.SizeofRtAttr:], value)
+	return buf
+}
+
+func nested(typ uint16, attrs ...[]byte) []byte {
+	var value []byte
+	for _, a := range attrs {
+		value = append(value, a...)
+	}
+	return attr(typ|nl.NLA_F_NESTED, value)
+}
+
+func u64(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func u16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+func TestParseFlow(t *testing.T) {
+	require := require.New(t)
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Minute)
+
+	data := []byte{unix.AF_INET, 0, 0, 0}
+	data = append(data, nested(nl.CTA_TUPLE_ORIG,
+		nested(nl.CTA_TUPLE_IP,
+			attr(nl.CTA_IP_V4_SRC, net.ParseIP("10.1.2.10").To4()),
+			attr(nl.CTA_IP_V4_DST, net.ParseIP("1.1.1.1").To4()),
+		),
+		nested(nl.CTA_TUPLE_PROTO,
+			attr(nl.CTA_PROTO_NUM, []byte{unix.IPPROTO_TCP}),
+			attr(nl.CTA_PROTO_SRC_PORT, u16(43210)),
+			attr(nl.CTA_PROTO_DST_PORT, u16(443)),
+		),
+	)...)
+	data = append(data, nested(nl.CTA_COUNTERS_ORIG,
+		attr(nl.CTA_COUNTERS_PACKETS, u64(10)),
+		attr(nl.CTA_COUNTERS_BYTES, u64(1000)),
+	)...)
+	data = append(data, nested(nl.CTA_COUNTERS_REPLY,
+		attr(nl.CTA_COUNTERS_PACKETS, u64(20)),
+		attr(nl.CTA_COUNTERS_BYTES, u64(20000)),
+	)...)
+	data = append(data, nested(nl.CTA_TIMESTAMP,
+		attr(nl.CTA_TIMESTAMP_START, u64(uint64(start.UnixNano()))),
+		attr(nl.CTA_TIMESTAMP_STOP, u64(uint64(end.UnixNano()))),
+	)...)
+
+	flows, err := parseMessages([]syscall.NetlinkMessage{
+		{Header: syscall.NlMsghdr{Type: ctDestroy}, Data: data},
+		// other messages are ignored
+		{Header: syscall.NlMsghdr{Type: unix.NFNL_SUBSYS_CTNETLINK << 8}, Data: data},
+	}, 0)
+	require.NoError(err)
+	require.Len(flows, 1)
+
+	// connections without the mark are ignored
+	flows, err = parseMessages([]syscall.NetlinkMessage{
+		{Header: syscall.NlMsghdr{Type: ctDestroy}, Data: data},
+	}, PublicMark)
+	require.NoError(err)
+	require.Empty(flows)
+
+	data = append(data, attr(nl.CTA_MARK, binary.BigEndian.AppendUint32(nil, PublicMark))...)
+	flows, err = parseMessages([]syscall.NetlinkMessage{
+		{Header: syscall.NlMsghdr{Type: ctDestroy}, Data: data},
+	}, PublicMark)
+	require.NoError(err)
+	require.Len(flows, 1)
+
+	flow := flows[0]
+	require.Equal("tcp", flow.Protocol)
+	require.True(net.ParseIP("10.1.2.10").Equal(flow.SrcIP))
+	require.True(net.ParseIP("1.1.1.1").Equal(flow.DstIP))
+	require.Equal(uint16(43210), flow.SrcPort)
+	require.Equal(uint16(443), flow.DstPort)
+	require.Equal(uint64(1000), flow.TxBytes)
+	require.Equal(uint64(20000), flow.RxBytes)
+	require.Equal(start, flow.Start)
+	require.Equal(end, flow.End)
+
+	_, err = parseFlow([]byte{unix.AF_INET, 0, 0, 0})
+	require.Error(err)
+}
@@ -0,0 +1,299 @@
+package flowlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// DefaultRetention is how long flows are kept
+	DefaultRetention = 24 * time.Hour
+
+	// DefaultMaxSize is the max size in bytes of the flows kept per twin
+	DefaultMaxSize = 32 * 1024 * 1024
+
+	// MaxFlows is the max number of flows returned by List
+	MaxFlows = 10000
+
+	bucketLayout = "2006010215"
+	bucketExt    = ".log"
+
+	// flushEvery is how often the buffered flows are written to disk
+	flushEvery = 10 * time.Second
+	// maxPending is the number of buffered flows after which they are
+	// written to disk without waiting for the next flush
+	maxPending = 1000
+)
+
+// Store keeps the flows of each twin for the retention window. Flows are
+// buffered in memory and periodically appended to hourly bucket files, old
+// buckets are removed as a whole. Once the flows of a twin reach the max
+// size new flows of the twin are dropped until old buckets are removed.
+type Store struct {
+	root      string
+	retention time.Duration
+	maxSize   int64
+
+	m         sync.Mutex
+	lastPrune time.Time
+	pending   map[uint32][]pkg.FlowLog
+	count     int
+	// sizes of the stored flows per twin, loaded on first write
+	sizes map[uint32]int64
+}
+
+// NewStore creates a new flows store in root
+func NewStore(root string, retention time.Duration, maxSize int64) (*Store, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create flow logs directory")
+	}
+
+	s := &Store{
+		root:      root,
+		retention: retention,
+		maxSize:   maxSize,
+		pending:   make(map[uint32][]pkg.FlowLog),
+		sizes:     make(map[uint32]int64),
+	}
+
+	go s.flusher()
+
+	return s, nil
+}
+
+// flusher writes the buffered flows to disk every flushEvery
+func (s *Store) flusher() {
+	ticker := time.NewTicker(flushEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.m.Lock()
+		if err := s.flush(); err != nil {
+			log.Error().Err(err).Msg("failed to write flow logs")
+		}
+		s.m.Unlock()
+	}
+}
+
+func (s *Store) twinDir(twin uint32) string {
+	return filepath.Join(s.root, fmt.Sprint(twin))
+}
+
+func bucketOf(t time.Time) string {
+	return t.UTC().Format(bucketLayout) + bucketExt
+}
+
+// Append adds flows to the logs of the twin. Flows are buffered and only
+// written to disk on the next flush.
+func (s *Store) Append(twin uint32, flows ...pkg.FlowLog) error {
+	if len(flows) == 0 {
+		return nil
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.pending[twin] = append(s.pending[twin], flows...)
+	s.count += len(flows)
+	if s.count < maxPending {
+		return nil
+	}
+
+	return s.flush()
+}
+
+// flush writes the buffered flows to disk, must be called with the lock held.
+// Buffered flows are dropped even if they fail to be written.
+func (s *Store) flush() error {
+	now := time.Now()
+	if now.Sub(s.lastPrune) > time.Hour {
+		s.prune(now)
+		s.lastPrune = now
+	}
+
+	pending := s.pending
+	s.pending = make(map[uint32][]pkg.FlowLog)
+	s.count = 0
+
+	var result error
+	for twin, flows := range pending {
+		if err := s.write(twin, now, flows); err != nil {
+			result = err
+		}
+	}
+
+	return result
+}
+
+// twinSize returns the size of the stored flows of the twin
+func (s *Store) twinSize(twin uint32) int64 {
+	if size, ok := s.sizes[twin]; ok {
+		return size
+	}
+
+	var size int64
+	entries, _ := os.ReadDir(s.twinDir(twin))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+	}
+
+	s.sizes[twin] = size
+	return size
+}
+
+// write appends the flows to the bucket of the twin at now. Flows that do
+// not fit in the twin max size are dropped.
+func (s *Store) write(twin uint32, now time.Time, flows []pkg.FlowLog) error {
+	dir := s.twinDir(twin)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	size := s.twinSize(twin)
+	dropped := 0
+	defer func() {
+		s.sizes[twin] = size
+		if dropped > 0 {
+			log.Warn().Uint32("twin", twin).Int("dropped", dropped).Msg("flow logs max size reached, dropping flows")
+		}
+	}()
+
+	file, err := os.OpenFile(filepath.Join(dir, bucketOf(now)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open flow logs bucket")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, flow := range flows {
+		data, err := json.Marshal(flow)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode flow log")
+		}
+
+		data = append(data, '\n')
+		if size+int64(len(data)) > s.maxSize {
+			dropped++
+			continue
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return errors.Wrap(err, "failed to write flow log")
+		}
+		size += int64(len(data))
+	}
+
+	return writer.Flush()
+}
+
+// List returns the flows of the twin that ended after since, sorted by end
+// time. Only the first MaxFlows are returned, the end time of the last flow
+// can be used as since to get the next ones
+func (s *Store) List(twin uint32, since time.Time) ([]pkg.FlowLog, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.flush(); err != nil {
+		log.Error().Err(err).Msg("failed to write flow logs")
+	}
+
+	if oldest := time.Now().Add(-s.retention); since.Before(oldest) {
+		since = oldest
+	}
+
+	dir := s.twinDir(twin)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// flows are stored once they end, so older buckets can't have any
+	// flow that ended after since
+	first := bucketOf(since.Truncate(time.Hour))
+
+	flows := make([]pkg.FlowLog, 0)
+	for _, entry := range entries {
+		if entry.Name() < first {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var flow pkg.FlowLog
+			if err := json.Unmarshal(scanner.Bytes(), &flow); err != nil {
+				log.Debug().Err(err).Str("bucket", entry.Name()).Msg("skipping invalid flow log")
+				continue
+			}
+
+			if flow.End.After(since) {
+				flows = append(flows, flow)
+			}
+		}
+
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrapf(err, "failed to read flow logs bucket '%s'", entry.Name())
+		}
+	}
+
+	sort.SliceStable(flows, func(i, j int) bool {
+		return flows[i].End.Before(flows[j].End)
+	})
+
+	if len(flows) > MaxFlows {
+		flows = flows[:MaxFlows]
+	}
+
+	return flows, nil
+}
+
+// prune removes the buckets that are older than the retention window
+func (s *Store) prune(now time.Time) {
+	oldest := bucketOf(now.Add(-s.retention).Truncate(time.Hour))
+	// sizes are loaded again on next write
+	s.sizes = make(map[uint32]int64)
+
+	twins, err := os.ReadDir(s.root)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list flow logs")
+		return
+	}
+
+	for _, twin := range twins {
+		dir := filepath.Join(s.root, twin.Name())
+		buckets, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, bucket := range buckets {
+			if bucket.Name() < oldest {
+				if err := os.Remove(filepath.Join(dir, bucket.Name())); err != nil {
+					log.Error().Err(err).Str("bucket", bucket.Name()).Msg("failed to remove flow logs bucket")
+				}
+			}
+		}
+
+		// removes the twin directory if empty
+		_ = os.Remove(dir)
+	}
+}
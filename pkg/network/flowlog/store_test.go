@@ -0,0 +1,118 @@
+package flowlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestStore(t *testing.T) {
+	require := require.New(t)
+
+	store, err := NewStore(t.TempDir(), time.Hour, DefaultMaxSize)
+	require.NoError(err)
+
+	now := time.Now().UTC()
+	require.NoError(store.Append(1,
+		pkg.FlowLog{Source: "a", End: now.Add(-2 * time.Minute)},
+		pkg.FlowLog{Source: "b", End: now.Add(-time.Minute)},
+	))
+	require.NoError(store.Append(2, pkg.FlowLog{Source: "c", End: now}))
+
+	flows, err := store.List(1, time.Time{})
+	require.NoError(err)
+	require.Len(flows, 2)
+	require.Equal("a", flows[0].Source)
+	require.Equal("b", flows[1].Source)
+
+	flows, err = store.List(1, now.Add(-90*time.Second))
+	require.NoError(err)
+	require.Len(flows, 1)
+	require.Equal("b", flows[0].Source)
+
+	flows, err = store.List(3, time.Time{})
+	require.NoError(err)
+	require.Empty(flows)
+}
+
+func TestStorePrune(t *testing.T) {
+	require := require.New(t)
+
+	root := t.TempDir()
+	store, err := NewStore(root, time.Hour, DefaultMaxSize)
+	require.NoError(err)
+
+	now := time.Now()
+	dir := filepath.Join(root, "1")
+	require.NoError(os.MkdirAll(dir, 0755))
+
+	old := bucketOf(now.Add(-3 * time.Hour))
+	current := bucketOf(now)
+	for _, bucket := range []string{old, current} {
+		require.NoError(os.WriteFile(filepath.Join(dir, bucket), nil, 0644))
+	}
+
+	store.prune(now)
+
+	_, err = os.Stat(filepath.Join(dir, old))
+	require.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, current))
+	require.NoError(err)
+}
+
+func TestStoreBuffer(t *testing.T) {
+	require := require.New(t)
+
+	root := t.TempDir()
+	store, err := NewStore(root, time.Hour, DefaultMaxSize)
+	require.NoError(err)
+
+	bucket := filepath.Join(root, "1", bucketOf(time.Now()))
+	require.NoError(store.Append(1, pkg.FlowLog{Source: "a", End: time.Now()}))
+	_, err = os.Stat(bucket)
+	require.True(os.IsNotExist(err), "flows are buffered")
+
+	flows := make([]pkg.FlowLog, maxPending)
+	require.NoError(store.Append(1, flows...))
+	_, err = os.Stat(bucket)
+	require.NoError(err, "full buffer is written")
+
+	store.m.Lock()
+	require.Zero(store.count)
+	store.m.Unlock()
+}
+
+func TestStoreMaxSize(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now().UTC()
+	flow := pkg.FlowLog{Source: "a", End: now}
+	data, err := json.Marshal(flow)
+	require.NoError(err)
+	size := int64(len(data) + 1)
+
+	store, err := NewStore(t.TempDir(), time.Hour, 2*size)
+	require.NoError(err)
+
+	require.NoError(store.Append(1, flow, flow, flow))
+	require.NoError(store.Append(2, flow))
+
+	flows, err := store.List(1, time.Time{})
+	require.NoError(err)
+	require.Len(flows, 2, "flows over the max size are dropped")
+
+	// the limit is per twin
+	flows, err = store.List(2, time.Time{})
+	require.NoError(err)
+	require.Len(flows, 1)
+
+	require.NoError(store.Append(1, flow))
+	flows, err = store.List(1, time.Time{})
+	require.NoError(err)
+	require.Len(flows, 2)
+}
@@ -0,0 +1,99 @@
+package flowlog
+
+import (
+	"context"
+	"errors"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/options"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// nfnlgrpConntrackDestroy is the netlink group of the conntrack destroy events
+	nfnlgrpConntrackDestroy = 3
+
+	// PublicMark is the connection mark of the connections to and from the
+	// public internet in the network resources namespaces
+	PublicMark = 0x70
+)
+
+// subscribe enables conntrack accounting and opens a socket that receives the
+// conntrack destroy events of the namespace with the given name. An empty
+// name is the host namespace
+func subscribe(name string) (sock *nl.NetlinkSocket, err error) {
+	open := func() error {
+		if err := options.SetConntrackAccounting(true); err != nil {
+			return err
+		}
+
+		sock, err = nl.Subscribe(unix.NETLINK_NETFILTER, nfnlgrpConntrackDestroy)
+		return err
+	}
+
+	if len(name) == 0 {
+		return sock, open()
+	}
+
+	netNS, err := namespace.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	defer netNS.Close()
+
+	err = netNS.Do(func(_ ns.NetNS) error {
+		return open()
+	})
+
+	return sock, err
+}
+
+// Watch calls handler with every connection that ends in the namespace with
+// the given name (host namespace if empty) until the context is canceled.
+// If mark is not zero only the connections with that mark are reported.
+func Watch(ctx context.Context, name string, mark uint32, handler func(pkg.FlowLog)) error {
+	sock, err := subscribe(name)
+	if err != nil {
+		return err
+	}
+
+	// receive blocks, so we need a timeout to check the context
+	if err := sock.SetReceiveTimeout(&unix.Timeval{Sec: 1}); err != nil {
+		sock.Close()
+		return err
+	}
+
+	go func() {
+		defer sock.Close()
+
+		logger := log.With().Str("namespace", name).Logger()
+		for ctx.Err() == nil {
+			msgs, _, err := sock.Receive()
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			} else if errors.Is(err, unix.ENOBUFS) {
+				// events are dropped by the kernel if we can't keep up
+				logger.Warn().Msg("flow log events overrun, some connections are not logged")
+				continue
+			} else if err != nil {
+				logger.Error().Err(err).Msg("failed to receive conntrack events")
+				return
+			}
+
+			flows, err := parseMessages(msgs, mark)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to parse conntrack event")
+			}
+
+			for _, flow := range flows {
+				handler(flow)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package network
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/network/flowlog"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+)
+
+const (
+	flowLogsDir = "flow-logs"
+
+	// hostFlows is the key of the host namespace watcher, it's where the
+	// (bridged) traffic of the public ips is tracked
+	hostFlows = ""
+)
+
+// pubIPFlows is the owner of a public ip with flow logs enabled
+type pubIPFlows struct {
+	twin   uint32
+	source string
+}
+
+// watchFlows starts logging the flows of the given namespace (with the given
+// mark if not zero) with handler. It's a no-op if the namespace flows are
+// already logged
+func (n *networker) watchFlows(ns string, mark uint32, handler func(pkg.FlowLog)) error {
+	if _, ok := n.flowWatchers[ns]; ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := flowlog.Watch(ctx, ns, mark, handler); err != nil {
+		cancel()
+		return errors.Wrap(err, "failed to watch connections")
+	}
+
+	n.flowWatchers[ns] = cancel
+	return nil
+}
+
+func (n *networker) stopFlows(ns string) {
+	if cancel, ok := n.flowWatchers[ns]; ok {
+		cancel()
+		delete(n.flowWatchers, ns)
+	}
+}
+
+func (n *networker) appendFlow(twin uint32, flow pkg.FlowLog) {
+	if err := n.flows.Append(twin, flow); err != nil {
+		log.Error().Err(err).Uint32("twin", twin).Msg("failed to store flow log")
+	}
+}
+
+// setNRFlowLogs starts (or stops) logging the flows of the network resource
+func (n *networker) setNRFlowLogs(wl gridtypes.WorkloadID, netNR pkg.Network) error {
+	twin, _, _, err := wl.Parts()
+	if err != nil {
+		return err
+	}
+
+	n.flowsLock.Lock()
+	defer n.flowsLock.Unlock()
+
+	ns := n.Namespace(netNR.NetID)
+	if !netNR.FlowLogs {
+		n.stopFlows(ns)
+		return nil
+	}
+
+	// only the connections over the public interface of the network
+	// resource are logged
+	source := wl.String()
+	return n.watchFlows(ns, flowlog.PublicMark, func(flow pkg.FlowLog) {
+		flow.Source = source
+		n.appendFlow(twin, flow)
+	})
+}
+
+// hostFlow handles the flows of the host namespace, only flows of public
+// ips with flow logs enabled are logged
+func (n *networker) hostFlow(flow pkg.FlowLog) {
+	n.flowsLock.Lock()
+	owner, ok := n.pubIPFlows[flow.SrcIP.String()]
+	if !ok {
+		owner, ok = n.pubIPFlows[flow.DstIP.String()]
+	}
+	n.flowsLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	flow.Source = owner.source
+	n.appendFlow(owner.twin, flow)
+}
+
+// SetPubIPFlowLogs implements pkg.Networker interface
+func (n *networker) SetPubIPFlowLogs(wl gridtypes.WorkloadID, ipv4 net.IP, ipv6 net.IP, enabled bool) error {
+	twin, _, _, err := wl.Parts()
+	if err != nil {
+		return err
+	}
+
+	n.flowsLock.Lock()
+	defer n.flowsLock.Unlock()
+
+	source := wl.String()
+	for ip, owner := range n.pubIPFlows {
+		if owner.source == source {
+			delete(n.pubIPFlows, ip)
+		}
+	}
+
+	if enabled {
+		for _, ip := range []net.IP{ipv4, ipv6} {
+			if len(ip) != 0 && !ip.IsUnspecified() {
+				n.pubIPFlows[ip.String()] = pubIPFlows{twin: twin, source: source}
+			}
+		}
+	}
+
+	if len(n.pubIPFlows) == 0 {
+		n.stopFlows(hostFlows)
+		return nil
+	}
+
+	// bridged traffic is only tracked if a ct expression is used in the
+	// bridge family
+	track := nft.NewRule("track connections", nft.CtState(expr.CtStateBitNEW), nft.Counter())
+	if err := nft.EnsureRule("", nftables.TableFamilyBridge, pubIPFilterTable, "prerouting", track); err != nil {
+		return errors.Wrap(err, "failed to enable public ips connection tracking")
+	}
+
+	return n.watchFlows(hostFlows, 0, n.hostFlow)
+}
+
+// FlowLogs implements pkg.Networker interface
+func (n *networker) FlowLogs(twin uint32, since time.Time) ([]pkg.FlowLog, error) {
+	return n.flows.List(twin, since)
+}
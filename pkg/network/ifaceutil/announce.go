@@ -0,0 +1,141 @@
+package ifaceutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	etherTypeVlan = 0x8100
+	etherTypeIPv4 = 0x0800
+	etherTypeARP  = 0x0806
+	etherTypeIPv6 = 0x86dd
+
+	icmpv6NeighborAdvertisement = 136
+)
+
+var (
+	broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	// all nodes multicast (ff02::1) mac
+	allNodesMAC = net.HardwareAddr{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+)
+
+// Announce sends a gratuitous arp (for ipv4) or an unsolicited neighbor
+// advertisement (for ipv6) out of iface, so the neighbors learn that ip is
+// now reachable over mac. If vlan is not zero the frame is tagged with it.
+func Announce(iface string, vlan uint16, mac net.HardwareAddr, ip net.IP) error {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get link '%s'", iface)
+	}
+
+	var frame []byte
+	var dst net.HardwareAddr
+	if ip.To4() != nil {
+		frame, dst = garpFrame(vlan, mac, ip), broadcastMAC
+	} else if ip.To16() != nil {
+		frame, dst = naFrame(vlan, mac, ip), allNodesMAC
+	} else {
+		return fmt.Errorf("invalid ip '%s'", ip)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to open packet socket")
+	}
+	defer unix.Close(fd)
+
+	addr := unix.SockaddrLinklayer{
+		Ifindex: link.Attrs().Index,
+		Halen:   uint8(len(dst)),
+	}
+	copy(addr.Addr[:], dst)
+
+	if err := unix.Sendto(fd, frame, 0, &addr); err != nil {
+		return errors.Wrapf(err, "failed to announce '%s' on '%s'", ip, iface)
+	}
+
+	return nil
+}
+
+// ethernet builds the ethernet header of a frame
+func ethernet(dst, src net.HardwareAddr, vlan uint16, typ uint16) []byte {
+	frame := make([]byte, 0, 18)
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	if vlan != 0 {
+		frame = binary.BigEndian.AppendUint16(frame, etherTypeVlan)
+		frame = binary.BigEndian.AppendUint16(frame, vlan&0x0fff)
+	}
+
+	return binary.BigEndian.AppendUint16(frame, typ)
+}
+
+// garpFrame builds a gratuitous arp request frame for ip from mac
+func garpFrame(vlan uint16, mac net.HardwareAddr, ip net.IP) []byte {
+	frame := ethernet(broadcastMAC, mac, vlan, etherTypeARP)
+	frame = binary.BigEndian.AppendUint16(frame, 1) // hardware type ethernet
+	frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv4)
+	frame = append(frame, 6, 4)
+	frame = binary.BigEndian.AppendUint16(frame, 1) // request
+	frame = append(frame, mac...)
+	frame = append(frame, ip.To4()...)
+	frame = append(frame, make([]byte, 6)...)
+	return append(frame, ip.To4()...)
+}
+
+// naFrame builds an unsolicited neighbor advertisement frame for ip from
+// mac, sent to all nodes
+func naFrame(vlan uint16, mac net.HardwareAddr, ip net.IP) []byte {
+	src := ip.To16()
+	dst := net.ParseIP("ff02::1").To16()
+
+	icmp := []byte{icmpv6NeighborAdvertisement, 0, 0, 0}
+	// override flag
+	icmp = append(icmp, 0x20, 0, 0, 0)
+	icmp = append(icmp, src...)
+	// target link-layer address option
+	icmp = append(icmp, 2, 1)
+	icmp = append(icmp, mac...)
+
+	binary.BigEndian.PutUint16(icmp[2:], icmpv6Checksum(src, dst, icmp))
+
+	frame := ethernet(allNodesMAC, mac, vlan, etherTypeIPv6)
+	frame = append(frame, 0x60, 0, 0, 0)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(icmp)))
+	frame = append(frame, unix.IPPROTO_ICMPV6, 255)
+	frame = append(frame, src...)
+	frame = append(frame, dst...)
+	return append(frame, icmp...)
+}
+
+// icmpv6Checksum computes the checksum of the icmpv6 message including the
+// ipv6 pseudo header
+func icmpv6Checksum(src, dst net.IP, msg []byte) uint16 {
+	pseudo := make([]byte, 0, 40+len(msg))
+	pseudo = append(pseudo, src.To16()...)
+	pseudo = append(pseudo, dst.To16()...)
+	pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(msg)))
+	pseudo = append(pseudo, 0, 0, 0, unix.IPPROTO_ICMPV6)
+	pseudo = append(pseudo, msg...)
+
+	var sum uint32
+	for i := 0; i+1 < len(pseudo); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(pseudo[i:]))
+	}
+
+	if len(pseudo)%2 == 1 {
+		sum += uint32(pseudo[len(pseudo)-1]) << 8
+	}
+
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+
+	return ^uint16(sum)
+}
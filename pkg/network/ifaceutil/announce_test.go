@@ -0,0 +1,52 @@
+package ifaceutil
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGarpFrame(t *testing.T) {
+	require := require.New(t)
+
+	mac, err := net.ParseMAC("de:ad:be:ef:00:01")
+	require.NoError(err)
+	ip := net.ParseIP("185.69.166.10")
+
+	frame := garpFrame(0, mac, ip)
+	require.Len(frame, 42)
+	require.Equal([]byte(broadcastMAC), frame[0:6])
+	require.Equal([]byte(mac), frame[6:12])
+	require.Equal(uint16(etherTypeARP), binary.BigEndian.Uint16(frame[12:]))
+	// sender and target ip are both the announced ip
+	require.Equal([]byte(ip.To4()), frame[28:32])
+	require.Equal([]byte(ip.To4()), frame[38:42])
+
+	frame = garpFrame(100, mac, ip)
+	require.Len(frame, 46)
+	require.Equal(uint16(etherTypeVlan), binary.BigEndian.Uint16(frame[12:]))
+	require.Equal(uint16(100), binary.BigEndian.Uint16(frame[14:]))
+}
+
+func TestNAFrame(t *testing.T) {
+	require := require.New(t)
+
+	mac, err := net.ParseMAC("de:ad:be:ef:00:01")
+	require.NoError(err)
+	ip := net.ParseIP("2a02:1802:5e::1")
+
+	frame := naFrame(0, mac, ip)
+	// ethernet + ipv6 header + advertisement with link-layer option
+	require.Len(frame, 14+40+32)
+	require.Equal([]byte(allNodesMAC), frame[0:6])
+
+	icmp := frame[54:]
+	require.Equal(byte(icmpv6NeighborAdvertisement), icmp[0])
+	require.Equal([]byte(ip.To16()), icmp[8:24])
+	require.Equal([]byte(mac), icmp[26:32])
+
+	// checksum over the message including the checksum is zero
+	require.Zero(icmpv6Checksum(frame[22:38], frame[38:54], icmp))
+}
@@ -0,0 +1,57 @@
+package ifaceutil
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+// EnsureBond makes sure a LACP (802.3ad) bond with given name exists with
+// the given nics as slaves
+func EnsureBond(name string, nics []netlink.Link) (*netlink.Bond, error) {
+	link, err := netlink.LinkByName(name)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		bond := netlink.NewLinkBond(netlink.LinkAttrs{Name: name})
+		bond.Mode = netlink.BOND_MODE_802_3AD
+		bond.LacpRate = netlink.BOND_LACP_RATE_FAST
+		bond.XmitHashPolicy = netlink.BOND_XMIT_HASH_POLICY_LAYER3_4
+		bond.Miimon = 100
+
+		if err := netlink.LinkAdd(bond); err != nil {
+			return nil, errors.Wrapf(err, "failed to create bond '%s'", name)
+		}
+
+		link, err = netlink.LinkByName(name)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get bond '%s'", name)
+	}
+
+	bond, ok := link.(*netlink.Bond)
+	if !ok {
+		return nil, fmt.Errorf("link '%s' is not a bond", name)
+	}
+
+	for _, nic := range nics {
+		if nic.Attrs().MasterIndex == bond.Index {
+			continue
+		}
+
+		// nics must be down to be enslaved
+		if err := netlink.LinkSetDown(nic); err != nil {
+			return nil, errors.Wrapf(err, "failed to set nic '%s' down", nic.Attrs().Name)
+		}
+
+		if err := netlink.LinkSetBondSlave(nic, bond); err != nil {
+			return nil, errors.Wrapf(err, "failed to add nic '%s' to bond '%s'", nic.Attrs().Name, name)
+		}
+
+		if err := netlink.LinkSetUp(nic); err != nil {
+			return nil, errors.Wrapf(err, "failed to set nic '%s' up", nic.Attrs().Name)
+		}
+	}
+
+	return bond, netlink.LinkSetUp(bond)
+}
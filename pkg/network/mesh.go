@@ -0,0 +1,108 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/nr"
+)
+
+// meshDiscoveryInterval is how often the mesh peers of the network
+// resources are discovered
+const meshDiscoveryInterval = 5 * time.Minute
+
+// setMesh (re)configures the mesh of the network resource. The mesh is
+// also updated by the discovery in the background, so this can't run at
+// the same time.
+func (n *networker) setMesh(netr *nr.NetResource) error {
+	n.meshLock.Lock()
+	defer n.meshLock.Unlock()
+
+	return netr.SetMesh()
+}
+
+// meshNetworks returns the ids of the networks in mesh mode
+func (n *networker) meshNetworks() ([]zos.NetID, error) {
+	links, err := os.ReadDir(n.linkDir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[zos.NetID]struct{})
+	var ids []zos.NetID
+	for _, link := range links {
+		if link.IsDir() {
+			continue
+		}
+
+		sym, err := os.Readlink(filepath.Join(n.linkDir, link.Name()))
+		if err != nil {
+			continue
+		}
+
+		netID := zos.NetID(filepath.Base(sym))
+		if _, ok := seen[netID]; ok {
+			continue
+		}
+		seen[netID] = struct{}{}
+
+		network, err := n.networkOf(netID)
+		if err != nil || network.Mesh == nil {
+			continue
+		}
+
+		ids = append(ids, netID)
+	}
+
+	return ids, nil
+}
+
+// discoverMesh discovers the mesh peers of a network and adds them to
+// its mesh
+func (n *networker) discoverMesh(netID zos.NetID) error {
+	network, err := n.networkOf(netID)
+	if err != nil {
+		return err
+	}
+
+	netr := nr.New(network, n.myceliumKeyDir)
+	if err := netr.DiscoverMesh(); err != nil {
+		return err
+	}
+
+	n.meshLock.Lock()
+	defer n.meshLock.Unlock()
+
+	// the network can be updated or deleted while its peers are discovered
+	network, err = n.networkOf(netID)
+	if os.IsNotExist(err) || (err == nil && network.Mesh == nil) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return nr.New(network, n.myceliumKeyDir).SetMesh()
+}
+
+// meshDiscovery discovers the mesh peers of all the networks in mesh mode
+// every meshDiscoveryInterval
+func (n *networker) meshDiscovery() {
+	for {
+		time.Sleep(meshDiscoveryInterval)
+
+		ids, err := n.meshNetworks()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list mesh networks")
+			continue
+		}
+
+		for _, netID := range ids {
+			if err := n.discoverMesh(netID); err != nil {
+				log.Error().Err(err).Stringer("network", netID).Msg("failed to discover mesh peers")
+			}
+		}
+	}
+}
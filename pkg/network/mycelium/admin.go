@@ -0,0 +1,113 @@
+package mycelium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+)
+
+const (
+	// adminAddr is the default address of the mycelium admin api
+	adminAddr  = "127.0.0.1:8989"
+	adminPeers = "/api/v1/admin/peers"
+)
+
+// adminEndpoint is a peer endpoint as returned by the admin api
+type adminEndpoint struct {
+	Proto         string `json:"proto"`
+	SocketAddress string `json:"socketAddress"`
+}
+
+func (e adminEndpoint) String() string {
+	return fmt.Sprintf("%s://%s", e.Proto, e.SocketAddress)
+}
+
+// adminPeer is the stats of a peer as returned by the admin api
+type adminPeer struct {
+	Endpoint        adminEndpoint `json:"endpoint"`
+	Type            string        `json:"type"`
+	ConnectionState string        `json:"connectionState"`
+	TxBytes         uint64        `json:"txBytes"`
+	RxBytes         uint64        `json:"rxBytes"`
+}
+
+// dial opens a connection from inside the namespace mycelium runs in
+func (s *MyceliumServer) dial(ctx context.Context, network, address string) (conn net.Conn, err error) {
+	netNS, err := namespace.GetByName(s.ns)
+	if err != nil {
+		return nil, err
+	}
+	defer netNS.Close()
+
+	var dialer net.Dialer
+	err = netNS.Do(func(_ ns.NetNS) error {
+		conn, err = dialer.DialContext(ctx, network, address)
+		return err
+	})
+
+	return conn, err
+}
+
+func (s *MyceliumServer) admin(ctx context.Context, method, path string, input, output interface{}) error {
+	var body bytes.Buffer
+	if input != nil {
+		if err := json.NewEncoder(&body).Encode(input); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, "http://"+adminAddr+path, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{
+		Transport: &http.Transport{DialContext: s.dial},
+		Timeout:   10 * time.Second,
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to call mycelium admin api")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("mycelium admin api returned '%s'", response.Status)
+	}
+
+	if output == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(output)
+}
+
+// adminListPeers lists the peers mycelium is connected (or connecting) to
+func (s *MyceliumServer) adminListPeers(ctx context.Context) (peers []adminPeer, err error) {
+	return peers, s.admin(ctx, http.MethodGet, adminPeers, nil, &peers)
+}
+
+// adminAddPeer makes mycelium connect to the given peer
+func (s *MyceliumServer) adminAddPeer(ctx context.Context, endpoint string) error {
+	input := struct {
+		Endpoint string `json:"endpoint"`
+	}{Endpoint: endpoint}
+
+	return s.admin(ctx, http.MethodPost, adminPeers, &input, nil)
+}
+
+// adminRemovePeer disconnects mycelium from the given peer
+func (s *MyceliumServer) adminRemovePeer(ctx context.Context, endpoint string) error {
+	return s.admin(ctx, http.MethodDelete, adminPeers+"/"+url.PathEscape(endpoint), nil, nil)
+}
@@ -32,6 +32,10 @@ const (
 type MyceliumServer struct {
 	cfg *NodeConfig
 	ns  string
+
+	// filter is used to filter the public peers list
+	filter []Filter
+	peers  peers
 }
 
 type MyceliumInspection struct {
@@ -136,6 +140,7 @@ func EnsureMycelium(ctx context.Context, privateKey ed25519.PrivateKey, ns Mycel
 	}
 
 	server := NewMyceliumServer(&cfg)
+	server.filter = []Filter{filter}
 	if err := server.Ensure(z, ns.Name()); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,426 @@
+package mycelium
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// MaxPeers is the max number of public peers mycelium is connected to
+	MaxPeers = 8
+	// maxFailures is the number of consecutive failed probes after which
+	// a peer is considered unhealthy
+	maxFailures = 3
+	// swapFactor is how much slower the worst active peer need to be compared
+	// to the best spare peer before they are swapped
+	swapFactor = 2
+	// maxPerRegion is the number of active peers from the same region
+	// before peers from other regions are preferred
+	maxPerRegion = 2
+	// probeWorkers is the max number of peers probed at the same time
+	probeWorkers = 8
+	probeTimeout = 5 * time.Second
+	monitorEvery = 5 * time.Minute
+
+	peerStatic = "static"
+	peerAlive  = "alive"
+)
+
+// PeerStatus is the health of a mycelium peer
+type PeerStatus struct {
+	Endpoint string
+	// Active is set if mycelium is configured to connect to that peer
+	Active bool
+	// Connected is set if mycelium connection to the peer is alive
+	Connected bool
+	// Latency is the last measured connect latency of the peer, it is 0 if the
+	// peer is unreachable
+	Latency time.Duration
+	// Failures is the number of consecutive failed probes
+	Failures int
+	RxBytes  uint64
+	TxBytes  uint64
+}
+
+func (p *PeerStatus) healthy() bool {
+	return p.Failures < maxFailures
+}
+
+// peers keeps track of the mycelium peers health
+type peers struct {
+	m      sync.Mutex
+	status map[string]*PeerStatus
+}
+
+// normalize an endpoint to the same format returned by the admin api
+// (proto://ip:port)
+func normalize(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	host := u.Hostname()
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return "", err
+		}
+		if len(ips) == 0 {
+			return "", fmt.Errorf("no ip found for '%s'", host)
+		}
+		ip = ips[0]
+	}
+
+	return fmt.Sprintf("%s://%s", u.Scheme, net.JoinHostPort(ip.String(), u.Port())), nil
+}
+
+// region groups the endpoints that are likely in the same location. The
+// peers list has no location information, so peers in the same /16 (/32
+// for ipv6) network are considered in the same region.
+func region(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil {
+		return endpoint
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(32, 128)).String()
+}
+
+// rotate computes the peers to add and remove from the active peers, given
+// the candidate peers and their health. Unhealthy peers are dropped and the
+// active set is filled up to max with the fastest healthy candidates, taking
+// at most maxPerRegion peers from the same region unless there are not enough
+// candidates. At most one healthy peer is swapped per call if a much faster
+// candidate exists.
+func rotate(active, candidates []string, status map[string]*PeerStatus, max int) (add, remove []string) {
+	latency := func(endpoint string) time.Duration {
+		if s, ok := status[endpoint]; ok {
+			return s.Latency
+		}
+		return 0
+	}
+	byLatency := func(list []string) {
+		sort.SliceStable(list, func(i, j int) bool {
+			return latency(list[i]) < latency(list[j])
+		})
+	}
+
+	isActive := make(map[string]struct{})
+	var kept, dropped []string
+	for _, endpoint := range active {
+		isActive[endpoint] = struct{}{}
+		// peers that are not probed yet are kept
+		if s, ok := status[endpoint]; ok && !s.healthy() {
+			dropped = append(dropped, endpoint)
+			continue
+		}
+		kept = append(kept, endpoint)
+	}
+
+	var spares []string
+	for _, endpoint := range candidates {
+		if _, ok := isActive[endpoint]; ok {
+			continue
+		}
+		if s, ok := status[endpoint]; ok && s.Failures == 0 && s.Latency > 0 {
+			spares = append(spares, endpoint)
+		}
+	}
+
+	if len(kept) == 0 && len(spares) == 0 {
+		// nothing better to connect to
+		return nil, nil
+	}
+
+	remove = dropped
+	byLatency(kept)
+	byLatency(spares)
+
+	if len(kept) > max {
+		remove = append(remove, kept[max:]...)
+		kept = kept[:max]
+	}
+
+	regions := make(map[string]int)
+	for _, endpoint := range kept {
+		regions[region(endpoint)]++
+	}
+
+	var crowded []string
+	for _, endpoint := range spares {
+		if len(kept) >= max || regions[region(endpoint)] >= maxPerRegion {
+			crowded = append(crowded, endpoint)
+			continue
+		}
+		add = append(add, endpoint)
+		kept = append(kept, endpoint)
+		regions[region(endpoint)]++
+	}
+
+	// fill up from the crowded regions if there is still room
+	spares = nil
+	for _, endpoint := range crowded {
+		if len(kept) < max {
+			add = append(add, endpoint)
+			kept = append(kept, endpoint)
+			continue
+		}
+		spares = append(spares, endpoint)
+	}
+
+	if len(add) == 0 && len(kept) > 0 && len(spares) > 0 {
+		worst := kept[len(kept)-1]
+		if latency(worst) > swapFactor*latency(spares[0]) {
+			remove = append(remove, worst)
+			add = append(add, spares[0])
+		}
+	}
+
+	return add, remove
+}
+
+// probe measures the tcp connect latency to the address from inside the
+// mycelium namespace.
+func (s *MyceliumServer) probe(ctx context.Context, address string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := s.dial(ctx, "tcp", address)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+
+	return latency, conn.Close()
+}
+
+type probeResult struct {
+	latency time.Duration
+	err     error
+}
+
+// probeHosts probes the hosts (host -> tcp address) in parallel, at most
+// probeWorkers at a time.
+func (s *MyceliumServer) probeHosts(ctx context.Context, hosts map[string]string) map[string]probeResult {
+	var (
+		wg      sync.WaitGroup
+		m       sync.Mutex
+		results = make(map[string]probeResult, len(hosts))
+		jobs    = make(chan string)
+	)
+
+	for i := 0; i < probeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				latency, err := s.probe(ctx, hosts[host])
+				m.Lock()
+				results[host] = probeResult{latency: latency, err: err}
+				m.Unlock()
+			}
+		}()
+	}
+
+	for host := range hosts {
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// candidates returns the public peers list after applying the server filters
+func (s *MyceliumServer) candidates() ([]string, error) {
+	list, err := fetchZosMyList()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err = list.Ups(s.filter...)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, peer := range list {
+		endpoint, err := normalize(peer)
+		if err != nil {
+			log.Error().Err(err).Str("peer", peer).Msg("failed to resolve mycelium peer")
+			continue
+		}
+		candidates = append(candidates, endpoint)
+	}
+
+	return candidates, nil
+}
+
+// check probes all known peers and rotates the active peers of mycelium
+func (s *MyceliumServer) check(ctx context.Context) error {
+	candidates, err := s.candidates()
+	if err != nil {
+		return err
+	}
+
+	current, err := s.adminListPeers(ctx)
+	if err != nil {
+		return err
+	}
+
+	status := make(map[string]*PeerStatus)
+	var active []string
+	for _, peer := range current {
+		if peer.Type != peerStatic {
+			// inbound and link local peers are not managed by us
+			continue
+		}
+		endpoint := peer.Endpoint.String()
+		active = append(active, endpoint)
+		status[endpoint] = &PeerStatus{
+			Endpoint:  endpoint,
+			Active:    true,
+			Connected: peer.ConnectionState == peerAlive,
+			RxBytes:   peer.RxBytes,
+			TxBytes:   peer.TxBytes,
+		}
+	}
+
+	for _, endpoint := range candidates {
+		if _, ok := status[endpoint]; !ok {
+			status[endpoint] = &PeerStatus{Endpoint: endpoint}
+		}
+	}
+
+	s.peers.m.Lock()
+	previous := s.peers.status
+	s.peers.m.Unlock()
+
+	// the tcp and quic endpoints of a host share the same latency, so each
+	// host is probed once over its tcp endpoint
+	hosts := make(map[string]string)
+	for endpoint := range status {
+		if u, err := url.Parse(endpoint); err == nil && u.Scheme == "tcp" {
+			hosts[u.Hostname()] = u.Host
+		}
+	}
+
+	results := s.probeHosts(ctx, hosts)
+	for endpoint, peer := range status {
+		if old, ok := previous[endpoint]; ok {
+			peer.Failures = old.Failures
+		}
+
+		var result probeResult
+		var probed bool
+		if u, err := url.Parse(endpoint); err == nil {
+			result, probed = results[u.Hostname()]
+		}
+
+		if !probed {
+			// quic only hosts are not probed, their health is only the
+			// state of the mycelium connection
+			if peer.Connected {
+				peer.Failures = 0
+			} else if peer.Active {
+				peer.Failures++
+			}
+			continue
+		}
+
+		if result.err != nil {
+			log.Debug().Err(result.err).Str("peer", endpoint).Msg("mycelium peer probe failed")
+			// a connected peer is healthy even if it can't be probed
+			if !peer.Connected {
+				peer.Failures++
+			}
+			continue
+		}
+		peer.Latency = result.latency
+		peer.Failures = 0
+	}
+
+	add, remove := rotate(active, candidates, status, MaxPeers)
+	for _, endpoint := range remove {
+		log.Info().Str("peer", endpoint).Msg("removing mycelium peer")
+		if err := s.adminRemovePeer(ctx, endpoint); err != nil {
+			log.Error().Err(err).Str("peer", endpoint).Msg("failed to remove mycelium peer")
+			continue
+		}
+		status[endpoint].Active = false
+		status[endpoint].Connected = false
+	}
+
+	for _, endpoint := range add {
+		log.Info().Str("peer", endpoint).Dur("latency", status[endpoint].Latency).Msg("adding mycelium peer")
+		if err := s.adminAddPeer(ctx, endpoint); err != nil {
+			log.Error().Err(err).Str("peer", endpoint).Msg("failed to add mycelium peer")
+			continue
+		}
+		status[endpoint].Active = true
+	}
+
+	s.peers.m.Lock()
+	s.peers.status = status
+	s.peers.m.Unlock()
+
+	return nil
+}
+
+// Monitor periodically checks the health of the mycelium peers and replaces
+// the ones that perform poorly, it blocks until the context is canceled
+func (s *MyceliumServer) Monitor(ctx context.Context) {
+	ticker := time.NewTicker(monitorEvery)
+	defer ticker.Stop()
+
+	for {
+		if err := s.check(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to check mycelium peers")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Peers returns the status of the mycelium peers as of the last check
+func (s *MyceliumServer) Peers() []PeerStatus {
+	s.peers.m.Lock()
+	defer s.peers.m.Unlock()
+
+	result := make([]PeerStatus, 0, len(s.peers.status))
+	for _, peer := range s.peers.status {
+		result = append(result, *peer)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Active != result[j].Active {
+			return result[i].Active
+		}
+		return result[i].Endpoint < result[j].Endpoint
+	})
+
+	return result
+}
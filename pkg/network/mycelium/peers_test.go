@@ -0,0 +1,84 @@
+package mycelium
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	endpoint, err := normalize("tcp://188.40.132.242:9651")
+	require.NoError(t, err)
+	require.Equal(t, "tcp://188.40.132.242:9651", endpoint)
+
+	endpoint, err = normalize("quic://[2a01:4f8:221:1e0b::2]:9651")
+	require.NoError(t, err)
+	require.Equal(t, "quic://[2a01:4f8:221:1e0b::2]:9651", endpoint)
+}
+
+func TestRegion(t *testing.T) {
+	require.Equal(t, "188.40.0.0", region("tcp://188.40.132.242:9651"))
+	require.Equal(t, "2a01:4f8::", region("quic://[2a01:4f8:221:1e0b::2]:9651"))
+	require.Equal(t, "a", region("a"))
+}
+
+func TestRotate(t *testing.T) {
+	ms := time.Millisecond
+	status := map[string]*PeerStatus{
+		"a": {Latency: 10 * ms},
+		"b": {Failures: maxFailures},
+		"c": {Latency: 30 * ms},
+		"d": {Latency: 5 * ms},
+		"e": {Failures: 1},
+		"f": {Latency: 100 * ms},
+	}
+
+	t.Run("fill", func(t *testing.T) {
+		add, remove := rotate([]string{"a", "b"}, []string{"a", "b", "c", "d", "e"}, status, 3)
+		require.Equal(t, []string{"d", "c"}, add)
+		require.Equal(t, []string{"b"}, remove)
+	})
+
+	t.Run("trim", func(t *testing.T) {
+		add, remove := rotate([]string{"a", "c", "d"}, nil, status, 2)
+		require.Empty(t, add)
+		require.Equal(t, []string{"c"}, remove)
+	})
+
+	t.Run("swap", func(t *testing.T) {
+		add, remove := rotate([]string{"a", "f"}, []string{"c", "d"}, status, 2)
+		require.Equal(t, []string{"d"}, add)
+		require.Equal(t, []string{"f"}, remove)
+
+		// not slow enough to be swapped
+		add, remove = rotate([]string{"a", "d"}, []string{"c"}, status, 2)
+		require.Empty(t, add)
+		require.Empty(t, remove)
+	})
+
+	t.Run("regions", func(t *testing.T) {
+		status := map[string]*PeerStatus{
+			"tcp://10.1.0.1:9651": {Latency: 1 * ms},
+			"tcp://10.1.0.2:9651": {Latency: 2 * ms},
+			"tcp://10.1.0.3:9651": {Latency: 3 * ms},
+			"tcp://10.2.0.1:9651": {Latency: 10 * ms},
+		}
+		candidates := []string{"tcp://10.1.0.1:9651", "tcp://10.1.0.2:9651", "tcp://10.1.0.3:9651", "tcp://10.2.0.1:9651"}
+
+		add, remove := rotate(nil, candidates, status, 3)
+		require.Equal(t, []string{"tcp://10.1.0.1:9651", "tcp://10.1.0.2:9651", "tcp://10.2.0.1:9651"}, add)
+		require.Empty(t, remove)
+
+		// crowded regions are used if there are no other candidates
+		add, remove = rotate(nil, candidates, status, 4)
+		require.Equal(t, []string{"tcp://10.1.0.1:9651", "tcp://10.1.0.2:9651", "tcp://10.2.0.1:9651", "tcp://10.1.0.3:9651"}, add)
+		require.Empty(t, remove)
+	})
+
+	t.Run("nothing better", func(t *testing.T) {
+		add, remove := rotate([]string{"b"}, []string{"e"}, status, 2)
+		require.Empty(t, add)
+		require.Empty(t, remove)
+	})
+}
@@ -2,6 +2,7 @@ package ndmz
 
 import (
 	"context"
+	"net"
 	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
@@ -10,6 +11,7 @@ import (
 	"github.com/threefoldtech/zos/pkg/network/dhcp"
 	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
 	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/static"
 	"github.com/threefoldtech/zos/pkg/zinit"
 	"github.com/vishvananda/netlink"
 )
@@ -19,6 +21,12 @@ import (
 type DHCPMon struct {
 	z       *zinit.Client
 	service dhcp.ClientService
+
+	// address is set if the interface uses a static address, no DHCP client
+	// is started then and the address is set again if the default route is
+	// missing
+	address *net.IPNet
+	gateway net.IP
 }
 
 // NewDHCPMon create a new DHCPMon object managing interface iface
@@ -31,19 +39,28 @@ func NewDHCPMon(iface, namespace string, z *zinit.Client) *DHCPMon {
 	}
 }
 
+// WithStatic makes the monitor use a static address and gateway instead
+// of DHCP
+func (d *DHCPMon) WithStatic(address net.IPNet, gateway net.IP) *DHCPMon {
+	d.address = &address
+	d.gateway = gateway
+	return d
+}
+
 // Start creates a zinit service for a DHCP client and start monitoring it
 // this method is blocking, start is in a goroutine if needed.
 // cancel the context to start it.
 func (d *DHCPMon) Start(ctx context.Context) error {
-
-	if err := d.startZinit(); err != nil {
-		return err
-	}
-	defer func() {
-		if err := d.stopZinit(); err != nil {
-			log.Error().Err(err).Msgf("error stopping %s zinit service", d.service.Name)
+	if d.address == nil {
+		if err := d.startZinit(); err != nil {
+			return err
 		}
-	}()
+		defer func() {
+			if err := d.stopZinit(); err != nil {
+				log.Error().Err(err).Msgf("error stopping %s zinit service", d.service.Name)
+			}
+		}()
+	}
 
 	t := time.NewTicker(time.Minute)
 	defer t.Stop()
@@ -60,11 +77,21 @@ func (d *DHCPMon) Start(ctx context.Context) error {
 				continue
 			}
 
-			if !has {
-				log.Info().Msg("ndmz default route missing, waking up dhcpcd")
-				if err := d.wakeUp(); err != nil {
-					log.Error().Err(err).Msg("error while sending signal to service ")
+			if has {
+				continue
+			}
+
+			if d.address != nil {
+				log.Info().Msg("ndmz default route missing, setting static address again")
+				if err := d.setStatic(); err != nil {
+					log.Error().Err(err).Msg("error while setting static address")
 				}
+				continue
+			}
+
+			log.Info().Msg("ndmz default route missing, waking up dhcpcd")
+			if err := d.wakeUp(); err != nil {
+				log.Error().Err(err).Msg("error while sending signal to service ")
 			}
 		}
 	}
@@ -79,6 +106,25 @@ func (d *DHCPMon) wakeUp() error {
 	return err
 }
 
+// setStatic sets the static address and default route of the interface
+func (d *DHCPMon) setStatic() error {
+	do := func(_ ns.NetNS) error {
+		return static.SetAddress(d.service.Iface, *d.address, d.gateway)
+	}
+
+	if d.service.Namespace == "" {
+		return do(nil)
+	}
+
+	netNS, err := namespace.GetByName(d.service.Namespace)
+	if err != nil {
+		return err
+	}
+	defer netNS.Close()
+
+	return netNS.Do(do)
+}
+
 // hasDefaultRoute checks if the network interface iface has a default route configured
 // if netNS is not empty, switch to the network namespace named netNS before checking the routes
 func hasDefaultRoute(iface, netNS string) (bool, error) {
@@ -1,13 +1,10 @@
 package ndmz
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
 	"os"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
@@ -18,6 +15,7 @@ import (
 	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
 	"github.com/threefoldtech/zos/pkg/network/nft"
 	"github.com/threefoldtech/zos/pkg/network/options"
+	"github.com/threefoldtech/zos/pkg/network/static"
 	"github.com/threefoldtech/zos/pkg/network/types"
 	"github.com/threefoldtech/zos/pkg/network/yggdrasil"
 	"github.com/threefoldtech/zos/pkg/zinit"
@@ -58,13 +56,18 @@ const (
 type dmzImpl struct {
 	nodeID string
 	public *netlink.Bridge
+	// static is the static network config of the node, nil if
+	// the node uses DHCP
+	static *static.Config
 }
 
-// New creates a new DMZ DualStack
-func New(nodeID string, public *netlink.Bridge) DMZ {
+// New creates a new DMZ DualStack. If cfg is set the ndmz uses its static
+// ndmz address instead of DHCP
+func New(nodeID string, public *netlink.Bridge, cfg *static.Config) DMZ {
 	return &dmzImpl{
 		nodeID: nodeID,
 		public: public,
+		static: cfg,
 	}
 }
 
@@ -120,7 +123,11 @@ func (d *dmzImpl) Create(ctx context.Context) error {
 			return errors.Wrapf(err, "failed to enable forwarding in ndmz")
 		}
 
-		if err := waitIP4(); err != nil {
+		if d.static != nil {
+			if err := static.SetAddress(dmzPub4, d.static.NDMZ.IPNet, d.static.Gateway); err != nil {
+				return errors.Wrap(err, "ndmz: failed to set static address")
+			}
+		} else if err := waitIP4(); err != nil {
 			return err
 		}
 
@@ -135,6 +142,9 @@ func (d *dmzImpl) Create(ctx context.Context) error {
 
 	z := zinit.Default()
 	dhcpMon := NewDHCPMon(dmzPub4, dmzNamespace, z)
+	if d.static != nil {
+		dhcpMon.WithStatic(d.static.NDMZ.IPNet, d.static.Gateway)
+	}
 	go func() {
 		_ = dhcpMon.Start(ctx)
 	}()
@@ -538,23 +548,13 @@ func createRoutingBridge(name string, netNS ns.NetNS) error {
 }
 
 func applyFirewall() error {
-	buf := bytes.Buffer{}
-
-	data := struct {
-		YggPorts string
-	}{
-		YggPorts: strings.Join([]string{
-			strconv.Itoa(yggdrasil.YggListenTCP),
-			strconv.Itoa(yggdrasil.YggListenTLS),
-			strconv.Itoa(yggdrasil.YggListenLinkLocal),
-		}, ","),
-	}
-
-	if err := fwTmpl.Execute(&buf, data); err != nil {
-		return errors.Wrap(err, "failed to build nft rule set")
-	}
+	tables := ruleset(
+		yggdrasil.YggListenTCP,
+		yggdrasil.YggListenTLS,
+		yggdrasil.YggListenLinkLocal,
+	)
 
-	if err := nft.Apply(&buf, dmzNamespace); err != nil {
+	if err := nft.ApplyRuleset(dmzNamespace, tables...); err != nil {
 		return errors.Wrap(err, "failed to apply nft rule set")
 	}
 
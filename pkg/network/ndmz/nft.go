@@ -1,72 +1,74 @@
 package ndmz
 
 import (
-	"text/template"
-)
-
-var fwTmpl *template.Template
-
-func init() {
-	fwTmpl = template.Must(template.New("").Parse(_nft))
-}
-
-var _nft = `
-flush ruleset
+	"net"
 
-table inet nat {
-  chain prerouting {
-    type nat hook prerouting priority dstnat; policy accept;
-  }
-
-  chain input {
-    type nat hook input priority 100; policy accept;
-  }
-
-  chain output {
-    type nat hook output priority -100; policy accept;
-  }
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"golang.org/x/sys/unix"
+)
 
-  chain postrouting {
-    type nat hook postrouting priority srcnat; policy accept;
-    ip6 saddr 200::/7 accept
-    oifname "npub4" masquerade fully-random;
-    oifname "npub6" masquerade fully-random;
-  }
-}
+// ruleset builds the full nft ruleset of the ndmz namespace. yggPorts are
+// the tcp ports yggdrasil listens on.
+func ruleset(yggPorts ...uint16) []nft.Table {
+	nat := nft.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   "nat",
+		Chains: []nft.Chain{
+			nft.BaseChain("prerouting", nftables.ChainTypeNAT, nftables.ChainHookPrerouting, -100, nftables.ChainPolicyAccept),
+			nft.BaseChain("input", nftables.ChainTypeNAT, nftables.ChainHookInput, 100, nftables.ChainPolicyAccept),
+			nft.BaseChain("output", nftables.ChainTypeNAT, nftables.ChainHookOutput, -100, nftables.ChainPolicyAccept),
+			nft.BaseChain("postrouting", nftables.ChainTypeNAT, nftables.ChainHookPostrouting, 100, nftables.ChainPolicyAccept,
+				nft.NewRule("", nft.SNet(net.IPNet{IP: net.ParseIP("200::"), Mask: net.CIDRMask(7, 128)}), nft.Accept()),
+				nft.NewRule("", nft.OIfName("npub4"), nft.Masquerade()),
+				nft.NewRule("", nft.OIfName("npub6"), nft.Masquerade()),
+			),
+		},
+	}
 
-table inet filter {
+	input := []nft.Rule{
+		nft.NewRule("", nft.Jump("base_checks")),
+		nft.NewRule("", nft.DNet(net.IPNet{IP: net.ParseIP("ff02::"), Mask: net.CIDRMask(64, 128)}), nft.Accept()),
+		nft.NewRule("", nft.DNet(net.IPNet{IP: net.ParseIP("fe80::"), Mask: net.CIDRMask(64, 128)}), nft.Accept()),
+	}
 
-  chain base_checks {
-    # allow established/related connections
-    ct state {established, related} accept
-    # early drop of invalid connections
-    ct state invalid drop
-  }
+	for _, port := range yggPorts {
+		input = append(input, nft.NewRule("", nft.TCPDPort(port, 0), nft.Accept()))
+	}
 
-  chain input {
-    type filter hook input priority 0; policy accept;
-    jump base_checks
-    ip6 daddr {ff02::/64,fe80::/64} accept
-    tcp dport { {{.YggPorts}} } accept
-    # port for provisiond
-    tcp dport 8051 accept
-    ip6 nexthdr icmpv6 accept
-    iifname "npub6" counter drop
-    iifname "npub4" counter drop
-  }
+	input = append(input,
+		// port for provisiond
+		nft.NewRule("", nft.TCPDPort(8051, 0), nft.Accept()),
+		nft.NewRule("", nft.L4Proto(unix.IPPROTO_ICMPV6), nft.Accept()),
+		nft.NewRule("", nft.IIfName("npub6"), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.IIfName("npub4"), nft.Counter(), nft.Drop()),
+	)
 
-  chain forward {
-    type filter hook forward priority 0; policy accept;
-    # is there already an existing stream? (outgoing)
-    jump base_checks
-    # if not, verify if it's new and coming in from the br4-gw network
-    # if it is, drop it
-    iifname "npub6" counter drop
-    iifname "npub4" counter drop
-  }
+	filter := nft.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   "filter",
+		Chains: []nft.Chain{
+			nft.RegularChain("base_checks",
+				// allow established/related connections
+				nft.NewRule("", nft.CtState(expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED), nft.Accept()),
+				// early drop of invalid connections
+				nft.NewRule("", nft.CtState(expr.CtStateBitINVALID), nft.Drop()),
+			),
+			nft.BaseChain("input", nftables.ChainTypeFilter, nftables.ChainHookInput, 0, nftables.ChainPolicyAccept, input...),
+			nft.BaseChain("forward", nftables.ChainTypeFilter, nftables.ChainHookForward, 0, nftables.ChainPolicyAccept,
+				// is there already an existing stream? (outgoing)
+				nft.NewRule("", nft.Jump("base_checks")),
+				// allow new connections of forwarded ports
+				nft.NewRule("", nft.IIfName("npub4"), nft.CtStatus(nft.CtStatusDNAT), nft.Accept()),
+				// if not, verify if it's new and coming in from the br4-gw network
+				// if it is, drop it
+				nft.NewRule("", nft.IIfName("npub6"), nft.Counter(), nft.Drop()),
+				nft.NewRule("", nft.IIfName("npub4"), nft.Counter(), nft.Drop()),
+			),
+			nft.BaseChain("output", nftables.ChainTypeFilter, nftables.ChainHookOutput, 0, nftables.ChainPolicyAccept),
+		},
+	}
 
-  chain output {
-    type filter hook output priority 0; policy accept;
-  }
+	return []nft.Table{nat, filter}
 }
-`
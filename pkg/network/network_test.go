@@ -9,7 +9,10 @@ import (
 
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
+	"github.com/threefoldtech/zos/pkg"
 	"github.com/threefoldtech/zos/pkg/crypto"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -43,3 +46,29 @@ func TestKeys(t *testing.T) {
 
 	assert.Equal(t, wgKey, wgKey2)
 }
+
+func TestOnlyPeersChanged(t *testing.T) {
+	require := require.New(t)
+
+	previous := pkg.Network{
+		NetID: "net",
+		Network: zos.Network{
+			NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+			Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+			WGPrivateKey:   "key",
+			WGListenPort:   3000,
+		},
+	}
+
+	current := previous
+	current.Peers = []zos.Peer{{
+		Subnet:      gridtypes.MustParseIPNet("10.1.3.0/24"),
+		WGPublicKey: "peer",
+	}}
+	require.True(onlyPeersChanged(previous, current))
+	require.False(onlyPeersChanged(previous, previous))
+	require.False(onlyPeersChanged(current, current))
+
+	current.WGListenPort = 3001
+	require.False(onlyPeersChanged(previous, current))
+}
@@ -6,13 +6,12 @@ import (
 	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blang/semver"
@@ -23,10 +22,12 @@ import (
 	"github.com/threefoldtech/zos/pkg/gridtypes"
 	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
 	"github.com/threefoldtech/zos/pkg/network/bootstrap"
+	"github.com/threefoldtech/zos/pkg/network/flowlog"
 	"github.com/threefoldtech/zos/pkg/network/iperf"
 	"github.com/threefoldtech/zos/pkg/network/mycelium"
 	"github.com/threefoldtech/zos/pkg/network/ndmz"
 	"github.com/threefoldtech/zos/pkg/network/options"
+	"github.com/threefoldtech/zos/pkg/network/portm"
 	"github.com/threefoldtech/zos/pkg/network/public"
 	"github.com/threefoldtech/zos/pkg/network/tuntap"
 	"github.com/threefoldtech/zos/pkg/network/wireguard"
@@ -77,11 +78,35 @@ var NetworkSchemaLatestVersion = semver.MustParse("0.1.0")
 
 type networker struct {
 	identity       *stubs.IdentityManagerStub
+	gateway        *stubs.GatewayStub
 	networkDir     string
 	linkDir        string
 	ipamLeaseDir   string
 	myceliumKeyDir string
 	portSet        *set.UIntSet
+	wgPorts        *portm.Allocator
+
+	portForwardDir string
+	portForwards   *portm.Allocator
+	forwardsLock   sync.Mutex
+
+	servicesDir  string
+	servicesLock sync.Mutex
+
+	pubTapsDir string
+
+	delegationDir  string
+	delegationLock sync.Mutex
+
+	snatDir  string
+	snatLock sync.Mutex
+
+	meshLock sync.Mutex
+
+	flows        *flowlog.Store
+	flowsLock    sync.Mutex
+	flowWatchers map[string]context.CancelFunc
+	pubIPFlows   map[string]pubIPFlows
 
 	ndmz     ndmz.DMZ
 	ygg      *yggdrasil.YggServer
@@ -90,8 +115,9 @@ type networker struct {
 
 var _ pkg.Networker = (*networker)(nil)
 
-// NewNetworker create a new pkg.Networker that can be used over zbus
-func NewNetworker(identity *stubs.IdentityManagerStub, ndmz ndmz.DMZ, ygg *yggdrasil.YggServer, myc *mycelium.MyceliumServer) (pkg.Networker, error) {
+// NewNetworker create a new pkg.Networker that can be used over zbus. root is
+// a persistent directory used to keep state that must survive reboots
+func NewNetworker(identity *stubs.IdentityManagerStub, gateway *stubs.GatewayStub, ndmz ndmz.DMZ, ygg *yggdrasil.YggServer, myc *mycelium.MyceliumServer, root string) (pkg.Networker, error) {
 	vd, err := cache.VolatileDir("networkd", 50*mib)
 	if err != nil && !os.IsExist(err) {
 		return nil, fmt.Errorf("failed to create networkd cache directory: %w", err)
@@ -101,20 +127,57 @@ func NewNetworker(identity *stubs.IdentityManagerStub, ndmz ndmz.DMZ, ygg *yggdr
 	linkDir := filepath.Join(runtimeDir, linkDir)
 	ipamLease := filepath.Join(vd, ipamLeaseDir)
 	myceliumKey := filepath.Join(vd, myceliumKeyDir)
+	services := filepath.Join(vd, servicesDir)
+	pubTaps := filepath.Join(vd, pubTapsDir)
 
-	for _, dir := range []string{linkDir, ipamLease, myceliumKey} {
+	for _, dir := range []string{linkDir, ipamLease, myceliumKey, services, pubTaps} {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, errors.Wrapf(err, "failed to create directory: '%s'", dir)
 		}
 	}
 
+	wgPorts, err := newWGPortAllocator(root)
+	if err != nil {
+		return nil, err
+	}
+
+	forwardDir := filepath.Join(root, portForwardDir)
+	forwards, err := newPortAllocator(forwardDir)
+	if err != nil {
+		return nil, err
+	}
+
+	delegation := filepath.Join(root, prefixDelegationDir)
+	snat := filepath.Join(root, snatDir)
+	for _, dir := range []string{delegation, snat} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrapf(err, "failed to create directory: '%s'", dir)
+		}
+	}
+
+	flows, err := flowlog.NewStore(filepath.Join(root, flowLogsDir), flowlog.DefaultRetention, flowlog.DefaultMaxSize)
+	if err != nil {
+		return nil, err
+	}
+
 	nw := &networker{
 		identity:       identity,
+		gateway:        gateway,
 		networkDir:     runtimeDir,
 		linkDir:        linkDir,
 		ipamLeaseDir:   ipamLease,
 		myceliumKeyDir: myceliumKey,
 		portSet:        set.NewInt(),
+		wgPorts:        wgPorts,
+		portForwardDir: forwardDir,
+		portForwards:   forwards,
+		servicesDir:    services,
+		pubTapsDir:     pubTaps,
+		delegationDir:  delegation,
+		snatDir:        snat,
+		flows:          flows,
+		flowWatchers:   make(map[string]context.CancelFunc),
+		pubIPFlows:     make(map[string]pubIPFlows),
 
 		ygg:      ygg,
 		mycelium: myc,
@@ -134,6 +197,22 @@ func NewNetworker(identity *stubs.IdentityManagerStub, ndmz ndmz.DMZ, ygg *yggdr
 		return nil, err
 	}
 
+	if err := nw.syncPortForwards(); err != nil {
+		log.Error().Err(err).Msg("failed to restore port forwards")
+	}
+
+	if cfg, err := public.LoadPublicConfig(); err == nil {
+		if err := nw.applyDelegation(cfg.Delegation); err != nil {
+			log.Error().Err(err).Msg("failed to restore ipv6 prefix delegation")
+		}
+	}
+
+	if err := nw.applySNAT(); err != nil {
+		log.Error().Err(err).Msg("failed to restore snat rules")
+	}
+
+	go nw.meshDiscovery()
+
 	return nw, nil
 }
 
@@ -563,155 +642,11 @@ func (n *networker) RemovePubTap(name string) error {
 		return errors.Wrap(err, "could not get network namespace tap device name")
 	}
 
-	return ifaceutil.Delete(tapIface, nil)
-}
-
-var (
-	pubIpTemplateSetup = template.Must(template.New("filter-setup").Parse(
-		`# add vm
-# add a chain for the vm public interface in arp and bridge
-nft 'add chain bridge filter {{.Name}}-pre'
-nft 'add chain bridge filter {{.Name}}-post'
-
-# make nft jump to vm chain
-nft 'add rule bridge filter prerouting iifname "{{.Iface}}" jump {{.Name}}-pre'
-nft 'add rule bridge filter postrouting oifname "{{.Iface}}" jump {{.Name}}-post'
-
-nft 'add rule bridge filter {{.Name}}-pre ip saddr . ether saddr != { {{.IPv4}} . {{.Mac}} } counter drop'
-# nft 'add rule bridge filter {{.Name}}-pre ip6 saddr . ether saddr != { {{.IPv6}} . {{.Mac}} } counter drop'
-
-nft 'add rule bridge filter {{.Name}}-pre arp operation reply arp saddr ip != {{.IPv4}} counter drop'
-nft 'add rule bridge filter {{.Name}}-pre arp operation request arp saddr ip != {{.IPv4}} counter drop'
-
-nft 'add rule bridge filter {{.Name}}-post ip daddr . ether daddr != { {{.IPv4}} . {{.Mac}} } counter drop'
-# nft 'add rule bridge filter {{.Name}}-post ip6 saddr . ether saddr != { {{.IPv6}} . {{.Mac}} } counter drop'
-`))
-
-	pubIpTemplateDestroy = template.Must(template.New("filter-destroy").Parse(
-		`# in bridge table
-nft 'flush chain bridge filter {{.Name}}-post'
-nft 'flush chain bridge filter {{.Name}}-pre'
-
-# the .name rule is for backward compatibility
-# to make sure older chains are deleted
-nft 'flush chain bridge filter {{.Name}}' || true
-
-# we need to make sure this clean up can also work on older setup
-# jump to chain rule
-a=$( nft -a list table bridge filter | awk '/jump {{.Name}}-pre/{ print $NF}' )
-if [ -n "${a}" ]; then
-	nft delete rule bridge filter prerouting handle ${a}
-fi
-a=$( nft -a list table bridge filter | awk '/jump {{.Name}}-post/{ print $NF}' )
-if [ -n "${a}" ]; then
-	nft delete rule bridge filter postrouting handle ${a}
-fi
-a=$( nft -a list table bridge filter | awk '/jump {{.Name}}/{ print $NF}' )
-if [ -n "${a}" ]; then
-	nft delete rule bridge filter forward handle ${a}
-fi
-
-# chain itself
-for chain in $( nft -a list table bridge filter | awk '/chain {{.Name}}/{ print $NF}' ); do
-	nft delete chain bridge filter handle ${chain}
-done
-
-# the next section is only for backward compatibility
-# in arp table
-nft 'flush chain arp filter {{.Name}}'
-# jump to chain rule
-a=$( nft -a list table arp filter | awk '/jump {{.Name}}/{ print $NF}' )
-if [ -n "${a}" ]; then
-	nft delete rule arp filter input handle ${a}
-fi
-# chain itself
-a=$( nft -a list table arp filter | awk '/chain {{.Name}}/{ print $NF}' )
-if [ -n "${a}" ]; then
-	nft delete chain arp filter handle ${a}
-fi
-`))
-)
-
-// SetupPubIPFilter sets up filter for this public ip
-func (n *networker) SetupPubIPFilter(filterName string, iface string, ipv4 net.IP, ipv6 net.IP, mac string) error {
-	if n.PubIPFilterExists(filterName) {
-		return nil
-	}
-
-	ipv4 = ipv4.To4()
-	ipv6 = ipv6.To16()
-	// if no ipv4 or ipv6 provided, we make sure
-	// to use zero ip so the user can't just assign
-	// an ip to his vm to use.
-	if len(ipv4) == 0 {
-		ipv4 = net.IPv4zero
-	}
-
-	if len(ipv6) == 0 {
-		ipv6 = net.IPv6zero
-	}
-
-	data := struct {
-		Name  string
-		Iface string
-		Mac   string
-		IPv4  string
-		IPv6  string
-	}{
-		Name:  filterName,
-		Iface: iface,
-		Mac:   mac,
-		IPv4:  ipv4.String(),
-		IPv6:  ipv6.String(),
-	}
-
-	var buffer bytes.Buffer
-	if err := pubIpTemplateSetup.Execute(&buffer, data); err != nil {
-		return errors.Wrap(err, "failed to execute filter template")
-	}
-
-	// TODO: use nft.Apply
-	cmd := exec.Command("/bin/sh", "-c", buffer.String())
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return errors.Wrapf(err, "could not setup firewall rules for public ip\n%s", string(output))
-	}
-
-	return nil
-}
-
-// PubIPFilterExists checks if pub ip filter
-func (n *networker) PubIPFilterExists(filterName string) bool {
-	cmd := exec.Command(
-		"/bin/sh",
-		"-c",
-		fmt.Sprintf(`nft list table bridge filter | grep "chain %s"`, filterName),
-	)
-	err := cmd.Run()
-	return err == nil
-}
-
-// RemovePubIPFilter removes the filter setted up by SetupPubIPFilter
-func (n *networker) RemovePubIPFilter(filterName string) error {
-	data := struct {
-		Name string
-	}{
-		Name: filterName,
+	if err := n.clearPubTapBandwidth(tapIface); err != nil {
+		log.Error().Err(err).Str("pubtap-name", name).Msg("failed to clear public tap bandwidth")
 	}
 
-	var buffer bytes.Buffer
-	if err := pubIpTemplateDestroy.Execute(&buffer, data); err != nil {
-		return errors.Wrap(err, "failed to execute filter template")
-	}
-
-	cmd := exec.Command("/bin/sh", "-c", buffer.String())
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return errors.Wrapf(err, "could not tear down firewall rules for public ip\n%s", string(output))
-	}
-	return nil
+	return ifaceutil.Delete(tapIface, nil)
 }
 
 // DisconnectPubTap disconnects the public tap from the network. The interface
@@ -778,34 +713,77 @@ func (n *networker) GetNet(networkID pkg.NetID) (net.IPNet, error) {
 	return localNR.NetworkIPRange.IPNet, nil
 }
 
+// GetSubnetV6 of a local network resource identified by the network ID. An
+// empty subnet is returned if the network has no IPv6 range
+func (n *networker) GetSubnetV6(networkID pkg.NetID) (net.IPNet, error) {
+	localNR, err := n.networkOf(networkID)
+	if err != nil {
+		return net.IPNet{}, errors.Wrapf(err, "couldn't load network with id (%s)", networkID)
+	}
+
+	return localNR.SubnetV6.IPNet, nil
+}
+
+// GetNetV6 of a network identified by the network ID. An empty range is
+// returned if the network has no IPv6 range
+func (n *networker) GetNetV6(networkID pkg.NetID) (net.IPNet, error) {
+	localNR, err := n.networkOf(networkID)
+	if err != nil {
+		return net.IPNet{}, errors.Wrapf(err, "couldn't load network with id (%s)", networkID)
+	}
+
+	return localNR.NetworkIPRangeV6.IPNet, nil
+}
+
 // GetDefaultGwIP returns the IPs of the default gateways inside the network
 // resource identified by the network ID on the local node, for IPv4 and IPv6
-// respectively
+// respectively. The IPv4 gateway is nil for IPv6 only networks
 func (n *networker) GetDefaultGwIP(networkID pkg.NetID) (net.IP, net.IP, error) {
 	localNR, err := n.networkOf(networkID)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "couldn't load network with id (%s)", networkID)
 	}
 
-	// only IP4 atm
-	ip := localNR.Subnet.IP.To4()
-	if ip == nil {
-		return nil, nil, errors.New("nr subnet is not valid IPv4")
+	var gw4 net.IP
+	if localNR.HasIPv4() {
+		gw4 = localNR.Subnet.IP.To4()
+		if gw4 == nil {
+			return nil, nil, errors.New("nr subnet is not valid IPv4")
+		}
+
+		// defaut gw is currently implied to be at `x.x.x.1`
+		// also a subnet in a NR is assumed to be a /24
+		gw4[len(gw4)-1] = 1
 	}
 
-	// defaut gw is currently implied to be at `x.x.x.1`
-	// also a subnet in a NR is assumed to be a /24
-	ip[len(ip)-1] = 1
+	if localNR.HasIPv6() {
+		return gw4, nr.GatewayIPv6(localNR.SubnetV6.IPNet).IP, nil
+	}
+
+	if gw4 == nil {
+		return nil, nil, errors.New("nr subnet is not valid IPv4")
+	}
 
 	// ipv6 is derived from the ipv4
-	return ip, nr.Convert4to6(string(networkID), ip), nil
+	return gw4, nr.Convert4to6(string(networkID), gw4), nil
 }
 
-// GetIPv6From4 generates an IPv6 address from a given IPv4 address in a NR
+// GetIPv6From4 generates an IPv6 address from a given IPv4 address in a NR.
+// If the network has an IPv6 range the address is part of the NR IPv6 subnet
 func (n *networker) GetIPv6From4(networkID pkg.NetID, ip net.IP) (net.IPNet, error) {
 	if ip.To4() == nil {
 		return net.IPNet{}, errors.New("invalid IPv4 address")
 	}
+
+	localNR, err := n.networkOf(networkID)
+	if err != nil {
+		return net.IPNet{}, errors.Wrapf(err, "couldn't load network with id (%s)", networkID)
+	}
+
+	if localNR.HasIPv6() {
+		return net.IPNet{IP: nr.IPv6From4(localNR.SubnetV6.IPNet, ip.To4()), Mask: net.CIDRMask(64, 128)}, nil
+	}
+
 	return net.IPNet{IP: nr.Convert4to6(string(networkID), ip), Mask: net.CIDRMask(64, 128)}, nil
 }
 
@@ -815,7 +793,138 @@ func (n *networker) SetPublicExitDevice(iface string) error {
 		return err
 	}
 
-	return public.SetPublicExitLink(link)
+	current, err := public.LoadPublicConfig()
+	if err != nil && err != public.ErrNoPublicConfig {
+		return errors.Wrap(err, "failed to load current public configuration")
+	}
+
+	if current == nil || current.Uplink == nil {
+		return public.SetPublicExitLink(link)
+	}
+
+	// the exit device replaces the configured uplink
+	if err := public.UnsetPublicUplink(); err != nil {
+		return errors.Wrap(err, "failed to unset public uplink")
+	}
+
+	if err := public.SetPublicExitLink(link); err != nil {
+		return err
+	}
+
+	current.Uplink = nil
+	if current.IsEmpty() {
+		return public.DeletePublicConfig()
+	}
+
+	return public.SavePublicConfig(*current)
+}
+
+// SetPublicUplink implements pkg.Networker interface
+func (n *networker) SetPublicUplink(uplink pkg.PublicUplink) error {
+	if err := uplink.Valid(); err != nil {
+		return err
+	}
+
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig {
+		current = &pkg.PublicConfig{}
+	} else if err != nil {
+		return errors.Wrap(err, "failed to load current public configuration")
+	}
+
+	if err := public.SetPublicUplink(uplink); err != nil {
+		return errors.Wrap(err, "failed to set public uplink")
+	}
+
+	current.Uplink = &uplink
+	return public.SavePublicConfig(*current)
+}
+
+// GetPublicUplink implements pkg.Networker interface
+func (n *networker) GetPublicUplink() (pkg.PublicUplink, error) {
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig {
+		return pkg.PublicUplink{}, nil
+	} else if err != nil {
+		return pkg.PublicUplink{}, err
+	}
+
+	if current.Uplink == nil {
+		return pkg.PublicUplink{}, nil
+	}
+
+	return *current.Uplink, nil
+}
+
+// SetGatewayDomains implements pkg.Networker interface
+func (n *networker) SetGatewayDomains(domains []pkg.GatewayDomain) error {
+	seen := make(map[string]struct{})
+	for i := range domains {
+		if err := domains[i].Valid(); err != nil {
+			return err
+		}
+
+		if _, ok := seen[domains[i].Domain]; ok {
+			return fmt.Errorf("gateway domain '%s' is set more than once", domains[i].Domain)
+		}
+		seen[domains[i].Domain] = struct{}{}
+	}
+
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig || (err == nil && current.IsEmpty()) {
+		return fmt.Errorf("gateway domains require a public config")
+	} else if err != nil {
+		return errors.Wrap(err, "failed to load current public configuration")
+	}
+
+	current.GatewayDomains = domains
+	if len(domains) == 0 {
+		current.GatewayDomains = nil
+	}
+
+	if err := public.SavePublicConfig(*current); err != nil {
+		return err
+	}
+
+	// the gateway reads the domains back from networkd so this can't
+	// block the call
+	go n.updateGatewayDomains()
+
+	return nil
+}
+
+// updateGatewayDomains notifies the gateway that the domains changed so
+// traefik is reconfigured right away
+func (n *networker) updateGatewayDomains() {
+	defer func() {
+		// the stub panics if the gateway is not reachable
+		if err := recover(); err != nil {
+			log.Error().Msgf("failed to notify gateway of domains change: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if err := n.gateway.UpdateDomains(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to update gateway domains")
+	}
+}
+
+// GetGatewayDomains implements pkg.Networker interface
+func (n *networker) GetGatewayDomains() ([]pkg.GatewayDomain, error) {
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig {
+		return []pkg.GatewayDomain{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if current.GatewayDomains == nil {
+		return []pkg.GatewayDomain{}, nil
+	}
+
+	return current.GatewayDomains, nil
 }
 
 func (n *networker) Interfaces(iface string, netns string) (pkg.Interfaces, error) {
@@ -926,27 +1035,49 @@ func (n *networker) Addrs(iface string, netns string) (ips []net.IP, mac string,
 func (n *networker) CreateNR(wl gridtypes.WorkloadID, netNR pkg.Network) (string, error) {
 	log.Info().Str("network", string(netNR.NetID)).Msg("create network resource")
 
-	if err := n.storeNetwork(wl, netNR); err != nil {
-		return "", errors.Wrap(err, "failed to store network object")
+	if n.updateNRPeers(wl, netNR) {
+		if err := n.setNRFlowLogs(wl, netNR); err != nil {
+			log.Error().Err(err).Msg("failed to set network resource flow logs")
+		}
+		return n.Namespace(netNR.NetID), nil
 	}
 
 	// check if there is a reserved wireguard port for this NR already
-	// or if we need to update it
+	// or if we need to update it. this must be done before the new
+	// network object is stored over the previous one
 	storedNR, err := n.networkOf(netNR.NetID)
 	if err != nil && !os.IsNotExist(err) {
 		return "", errors.Wrap(err, "failed to load previous network setup")
 	}
 
-	if err == nil {
+	previous := err == nil
+	if previous && storedNR.WGListenPort == netNR.WGListenPort {
+		// the port is reserved again below
 		if err := n.releasePort(storedNR.WGListenPort); err != nil {
 			return "", err
 		}
+		previous = false
 	}
 
-	if err := n.reservePort(netNR.WGListenPort); err != nil {
+	if err := n.reservePort(wl, netNR.WGListenPort); err != nil {
 		return "", err
 	}
 
+	if err := n.storeNetwork(wl, netNR); err != nil {
+		if err := n.releasePort(netNR.WGListenPort); err != nil {
+			log.Error().Err(err).Msg("release wireguard port failed")
+		}
+		return "", errors.Wrap(err, "failed to store network object")
+	}
+
+	// the previous port is only released once the new one is reserved
+	// so the network keeps its port if the new one is not available
+	if previous {
+		if err := n.releasePort(storedNR.WGListenPort); err != nil {
+			log.Error().Err(err).Uint16("port", storedNR.WGListenPort).Msg("release previous wireguard port failed")
+		}
+	}
+
 	netr := nr.New(netNR, n.myceliumKeyDir)
 
 	cleanup := func() {
@@ -980,6 +1111,10 @@ func (n *networker) CreateNR(wl gridtypes.WorkloadID, netNR pkg.Network) (string
 		return "", errors.Wrap(err, "failed to setup mycelium")
 	}
 
+	if err = n.setMesh(netr); err != nil {
+		return "", errors.Wrap(err, "failed to setup network mesh")
+	}
+
 	exists, err := netr.HasWireguard()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to check if network resource has wireguard setup")
@@ -1009,9 +1144,101 @@ func (n *networker) CreateNR(wl gridtypes.WorkloadID, netNR pkg.Network) (string
 		return "", errors.Wrap(err, "failed to configure network resource")
 	}
 
+	if err = netr.SetBandwidth(); err != nil {
+		return "", errors.Wrap(err, "failed to set network resource bandwidth")
+	}
+
+	// the public taps of the vms follow the bandwidth of the network
+	if err := n.applyPubTapsBandwidth(netNR.NetID); err != nil {
+		log.Error().Err(err).Msg("failed to update public taps bandwidth")
+	}
+
+	n.servicesLock.Lock()
+	err = n.setServices(wl, netr, netNR.NetID)
+	n.servicesLock.Unlock()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to setup network services")
+	}
+
+	// the network resource ruleset is recreated, so port forwards
+	// need to be applied again
+	if err := n.applyPortForwards(netNR.NetID); err != nil {
+		log.Error().Err(err).Msg("failed to apply network resource port forwards")
+	}
+
+	if err := n.setNRFlowLogs(wl, netNR); err != nil {
+		log.Error().Err(err).Msg("failed to set network resource flow logs")
+	}
+
+	if err := n.applySNAT(); err != nil {
+		log.Error().Err(err).Msg("failed to apply snat rules")
+	}
+
 	return netr.Namespace()
 }
 
+// onlyPeersChanged returns true if the only difference between the two
+// network resources is the peers list. It returns false if the peers
+// did not change either
+func onlyPeersChanged(previous, current pkg.Network) bool {
+	equal := func(a, b interface{}) bool {
+		x, err := json.Marshal(a)
+		if err != nil {
+			return false
+		}
+
+		y, err := json.Marshal(b)
+		if err != nil {
+			return false
+		}
+
+		return bytes.Equal(x, y)
+	}
+
+	if equal(previous.Peers, current.Peers) {
+		return false
+	}
+
+	previous.Peers, current.Peers = nil, nil
+
+	return equal(previous, current)
+}
+
+// updateNRPeers updates the peers of an existing network resource in place
+// if they are the only change to the network resource. This keeps the
+// existing connections alive. It returns false if the network resource
+// needs to be fully (re)created
+func (n *networker) updateNRPeers(wl gridtypes.WorkloadID, netNR pkg.Network) bool {
+	previous, err := n.networkOf(netNR.NetID)
+	if err != nil || !onlyPeersChanged(previous, netNR) {
+		return false
+	}
+
+	netr := nr.New(netNR, n.myceliumKeyDir)
+	if exists, err := netr.HasWireguard(); err != nil || !exists {
+		return false
+	}
+
+	log.Info().Str("network", string(netNR.NetID)).Msg("update network resource peers")
+
+	if err := netr.UpdatePeers(); err != nil {
+		log.Error().Err(err).Msg("failed to update network resource peers, recreating network resource")
+		return false
+	}
+
+	if err := n.setMesh(netr); err != nil {
+		log.Error().Err(err).Msg("failed to update network mesh, recreating network resource")
+		return false
+	}
+
+	if err := n.storeNetwork(wl, netNR); err != nil {
+		log.Error().Err(err).Msg("failed to store network object")
+		return false
+	}
+
+	return true
+}
+
 func (n *networker) rmNetwork(wl gridtypes.WorkloadID) error {
 	netID, err := zos.NetworkIDFromWorkloadID(wl)
 	if err != nil {
@@ -1070,10 +1297,21 @@ func (n *networker) DeleteNR(wl gridtypes.WorkloadID) error {
 
 	nr := nr.New(netNR, n.myceliumKeyDir)
 
-	if err := nr.Delete(); err != nil {
+	n.flowsLock.Lock()
+	n.stopFlows(n.Namespace(netID))
+	n.flowsLock.Unlock()
+
+	n.meshLock.Lock()
+	err = nr.Delete()
+	n.meshLock.Unlock()
+	if err != nil {
 		return errors.Wrap(err, "failed to delete network resource")
 	}
 
+	if err := os.RemoveAll(n.servicesDirOf(netID)); err != nil {
+		log.Error().Err(err).Msg("failed to remove network services directory")
+	}
+
 	if err := n.releasePort(netNR.WGListenPort); err != nil {
 		log.Error().Err(err).Msg("release wireguard port failed")
 		// TODO: should we return the error ?
@@ -1087,6 +1325,11 @@ func (n *networker) DeleteNR(wl gridtypes.WorkloadID) error {
 		log.Error().Err(err).Msg("failed to remove file mapping between network ID and namespace")
 	}
 
+	// releases the snat address of the twin if it has no networks left
+	if err := n.applySNAT(); err != nil {
+		log.Error().Err(err).Msg("failed to apply snat rules")
+	}
+
 	return nil
 }
 
@@ -1095,8 +1338,19 @@ func (n *networker) Namespace(id zos.NetID) string {
 }
 
 func (n *networker) UnsetPublicConfig() error {
+	current, err := public.LoadPublicConfig()
+	if err != nil && err != public.ErrNoPublicConfig {
+		return errors.Wrap(err, "failed to load current public configuration")
+	}
+
+	// the uplink is not part of the public config, so it's kept
+	var cfg *pkg.PublicConfig
+	if current != nil && current.Uplink != nil {
+		cfg = &pkg.PublicConfig{Uplink: current.Uplink}
+	}
+
 	id := n.identity.NodeID(context.Background())
-	_, err := public.EnsurePublicSetup(id, environment.MustGet().PubVlan, nil)
+	_, err = public.EnsurePublicSetup(id, environment.MustGet().PubVlan, cfg)
 	return err
 }
 
@@ -1111,11 +1365,32 @@ func (n *networker) SetPublicConfig(cfg pkg.PublicConfig) error {
 		return errors.Wrapf(err, "failed to load current public configuration")
 	}
 
+	if current != nil && cfg.Uplink == nil {
+		// the public config from the chain does not have the uplink
+		cfg.Uplink = current.Uplink
+	}
+
+	if current != nil && cfg.Delegation == nil {
+		cfg.Delegation = current.Delegation
+	}
+
+	if current != nil && cfg.GatewayDomains == nil {
+		cfg.GatewayDomains = current.GatewayDomains
+	}
+
+	if current != nil && cfg.SNATPools == nil {
+		cfg.SNATPools = current.SNATPools
+	}
+
 	if current != nil && current.Equal(cfg) {
 		// nothing to do
 		return nil
 	}
 
+	if err := validSNATPools(cfg.SNATPools, &cfg); err != nil {
+		return errors.Wrap(err, "public config conflicts with the snat pools")
+	}
+
 	id := n.identity.NodeID(context.Background())
 	_, err = public.EnsurePublicSetup(id, environment.MustGet().PubVlan, &cfg)
 	if err != nil {
@@ -1126,6 +1401,15 @@ func (n *networker) SetPublicConfig(cfg pkg.PublicConfig) error {
 		return errors.Wrap(err, "failed to store public config")
 	}
 
+	if err := n.applyDelegation(cfg.Delegation); err != nil {
+		log.Error().Err(err).Msg("failed to apply ipv6 prefix delegation")
+	}
+
+	// the ndmz public interface might have changed
+	if err := n.applySNAT(); err != nil {
+		log.Error().Err(err).Msg("failed to apply snat rules")
+	}
+
 	// when public setup is updated. it can take a while but the capacityd
 	// will detect this change and take necessary actions to update the node
 	ctx := context.Background()
@@ -1234,22 +1518,6 @@ func (n *networker) networkOf(id zos.NetID) (nr pkg.Network, err error) {
 	return net, nil
 }
 
-func (n *networker) reservePort(port uint16) error {
-	log.Debug().Uint16("port", port).Msg("reserve wireguard port")
-	err := n.portSet.Add(uint(port))
-	if err != nil {
-		return errors.Wrap(err, "wireguard listen port already in use, pick another one")
-	}
-
-	return nil
-}
-
-func (n *networker) releasePort(port uint16) error {
-	log.Debug().Uint16("port", port).Msg("release wireguard port")
-	n.portSet.Remove(uint(port))
-	return nil
-}
-
 func (n *networker) DMZAddresses(ctx context.Context) <-chan pkg.NetlinkAddresses {
 	ch := make(chan pkg.NetlinkAddresses)
 	go func() {
@@ -1323,6 +1591,81 @@ func (n *networker) Metrics() (pkg.NetResourceMetrics, error) {
 	return metrics, nil
 }
 
+// WireguardStatus implements pkg.Networker interface
+func (n *networker) WireguardStatus(twin uint32) ([]pkg.NetworkWGStatus, error) {
+	links, err := os.ReadDir(n.linkDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list networks")
+	}
+
+	// the same network can be used by multiple workloads of the twin
+	seen := make(map[zos.NetID]struct{})
+	statuses := make([]pkg.NetworkWGStatus, 0)
+	for _, link := range links {
+		if link.IsDir() {
+			continue
+		}
+
+		wl := gridtypes.WorkloadID(link.Name())
+		owner, _, name, err := wl.Parts()
+		if err != nil || owner != twin {
+			continue
+		}
+
+		netID := zos.NetworkID(twin, name)
+		if _, ok := seen[netID]; ok {
+			continue
+		}
+		seen[netID] = struct{}{}
+
+		status := pkg.NetworkWGStatus{
+			Network: name,
+			NetID:   netID,
+		}
+
+		status.Peers, err = n.wgStatus(netID)
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to get network wireguard status")
+			status.Error = err.Error()
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (n *networker) wgStatus(netID zos.NetID) ([]pkg.WGPeerStatus, error) {
+	network, err := n.networkOf(netID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load network")
+	}
+
+	return nr.New(network, n.myceliumKeyDir).WGStatus()
+}
+
+// MyceliumPeers implements pkg.Networker interface
+func (n *networker) MyceliumPeers() ([]pkg.MyceliumPeer, error) {
+	if n.mycelium == nil {
+		return nil, fmt.Errorf("mycelium is not running")
+	}
+
+	peers := make([]pkg.MyceliumPeer, 0)
+	for _, peer := range n.mycelium.Peers() {
+		peers = append(peers, pkg.MyceliumPeer{
+			Endpoint:  peer.Endpoint,
+			Active:    peer.Active,
+			Connected: peer.Connected,
+			Latency:   peer.Latency,
+			Failures:  peer.Failures,
+			RxBytes:   peer.RxBytes,
+			TxBytes:   peer.TxBytes,
+		})
+	}
+
+	return peers, nil
+}
+
 func (n *networker) YggAddresses(ctx context.Context) <-chan pkg.NetlinkAddresses {
 	ch := make(chan pkg.NetlinkAddresses)
 	go func() {
@@ -1486,6 +1829,10 @@ func (n *networker) syncWGPorts() error {
 		_ = n.portSet.Add(uint(port))
 	}
 
+	if err := n.reconcileWGPorts(); err != nil {
+		log.Error().Err(err).Msg("failed to reconcile wireguard ports")
+	}
+
 	return nil
 }
 
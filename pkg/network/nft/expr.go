@@ -0,0 +1,377 @@
+package nft
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// The functions in this file build the nft expressions of the most common
+// matches and statements used by networkd. Each function returns the list of
+// expressions (including the needed dependencies) so they can be combined
+// with NewRule. All matches load into register 1.
+
+const (
+	// EtherTypeIPv4 ether type of ipv4
+	EtherTypeIPv4 = 0x0800
+	// EtherTypeIPv6 ether type of ipv6
+	EtherTypeIPv6 = 0x86dd
+	// EtherTypeARP ether type of arp
+	EtherTypeARP = 0x0806
+
+	// ARPReply arp reply operation
+	ARPReply = 2
+	// ARPRequest arp request operation
+	ARPRequest = 1
+
+	// ICMPv6RouterSolicitation icmpv6 router solicitation type
+	ICMPv6RouterSolicitation = 133
+	// ICMPv6RouterAdvertisement icmpv6 router advertisement type
+	ICMPv6RouterAdvertisement = 134
+	// ICMPv6NeighborSolicitation icmpv6 neighbor solicitation type
+	ICMPv6NeighborSolicitation = 135
+	// ICMPv6NeighborAdvertisement icmpv6 neighbor advertisement type
+	ICMPv6NeighborAdvertisement = 136
+
+	// ICMPAdminProhibited icmp destination unreachable code of
+	// administratively filtered packets
+	ICMPAdminProhibited = 13
+
+	// CtStatusDNAT conntrack status bit of destination natted connections
+	CtStatusDNAT = 1 << 5
+
+	ifNameSize = unix.IFNAMSIZ
+)
+
+func be16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func ne32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, v)
+	return b
+}
+
+func ifname(name string) []byte {
+	// a trailing * is a wildcard, we then only compare the prefix
+	if len(name) > 0 && name[len(name)-1] == '*' {
+		return []byte(name[:len(name)-1])
+	}
+
+	b := make([]byte, ifNameSize)
+	copy(b, name)
+	return b
+}
+
+func cmp(op expr.CmpOp, data []byte) *expr.Cmp {
+	return &expr.Cmp{Op: op, Register: 1, Data: data}
+}
+
+func meta(key expr.MetaKey) *expr.Meta {
+	return &expr.Meta{Key: key, Register: 1}
+}
+
+func payload(base expr.PayloadBase, offset, length uint32) *expr.Payload {
+	return &expr.Payload{DestRegister: 1, Base: base, Offset: offset, Len: length}
+}
+
+// IIfName matches the input interface name. Name can end with * to match
+// all interfaces with that prefix
+func IIfName(name string) []expr.Any {
+	return []expr.Any{
+		meta(expr.MetaKeyIIFNAME),
+		cmp(expr.CmpOpEq, ifname(name)),
+	}
+}
+
+// OIfName matches the output interface name. Name can end with * to match
+// all interfaces with that prefix
+func OIfName(name string) []expr.Any {
+	return []expr.Any{
+		meta(expr.MetaKeyOIFNAME),
+		cmp(expr.CmpOpEq, ifname(name)),
+	}
+}
+
+// IIfLoopback matches packets received over the loopback interface
+func IIfLoopback() []expr.Any {
+	return IIfName("lo")
+}
+
+// EtherType matches the packet ether type (meta protocol)
+func EtherType(typ uint16) []expr.Any {
+	return []expr.Any{
+		meta(expr.MetaKeyPROTOCOL),
+		cmp(expr.CmpOpEq, be16(typ)),
+	}
+}
+
+// NFProto matches the protocol family of the packet in the inet family (for
+// example unix.NFPROTO_IPV4)
+func NFProto(proto byte) []expr.Any {
+	return []expr.Any{
+		meta(expr.MetaKeyNFPROTO),
+		cmp(expr.CmpOpEq, []byte{proto}),
+	}
+}
+
+// L4Proto matches the transport protocol (for example unix.IPPROTO_TCP)
+func L4Proto(proto byte) []expr.Any {
+	return []expr.Any{
+		meta(expr.MetaKeyL4PROTO),
+		cmp(expr.CmpOpEq, []byte{proto}),
+	}
+}
+
+func ct(key expr.CtKey, bits uint32) []expr.Any {
+	return []expr.Any{
+		&expr.Ct{Register: 1, Key: key},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            4,
+			Mask:           ne32(bits),
+			Xor:            ne32(0),
+		},
+		cmp(expr.CmpOpNeq, ne32(0)),
+	}
+}
+
+// CtState matches if the connection state is any of the given state bits
+// (for example expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED)
+func CtState(bits uint32) []expr.Any {
+	return ct(expr.CtKeySTATE, bits)
+}
+
+// CtStatus matches if the connection status has any of the given status
+// bits (for example CtStatusDNAT)
+func CtStatus(bits uint32) []expr.Any {
+	return ct(expr.CtKeySTATUS, bits)
+}
+
+// CtMarkSet sets the connection mark
+func CtMarkSet(mark uint32) []expr.Any {
+	return []expr.Any{
+		&expr.Immediate{Register: 1, Data: ne32(mark)},
+		&expr.Ct{Register: 1, Key: expr.CtKeyMARK, SourceRegister: true},
+	}
+}
+
+func addr(ip net.IP, v4Offset, v6Offset uint32, op expr.CmpOp, mask net.IPMask) []expr.Any {
+	typ := uint16(EtherTypeIPv6)
+	offset := v6Offset
+	length := uint32(net.IPv6len)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		typ = EtherTypeIPv4
+		offset = v4Offset
+		length = net.IPv4len
+	} else {
+		ip = ip.To16()
+	}
+
+	exprs := append(EtherType(typ), payload(expr.PayloadBaseNetworkHeader, offset, length))
+
+	if mask != nil {
+		ones, bits := mask.Size()
+		if ones != bits {
+			if len(mask) != len(ip) {
+				mask = mask[len(mask)-len(ip):]
+			}
+			exprs = append(exprs, &expr.Bitwise{
+				SourceRegister: 1,
+				DestRegister:   1,
+				Len:            length,
+				Mask:           []byte(mask),
+				Xor:            make([]byte, length),
+			})
+			ip = ip.Mask(mask)
+		}
+	}
+
+	return append(exprs, cmp(op, []byte(ip)))
+}
+
+// SAddr matches the source address of an ipv4 or ipv6 packet
+func SAddr(ip net.IP) []expr.Any {
+	return addr(ip, 12, 8, expr.CmpOpEq, nil)
+}
+
+// SAddrNot matches packets with a source address other than ip
+func SAddrNot(ip net.IP) []expr.Any {
+	return addr(ip, 12, 8, expr.CmpOpNeq, nil)
+}
+
+// DAddr matches the destination address of an ipv4 or ipv6 packet
+func DAddr(ip net.IP) []expr.Any {
+	return addr(ip, 16, 24, expr.CmpOpEq, nil)
+}
+
+// DAddrNot matches packets with a destination address other than ip
+func DAddrNot(ip net.IP) []expr.Any {
+	return addr(ip, 16, 24, expr.CmpOpNeq, nil)
+}
+
+// SNet matches packets with source address in the given network
+func SNet(n net.IPNet) []expr.Any {
+	return addr(n.IP, 12, 8, expr.CmpOpEq, n.Mask)
+}
+
+// DNet matches packets with destination address in the given network
+func DNet(n net.IPNet) []expr.Any {
+	return addr(n.IP, 16, 24, expr.CmpOpEq, n.Mask)
+}
+
+// EtherSAddrNot matches frames with a source mac other than mac
+func EtherSAddrNot(mac net.HardwareAddr) []expr.Any {
+	return []expr.Any{
+		payload(expr.PayloadBaseLLHeader, 6, 6),
+		cmp(expr.CmpOpNeq, []byte(mac)),
+	}
+}
+
+// EtherDAddrNot matches frames with a destination mac other than mac
+func EtherDAddrNot(mac net.HardwareAddr) []expr.Any {
+	return []expr.Any{
+		payload(expr.PayloadBaseLLHeader, 0, 6),
+		cmp(expr.CmpOpNeq, []byte(mac)),
+	}
+}
+
+// ARPOperation matches the arp operation (ARPRequest, ARPReply)
+func ARPOperation(op uint16) []expr.Any {
+	return append(EtherType(EtherTypeARP),
+		payload(expr.PayloadBaseNetworkHeader, 6, 2),
+		cmp(expr.CmpOpEq, be16(op)),
+	)
+}
+
+func arpSAddrIP(ip net.IP, op expr.CmpOp) []expr.Any {
+	ip = ip.To4()
+	if ip == nil {
+		ip = net.IPv4zero.To4()
+	}
+
+	return []expr.Any{
+		payload(expr.PayloadBaseNetworkHeader, 14, 4),
+		cmp(op, []byte(ip)),
+	}
+}
+
+// ARPSAddrIP matches arp packets where the sender ip is ip
+func ARPSAddrIP(ip net.IP) []expr.Any {
+	return arpSAddrIP(ip, expr.CmpOpEq)
+}
+
+// ARPSAddrIPNot matches arp packets where the sender ip is not ip
+func ARPSAddrIPNot(ip net.IP) []expr.Any {
+	return arpSAddrIP(ip, expr.CmpOpNeq)
+}
+
+func dport(proto byte, from, to uint16) []expr.Any {
+	exprs := append(L4Proto(proto), payload(expr.PayloadBaseTransportHeader, 2, 2))
+	if to == 0 || to == from {
+		return append(exprs, cmp(expr.CmpOpEq, be16(from)))
+	}
+
+	return append(exprs, &expr.Range{
+		Op:       expr.CmpOpEq,
+		Register: 1,
+		FromData: be16(from),
+		ToData:   be16(to),
+	})
+}
+
+// TCPDPort matches tcp destination port in the range [from, to]. If to
+// is zero only from port is matched
+func TCPDPort(from, to uint16) []expr.Any {
+	return dport(unix.IPPROTO_TCP, from, to)
+}
+
+// UDPDPort matches udp destination port in the range [from, to]. If to
+// is zero only from port is matched
+func UDPDPort(from, to uint16) []expr.Any {
+	return dport(unix.IPPROTO_UDP, from, to)
+}
+
+// ICMPv6Type matches the icmpv6 type (for example ICMPv6NeighborSolicitation)
+func ICMPv6Type(typ byte) []expr.Any {
+	return append(L4Proto(unix.IPPROTO_ICMPV6),
+		payload(expr.PayloadBaseTransportHeader, 0, 1),
+		cmp(expr.CmpOpEq, []byte{typ}),
+	)
+}
+
+// Counter statement
+func Counter() []expr.Any {
+	return []expr.Any{&expr.Counter{}}
+}
+
+// Masquerade statement with fully random port mapping
+func Masquerade() []expr.Any {
+	return []expr.Any{&expr.Masq{FullyRandom: true}}
+}
+
+// DNat destination nat statement to the given ipv4 address and port. The
+// rule must only match ipv4 packets with a transport header (tcp or udp)
+func DNat(ip net.IP, port uint16) []expr.Any {
+	return []expr.Any{
+		&expr.Immediate{Register: 1, Data: []byte(ip.To4())},
+		&expr.Immediate{Register: 2, Data: be16(port)},
+		&expr.NAT{
+			Type:        expr.NATTypeDestNAT,
+			Family:      unix.NFPROTO_IPV4,
+			RegAddrMin:  1,
+			RegProtoMin: 2,
+		},
+	}
+}
+
+// SNat source nat statement to the given ipv4 address with fully random
+// port mapping
+func SNat(ip net.IP) []expr.Any {
+	return []expr.Any{
+		&expr.Immediate{Register: 1, Data: []byte(ip.To4())},
+		&expr.NAT{
+			Type:        expr.NATTypeSourceNAT,
+			Family:      unix.NFPROTO_IPV4,
+			RegAddrMin:  1,
+			FullyRandom: true,
+		},
+	}
+}
+
+func verdict(kind expr.VerdictKind, chain string) []expr.Any {
+	return []expr.Any{&expr.Verdict{Kind: kind, Chain: chain}}
+}
+
+// Accept verdict
+func Accept() []expr.Any {
+	return verdict(expr.VerdictAccept, "")
+}
+
+// Drop verdict
+func Drop() []expr.Any {
+	return verdict(expr.VerdictDrop, "")
+}
+
+// RejectAdminProhibited rejects the packet with an icmp admin prohibited
+// error. The rule must only match ipv4 packets
+func RejectAdminProhibited() []expr.Any {
+	return []expr.Any{&expr.Reject{Type: unix.NFT_REJECT_ICMP_UNREACH, Code: ICMPAdminProhibited}}
+}
+
+// Return verdict
+func Return() []expr.Any {
+	return verdict(expr.VerdictReturn, "")
+}
+
+// Jump verdict
+func Jump(chain string) []expr.Any {
+	return verdict(expr.VerdictJump, chain)
+}
@@ -0,0 +1,190 @@
+package nft
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestIfName(t *testing.T) {
+	require := require.New(t)
+
+	exprs := IIfName("public")
+	require.Len(exprs, 2)
+	require.Equal(&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1}, exprs[0])
+	data := exprs[1].(*expr.Cmp).Data
+	require.Len(data, ifNameSize)
+	require.Equal([]byte("public"), data[:6])
+
+	// wildcard only compares the prefix
+	exprs = OIfName("b-*")
+	require.Equal([]byte("b-"), exprs[1].(*expr.Cmp).Data)
+}
+
+func TestAddr(t *testing.T) {
+	require := require.New(t)
+
+	exprs := SAddrNot(net.ParseIP("185.69.166.10"))
+	require.Equal([]expr.Any{
+		&expr.Meta{Key: expr.MetaKeyPROTOCOL, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x08, 0x00}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{185, 69, 166, 10}},
+	}, exprs)
+
+	_, n, err := net.ParseCIDR("2001:db8::/32")
+	require.NoError(err)
+	exprs = DNet(*n)
+	require.Len(exprs, 5)
+	require.Equal(&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x86, 0xdd}}, exprs[1])
+	require.Equal(&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 24, Len: 16}, exprs[2])
+	bitwise := exprs[3].(*expr.Bitwise)
+	require.Equal([]byte(n.Mask), bitwise.Mask)
+	require.Equal([]byte(n.IP), exprs[4].(*expr.Cmp).Data)
+
+	// a full mask does not need a bitwise expression
+	exprs = SNet(net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)})
+	require.Len(exprs, 4)
+}
+
+func TestPorts(t *testing.T) {
+	require := require.New(t)
+
+	exprs := TCPDPort(22, 0)
+	require.Equal(&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 22}}, exprs[3])
+
+	exprs = UDPDPort(8000, 8080)
+	require.Equal(&expr.Range{Op: expr.CmpOpEq, Register: 1, FromData: []byte{0x1f, 0x40}, ToData: []byte{0x1f, 0x90}}, exprs[3])
+}
+
+func TestDNat(t *testing.T) {
+	require := require.New(t)
+
+	exprs := DNat(net.ParseIP("100.127.0.10"), 8080)
+	require.Equal([]expr.Any{
+		&expr.Immediate{Register: 1, Data: []byte{100, 127, 0, 10}},
+		&expr.Immediate{Register: 2, Data: []byte{0x1f, 0x90}},
+		&expr.NAT{Type: expr.NATTypeDestNAT, Family: unix.NFPROTO_IPV4, RegAddrMin: 1, RegProtoMin: 2},
+	}, exprs)
+
+	exprs = CtStatus(CtStatusDNAT)
+	require.Equal(&expr.Ct{Register: 1, Key: expr.CtKeySTATUS}, exprs[0])
+}
+
+func TestSNat(t *testing.T) {
+	require := require.New(t)
+
+	exprs := SNat(net.ParseIP("185.69.166.10"))
+	require.Equal([]expr.Any{
+		&expr.Immediate{Register: 1, Data: []byte{185, 69, 166, 10}},
+		&expr.NAT{Type: expr.NATTypeSourceNAT, Family: unix.NFPROTO_IPV4, RegAddrMin: 1, FullyRandom: true},
+	}, exprs)
+}
+
+func TestRulesEqual(t *testing.T) {
+	require := require.New(t)
+
+	desired := []Rule{
+		NewRule("", IIfName("public"), Counter(), Drop()),
+		NewRule("jump fw", Jump("fw")),
+	}
+
+	current := []*nftables.Rule{
+		{
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifname("public")},
+				&expr.Counter{Bytes: 100, Packets: 2},
+				&expr.Verdict{Kind: expr.VerdictDrop},
+			},
+		},
+		{
+			Exprs:    []expr.Any{&expr.Verdict{Kind: expr.VerdictJump, Chain: "fw"}},
+			UserData: desired[1].userData(),
+		},
+	}
+
+	require.True(rulesEqual(nftables.TableFamilyINet, current, desired))
+	require.Equal("fw", jumpTarget(current[1]))
+	require.Equal("jump fw", comment(current[1]))
+
+	current[1].UserData = nil
+	require.False(rulesEqual(nftables.TableFamilyINet, current, desired))
+	require.False(rulesEqual(nftables.TableFamilyINet, current[:1], desired))
+}
+
+// decode simulates loading the expression back from the kernel, the
+// expression is encoded and then decoded into a new empty expression
+func decode(t *testing.T, family nftables.TableFamily, e expr.Any) expr.Any {
+	data, err := expr.Marshal(byte(family), e)
+	require.NoError(t, err)
+
+	// the encoding is the expression name attribute followed by the
+	// nested expression data attribute
+	attr := func(b []byte) ([]byte, []byte) {
+		size := int(binary.NativeEndian.Uint16(b))
+		return b[4:size], b[(size+3)&^3:]
+	}
+	_, rest := attr(data)
+	inner, _ := attr(rest)
+
+	decoded := reflect.New(reflect.TypeOf(e).Elem()).Interface().(expr.Any)
+	require.NoError(t, expr.Unmarshal(byte(family), inner, decoded))
+	return decoded
+}
+
+func TestRulesEqualDecoded(t *testing.T) {
+	require := require.New(t)
+
+	_, subnet, err := net.ParseCIDR("10.1.0.0/16")
+	require.NoError(err)
+
+	desired := []Rule{
+		NewRule("established", CtState(expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED), Accept()),
+		NewRule("", IIfName("b-*"), NFProto(unix.NFPROTO_IPV4), TCPDPort(25, 0), RejectAdminProhibited()),
+		NewRule("", SNet(*subnet), UDPDPort(8000, 8080), Counter(), Drop()),
+		NewRule("", EtherSAddrNot(net.HardwareAddr{2, 0, 0, 0, 0, 1}), ARPSAddrIPNot(net.ParseIP("185.69.166.10")), Drop()),
+		NewRule("", DAddr(net.ParseIP("185.69.166.10")), TCPDPort(8080, 0), DNat(net.ParseIP("10.1.2.3"), 80)),
+		NewRule("", SNet(*subnet), SNat(net.ParseIP("185.69.166.11"))),
+		NewRule("", CtStatus(CtStatusDNAT), Masquerade()),
+		NewRule("jump fw", Jump("fw")),
+	}
+
+	for _, family := range []nftables.TableFamily{nftables.TableFamilyINet, nftables.TableFamilyBridge} {
+		var current []*nftables.Rule
+		for _, rule := range desired {
+			loaded := &nftables.Rule{UserData: rule.userData()}
+			for _, e := range rule.Exprs {
+				loaded.Exprs = append(loaded.Exprs, decode(t, family, e))
+			}
+			current = append(current, loaded)
+		}
+
+		// applying the same rules again must not change anything
+		require.True(rulesEqual(family, current, desired))
+
+		// a changed rule is detected
+		changed := append([]Rule{}, desired...)
+		changed[2] = NewRule("", SNet(*subnet), UDPDPort(8000, 8081), Counter(), Drop())
+		require.False(rulesEqual(family, current, changed))
+	}
+}
+
+func TestRejectAdminProhibited(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.NFPROTO_IPV4}},
+	}, NFProto(unix.NFPROTO_IPV4))
+
+	require.Equal([]expr.Any{
+		&expr.Reject{Type: unix.NFT_REJECT_ICMP_UNREACH, Code: 13},
+	}, RejectAdminProhibited())
+}
@@ -0,0 +1,87 @@
+package nft
+
+import (
+	"fmt"
+
+	"github.com/google/nftables/expr"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"golang.org/x/sys/unix"
+)
+
+// FirewallRules builds the user firewall rules of the given direction, in the
+// same order they were defined by the user. The direction policy is appended
+// as a final rule.
+//
+// The rules are meant to be placed in a regular chain that is jumped to from
+// a base chain, hence accepted traffic `return`s to the calling chain instead
+// of being accepted directly, so the rest of the base chain is still evaluated.
+func FirewallRules(fw *zos.Firewall, direction zos.FirewallDirection) []Rule {
+	if fw == nil {
+		return nil
+	}
+
+	var rules []Rule
+	for i, rule := range fw.Rules {
+		if rule.Direction != direction {
+			continue
+		}
+
+		rules = append(rules, firewallRule(i, &rule)...)
+	}
+
+	if fw.Policy(direction) == zos.FirewallDrop {
+		rules = append(rules, NewRule(fmt.Sprintf("%s policy", direction), Counter(), Drop()))
+	}
+
+	return rules
+}
+
+func firewallRule(index int, rule *zos.FirewallRule) []Rule {
+	var match []expr.Any
+
+	if !rule.CIDR.Nil() {
+		if rule.Direction == zos.FirewallEgress {
+			match = append(match, DNet(rule.CIDR.IPNet)...)
+		} else {
+			match = append(match, SNet(rule.CIDR.IPNet)...)
+		}
+	}
+
+	// icmp protocol matches both icmp and icmpv6, if the cidr is not set
+	// this needs 2 separate rules
+	protocols := [][]expr.Any{nil}
+	switch rule.Protocol {
+	case zos.FirewallProtocolTCP, zos.FirewallProtocolUDP:
+		proto := byte(unix.IPPROTO_TCP)
+		if rule.Protocol == zos.FirewallProtocolUDP {
+			proto = unix.IPPROTO_UDP
+		}
+
+		if rule.FromPort == 0 {
+			protocols = [][]expr.Any{L4Proto(proto)}
+		} else {
+			protocols = [][]expr.Any{dport(proto, rule.FromPort, rule.ToPort)}
+		}
+	case zos.FirewallProtocolICMP:
+		switch {
+		case rule.CIDR.Nil():
+			protocols = [][]expr.Any{L4Proto(unix.IPPROTO_ICMP), L4Proto(unix.IPPROTO_ICMPV6)}
+		case rule.CIDR.IP.To4() != nil:
+			protocols = [][]expr.Any{L4Proto(unix.IPPROTO_ICMP)}
+		default:
+			protocols = [][]expr.Any{L4Proto(unix.IPPROTO_ICMPV6)}
+		}
+	}
+
+	action := Return()
+	if rule.Action == zos.FirewallDrop {
+		action = Drop()
+	}
+
+	var rules []Rule
+	for _, proto := range protocols {
+		rules = append(rules, NewRule(fmt.Sprintf("rule %d", index), match, proto, Counter(), action))
+	}
+
+	return rules
+}
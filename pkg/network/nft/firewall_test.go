@@ -0,0 +1,69 @@
+package nft
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"golang.org/x/sys/unix"
+)
+
+func TestFirewallRules(t *testing.T) {
+	require := require.New(t)
+
+	require.Empty(FirewallRules(nil, zos.FirewallIngress))
+
+	fw := zos.Firewall{
+		IngressPolicy: zos.FirewallDrop,
+		Rules: []zos.FirewallRule{
+			{
+				Direction: zos.FirewallIngress,
+				Protocol:  zos.FirewallProtocolTCP,
+				FromPort:  22,
+				CIDR:      gridtypes.MustParseIPNet("10.0.0.0/8"),
+				Action:    zos.FirewallAccept,
+			},
+			{
+				Direction: zos.FirewallIngress,
+				Protocol:  zos.FirewallProtocolUDP,
+				FromPort:  8000,
+				ToPort:    8080,
+				Action:    zos.FirewallAccept,
+			},
+			{
+				Direction: zos.FirewallIngress,
+				Protocol:  zos.FirewallProtocolICMP,
+				Action:    zos.FirewallAccept,
+			},
+			{
+				Direction: zos.FirewallEgress,
+				Protocol:  zos.FirewallProtocolAny,
+				CIDR:      gridtypes.MustParseIPNet("192.168.1.0/24"),
+				Action:    zos.FirewallDrop,
+			},
+			{
+				Direction: zos.FirewallEgress,
+				Protocol:  zos.FirewallProtocolTCP,
+				Action:    zos.FirewallDrop,
+			},
+		},
+	}
+
+	_, src, _ := net.ParseCIDR("10.0.0.0/8")
+	_, dst, _ := net.ParseCIDR("192.168.1.0/24")
+
+	require.Equal([]Rule{
+		NewRule("rule 0", SNet(*src), TCPDPort(22, 0), Counter(), Return()),
+		NewRule("rule 1", UDPDPort(8000, 8080), Counter(), Return()),
+		NewRule("rule 2", L4Proto(unix.IPPROTO_ICMP), Counter(), Return()),
+		NewRule("rule 2", L4Proto(unix.IPPROTO_ICMPV6), Counter(), Return()),
+		NewRule("ingress policy", Counter(), Drop()),
+	}, FirewallRules(&fw, zos.FirewallIngress))
+
+	require.Equal([]Rule{
+		NewRule("rule 3", DNet(*dst), Counter(), Drop()),
+		NewRule("rule 4", L4Proto(unix.IPPROTO_TCP), Counter(), Drop()),
+	}, FirewallRules(&fw, zos.FirewallEgress))
+}
@@ -1,34 +1,456 @@
 package nft
 
 import (
-	"io"
-	"os/exec"
-
-	"github.com/rs/zerolog/log"
+	"bytes"
+	"fmt"
 
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
 )
 
-// Apply applies the ntf configuration contained in the reader r
-// if ns is specified, the nft command is execute in the network namespace names ns
-func Apply(r io.Reader, ns string) error {
-	var cmd *exec.Cmd
+// Table is the declarative definition of an nft table and its chains
+type Table struct {
+	Family nftables.TableFamily
+	Name   string
+	Chains []Chain
+}
+
+// Chain is the declarative definition of an nft chain. If Hook is set
+// the chain is a base chain, otherwise it's a regular chain that can only
+// be jumped to.
+type Chain struct {
+	Name     string
+	Type     nftables.ChainType
+	Hook     *nftables.ChainHook
+	Priority *nftables.ChainPriority
+	Policy   *nftables.ChainPolicy
+	Rules    []Rule
+}
+
+// Rule is a single nft rule. The comment is stored with the rule so it shows
+// up in `nft list` output.
+type Rule struct {
+	Comment string
+	Exprs   []expr.Any
+}
+
+// NewRule creates a rule from the given list of matches and statements
+func NewRule(comment string, parts ...[]expr.Any) Rule {
+	var exprs []expr.Any
+	for _, part := range parts {
+		exprs = append(exprs, part...)
+	}
+
+	return Rule{Comment: comment, Exprs: exprs}
+}
+
+func (r *Rule) userData() []byte {
+	if len(r.Comment) == 0 {
+		return nil
+	}
+
+	return userdata.AppendString(nil, userdata.TypeComment, r.Comment)
+}
+
+// BaseChain creates a base chain hooked in the given hook
+func BaseChain(name string, typ nftables.ChainType, hook *nftables.ChainHook, priority int32, policy nftables.ChainPolicy, rules ...Rule) Chain {
+	return Chain{
+		Name:     name,
+		Type:     typ,
+		Hook:     hook,
+		Priority: nftables.ChainPriorityRef(nftables.ChainPriority(priority)),
+		Policy:   &policy,
+		Rules:    rules,
+	}
+}
+
+// RegularChain creates a regular (non base) chain
+func RegularChain(name string, rules ...Rule) Chain {
+	return Chain{
+		Name:  name,
+		Rules: rules,
+	}
+}
+
+func (t *Table) table() *nftables.Table {
+	return &nftables.Table{Family: t.Family, Name: t.Name}
+}
+
+func (c *Chain) chain(table *nftables.Table) *nftables.Chain {
+	return &nftables.Chain{
+		Name:     c.Name,
+		Table:    table,
+		Type:     c.Type,
+		Hooknum:  c.Hook,
+		Priority: c.Priority,
+		Policy:   c.Policy,
+	}
+}
+
+func addRules(conn *nftables.Conn, chain *nftables.Chain, rules []Rule) {
+	for i := range rules {
+		conn.AddRule(&nftables.Rule{
+			Table:    chain.Table,
+			Chain:    chain,
+			Exprs:    rules[i].Exprs,
+			UserData: rules[i].userData(),
+		})
+	}
+}
+
+// connect opens an nft connection to the given namespace. If ns is empty
+// the host namespace is used
+func connect(ns string) (*nftables.Conn, func(), error) {
+	if len(ns) == 0 {
+		conn, err := nftables.New()
+		return conn, func() {}, err
+	}
+
+	netNS, err := namespace.GetByName(ns)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get namespace '%s'", ns)
+	}
+
+	conn, err := nftables.New(nftables.WithNetNSFd(int(netNS.Fd())))
+	if err != nil {
+		netNS.Close()
+		return nil, nil, err
+	}
+
+	return conn, func() { netNS.Close() }, nil
+}
+
+// ApplyRuleset atomically replaces the full ruleset of the namespace ns with
+// the given tables. If ns is empty the host namespace is used
+func ApplyRuleset(ns string, tables ...Table) error {
+	conn, closer, err := connect(ns)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	conn.FlushRuleset()
+	for _, t := range tables {
+		table := conn.AddTable(t.table())
+		for _, c := range t.Chains {
+			chain := conn.AddChain(c.chain(table))
+			addRules(conn, chain, c.Rules)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return errors.Wrap(err, "failed to apply nft ruleset")
+	}
+
+	return nil
+}
+
+// ApplyChains makes sure the table and all its defined chains exist with
+// exactly the given rules. Other chains in the table are not touched. Chains
+// that already have the desired rules are left as is, otherwise they are
+// flushed and their rules are replaced. All changes are applied in a single
+// transaction.
+func ApplyChains(ns string, t Table) error {
+	conn, closer, err := connect(ns)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	existing, err := listChains(conn, t.Family, t.Name)
+	if err != nil {
+		return err
+	}
+
+	table := conn.AddTable(t.table())
+	for _, c := range t.Chains {
+		chain := c.chain(table)
+		if _, ok := existing[c.Name]; ok {
+			current, err := conn.GetRules(table, chain)
+			if err != nil {
+				return errors.Wrapf(err, "failed to list rules of chain '%s'", c.Name)
+			}
+
+			if rulesEqual(t.Family, current, c.Rules) {
+				continue
+			}
+
+			log.Debug().Str("table", t.Name).Str("chain", c.Name).Msg("updating nft chain")
+			conn.FlushChain(chain)
+		} else {
+			chain = conn.AddChain(chain)
+		}
+
+		addRules(conn, chain, c.Rules)
+	}
+
+	if err := conn.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to apply chains of table '%s'", t.Name)
+	}
+
+	return nil
+}
+
+// EnsureChains makes sure the table and all its defined chains exist. Rules
+// of existing chains are not touched, the defined rules are only added to
+// new chains. Existing chains that are not hooked like their definition (left
+// over by older versions) are deleted and created again, which only works if
+// they are empty. All changes are applied in a single transaction.
+func EnsureChains(ns string, t Table) error {
+	conn, closer, err := connect(ns)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	existing, err := listChains(conn, t.Family, t.Name)
+	if err != nil {
+		return err
+	}
+
+	table := conn.AddTable(t.table())
+	for _, c := range t.Chains {
+		if current, ok := existing[c.Name]; ok {
+			if hookEqual(current.Hooknum, c.Hook) {
+				continue
+			}
+
+			log.Debug().Str("table", t.Name).Str("chain", c.Name).Msg("recreating nft chain with wrong hook")
+			conn.DelChain(current)
+		}
+
+		chain := conn.AddChain(c.chain(table))
+		addRules(conn, chain, c.Rules)
+	}
+
+	if err := conn.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to ensure chains of table '%s'", t.Name)
+	}
+
+	return nil
+}
+
+func hookEqual(a, b *nftables.ChainHook) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// EnsureRule appends the rule to an existing chain if the chain has no rule
+// with the same comment. The rule comment is required. If the rule is a jump
+// an existing jump to the same chain is also considered the same rule.
+func EnsureRule(ns string, family nftables.TableFamily, table, chain string, rule Rule) error {
+	return ensureRule(ns, family, table, chain, rule, false)
+}
+
+// InsertRule is like EnsureRule but the rule is added at the start of the
+// chain, so it's evaluated before the existing rules
+func InsertRule(ns string, family nftables.TableFamily, table, chain string, rule Rule) error {
+	return ensureRule(ns, family, table, chain, rule, true)
+}
+
+func ensureRule(ns string, family nftables.TableFamily, table, chain string, rule Rule, insert bool) error {
+	if len(rule.Comment) == 0 {
+		return fmt.Errorf("rule comment is required")
+	}
+
+	conn, closer, err := connect(ns)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	t := &nftables.Table{Family: family, Name: table}
+	c := &nftables.Chain{Table: t, Name: chain}
+	rules, err := conn.GetRules(t, c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list rules of chain '%s'", chain)
+	}
+
+	target := jumpTarget(&nftables.Rule{Exprs: rule.Exprs})
+	for _, r := range rules {
+		if comment(r) == rule.Comment {
+			return nil
+		}
+
+		if len(target) != 0 && jumpTarget(r) == target {
+			return nil
+		}
+	}
 
-	if ns != "" {
-		cmd = exec.Command("ip", "netns", "exec", ns, "nft", "-f", "-")
+	if insert {
+		conn.InsertRule(&nftables.Rule{
+			Table:    t,
+			Chain:    c,
+			Exprs:    rule.Exprs,
+			UserData: rule.userData(),
+		})
 	} else {
-		cmd = exec.Command("nft", "-f", "-")
+		addRules(conn, c, []Rule{rule})
 	}
 
-	cmd.Stdin = r
+	if err := conn.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to add rule to chain '%s'", chain)
+	}
+
+	return nil
+}
 
-	out, err := cmd.CombinedOutput()
+// DeleteChains deletes the given chains from the table. Rules in other chains
+// of the same table that jump to any of the deleted chains are deleted as
+// well. Chains that do not exist are ignored.
+func DeleteChains(ns string, family nftables.TableFamily, table string, names ...string) error {
+	conn, closer, err := connect(ns)
 	if err != nil {
-		log.Error().Err(err).Str("output", string(out)).Msg("error during nft")
-		if eerr, ok := err.(*exec.ExitError); ok {
-			return errors.Wrapf(err, "failed to execute nft: %v", string(eerr.Stderr))
+		return err
+	}
+	defer closer()
+
+	existing, err := listChains(conn, family, table)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[string]struct{})
+	for _, name := range names {
+		if _, ok := existing[name]; ok {
+			deleted[name] = struct{}{}
 		}
-		return errors.Wrap(err, "failed to execute nft")
 	}
+
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	for name, chain := range existing {
+		if _, ok := deleted[name]; ok {
+			conn.FlushChain(chain)
+			continue
+		}
+
+		rules, err := conn.GetRules(chain.Table, chain)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list rules of chain '%s'", name)
+		}
+
+		for _, rule := range rules {
+			if _, ok := deleted[jumpTarget(rule)]; !ok {
+				continue
+			}
+
+			if err := conn.DelRule(rule); err != nil {
+				return errors.Wrapf(err, "failed to delete jump rule in chain '%s'", name)
+			}
+		}
+	}
+
+	for name := range deleted {
+		conn.DelChain(existing[name])
+	}
+
+	if err := conn.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to delete chains from table '%s'", table)
+	}
+
 	return nil
 }
+
+// ChainExists checks if a chain exists in the given table
+func ChainExists(ns string, family nftables.TableFamily, table, chain string) (bool, error) {
+	conn, closer, err := connect(ns)
+	if err != nil {
+		return false, err
+	}
+	defer closer()
+
+	chains, err := listChains(conn, family, table)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := chains[chain]
+	return ok, nil
+}
+
+func listChains(conn *nftables.Conn, family nftables.TableFamily, table string) (map[string]*nftables.Chain, error) {
+	chains, err := conn.ListChainsOfTableFamily(family)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nft chains")
+	}
+
+	result := make(map[string]*nftables.Chain)
+	for _, chain := range chains {
+		if chain.Table.Name != table {
+			continue
+		}
+		result[chain.Name] = chain
+	}
+
+	return result, nil
+}
+
+func comment(rule *nftables.Rule) string {
+	value, _ := userdata.GetString(rule.UserData, userdata.TypeComment)
+	return value
+}
+
+func jumpTarget(rule *nftables.Rule) string {
+	for _, e := range rule.Exprs {
+		if verdict, ok := e.(*expr.Verdict); ok && (verdict.Kind == expr.VerdictJump || verdict.Kind == expr.VerdictGoto) {
+			return verdict.Chain
+		}
+	}
+
+	return ""
+}
+
+// normalize returns the netlink encoding of the expression. Expressions
+// loaded from the kernel can have fields set that are left empty by the
+// builders (and the other way around) while they encode to the same
+// expression, so the encoding is compared instead of the structs. Counter
+// values are ignored.
+func normalize(family nftables.TableFamily, e expr.Any) ([]byte, error) {
+	if _, ok := e.(*expr.Counter); ok {
+		e = &expr.Counter{}
+	}
+
+	return expr.Marshal(byte(family), e)
+}
+
+// rulesEqual compares the rules as loaded from the kernel with the
+// desired rules.
+func rulesEqual(family nftables.TableFamily, current []*nftables.Rule, desired []Rule) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+
+	for i, rule := range current {
+		if comment(rule) != desired[i].Comment {
+			return false
+		}
+
+		if len(rule.Exprs) != len(desired[i].Exprs) {
+			return false
+		}
+
+		for j, e := range rule.Exprs {
+			a, err := normalize(family, e)
+			if err != nil {
+				return false
+			}
+
+			b, err := normalize(family, desired[i].Exprs[j])
+			if err != nil || !bytes.Equal(a, b) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,66 @@
+package nr
+
+import (
+	"fmt"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/tc"
+	"github.com/vishvananda/netlink"
+)
+
+// SetBandwidth applies the bandwidth limits of the network resource to its
+// public interface (to the ndmz). All the traffic leaving the node is limited,
+// that includes the wireguard traffic to the other peers of the network since
+// it also goes over the public interface. The egress limit shapes the traffic
+// leaving the public interface, and the ingress limit the traffic arriving on
+// it (redirected to an ifb device). Limits are removed if the network resource
+// has none.
+func (nr *NetResource) SetBandwidth() error {
+	var ingress, egress uint64
+	if bw := nr.resource.Bandwidth; bw != nil {
+		ingress, egress = uint64(bw.Ingress), uint64(bw.Egress)
+	}
+
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return err
+	}
+
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return fmt.Errorf("network namespace %s does not exits", nsName)
+	}
+	defer netNS.Close()
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		// older versions limited the ingress on the nr interface, which
+		// also limited the traffic coming from the other peers
+		if link, err := netlink.LinkByName(nrIface); err == nil {
+			if err := tc.ClearRateLimit(link); err != nil {
+				return errors.Wrapf(err, "failed to clear bandwidth limit of '%s'", nrIface)
+			}
+		}
+
+		link, err := netlink.LinkByName("public")
+		if err != nil {
+			return errors.Wrap(err, "failed to get interface 'public'")
+		}
+
+		if err := tc.SetRateLimit(link, egress); err != nil {
+			return errors.Wrap(err, "failed to set egress bandwidth limit")
+		}
+
+		if err := tc.SetIngressRateLimit(link, ingress); err != nil {
+			return errors.Wrap(err, "failed to set ingress bandwidth limit")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,740 @@
+package nr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/environment"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/zinit"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	meshTunnelPrefix = "t-"
+	// meshMTU is the mtu of the mycelium tun device minus the
+	// ipv6 header added by the tunnel
+	meshMTU = 1400 - 40
+	// ip6tnlIgnoreEncapLimit prevents the tunnel from adding the encapsulation
+	// limit option header to the packets (IP6_TNL_F_IGN_ENCAP_LIMIT)
+	ip6tnlIgnoreEncapLimit = 0x1
+
+	// meshLink is the veth in the network resource namespace that connects
+	// the mesh namespace, meshUplink is its peer in the mesh namespace
+	meshLink   = "mesh"
+	meshUplink = "public"
+
+	// meshCandidateBits limits the mesh discovery to network ranges of up
+	// to 2^meshCandidateBits subnets, for example a /16 range of /24 subnets
+	meshCandidateBits = 8
+	// meshDiscoveryWorkers is the max number of subnets that are probed
+	// at the same time
+	meshDiscoveryWorkers = 32
+	// meshPeerExpiry is how long a discovered peer is kept in the mesh
+	// after it stopped replying
+	meshPeerExpiry = 15 * time.Minute
+)
+
+var (
+	// meshLinkIP and meshUplinkIP are the addresses of the veth pair between
+	// the network resource and the mesh namespaces. They are outside of the
+	// ndmz range (100.127.0.0/16) that network resources get their public
+	// address from, and of the wireguard range (100.64.0.0/16)
+	meshLinkIP   = net.IPNet{IP: net.ParseIP("100.126.0.1"), Mask: net.CIDRMask(30, 32)}
+	meshUplinkIP = net.IPNet{IP: net.ParseIP("100.126.0.2"), Mask: net.CIDRMask(30, 32)}
+
+	// meshAddresses caches the mycelium address of each mesh identity
+	// since computing it requires running mycelium
+	meshAddresses     = make(map[string]net.IP)
+	meshAddressesLock sync.Mutex
+)
+
+// meshID is the id of a network resource in the mesh. It's the ipv4 subnet
+// of the resource, or the ipv6 subnet for ipv6 only networks
+func meshID(subnet, subnetV6 gridtypes.IPNet) string {
+	if subnet.Nil() {
+		subnet = subnetV6
+	}
+
+	// the id must be the same whatever host ip the subnet was set with
+	id := net.IPNet{IP: subnet.IP.Mask(subnet.Mask), Mask: subnet.Mask}
+	return id.String()
+}
+
+// meshKey derives the mesh identity of a network resource from the mesh key
+// shared by all the resources of the network. This gives each resource a
+// different mycelium address that any other resource can compute.
+func meshKey(key zos.Bytes, netID zos.NetID, id string) []byte {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s:%s", netID, id)
+	return mac.Sum(nil)
+}
+
+// meshTunnelName returns the name of the tunnel to the peer with given mesh id
+func meshTunnelName(id string) string {
+	h := md5.Sum([]byte(id))
+	return fmt.Sprintf("%s%x", meshTunnelPrefix, h[:4])
+}
+
+// meshNamespace is the namespace of the mycelium node that carries the mesh
+// tunnels. It's separate from the network resource namespace so it doesn't
+// clash with the mycelium node of the network resource.
+func (nr *NetResource) meshNamespace() string {
+	return fmt.Sprintf("mesh-%s", nr.id)
+}
+
+func (nr *NetResource) meshServiceName() string {
+	return fmt.Sprintf("mycelium-mesh-%s", nr.ID())
+}
+
+func (nr *NetResource) meshKeyFile() string {
+	return filepath.Join(nr.keyDir, fmt.Sprintf("%s.mesh", nr.ID()))
+}
+
+func (nr *NetResource) meshDiscoveredFile() string {
+	return filepath.Join(nr.keyDir, fmt.Sprintf("%s.mesh-peers", nr.ID()))
+}
+
+// meshAddress returns the mycelium address of the peer with given mesh id
+func (nr *NetResource) meshAddress(id string) (net.IP, error) {
+	key := meshKey(nr.resource.Mesh.Key, nr.id, id)
+	cache := hex.EncodeToString(key)
+
+	meshAddressesLock.Lock()
+	address, ok := meshAddresses[cache]
+	meshAddressesLock.Unlock()
+	if ok {
+		return address, nil
+	}
+
+	file, err := os.CreateTemp(nr.keyDir, fmt.Sprintf(".%s-", nr.ID()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create peer key file")
+	}
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Write(key); err != nil {
+		return nil, errors.Wrap(err, "failed to write peer key file")
+	}
+
+	inspection, err := nr.inspectMycelium(file.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	meshAddressesLock.Lock()
+	meshAddresses[cache] = inspection.Address
+	meshAddressesLock.Unlock()
+
+	return inspection.Address, nil
+}
+
+// meshCandidates returns all the subnets of the network range with the
+// same size as the subnet of the network resource, except the subnet
+// itself. Those are the subnets other network resources can have.
+func meshCandidates(ipRange, subnet net.IPNet) ([]net.IPNet, error) {
+	rangeOnes, bits := ipRange.Mask.Size()
+	ones, subnetBits := subnet.Mask.Size()
+	if bits != subnetBits || ones < rangeOnes || !ipRange.Contains(subnet.IP) {
+		return nil, fmt.Errorf("subnet '%s' is not part of network range '%s'", subnet.String(), ipRange.String())
+	}
+
+	if ones-rangeOnes > meshCandidateBits {
+		return nil, fmt.Errorf("network range '%s' has more than %d subnets of size /%d", ipRange.String(), 1<<meshCandidateBits, ones)
+	}
+
+	ip := ipRange.IP.Mask(ipRange.Mask)
+	if bits == 8*net.IPv4len {
+		ip = ip.To4()
+	}
+
+	first := new(big.Int).SetBytes(ip)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	own := subnet.IP.Mask(subnet.Mask)
+
+	var candidates []net.IPNet
+	for i := 0; i < 1<<(ones-rangeOnes); i++ {
+		value := new(big.Int).Add(first, new(big.Int).Mul(step, big.NewInt(int64(i))))
+		candidate := make(net.IP, len(ip))
+		value.FillBytes(candidate)
+
+		if candidate.Equal(own) {
+			continue
+		}
+
+		candidates = append(candidates, net.IPNet{IP: candidate, Mask: subnet.Mask})
+	}
+
+	return candidates, nil
+}
+
+// meshRange returns the network range and subnet that are used to find
+// the mesh peers, the ipv6 ones are only used for ipv6 only networks
+func (nr *NetResource) meshRange() (ipRange, subnet net.IPNet) {
+	if !nr.resource.Subnet.Nil() {
+		return nr.networkIPRange, nr.resource.Subnet.IPNet
+	}
+
+	return nr.resource.NetworkIPRangeV6.IPNet, nr.resource.SubnetV6.IPNet
+}
+
+// loadMeshDiscovered returns the discovered mesh peers with the last
+// time they replied
+func (nr *NetResource) loadMeshDiscovered() (map[string]gridtypes.Timestamp, error) {
+	discovered := make(map[string]gridtypes.Timestamp)
+	data, err := os.ReadFile(nr.meshDiscoveredFile())
+	if os.IsNotExist(err) {
+		return discovered, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &discovered); err != nil {
+		return nil, errors.Wrap(err, "failed to load discovered mesh peers")
+	}
+
+	return discovered, nil
+}
+
+// DiscoverMesh looks for the other network resources of the network that
+// are in the mesh. Every subnet of the network range is a candidate, and
+// since the mesh identity of a network resource is derived from its subnet,
+// the node can compute the mycelium address of each candidate and ping it
+// over the mesh mycelium node. Only nodes that hold the mesh key can reply.
+// The candidates that reply are added to the mesh by SetMesh, and removed
+// once they did not reply for meshPeerExpiry.
+func (nr *NetResource) DiscoverMesh() error {
+	if nr.resource.Mesh == nil {
+		return nil
+	}
+
+	candidates, err := meshCandidates(nr.meshRange())
+	if err != nil {
+		return errors.Wrap(err, "failed to list mesh candidates")
+	}
+
+	discovered, err := nr.loadMeshDiscovered()
+	if err != nil {
+		log.Error().Err(err).Msg("dropping discovered mesh peers")
+		discovered = make(map[string]gridtypes.Timestamp)
+	}
+
+	now := time.Now()
+	jobs := make(chan net.IPNet)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < meshDiscoveryWorkers && i < len(candidates); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for candidate := range jobs {
+				id := candidate.String()
+				address, err := nr.meshAddress(id)
+				if err != nil {
+					log.Error().Err(err).Str("peer", id).Msg("failed to get mesh address of candidate")
+					continue
+				}
+
+				if _, err := nr.ping(nr.meshNamespace(), address); err != nil {
+					continue
+				}
+
+				lock.Lock()
+				discovered[id] = gridtypes.Timestamp(now.Unix())
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for _, candidate := range candidates {
+		jobs <- candidate
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	for id, seen := range discovered {
+		if now.Sub(time.Unix(int64(seen), 0)) > meshPeerExpiry {
+			delete(discovered, id)
+		}
+	}
+
+	data, err := json.Marshal(discovered)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(nr.meshDiscoveredFile(), data, 0644)
+}
+
+// meshPeers returns the peers of the network resource that are reached
+// over the mesh. Those are the peers listed in the network resource, and
+// the peers found by DiscoverMesh that are not listed.
+func (nr *NetResource) meshPeers() []zos.Peer {
+	if nr.resource.Mesh == nil {
+		return nil
+	}
+
+	var peers []zos.Peer
+	// listed peers, also the wireguard ones so they are not
+	// reached over the mesh
+	listed := make(map[string]struct{})
+	for _, peer := range nr.resource.Peers {
+		listed[meshID(peer.Subnet, peer.SubnetV6)] = struct{}{}
+		if peer.IsMesh() {
+			peers = append(peers, peer)
+		}
+	}
+
+	discovered, err := nr.loadMeshDiscovered()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load discovered mesh peers")
+		return peers
+	}
+
+	own := meshID(nr.resource.Subnet, nr.resource.SubnetV6)
+	for id := range discovered {
+		if _, ok := listed[id]; ok || id == own {
+			continue
+		}
+
+		subnet, err := gridtypes.ParseIPNet(id)
+		if err != nil {
+			continue
+		}
+
+		// only the subnet the peer is discovered with is known
+		if subnet.IP.To4() != nil {
+			peers = append(peers, zos.Peer{Subnet: subnet})
+		} else {
+			peers = append(peers, zos.Peer{SubnetV6: subnet})
+		}
+	}
+
+	return peers
+}
+
+// ensureMeshNamespace creates the mesh namespace and connects it to the
+// network resource namespace, the mesh traffic goes out over the public
+// interface of the network resource.
+func (nr *NetResource) ensureMeshNamespace(nrNS ns.NetNS) error {
+	name := nr.meshNamespace()
+
+	var meshNS ns.NetNS
+	var err error
+	if namespace.Exists(name) {
+		meshNS, err = namespace.GetByName(name)
+	} else {
+		log.Info().Str("namespace", name).Msg("create mesh namespace")
+		meshNS, err = namespace.Create(name)
+		if err == nil {
+			err = meshNS.Do(func(_ ns.NetNS) error {
+				return ifaceutil.SetLoUp()
+			})
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get mesh namespace '%s'", name)
+	}
+	defer meshNS.Close()
+
+	err = nrNS.Do(func(_ ns.NetNS) error {
+		if _, err := netlink.LinkByName(meshLink); err == nil {
+			return nil
+		}
+
+		veth := &netlink.Veth{
+			LinkAttrs: netlink.LinkAttrs{Name: meshLink},
+			PeerName:  meshUplink,
+		}
+		if err := netlink.LinkAdd(veth); err != nil {
+			return errors.Wrap(err, "failed to create mesh veth pair")
+		}
+
+		peer, err := netlink.LinkByName(meshUplink)
+		if err != nil {
+			return err
+		}
+
+		return netlink.LinkSetNsFd(peer, int(meshNS.Fd()))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := nrNS.Do(func(_ ns.NetNS) error {
+		return setupMeshLink(meshLink, meshLinkIP, nil)
+	}); err != nil {
+		return err
+	}
+
+	return meshNS.Do(func(_ ns.NetNS) error {
+		return setupMeshLink(meshUplink, meshUplinkIP, meshLinkIP.IP)
+	})
+}
+
+// setupMeshLink sets the address of the link and brings it up, if gw is set
+// it's used as the default gateway
+func setupMeshLink(name string, ip net.IPNet, gw net.IP) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get mesh link '%s'", name)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list addresses of mesh link '%s'", name)
+	}
+
+	// links created by older versions used a different address
+	for i := range addrs {
+		if addrs[i].IPNet.String() == ip.String() {
+			continue
+		}
+
+		if err := netlink.AddrDel(link, &addrs[i]); err != nil {
+			return errors.Wrapf(err, "failed to remove address '%s' of mesh link '%s'", addrs[i].IPNet, name)
+		}
+	}
+
+	if err := netlink.AddrReplace(link, &netlink.Addr{IPNet: &ip}); err != nil {
+		return errors.Wrapf(err, "failed to set address of mesh link '%s'", name)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return errors.Wrapf(err, "failed to set mesh link '%s' up", name)
+	}
+
+	if gw == nil {
+		return nil
+	}
+
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Gw:        gw,
+	}
+	if err := netlink.RouteReplace(route); err != nil {
+		return errors.Wrapf(err, "failed to add route %s", route.String())
+	}
+
+	return nil
+}
+
+// ensureMeshService starts the mycelium node of the mesh with the mesh
+// identity of the network resource
+func (nr *NetResource) ensureMeshService() error {
+	peers, err := environment.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get public mycelium peer list")
+	}
+
+	keyFile := nr.meshKeyFile()
+	key := meshKey(nr.resource.Mesh.Key, nr.id, meshID(nr.resource.Subnet, nr.resource.SubnetV6))
+	previous, _ := os.ReadFile(keyFile)
+	changed := previous != nil && !bytes.Equal(previous, key)
+
+	if err := os.WriteFile(keyFile, key, 0444); err != nil {
+		return errors.Wrap(err, "failed to store mesh key")
+	}
+
+	name := nr.meshServiceName()
+	init := zinit.Default()
+	exists, err := init.Exists(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to check mesh service")
+	}
+
+	if exists {
+		if changed {
+			// zinit restarts the service with the new key
+			return init.Kill(name, zinit.SIGTERM)
+		}
+		return nil
+	}
+
+	args := []string{
+		"ip", "netns", "exec", nr.meshNamespace(),
+		"mycelium",
+		"--silent",
+		"--key-file", keyFile,
+		"--tun-name", "my",
+		"--peers",
+	}
+	args = append(args, peers.Mycelium.Peers...)
+
+	err = zinit.AddService(name, zinit.InitService{
+		Exec: strings.Join(args, " "),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to add mesh service for nr")
+	}
+
+	return init.Monitor(name)
+}
+
+// SetMesh creates a tunnel over mycelium to each mesh peer of the network
+// resource and routes the peer subnets over it. Tunnels of peers that
+// are not part of the network anymore are removed, and so is the whole
+// mesh if the network is not in mesh mode.
+func (nr *NetResource) SetMesh() error {
+	if nr.resource.Mesh == nil {
+		return nr.deleteMesh()
+	}
+
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return fmt.Errorf("network namespace %s does not exits", nsName)
+	}
+	defer netNS.Close()
+
+	if err := nr.ensureMeshNamespace(netNS); err != nil {
+		return errors.Wrap(err, "failed to setup mesh namespace")
+	}
+
+	if err := nr.ensureMeshService(); err != nil {
+		return err
+	}
+
+	local, err := nr.meshAddress(meshID(nr.resource.Subnet, nr.resource.SubnetV6))
+	if err != nil {
+		return errors.Wrap(err, "failed to get network resource mesh address")
+	}
+
+	// name -> peer
+	tunnels := make(map[string]zos.Peer)
+	remotes := make(map[string]net.IP)
+	for _, peer := range nr.meshPeers() {
+		id := meshID(peer.Subnet, peer.SubnetV6)
+		remote, err := nr.meshAddress(id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get mesh address of peer '%s'", id)
+		}
+
+		name := meshTunnelName(id)
+		tunnels[name] = peer
+		remotes[name] = remote
+	}
+
+	// tunnels that need to be created
+	var missing []string
+	err = netNS.Do(func(_ ns.NetNS) error {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return errors.Wrap(err, "failed to list links")
+		}
+
+		existing := make(map[string]struct{})
+		for _, link := range links {
+			name := link.Attrs().Name
+			if !strings.HasPrefix(name, meshTunnelPrefix) {
+				continue
+			}
+
+			if _, ok := tunnels[name]; ok {
+				if tunnel, ok := link.(*netlink.Ip6tnl); ok && tunnel.Local.Equal(local) && tunnel.Remote.Equal(remotes[name]) {
+					existing[name] = struct{}{}
+					continue
+				}
+			}
+
+			// tunnel is not needed anymore, or its addresses changed
+			log.Info().Str("tunnel", name).Msg("remove mesh tunnel")
+			if err := netlink.LinkDel(link); err != nil {
+				return errors.Wrapf(err, "failed to delete mesh tunnel '%s'", name)
+			}
+		}
+
+		for name := range tunnels {
+			if _, ok := existing[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		meshNS, err := namespace.GetByName(nr.meshNamespace())
+		if err != nil {
+			return errors.Wrap(err, "failed to get mesh namespace")
+		}
+		defer meshNS.Close()
+
+		// the tunnels are created in the mesh namespace so they are carried
+		// by its mycelium node, and then moved to the network resource
+		err = meshNS.Do(func(_ ns.NetNS) error {
+			for _, name := range missing {
+				if err := createMeshTunnel(name, local, remotes[name], netNS); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		for name, peer := range tunnels {
+			if err := routeMeshTunnel(name, peer); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// createMeshTunnel creates the tunnel with given name and moves it to the
+// target namespace. Must be called inside the mesh namespace
+func createMeshTunnel(name string, local, remote net.IP, target ns.NetNS) error {
+	log.Info().
+		Str("tunnel", name).
+		Str("remote", remote.String()).
+		Msg("create mesh tunnel")
+
+	link := &netlink.Ip6tnl{
+		LinkAttrs: netlink.LinkAttrs{
+			Name: name,
+			MTU:  meshMTU,
+		},
+		Local:  local,
+		Remote: remote,
+		Ttl:    64,
+		Flags:  ip6tnlIgnoreEncapLimit,
+	}
+
+	if err := netlink.LinkAdd(link); err != nil {
+		return errors.Wrapf(err, "failed to create mesh tunnel '%s'", name)
+	}
+
+	if err := netlink.LinkSetNsFd(link, int(target.Fd())); err != nil {
+		_ = netlink.LinkDel(link)
+		return errors.Wrapf(err, "failed to move mesh tunnel '%s'", name)
+	}
+
+	return nil
+}
+
+// routeMeshTunnel brings the tunnel with given name up and routes the peer
+// subnets over it. Must be called inside the network resource namespace
+func routeMeshTunnel(name string, peer zos.Peer) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get mesh tunnel '%s'", name)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return errors.Wrapf(err, "failed to set mesh tunnel '%s' up", name)
+	}
+
+	for _, subnet := range []gridtypes.IPNet{peer.Subnet, peer.SubnetV6} {
+		if subnet.Nil() {
+			continue
+		}
+
+		dst := subnet.IPNet
+		route := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       &dst,
+		}
+
+		if err := netlink.RouteReplace(route); err != nil {
+			return errors.Wrapf(err, "failed to add route %s", route.String())
+		}
+	}
+
+	return nil
+}
+
+// deleteMesh stops the mesh mycelium node and removes the mesh namespace
+// and tunnels of the network resource
+func (nr *NetResource) deleteMesh() error {
+	name := nr.meshServiceName()
+	init := zinit.Default()
+	exists, err := init.Exists(name)
+	if err == nil && exists {
+		if err := init.StopMultiple(10*time.Second, name); err != nil {
+			log.Error().Err(err).Msg("failed to stop mesh mycelium for network resource")
+		}
+
+		_ = init.Forget(name)
+		_ = zinit.RemoveService(name)
+	}
+
+	_ = os.Remove(nr.meshKeyFile())
+	_ = os.Remove(nr.meshDiscoveredFile())
+
+	if nsName, err := nr.Namespace(); err == nil && namespace.Exists(nsName) {
+		netNS, err := namespace.GetByName(nsName)
+		if err != nil {
+			return err
+		}
+		defer netNS.Close()
+
+		err = netNS.Do(func(_ ns.NetNS) error {
+			links, err := netlink.LinkList()
+			if err != nil {
+				return errors.Wrap(err, "failed to list links")
+			}
+
+			for _, link := range links {
+				name := link.Attrs().Name
+				if name != meshLink && !strings.HasPrefix(name, meshTunnelPrefix) {
+					continue
+				}
+
+				if err := netlink.LinkDel(link); err != nil {
+					return errors.Wrapf(err, "failed to delete mesh link '%s'", name)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !namespace.Exists(nr.meshNamespace()) {
+		return nil
+	}
+
+	meshNS, err := namespace.GetByName(nr.meshNamespace())
+	if err != nil {
+		return err
+	}
+
+	// don't explicitly close meshNS here, namespace.Delete will take care of it
+	return namespace.Delete(meshNS)
+}
@@ -0,0 +1,138 @@
+package nr
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+func TestMeshKey(t *testing.T) {
+	require := require.New(t)
+
+	key := make(zos.Bytes, zos.MyceliumKeyLen)
+	a := meshKey(key, "net", "10.1.1.0/24")
+	require.Len(a, zos.MyceliumKeyLen)
+	require.Equal(a, meshKey(key, "net", "10.1.1.0/24"))
+	require.NotEqual(a, meshKey(key, "net", "10.1.2.0/24"))
+	require.NotEqual(a, meshKey(key, "other", "10.1.1.0/24"))
+
+	require.Equal("10.1.1.0/24", meshID(gridtypes.MustParseIPNet("10.1.1.0/24"), gridtypes.IPNet{}))
+	require.Equal("fd00:1:2::/64", meshID(gridtypes.IPNet{}, gridtypes.MustParseIPNet("fd00:1:2::/64")))
+	require.Equal("10.1.1.0/24", meshID(gridtypes.MustParseIPNet("10.1.1.1/24"), gridtypes.IPNet{}))
+}
+
+func TestMeshPeers(t *testing.T) {
+	require := require.New(t)
+
+	network := pkg.Network{
+		NetID: "net",
+		Network: zos.Network{
+			Subnet:   gridtypes.MustParseIPNet("10.1.1.0/24"),
+			Mycelium: &zos.Mycelium{Key: make(zos.Bytes, zos.MyceliumKeyLen)},
+			Peers: []zos.Peer{
+				{Subnet: gridtypes.MustParseIPNet("10.1.2.0/24")},
+				{
+					Subnet:      gridtypes.MustParseIPNet("10.1.3.0/24"),
+					WGPublicKey: "key",
+					AllowedIPs:  []gridtypes.IPNet{gridtypes.MustParseIPNet("10.1.3.0/24")},
+				},
+			},
+		},
+	}
+
+	nr := New(network, "")
+	require.Empty(nr.meshPeers())
+
+	network.Mesh = &zos.NetworkMesh{Key: make(zos.Bytes, zos.MyceliumKeyLen)}
+	nr = New(network, "")
+	require.Len(nr.meshPeers(), 1)
+
+	peers, err := nr.wgPeers()
+	require.NoError(err)
+	require.Len(peers, 1)
+	require.Equal("key", peers[0].PublicKey)
+
+	name := meshTunnelName("10.1.2.0/24")
+	require.Len(name, 10)
+	require.NotEqual(name, meshTunnelName("10.1.3.0/24"))
+}
+
+func TestMeshCandidates(t *testing.T) {
+	require := require.New(t)
+
+	parse := func(s string) net.IPNet {
+		return gridtypes.MustParseIPNet(s).IPNet
+	}
+
+	candidates, err := meshCandidates(parse("10.1.0.0/16"), parse("10.1.2.0/24"))
+	require.NoError(err)
+	require.Len(candidates, 255)
+	require.Equal("10.1.0.0/24", candidates[0].String())
+	require.Equal("10.1.1.0/24", candidates[1].String())
+	require.Equal("10.1.3.0/24", candidates[2].String())
+	require.Equal("10.1.255.0/24", candidates[254].String())
+
+	candidates, err = meshCandidates(parse("fd00:1::/62"), parse("fd00:1:0:1::/64"))
+	require.NoError(err)
+	require.Len(candidates, 3)
+	require.Equal("fd00:1::/64", candidates[0].String())
+	require.Equal("fd00:1:0:2::/64", candidates[1].String())
+	require.Equal("fd00:1:0:3::/64", candidates[2].String())
+
+	_, err = meshCandidates(parse("10.0.0.0/8"), parse("10.1.2.0/24"))
+	require.Error(err)
+
+	_, err = meshCandidates(parse("10.1.0.0/16"), parse("10.2.2.0/24"))
+	require.Error(err)
+}
+
+func TestMeshDiscoveredPeers(t *testing.T) {
+	require := require.New(t)
+
+	network := pkg.Network{
+		NetID: "net",
+		Network: zos.Network{
+			Subnet: gridtypes.MustParseIPNet("10.1.1.0/24"),
+			Mesh:   &zos.NetworkMesh{Key: make(zos.Bytes, zos.MyceliumKeyLen)},
+			Peers: []zos.Peer{
+				{Subnet: gridtypes.MustParseIPNet("10.1.2.0/24")},
+				{
+					Subnet:      gridtypes.MustParseIPNet("10.1.3.0/24"),
+					WGPublicKey: "key",
+					AllowedIPs:  []gridtypes.IPNet{gridtypes.MustParseIPNet("10.1.3.0/24")},
+				},
+			},
+		},
+	}
+
+	nr := New(network, t.TempDir())
+	require.NoError(os.WriteFile(nr.meshDiscoveredFile(), []byte(`{
+		"10.1.1.0/24": 1,
+		"10.1.2.0/24": 1,
+		"10.1.3.0/24": 1,
+		"10.1.4.0/24": 1
+	}`), 0644))
+
+	peers := nr.meshPeers()
+	require.Len(peers, 2)
+	require.Equal("10.1.2.0/24", peers[0].Subnet.String())
+	require.Equal("10.1.4.0/24", peers[1].Subnet.String())
+	require.True(peers[1].IsMesh())
+}
+
+func TestMeshLinkIP(t *testing.T) {
+	require := require.New(t)
+
+	require.True(meshLinkIP.Contains(meshUplinkIP.IP))
+
+	// the link must not take an address the ndmz can give to a network resource
+	_, ndmz, err := net.ParseCIDR("100.127.0.0/16")
+	require.NoError(err)
+	require.False(ndmz.Contains(meshLinkIP.IP))
+	require.False(ndmz.Contains(meshUplinkIP.IP))
+}
@@ -1,7 +1,6 @@
 package nr
 
 import (
-	"bytes"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -519,7 +518,9 @@ func (nr *NetResource) ConfigureWG(privateKey string) error {
 		}
 
 		newAddrs := mapset.NewSet()
-		newAddrs.Add(wgIP(&nr.resource.Subnet.IPNet).String())
+		if nr.resource.HasIPv4() {
+			newAddrs.Add(wgIP(&nr.resource.Subnet.IPNet).String())
+		}
 
 		toRemove := curAddrs.Difference(newAddrs)
 		toAdd := newAddrs.Difference(curAddrs)
@@ -544,16 +545,18 @@ func (nr *NetResource) ConfigureWG(privateKey string) error {
 			}
 		}
 
-		route := &netlink.Route{
-			LinkIndex: wg.Attrs().Index,
-			Dst:       &nr.networkIPRange,
-		}
-		if err := netlink.RouteAdd(route); err != nil && !os.IsExist(err) {
-			log.Error().
-				Err(err).
-				Str("route", route.String()).
-				Msg("fail to set route")
-			return errors.Wrapf(err, "failed to add route %s", route.String())
+		for _, dst := range nr.networkRanges() {
+			route := &netlink.Route{
+				LinkIndex: wg.Attrs().Index,
+				Dst:       &dst,
+			}
+			if err := netlink.RouteAdd(route); err != nil && !os.IsExist(err) {
+				log.Error().
+					Err(err).
+					Str("route", route.String()).
+					Msg("fail to set route")
+				return errors.Wrapf(err, "failed to add route %s", route.String())
+			}
 		}
 
 		return nil
@@ -562,6 +565,41 @@ func (nr *NetResource) ConfigureWG(privateKey string) error {
 	return netNS.Do(handler)
 }
 
+// UpdatePeers applies the network resource peers to the existing wireguard
+// interface. Unlike ConfigureWG the interface is not brought down, only the
+// peers that changed are updated
+func (nr *NetResource) UpdatePeers() error {
+	wgPeers, err := nr.wgPeers()
+	if err != nil {
+		return errors.Wrap(err, "failed to wireguard peer configuration")
+	}
+
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return fmt.Errorf("network namespace %s does not exits", nsName)
+	}
+	defer netNS.Close()
+
+	wgName, err := nr.WGName()
+	if err != nil {
+		return err
+	}
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		wg, err := wireguard.GetByName(wgName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get wireguard interface %s", wgName)
+		}
+
+		return wg.UpdatePeers(wgPeers)
+	})
+}
+
 // Delete removes all the interfaces and namespaces created by the Create method
 func (nr *NetResource) Delete() error {
 	netnsName, err := nr.Namespace()
@@ -595,6 +633,14 @@ func (nr *NetResource) Delete() error {
 		_ = os.Remove(keyFile)
 	}
 
+	if err := nr.stopServices(); err != nil {
+		log.Error().Err(err).Msg("failed to stop network resource services")
+	}
+
+	if err := nr.deleteMesh(); err != nil {
+		log.Error().Err(err).Msg("failed to delete network resource mesh")
+	}
+
 	if bridge.Exists(nrBrName) {
 		if err := bridge.Delete(nrBrName); err != nil {
 			log.Error().
@@ -632,17 +678,58 @@ func (nr *NetResource) Delete() error {
 	return nil
 }
 
+// networkRanges returns the ip ranges of the network that are routed over
+// wireguard, for both ipv4 and ipv6 if set
+func (nr *NetResource) networkRanges() []net.IPNet {
+	var ranges []net.IPNet
+	if nr.resource.HasIPv4() {
+		ranges = append(ranges, nr.networkIPRange)
+	}
+
+	if nr.resource.HasIPv6() {
+		ranges = append(ranges, nr.resource.NetworkIPRangeV6.IPNet)
+	}
+
+	return ranges
+}
+
+// peerAllowedIPs returns the allowed ips of the peer as given by the user.
+// On a dual-stack network the peer ipv6 subnet is added if the user allowed
+// ips do not already cover it.
+func peerAllowedIPs(network *zos.Network, peer zos.Peer) []string {
+	allowedIPs := make([]string, 0, len(peer.AllowedIPs)+1)
+	for _, ip := range peer.AllowedIPs {
+		allowedIPs = append(allowedIPs, ip.String())
+	}
+
+	if !network.HasIPv4() || !network.HasIPv6() || peer.SubnetV6.Nil() {
+		return allowedIPs
+	}
+
+	subnet := peer.SubnetV6
+	subnetOnes, _ := subnet.Mask.Size()
+	for _, ip := range peer.AllowedIPs {
+		ones, bits := ip.Mask.Size()
+		if bits == 8*net.IPv6len && ones <= subnetOnes && ip.Contains(subnet.IP) {
+			return allowedIPs
+		}
+	}
+
+	return append(allowedIPs, subnet.String())
+}
+
 func (nr *NetResource) wgPeers() ([]*wireguard.Peer, error) {
 
 	wgPeers := make([]*wireguard.Peer, 0, len(nr.resource.Peers)+1)
 
 	for _, peer := range nr.resource.Peers {
-
-		allowedIPs := make([]string, 0, len(peer.AllowedIPs))
-		for _, ip := range peer.AllowedIPs {
-			allowedIPs = append(allowedIPs, ip.String())
+		if nr.resource.Mesh != nil && peer.IsMesh() {
+			// reached over a mesh tunnel instead
+			continue
 		}
 
+		allowedIPs := peerAllowedIPs(&nr.resource.Network, peer)
+
 		wgPeer := &wireguard.Peer{
 			PublicKey:  peer.WGPublicKey,
 			AllowedIPs: allowedIPs,
@@ -722,25 +809,36 @@ func (nr *NetResource) attachToNRBridge() error {
 			return err
 		}
 
-		ipnet := nr.resource.Subnet
-		ipnet.IP[len(ipnet.IP)-1] = 0x01
-		log.Info().Str("addr", ipnet.String()).Msg("set address on macvlan interface")
+		var addrs []net.IPNet
+		if nr.resource.HasIPv4() {
+			ipnet := nr.resource.Subnet
+			ipnet.IP[len(ipnet.IP)-1] = 0x01
+			addrs = append(addrs, ipnet.IPNet)
+
+			if !nr.resource.HasIPv6() {
+				// no user ipv6 range, the ipv6 is derived from the ipv4
+				addrs = append(addrs, net.IPNet{
+					IP:   Convert4to6(nr.ID(), ipnet.IP),
+					Mask: net.CIDRMask(64, 128),
+				})
+			}
+		}
 
-		addr := &netlink.Addr{IPNet: &ipnet.IPNet, Label: ""}
-		if err = netlink.AddrAdd(link, addr); err != nil && !os.IsExist(err) {
-			return err
+		if nr.resource.HasIPv6() {
+			addrs = append(addrs, GatewayIPv6(nr.resource.SubnetV6.IPNet))
 		}
 
-		ipv6 := Convert4to6(nr.ID(), ipnet.IP)
-		addr = &netlink.Addr{IPNet: &net.IPNet{
-			IP:   ipv6,
-			Mask: net.CIDRMask(64, 128),
-		}}
-		if err = netlink.AddrAdd(link, addr); err != nil && !os.IsExist(err) {
-			return err
+		for _, ipnet := range addrs {
+			ipnet := ipnet
+			log.Info().Str("addr", ipnet.String()).Msg("set address on macvlan interface")
+
+			addr := &netlink.Addr{IPNet: &ipnet, Label: ""}
+			if err = netlink.AddrAdd(link, addr); err != nil && !os.IsExist(err) {
+				return err
+			}
 		}
 
-		addr = &netlink.Addr{IPNet: &net.IPNet{
+		addr := &netlink.Addr{IPNet: &net.IPNet{
 			IP:   net.ParseIP("fe80::1"),
 			Mask: net.CIDRMask(64, 128),
 		}}
@@ -778,6 +876,62 @@ func (nr *NetResource) ensureNRBridge() error {
 	return nil
 }
 
+// WGStatus returns the status of the wireguard peers of the network resource
+func (nr *NetResource) WGStatus() ([]pkg.WGPeerStatus, error) {
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	nrNetNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer nrNetNS.Close()
+
+	wgName, err := nr.WGName()
+	if err != nil {
+		return nil, err
+	}
+
+	var peers []pkg.WGPeerStatus
+	err = nrNetNS.Do(func(_ ns.NetNS) error {
+		wg, err := wireguard.GetByName(wgName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get wireguard interface %s", wgName)
+		}
+
+		device, err := wg.Device()
+		if err != nil {
+			return errors.Wrap(err, "failed to get wireguard device")
+		}
+
+		for _, peer := range device.Peers {
+			status := pkg.WGPeerStatus{
+				PublicKey:     peer.PublicKey.String(),
+				LastHandshake: peer.LastHandshakeTime,
+				RxBytes:       peer.ReceiveBytes,
+				TxBytes:       peer.TransmitBytes,
+			}
+
+			if peer.Endpoint != nil {
+				status.Endpoint = peer.Endpoint.String()
+			}
+
+			for _, ip := range peer.AllowedIPs {
+				status.AllowedIPs = append(status.AllowedIPs, ip.String())
+			}
+
+			peers = append(peers, status)
+		}
+
+		return nil
+	})
+
+	return peers, err
+}
+
 // HasWireguard checks if network resource has wireguard setup up
 func (nr *NetResource) HasWireguard() (bool, error) {
 	nsName, err := nr.Namespace()
@@ -835,12 +989,12 @@ func (nr *NetResource) applyFirewall() error {
 		return err
 	}
 
-	buf := bytes.Buffer{}
-	if err := fwTmpl.Execute(&buf, nil); err != nil {
-		return errors.Wrap(err, "failed to build nft rule set")
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return err
 	}
 
-	if err := nft.Apply(&buf, nsName); err != nil {
+	if err := nft.ApplyRuleset(nsName, ruleset(nrIface, nr.resource.Firewall)...); err != nil {
 		return errors.Wrap(err, "failed to apply nft rule set")
 	}
 
@@ -869,6 +1023,27 @@ func Convert4to6(netID string, ip net.IP) net.IP {
 	return net.ParseIP(ipv6)
 }
 
+// GatewayIPv6 returns the gateway address (::1) of the network resource
+// ipv6 /64 subnet
+func GatewayIPv6(subnet net.IPNet) net.IPNet {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, subnet.IP.To16().Mask(subnet.Mask))
+	ip[net.IPv6len-1] = 1
+
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(64, 128)}
+}
+
+// IPv6From4 builds the ipv6 address in the network resource ipv6 /64 subnet
+// that corresponds to the given (private) ipv4. Like the ipv4, the host part
+// is the last byte of the ipv4, so the gateway x.x.x.1 maps to ::1
+func IPv6From4(subnet net.IPNet, ip net.IP) net.IP {
+	ipv6 := make(net.IP, net.IPv6len)
+	copy(ipv6, subnet.IP.To16().Mask(subnet.Mask))
+	ipv6[net.IPv6len-1] = ip[len(ip)-1]
+
+	return ipv6
+}
+
 // AppendFunc appends arrays with automatic map
 func AppendFunc[A any, B any, S []A, D []B](d D, s S, f func(A) B) D {
 	d = slices.Grow(d, len(s))
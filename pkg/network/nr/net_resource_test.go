@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
 	"github.com/vishvananda/netlink"
 )
 
@@ -211,3 +213,77 @@ func TestMyceliumIP(t *testing.T) {
 	require.Equal(t, "3b4:ca67:822d:b0c1::1/64", gw.String())
 
 }
+
+func TestIPv6From4(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("fd12:3456:789a:2::/64")
+	require.NoError(t, err)
+
+	gw := GatewayIPv6(*subnet)
+	require.Equal(t, "fd12:3456:789a:2::1/64", gw.String())
+	require.Equal(t, net.ParseIP("fd12:3456:789a:2::a"), IPv6From4(*subnet, net.ParseIP("10.1.2.10").To4()))
+}
+
+func TestPeerAllowedIPs(t *testing.T) {
+	v4 := zos.Network{
+		NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+	}
+	dual := zos.Network{
+		NetworkIPRange:   gridtypes.MustParseIPNet("10.1.0.0/16"),
+		NetworkIPRangeV6: gridtypes.MustParseIPNet("fd12:3456:789a::/48"),
+	}
+
+	peer := zos.Peer{
+		Subnet:   gridtypes.MustParseIPNet("10.1.3.0/24"),
+		SubnetV6: gridtypes.MustParseIPNet("fd12:3456:789a:3::/64"),
+		AllowedIPs: []gridtypes.IPNet{
+			gridtypes.MustParseIPNet("10.1.3.0/24"),
+			gridtypes.MustParseIPNet("100.64.1.3/32"),
+		},
+	}
+
+	covered := peer
+	covered.AllowedIPs = append([]gridtypes.IPNet{
+		gridtypes.MustParseIPNet("fd12:3456:789a::/48"),
+	}, peer.AllowedIPs...)
+
+	v4Only := peer
+	v4Only.SubnetV6 = gridtypes.IPNet{}
+
+	cases := []struct {
+		name    string
+		network zos.Network
+		peer    zos.Peer
+		exp     []string
+	}{
+		{
+			name:    "ipv4 network",
+			network: v4,
+			peer:    peer,
+			exp:     []string{"10.1.3.0/24", "100.64.1.3/32"},
+		},
+		{
+			name:    "dual stack",
+			network: dual,
+			peer:    peer,
+			exp:     []string{"10.1.3.0/24", "100.64.1.3/32", "fd12:3456:789a:3::/64"},
+		},
+		{
+			name:    "dual stack covered",
+			network: dual,
+			peer:    covered,
+			exp:     []string{"fd12:3456:789a::/48", "10.1.3.0/24", "100.64.1.3/32"},
+		},
+		{
+			name:    "dual stack peer without ipv6",
+			network: dual,
+			peer:    v4Only,
+			exp:     []string{"10.1.3.0/24", "100.64.1.3/32"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.exp, peerAllowedIPs(&c.network, c.peer))
+		})
+	}
+}
@@ -1,62 +1,68 @@
 package nr
 
 import (
-	"text/template"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/flowlog"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"golang.org/x/sys/unix"
 )
 
-var fwTmpl *template.Template
+// ruleset builds the full nft ruleset of the network resource namespace.
+// nrIface is the interface connected to the network resource bridge.
+func ruleset(nrIface string, fw *zos.Firewall) []nft.Table {
+	nat := nft.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   "nat",
+		Chains: []nft.Chain{
+			nft.BaseChain("prerouting", nftables.ChainTypeNAT, nftables.ChainHookPrerouting, -100, nftables.ChainPolicyAccept),
+			nft.BaseChain("input", nftables.ChainTypeNAT, nftables.ChainHookInput, 100, nftables.ChainPolicyAccept),
+			nft.BaseChain("output", nftables.ChainTypeNAT, nftables.ChainHookOutput, -100, nftables.ChainPolicyAccept),
+			nft.BaseChain("postrouting", nftables.ChainTypeNAT, nftables.ChainHookPostrouting, 100, nftables.ChainPolicyAccept,
+				nft.NewRule("", nft.OIfName("public"), nft.Masquerade()),
+			),
+		},
+	}
 
-func init() {
-	fwTmpl = template.Must(template.New("nrfw").Parse(_nft))
-}
-
-var _nft = `
-flush ruleset
-
-table inet nat {
-  chain prerouting {
-    type nat hook prerouting priority dstnat; policy accept;
-  }
-
-  chain input {
-    type nat hook input priority 100; policy accept;
-  }
-
-  chain output {
-    type nat hook output priority -100; policy accept;
-  }
-
-  chain postrouting {
-    type nat hook postrouting priority srcnat; policy accept;
-    oifname "public" masquerade fully-random;
-  }
-}
-
-table inet filter {
-    chain base_checks {
-        # allow established/related connections
-        ct state {established, related} accept
-        # early drop of invalid connections
-        ct state invalid drop
-    }
-  chain input {
-    type filter hook input priority 0; policy accept;
-    jump base_checks
-    ip6 nexthdr icmpv6 accept
-    iifname "public" counter drop
-  }
-
-  chain forward {
-    type filter hook forward priority 0; policy accept;
-        # is there already an existing stream? (outgoing)
-        jump base_checks
-        # if not, verify if it's new and coming in from the br4-gw network
-        # if it is, drop it
-        iifname "public" counter drop
-  }
+	filter := nft.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   "filter",
+		Chains: []nft.Chain{
+			nft.RegularChain("base_checks",
+				// allow established/related connections
+				nft.NewRule("", nft.CtState(expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED), nft.Accept()),
+				// early drop of invalid connections
+				nft.NewRule("", nft.CtState(expr.CtStateBitINVALID), nft.Drop()),
+			),
+			nft.BaseChain("input", nftables.ChainTypeFilter, nftables.ChainHookInput, 0, nftables.ChainPolicyAccept,
+				nft.NewRule("", nft.Jump("base_checks")),
+				nft.NewRule("", nft.L4Proto(unix.IPPROTO_ICMPV6), nft.Accept()),
+				nft.NewRule("", nft.IIfName("public"), nft.Counter(), nft.Drop()),
+			),
+			// user defined firewall rules
+			nft.RegularChain("fw_ingress", nft.FirewallRules(fw, zos.FirewallIngress)...),
+			nft.RegularChain("fw_egress", nft.FirewallRules(fw, zos.FirewallEgress)...),
+			nft.BaseChain("forward", nftables.ChainTypeFilter, nftables.ChainHookForward, 0, nftables.ChainPolicyAccept,
+				// mark the connections to and from the public internet for the flow logs
+				nft.NewRule("", nft.IIfName("public"), nft.CtMarkSet(flowlog.PublicMark)),
+				nft.NewRule("", nft.OIfName("public"), nft.CtMarkSet(flowlog.PublicMark)),
+				// is there already an existing stream? (outgoing)
+				nft.NewRule("", nft.Jump("base_checks")),
+				// apply user firewall on traffic to and from the network resource
+				nft.NewRule("", nft.OIfName(nrIface), nft.Jump("fw_ingress")),
+				nft.NewRule("", nft.IIfName(nrIface), nft.Jump("fw_egress")),
+				// allow new connections of forwarded ports
+				nft.NewRule("", nft.IIfName("public"), nft.CtStatus(nft.CtStatusDNAT), nft.Accept()),
+				// if not, verify if it's new and coming in from the br4-gw network
+				// if it is, drop it
+				nft.NewRule("", nft.IIfName("public"), nft.Counter(), nft.Drop()),
+			),
+			nft.BaseChain("output", nftables.ChainTypeFilter, nftables.ChainHookOutput, 0, nftables.ChainPolicyAccept,
+				nft.NewRule("", nft.OIfName("public"), nft.CtMarkSet(flowlog.PublicMark)),
+			),
+		},
+	}
 
-  chain output {
-    type filter hook output priority 0; policy accept;
-  }
+	return []nft.Table{nat, filter}
 }
-`
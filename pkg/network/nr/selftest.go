@@ -0,0 +1,133 @@
+package nr
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+const (
+	// selfTestPings is the number of pings sent to each peer
+	selfTestPings = 3
+	// selfTestTimeout is the max time the check of a single peer can take
+	selfTestTimeout = 15 * time.Second
+	// selfTestWorkers is the max number of peers that are checked at the
+	// same time
+	selfTestWorkers = 8
+)
+
+var (
+	// pingRTT matches the summary line of both iputils and busybox ping, for
+	// example `rtt min/avg/max/mdev = 0.045/0.051/0.060/0.006 ms`
+	pingRTT = regexp.MustCompile(`= [\d.]+/([\d.]+)/`)
+)
+
+// peerGateway returns the ip that is pinged to check if the peer is
+// reachable. For wireguard peers it's the wireguard ip of the peer,
+// otherwise it's the gateway of the peer subnet
+func peerGateway(peer zos.Peer, mesh bool) net.IP {
+	if peer.Subnet.Nil() {
+		return GatewayIPv6(peer.SubnetV6.IPNet).IP
+	}
+
+	if !mesh {
+		return wgIP(&peer.Subnet.IPNet).IP
+	}
+
+	ip := peer.Subnet.IP.To4().Mask(peer.Subnet.Mask)
+	gw := make(net.IP, net.IPv4len)
+	copy(gw, ip)
+	gw[net.IPv4len-1] = 1
+	return gw
+}
+
+// parsePingRTT returns the average round trip time (in milliseconds) from
+// the ping output
+func parsePingRTT(output []byte) (float64, error) {
+	match := pingRTT.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("no round trip time in ping output")
+	}
+
+	return strconv.ParseFloat(string(match[1]), 64)
+}
+
+// ping pings the ip from inside the network resource namespace
+func (nr *NetResource) ping(nsName string, ip net.IP) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ip", "netns", "exec", nsName,
+		"ping", "-q", "-c", strconv.Itoa(selfTestPings), "-W", "2", ip.String(),
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, errors.Wrapf(err, "no reply from '%s'", ip)
+	}
+
+	// the peer replied, a missing round trip time is not an error
+	rtt, _ := parsePingRTT(output)
+	return rtt, nil
+}
+
+// CheckPeers pings the gateway of each peer of the network resource and
+// returns the reachability of all peers. Peers are checked in parallel by
+// a bounded number of workers.
+func (nr *NetResource) CheckPeers() ([]zos.PeerReachability, error) {
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]zos.PeerReachability, len(nr.resource.Peers))
+	for i, peer := range nr.resource.Peers {
+		mesh := nr.resource.Mesh != nil && peer.IsMesh()
+		results[i] = zos.PeerReachability{
+			Subnet:   peer.Subnet,
+			Endpoint: peer.Endpoint,
+			Gateway:  peerGateway(peer, mesh),
+		}
+
+		if peer.Subnet.Nil() {
+			results[i].Subnet = peer.SubnetV6
+		}
+	}
+
+	jobs := make(chan *zos.PeerReachability)
+	var wg sync.WaitGroup
+	for i := 0; i < selfTestWorkers && i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for result := range jobs {
+				rtt, err := nr.ping(nsName, result.Gateway)
+				if err != nil {
+					result.Error = err.Error()
+					continue
+				}
+
+				result.Reachable = true
+				result.RTT = rtt
+			}
+		}()
+	}
+
+	for i := range results {
+		jobs <- &results[i]
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return results, nil
+}
@@ -0,0 +1,39 @@
+package nr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+func TestPeerGateway(t *testing.T) {
+	require := require.New(t)
+
+	peer := zos.Peer{Subnet: gridtypes.MustParseIPNet("10.1.3.0/24")}
+	require.Equal("100.64.1.3", peerGateway(peer, false).String())
+	require.Equal("10.1.3.1", peerGateway(peer, true).String())
+
+	peer = zos.Peer{SubnetV6: gridtypes.MustParseIPNet("fd12:3456:789a:3::/64")}
+	require.Equal("fd12:3456:789a:3::1", peerGateway(peer, false).String())
+}
+
+func TestParsePingRTT(t *testing.T) {
+	require := require.New(t)
+
+	rtt, err := parsePingRTT([]byte(`--- 100.64.1.3 ping statistics ---
+3 packets transmitted, 3 received, 0% packet loss, time 2003ms
+rtt min/avg/max/mdev = 0.045/0.051/0.060/0.006 ms
+`))
+	require.NoError(err)
+	require.Equal(0.051, rtt)
+
+	// busybox
+	rtt, err = parsePingRTT([]byte(`round-trip min/avg/max = 12.104/14.513/16.002 ms`))
+	require.NoError(err)
+	require.Equal(14.513, rtt)
+
+	_, err = parsePingRTT([]byte(`3 packets transmitted, 0 packets received, 100% packet loss`))
+	require.Error(err)
+}
@@ -0,0 +1,289 @@
+package nr
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/zinit"
+)
+
+const (
+	servicesConfFile     = "dnsmasq.conf"
+	servicesHostsFile    = "hosts"
+	servicesDHCPHostFile = "dhcp-hosts"
+	servicesLeaseFile    = "leases"
+	servicesPidFile      = "dnsmasq.pid"
+)
+
+// servicesConfig is the configuration of the network resource dnsmasq
+type servicesConfig struct {
+	// Dir where the config, hosts and leases files are kept
+	Dir string
+	// Iface is the interface the services listen on
+	Iface string
+	// Domain served by the dns
+	Domain string
+	// Subnet is the ipv4 subnet of the network resource, only needed
+	// for dhcp
+	Subnet *net.IPNet
+	// Gateway is the ipv4 gateway of the network resource
+	Gateway net.IP
+
+	DHCP bool
+	DNS  bool
+}
+
+// ServicesDomain returns the dns domain of the network with given name
+func ServicesDomain(network string) string {
+	return fmt.Sprintf("%s.internal", HostName(network))
+}
+
+// HostName converts a workload name to a valid dns label
+func HostName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+func (c *servicesConfig) render() []byte {
+	var buf bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteByte('\n')
+	}
+
+	line("interface=%s", c.Iface)
+	line("bind-interfaces")
+	line("user=root")
+	line("pid-file=%s", filepath.Join(c.Dir, servicesPidFile))
+
+	if c.DNS {
+		line("domain=%s", c.Domain)
+		// only answer names of the domain from the hosts file, never forward them
+		line("local=/%s/", c.Domain)
+		line("expand-hosts")
+		line("no-hosts")
+		line("addn-hosts=%s", filepath.Join(c.Dir, servicesHostsFile))
+	} else {
+		// disables dns
+		line("port=0")
+	}
+
+	if c.DHCP {
+		line("dhcp-authoritative")
+		line("dhcp-range=%s,static,%s,infinite", c.Subnet.IP, net.IP(c.Subnet.Mask))
+		line("dhcp-hostsfile=%s", filepath.Join(c.Dir, servicesDHCPHostFile))
+		line("dhcp-leasefile=%s", filepath.Join(c.Dir, servicesLeaseFile))
+		line("dhcp-option=option:router,%s", c.Gateway)
+		if c.DNS {
+			line("dhcp-option=option:dns-server,%s", c.Gateway)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// renderHosts renders the dns hosts file and the dhcp static leases
+// of the given hosts
+func renderHosts(hosts []pkg.NetworkHost) (dns []byte, dhcp []byte) {
+	var dnsBuf, dhcpBuf bytes.Buffer
+	for _, host := range hosts {
+		name := HostName(host.Name)
+		for _, ip := range host.IPs {
+			fmt.Fprintf(&dnsBuf, "%s %s\n", ip, name)
+			if ip4 := ip.To4(); ip4 != nil && len(host.MAC) != 0 {
+				fmt.Fprintf(&dhcpBuf, "%s,%s,%s\n", host.MAC, ip4, name)
+			}
+		}
+	}
+
+	return dnsBuf.Bytes(), dhcpBuf.Bytes()
+}
+
+func (nr *NetResource) servicesName() string {
+	return fmt.Sprintf("dnsmasq-%s", nr.ID())
+}
+
+func (nr *NetResource) servicesEnabled() bool {
+	services := nr.resource.Services
+	return services != nil && (services.DHCP || services.DNS)
+}
+
+// writeIfChanged writes data to path and returns true if the content
+// of the file changed
+func writeIfChanged(path string, data []byte) (bool, error) {
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, data) {
+		return false, nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	return true, os.WriteFile(path, data, 0644)
+}
+
+// SetServices starts (or updates) the dhcp and dns services of the network
+// resource. dir is where the services files are kept, domain is the dns
+// domain of the network. The services are stopped if the network resource
+// does not have them enabled anymore.
+func (nr *NetResource) SetServices(dir, domain string, hosts []pkg.NetworkHost) error {
+	if !nr.servicesEnabled() {
+		return nr.deleteServices(dir)
+	}
+
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return err
+	}
+
+	config := servicesConfig{
+		Dir:    dir,
+		Iface:  nrIface,
+		Domain: domain,
+		DHCP:   nr.resource.Services.DHCP,
+		DNS:    nr.resource.Services.DNS,
+	}
+
+	if config.DHCP {
+		subnet := nr.resource.Subnet.IPNet
+		config.Subnet = &subnet
+		config.Gateway = append(net.IP(nil), subnet.IP.To4()...)
+		config.Gateway[3] = 1
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create network services directory")
+	}
+
+	if _, err := nr.writeHosts(dir, hosts); err != nil {
+		return err
+	}
+
+	changed, err := writeIfChanged(filepath.Join(dir, servicesConfFile), config.render())
+	if err != nil {
+		return errors.Wrap(err, "failed to write network services config")
+	}
+
+	name := nr.servicesName()
+	init := zinit.Default()
+	exists, err := init.Exists(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to check network services")
+	}
+
+	if exists {
+		if !changed {
+			return nil
+		}
+
+		// the config file is only read on start
+		if err := init.StopWait(10*time.Second, name); err != nil {
+			return errors.Wrap(err, "failed to stop network services")
+		}
+
+		return init.Start(name)
+	}
+
+	ns, err := nr.Namespace()
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"ip", "netns", "exec", ns,
+		"dnsmasq",
+		"--keep-in-foreground",
+		"--conf-file=" + filepath.Join(dir, servicesConfFile),
+	}
+
+	err = zinit.AddService(name, zinit.InitService{
+		Exec: strings.Join(args, " "),
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to add network services for nr")
+	}
+
+	return init.Monitor(name)
+}
+
+func (nr *NetResource) writeHosts(dir string, hosts []pkg.NetworkHost) (bool, error) {
+	dns, dhcp := renderHosts(hosts)
+
+	dnsChanged, err := writeIfChanged(filepath.Join(dir, servicesHostsFile), dns)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to write network hosts")
+	}
+
+	dhcpChanged, err := writeIfChanged(filepath.Join(dir, servicesDHCPHostFile), dhcp)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to write network dhcp hosts")
+	}
+
+	return dnsChanged || dhcpChanged, nil
+}
+
+// ReloadServices updates the hosts served by the network resource services.
+// It's a no-op if the network resource does not run any services
+func (nr *NetResource) ReloadServices(dir string, hosts []pkg.NetworkHost) error {
+	if !nr.servicesEnabled() {
+		return nil
+	}
+
+	changed, err := nr.writeHosts(dir, hosts)
+	if err != nil || !changed {
+		return err
+	}
+
+	name := nr.servicesName()
+	init := zinit.Default()
+	exists, err := init.Exists(name)
+	if err != nil || !exists {
+		return err
+	}
+
+	// dnsmasq reloads the hosts files on SIGHUP
+	return init.Kill(name, zinit.SIGHUP)
+}
+
+// deleteServices stops the network resource services and removes their files
+func (nr *NetResource) deleteServices(dir string) error {
+	if err := nr.stopServices(); err != nil {
+		return err
+	}
+
+	for _, file := range []string{servicesConfFile, servicesLeaseFile, servicesPidFile} {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Str("file", file).Msg("failed to remove network services file")
+		}
+	}
+
+	return nil
+}
+
+// stopServices stops and removes the network resource services if running
+func (nr *NetResource) stopServices() error {
+	name := nr.servicesName()
+	init := zinit.Default()
+	exists, err := init.Exists(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to check network services")
+	}
+
+	if exists {
+		if err := init.StopMultiple(10*time.Second, name); err != nil {
+			log.Error().Err(err).Msg("failed to stop services for network resource")
+		}
+
+		_ = init.Forget(name)
+		_ = zinit.RemoveService(name)
+	}
+
+	return nil
+}
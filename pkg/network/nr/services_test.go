@@ -0,0 +1,74 @@
+package nr
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestServicesConfig(t *testing.T) {
+	require := require.New(t)
+
+	_, subnet, err := net.ParseCIDR("10.1.2.0/24")
+	require.NoError(err)
+
+	config := servicesConfig{
+		Dir:     "/var/run/services",
+		Iface:   "n-test",
+		Domain:  ServicesDomain("my_net"),
+		Subnet:  subnet,
+		Gateway: net.ParseIP("10.1.2.1"),
+		DHCP:    true,
+		DNS:     true,
+	}
+
+	require.Equal(`interface=n-test
+bind-interfaces
+user=root
+pid-file=/var/run/services/dnsmasq.pid
+domain=my-net.internal
+local=/my-net.internal/
+expand-hosts
+no-hosts
+addn-hosts=/var/run/services/hosts
+dhcp-authoritative
+dhcp-range=10.1.2.0,static,255.255.255.0,infinite
+dhcp-hostsfile=/var/run/services/dhcp-hosts
+dhcp-leasefile=/var/run/services/leases
+dhcp-option=option:router,10.1.2.1
+dhcp-option=option:dns-server,10.1.2.1
+`, string(config.render()))
+
+	// dns only
+	config.DHCP = false
+	require.NotContains(string(config.render()), "dhcp")
+
+	// dhcp only
+	config.DHCP = true
+	config.DNS = false
+	rendered := string(config.render())
+	require.Contains(rendered, "port=0\n")
+	require.NotContains(rendered, "dns-server")
+}
+
+func TestRenderHosts(t *testing.T) {
+	require := require.New(t)
+
+	dns, dhcp := renderHosts([]pkg.NetworkHost{
+		{
+			Name: "VM_1",
+			MAC:  "de:ad:be:ef:00:01",
+			IPs:  []net.IP{net.ParseIP("10.1.2.10"), net.ParseIP("fd00::10")},
+		},
+		{
+			Name: "vm2",
+			IPs:  []net.IP{net.ParseIP("fd00::20")},
+		},
+	})
+
+	require.Equal("10.1.2.10 vm-1\nfd00::10 vm-1\nfd00::20 vm2\n", string(dns))
+	// only ipv4 addresses get a dhcp lease
+	require.Equal("de:ad:be:ef:00:01,10.1.2.10,vm-1\n", string(dhcp))
+}
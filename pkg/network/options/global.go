@@ -42,3 +42,14 @@ func SetIPv6LearnDefaultRouteInRA(f bool) error {
 	_, err := sysctl.Sysctl("net.ipv6.conf.all.accept_ra_defrtr", flag(f))
 	return err
 }
+
+// SetConntrackAccounting enables or disables the bytes counters and the
+// timestamps of the tracked connections
+func SetConntrackAccounting(f bool) error {
+	if _, err := sysctl.Sysctl("net.netfilter.nf_conntrack_acct", flag(f)); err != nil {
+		return err
+	}
+
+	_, err := sysctl.Sysctl("net.netfilter.nf_conntrack_timestamp", flag(f))
+	return err
+}
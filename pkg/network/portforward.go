@@ -0,0 +1,376 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"github.com/threefoldtech/zos/pkg/network/portm"
+	"github.com/threefoldtech/zos/pkg/network/portm/backend"
+)
+
+const (
+	portForwardDir   = "port-forward"
+	portForwardTable = "portforward"
+	portForwardChain = "prerouting"
+)
+
+// portForwardRange is the range of node ports that are allocated for
+// port forwards
+var portForwardRange = portm.PortRange{Start: 20000, End: 29999}
+
+// maxTwinPortForwards is the max number of port forwards a single twin
+// can have on the node, so no twin can exhaust the forward range
+const maxTwinPortForwards = 32
+
+// portForward is the stored state of a port forward
+type portForward struct {
+	pkg.PortForward
+	PublicPort uint16 `json:"public_port"`
+}
+
+func newPortAllocator(root string) (*portm.Allocator, error) {
+	store, err := backend.NewFSStore(filepath.Join(root, "ports"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create port forward store")
+	}
+
+	return portm.NewAllocator(portForwardRange, store), nil
+}
+
+// portForwardRule builds the dnat rule of a single forward
+func portForwardRule(comment, iface string, proto zos.ForwardProtocol, port uint16, ip net.IP, target uint16) nft.Rule {
+	match := nft.TCPDPort(port, 0)
+	if proto == zos.ForwardUDP {
+		match = nft.UDPDPort(port, 0)
+	}
+
+	return nft.NewRule(comment, nft.IIfName(iface), match, nft.Counter(), nft.DNat(ip, target))
+}
+
+func portForwardTableOf(rules ...nft.Rule) nft.Table {
+	return nft.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   portForwardTable,
+		Chains: []nft.Chain{
+			nft.BaseChain(portForwardChain, nftables.ChainTypeNAT, nftables.ChainHookPrerouting, -100, nftables.ChainPolicyAccept, rules...),
+		},
+	}
+}
+
+func (n *networker) portForwardPath(wl gridtypes.WorkloadID) string {
+	return filepath.Join(n.portForwardDir, "forwards", wl.String())
+}
+
+func (n *networker) loadPortForward(wl gridtypes.WorkloadID) (forward portForward, err error) {
+	data, err := os.ReadFile(n.portForwardPath(wl))
+	if err != nil {
+		return forward, err
+	}
+
+	if err := json.Unmarshal(data, &forward); err != nil {
+		return forward, errors.Wrapf(err, "failed to load port forward '%s'", wl)
+	}
+
+	return forward, nil
+}
+
+func (n *networker) storePortForward(wl gridtypes.WorkloadID, forward portForward) error {
+	path := n.portForwardPath(wl)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(forward)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+func (n *networker) listPortForwards() (map[gridtypes.WorkloadID]portForward, error) {
+	dir := filepath.Join(n.portForwardDir, "forwards")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	forwards := make(map[gridtypes.WorkloadID]portForward)
+	for _, entry := range entries {
+		wl := gridtypes.WorkloadID(entry.Name())
+		forward, err := n.loadPortForward(wl)
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to load port forward")
+			continue
+		}
+		forwards[wl] = forward
+	}
+
+	return forwards, nil
+}
+
+// allocateForwardPort reserves a free port from the port forward range that
+// is not used by anything else on the node (for example a wireguard port)
+func (n *networker) allocateForwardPort(wl gridtypes.WorkloadID) (uint16, error) {
+	ns := n.ndmz.Namespace()
+
+	var skipped []int
+	defer func() {
+		for _, port := range skipped {
+			if err := n.portForwards.Release(ns, port); err != nil {
+				log.Error().Err(err).Int("port", port).Msg("failed to release skipped port")
+			}
+		}
+	}()
+
+	for {
+		port, err := n.portForwards.Reserve(ns, wl.String())
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to allocate port")
+		}
+
+		if err := n.portSet.Add(uint(port)); err != nil {
+			// keep it reserved until we find a free port so it's not
+			// returned again
+			skipped = append(skipped, port)
+			continue
+		}
+
+		return uint16(port), nil
+	}
+}
+
+func (n *networker) releaseForwardPort(port uint16) {
+	n.portSet.Remove(uint(port))
+	if err := n.portForwards.Release(n.ndmz.Namespace(), int(port)); err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Uint16("port", port).Msg("failed to release port forward port")
+	}
+}
+
+// nrPublicIPv4 returns the ip of the network resource on the ndmz bridge
+func (n *networker) nrPublicIPv4(netID zos.NetID) (net.IP, error) {
+	ips, _, err := n.Addrs("public", n.Namespace(netID))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+
+	return nil, fmt.Errorf("network resource '%s' has no ndmz ipv4", netID)
+}
+
+// applyPortForwards applies the dnat rules of all port forwards in the ndmz
+// and in the network resources with the given ids. Forwards to network
+// resources that do not exist (yet) are skipped, they are applied once
+// the network resource is created.
+func (n *networker) applyPortForwards(netIDs ...zos.NetID) error {
+	forwards, err := n.listPortForwards()
+	if err != nil {
+		return errors.Wrap(err, "failed to list port forwards")
+	}
+
+	nrs := make(map[zos.NetID][]nft.Rule)
+	for _, id := range netIDs {
+		nrs[id] = nil
+	}
+
+	var ndmzRules []nft.Rule
+	for wl, forward := range forwards {
+		if !namespace.Exists(n.Namespace(forward.NetID)) {
+			continue
+		}
+
+		nrIP, err := n.nrPublicIPv4(forward.NetID)
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to get port forward network resource ip")
+			continue
+		}
+
+		comment := fmt.Sprintf("forward %s", wl)
+		// the ndmz forwards the public port to the network resource, which
+		// in its turn forwards it to the target ip
+		ndmzRules = append(ndmzRules, portForwardRule(comment, "npub4", forward.Protocol, forward.PublicPort, nrIP, forward.PublicPort))
+		if rules, ok := nrs[forward.NetID]; ok {
+			nrs[forward.NetID] = append(rules, portForwardRule(comment, "public", forward.Protocol, forward.PublicPort, forward.IP, forward.Port))
+		}
+	}
+
+	// map iteration order is random, we sort the rules so the chains are
+	// only updated if the forwards change
+	sortRules(ndmzRules)
+	if err := nft.ApplyChains(n.ndmz.Namespace(), portForwardTableOf(ndmzRules...)); err != nil {
+		return errors.Wrap(err, "failed to apply ndmz port forwards")
+	}
+
+	for id, rules := range nrs {
+		ns := n.Namespace(id)
+		if !namespace.Exists(ns) {
+			continue
+		}
+
+		sortRules(rules)
+		if err := nft.ApplyChains(ns, portForwardTableOf(rules...)); err != nil {
+			return errors.Wrapf(err, "failed to apply port forwards of network '%s'", id)
+		}
+	}
+
+	return nil
+}
+
+func sortRules(rules []nft.Rule) {
+	slices.SortFunc(rules, func(a, b nft.Rule) int {
+		return strings.Compare(a.Comment, b.Comment)
+	})
+}
+
+// checkTwinPortForwards makes sure the twin of the workload did not reach
+// the max number of port forwards
+func (n *networker) checkTwinPortForwards(wl gridtypes.WorkloadID) error {
+	twin, _, _, err := wl.Parts()
+	if err != nil {
+		return err
+	}
+
+	forwards, err := n.listPortForwards()
+	if err != nil {
+		return errors.Wrap(err, "failed to list port forwards")
+	}
+
+	if countTwinForwards(forwards, twin) >= maxTwinPortForwards {
+		return fmt.Errorf("twin '%d' reached the max of %d port forwards", twin, maxTwinPortForwards)
+	}
+
+	return nil
+}
+
+// countTwinForwards counts the forwards owned by the given twin
+func countTwinForwards(forwards map[gridtypes.WorkloadID]portForward, twin uint32) int {
+	count := 0
+	for id := range forwards {
+		owner, _, _, err := id.Parts()
+		if err == nil && owner == twin {
+			count++
+		}
+	}
+
+	return count
+}
+
+// SetupPortForward implements pkg.Networker interface
+func (n *networker) SetupPortForward(wl gridtypes.WorkloadID, forward pkg.PortForward) (uint16, error) {
+	n.forwardsLock.Lock()
+	defer n.forwardsLock.Unlock()
+
+	if forward.IP.To4() == nil {
+		return 0, fmt.Errorf("port forward target must be an ipv4")
+	}
+
+	if err := forward.Protocol.Valid(); err != nil {
+		return 0, err
+	}
+
+	if !namespace.Exists(n.Namespace(forward.NetID)) {
+		return 0, fmt.Errorf("network '%s' does not exist", forward.NetID)
+	}
+
+	netIDs := []zos.NetID{forward.NetID}
+
+	current, err := n.loadPortForward(wl)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	var port uint16
+	if err == nil {
+		// reuse the already allocated port
+		port = current.PublicPort
+		if current.NetID != forward.NetID {
+			netIDs = append(netIDs, current.NetID)
+		}
+	} else {
+		if err := n.checkTwinPortForwards(wl); err != nil {
+			return 0, err
+		}
+
+		port, err = n.allocateForwardPort(wl)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	log.Info().Stringer("workload", wl).Uint16("port", port).Msg("setting up port forward")
+
+	if err := n.storePortForward(wl, portForward{PortForward: forward, PublicPort: port}); err != nil {
+		if current.PublicPort == 0 {
+			n.releaseForwardPort(port)
+		}
+		return 0, errors.Wrap(err, "failed to store port forward")
+	}
+
+	return port, n.applyPortForwards(netIDs...)
+}
+
+// RemovePortForward implements pkg.Networker interface
+func (n *networker) RemovePortForward(wl gridtypes.WorkloadID) error {
+	n.forwardsLock.Lock()
+	defer n.forwardsLock.Unlock()
+
+	forward, err := n.loadPortForward(wl)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	log.Info().Stringer("workload", wl).Uint16("port", forward.PublicPort).Msg("removing port forward")
+
+	if err := os.Remove(n.portForwardPath(wl)); err != nil {
+		return errors.Wrap(err, "failed to delete port forward")
+	}
+
+	n.releaseForwardPort(forward.PublicPort)
+
+	return n.applyPortForwards(forward.NetID)
+}
+
+// syncPortForwards reconciles the port leases with the stored port forwards,
+// marks their ports as used and re-applies their rules. It's called on start since the ndmz ruleset is
+// recreated on networkd start
+func (n *networker) syncPortForwards() error {
+	forwards, err := n.listPortForwards()
+	if err != nil {
+		return err
+	}
+
+	if err := n.reconcileForwardPorts(forwards); err != nil {
+		log.Error().Err(err).Msg("failed to reconcile port forward ports")
+	}
+
+	var netIDs []zos.NetID
+	for _, forward := range forwards {
+		// skip error cause we don't care if there are some duplicate at this point
+		_ = n.portSet.Add(uint(forward.PublicPort))
+		netIDs = append(netIDs, forward.NetID)
+	}
+
+	return n.applyPortForwards(netIDs...)
+}
@@ -0,0 +1,39 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+)
+
+func TestPortForwardRule(t *testing.T) {
+	require := require.New(t)
+
+	ip := net.ParseIP("10.1.2.10")
+	rule := portForwardRule("forward 1-2-vm", "public", zos.ForwardTCP, 20001, ip, 22)
+	require.Equal(nft.NewRule("forward 1-2-vm", nft.IIfName("public"), nft.TCPDPort(20001, 0), nft.Counter(), nft.DNat(ip, 22)), rule)
+
+	rule = portForwardRule("forward 1-2-vm", "npub4", zos.ForwardUDP, 20001, ip, 20001)
+	require.Equal(nft.NewRule("forward 1-2-vm", nft.IIfName("npub4"), nft.UDPDPort(20001, 0), nft.Counter(), nft.DNat(ip, 20001)), rule)
+
+	rules := []nft.Rule{nft.NewRule("forward b"), nft.NewRule("forward a")}
+	sortRules(rules)
+	require.Equal("forward a", rules[0].Comment)
+}
+
+func TestCountTwinForwards(t *testing.T) {
+	forwards := map[gridtypes.WorkloadID]portForward{
+		"1-10-a":  {},
+		"1-11-b":  {},
+		"2-12-a":  {},
+		"invalid": {},
+	}
+
+	require.Equal(t, 2, countTwinForwards(forwards, 1))
+	require.Equal(t, 1, countTwinForwards(forwards, 2))
+	require.Equal(t, 0, countTwinForwards(forwards, 3))
+}
@@ -2,6 +2,7 @@ package portm
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/threefoldtech/zos/pkg/network/portm/backend"
 )
@@ -10,6 +11,17 @@ import (
 // the port of the range have been already reserved
 var ErrNoFreePort = errors.New("no free port find")
 
+// ErrPortInUse is returned when trying to reserve a specific port
+// that is already reserved by another owner
+type ErrPortInUse struct {
+	Port  int
+	Owner string
+}
+
+func (e ErrPortInUse) Error() string {
+	return fmt.Sprintf("port %d is already reserved by '%s'", e.Port, e.Owner)
+}
+
 // PortRange hold the beginging and end of a range of port
 // a PortAllocator can reserve
 type PortRange struct {
@@ -34,7 +46,7 @@ func NewAllocator(pRange PortRange, store backend.Store) *Allocator {
 }
 
 // Reserve implements PortAllocator interface
-func (a *Allocator) Reserve(ns string) (int, error) {
+func (a *Allocator) Reserve(ns string, owner string) (int, error) {
 	if err := a.store.Lock(); err != nil {
 		return 0, err
 	}
@@ -64,7 +76,7 @@ func (a *Allocator) Reserve(ns string) (int, error) {
 			continue
 		}
 
-		reserved, err := a.store.Reserve(ns, port)
+		reserved, err := a.store.Reserve(ns, port, owner)
 		if err != nil {
 			return 0, err
 		}
@@ -76,6 +88,104 @@ func (a *Allocator) Reserve(ns string) (int, error) {
 	return 0, ErrNoFreePort
 }
 
+// ReservePort implements PortAllocator interface. Reserving a port that is
+// already reserved by the same owner is a no-op. A port reserved without
+// an owner is taken over by the new owner.
+func (a *Allocator) ReservePort(ns string, port int, owner string) error {
+	if port < a.pRange.Start || port > a.pRange.End {
+		return fmt.Errorf("port %d is out of range [%d, %d]", port, a.pRange.Start, a.pRange.End)
+	}
+
+	if err := a.store.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		_ = a.store.Unlock()
+	}()
+
+	reserved, err := a.store.Reserve(ns, port, owner)
+	if err != nil || reserved {
+		return err
+	}
+
+	lease, err := a.lease(ns, port)
+	if err != nil {
+		return err
+	}
+
+	switch lease.Owner {
+	case owner:
+		return nil
+	case "":
+		if err := a.store.Release(ns, port); err != nil {
+			return err
+		}
+
+		_, err := a.store.Reserve(ns, port, owner)
+		return err
+	}
+
+	return ErrPortInUse{Port: port, Owner: lease.Owner}
+}
+
+func (a *Allocator) lease(ns string, port int) (backend.Lease, error) {
+	leases, err := a.store.Leases(ns)
+	if err != nil {
+		return backend.Lease{}, err
+	}
+
+	for _, lease := range leases {
+		if lease.Port == port {
+			return lease, nil
+		}
+	}
+
+	return backend.Lease{}, fmt.Errorf("port %d is not reserved", port)
+}
+
+// Leases implements PortAllocator interface
+func (a *Allocator) Leases(ns string) ([]backend.Lease, error) {
+	if err := a.store.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = a.store.Unlock()
+	}()
+
+	return a.store.Leases(ns)
+}
+
+// GC releases all the leases of the namespace that are not active anymore
+// (for example because their owner crashed before releasing them) and
+// returns the released leases
+func (a *Allocator) GC(ns string, active func(lease backend.Lease) bool) ([]backend.Lease, error) {
+	if err := a.store.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = a.store.Unlock()
+	}()
+
+	leases, err := a.store.Leases(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	var released []backend.Lease
+	for _, lease := range leases {
+		if active(lease) {
+			continue
+		}
+
+		if err := a.store.Release(ns, lease.Port); err != nil {
+			return released, err
+		}
+		released = append(released, lease)
+	}
+
+	return released, nil
+}
+
 // Release implements PortAllocator interface
 func (a *Allocator) Release(ns string, port int) error {
 	if err := a.store.Lock(); err != nil {
@@ -7,10 +7,12 @@ import (
 	mapset "github.com/deckarep/golang-set"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/network/portm/backend"
 )
 
 type testStore struct {
 	reserved map[string]mapset.Set
+	owners   map[string]map[int]string
 	last     map[string]int
 	sync.Mutex
 }
@@ -18,6 +20,7 @@ type testStore struct {
 func newTestStore() *testStore {
 	return &testStore{
 		reserved: make(map[string]mapset.Set),
+		owners:   make(map[string]map[int]string),
 		last:     make(map[string]int),
 	}
 }
@@ -30,11 +33,12 @@ func (s *testStore) Unlock() error {
 	s.Mutex.Unlock()
 	return nil
 }
-func (s *testStore) Reserve(ns string, port int) (bool, error) {
+func (s *testStore) Reserve(ns string, port int, owner string) (bool, error) {
 	set, ok := s.reserved[ns]
 	if !ok {
 		set = mapset.NewSet()
 		s.reserved[ns] = set
+		s.owners[ns] = make(map[int]string)
 	}
 
 	if set.Contains(port) {
@@ -42,6 +46,7 @@ func (s *testStore) Reserve(ns string, port int) (bool, error) {
 	}
 
 	s.last[ns] = port
+	s.owners[ns][port] = owner
 	return set.Add(port), nil
 }
 func (s *testStore) Release(ns string, port int) error {
@@ -69,6 +74,16 @@ func (s *testStore) GetByNS(ns string) ([]int, error) {
 	return output, nil
 }
 
+func (s *testStore) Leases(ns string) ([]backend.Lease, error) {
+	ports, _ := s.GetByNS(ns)
+	leases := make([]backend.Lease, 0, len(ports))
+	for _, port := range ports {
+		leases = append(leases, backend.Lease{Port: port, Owner: s.owners[ns][port]})
+	}
+
+	return leases, nil
+}
+
 func (s *testStore) LastReserved(ns string) (int, error) {
 	port, ok := s.last[ns]
 	if !ok {
@@ -90,13 +105,13 @@ func TestReserve(t *testing.T) {
 	ns := "ns"
 	alloc := NewAllocator(pRange, store)
 
-	p1, err := alloc.Reserve(ns)
+	p1, err := alloc.Reserve(ns, "owner")
 	require.NoError(t, err)
 	assert.True(t, store.reserved[ns].Contains(p1))
 	assert.True(t, p1 >= pRange.Start)
 	assert.True(t, p1 <= pRange.End)
 
-	p2, err := alloc.Reserve(ns)
+	p2, err := alloc.Reserve(ns, "owner")
 	require.NoError(t, err)
 	assert.True(t, store.reserved[ns].Contains(p2))
 	assert.True(t, p1 != p2)
@@ -123,7 +138,7 @@ func TestReserveConcurent(t *testing.T) {
 			reserved[i] = make([]int, 0, 20)
 
 			for y := 0; y < 20; y++ {
-				p, err := alloc.Reserve(ns)
+				p, err := alloc.Reserve(ns, "owner")
 				require.NoError(t, err)
 				reserved[i] = append(reserved[i], p)
 			}
@@ -152,25 +167,25 @@ func TestReuseReleased(t *testing.T) {
 	alloc := NewAllocator(pRange, store)
 
 	for i := 0; i <= 5000; i++ {
-		_, err := alloc.Reserve(ns)
+		_, err := alloc.Reserve(ns, "owner")
 		require.NoError(t, err)
 	}
 
-	_, err := alloc.Reserve(ns)
+	_, err := alloc.Reserve(ns, "owner")
 	assert.Equal(t, err, ErrNoFreePort)
 
 	err = alloc.Release(ns, 1000)
 	require.NoError(t, err)
 
-	port, err := alloc.Reserve(ns)
+	port, err := alloc.Reserve(ns, "owner")
 	require.NoError(t, err)
 	assert.Equal(t, 1000, port)
 }
 
 func TestRelease(t *testing.T) {
 	store := newTestStore()
-	_, _ = store.Reserve("ns", 1000)
-	_, _ = store.Reserve("ns", 1001)
+	_, _ = store.Reserve("ns", 1000, "owner")
+	_, _ = store.Reserve("ns", 1001, "owner")
 
 	pRange := PortRange{
 		Start: 1000,
@@ -184,6 +199,61 @@ func TestRelease(t *testing.T) {
 	assert.False(t, store.reserved["ns"].Contains(1000))
 }
 
+func TestReservePort(t *testing.T) {
+	store := newTestStore()
+	pRange := PortRange{
+		Start: 1000,
+		End:   6000,
+	}
+	alloc := NewAllocator(pRange, store)
+
+	err := alloc.ReservePort("ns", 2000, "a")
+	require.NoError(t, err)
+	assert.True(t, store.reserved["ns"].Contains(2000))
+
+	// same owner
+	err = alloc.ReservePort("ns", 2000, "a")
+	require.NoError(t, err)
+
+	err = alloc.ReservePort("ns", 2000, "b")
+	assert.Equal(t, ErrPortInUse{Port: 2000, Owner: "a"}, err)
+
+	err = alloc.ReservePort("ns", 100, "a")
+	assert.Error(t, err)
+
+	// ports reserved without an owner are taken over
+	_, _ = store.Reserve("ns", 3000, "")
+	err = alloc.ReservePort("ns", 3000, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "b", store.owners["ns"][3000])
+}
+
+func TestGC(t *testing.T) {
+	store := newTestStore()
+	pRange := PortRange{
+		Start: 1000,
+		End:   6000,
+	}
+	alloc := NewAllocator(pRange, store)
+
+	require.NoError(t, alloc.ReservePort("ns", 1000, "a"))
+	require.NoError(t, alloc.ReservePort("ns", 1001, "b"))
+	require.NoError(t, alloc.ReservePort("ns", 1002, "c"))
+
+	released, err := alloc.GC("ns", func(lease backend.Lease) bool {
+		return lease.Owner != "b"
+	})
+	require.NoError(t, err)
+	require.Len(t, released, 1)
+	assert.Equal(t, 1001, released[0].Port)
+	assert.Equal(t, "b", released[0].Owner)
+
+	leases, err := alloc.Leases("ns")
+	require.NoError(t, err)
+	assert.Len(t, leases, 2)
+	assert.False(t, store.reserved["ns"].Contains(1001))
+}
+
 func BenchmarkReserve(b *testing.B) {
 	store := newTestStore()
 	pRange := PortRange{
@@ -193,7 +263,7 @@ func BenchmarkReserve(b *testing.B) {
 	alloc := NewAllocator(pRange, store)
 
 	for i := 0; i < b.N; i++ {
-		port, err := alloc.Reserve("ns")
+		port, err := alloc.Reserve("ns", "owner")
 		if err == ErrNoFreePort {
 			break
 		}
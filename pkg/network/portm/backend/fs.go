@@ -35,14 +35,16 @@ func nsPath(root, ns string, port string) string {
 	return filepath.Join(root, ns, port)
 }
 
-func (s *fsStore) Reserve(ns string, port int) (bool, error) {
+// Reserve reserves the port in the namespace, the owner of the port is
+// stored as the content of the port file
+func (s *fsStore) Reserve(ns string, port int, owner string) (bool, error) {
 	fname := nsPath(s.root, ns, strconv.Itoa(port))
 
 	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
 		return false, err
 	}
 
-	f, err := os.OpenFile(fname, os.O_CREATE|os.O_EXCL|os.O_TRUNC, 0660)
+	f, err := os.OpenFile(fname, os.O_CREATE|os.O_EXCL|os.O_TRUNC|os.O_WRONLY, 0660)
 	if os.IsExist(err) {
 		return false, nil
 	}
@@ -50,6 +52,12 @@ func (s *fsStore) Reserve(ns string, port int) (bool, error) {
 		return false, err
 	}
 
+	if _, err := f.WriteString(owner); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return false, err
+	}
+
 	if err := f.Close(); err != nil {
 		os.Remove(f.Name())
 		return false, err
@@ -83,25 +91,62 @@ func (s *fsStore) LastReserved(ns string) (int, error) {
 }
 
 func (s *fsStore) GetByNS(ns string) ([]int, error) {
-	var ports []int
+	leases, err := s.Leases(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]int, 0, len(leases))
+	for _, lease := range leases {
+		ports = append(ports, lease.Port)
+	}
+
+	return ports, nil
+}
+
+// Leases returns all the reserved ports of the namespace with their owners
+func (s *fsStore) Leases(ns string) ([]Lease, error) {
 	dir := filepath.Join(s.root, ns)
 
-	infos, err := os.ReadDir(dir)
-	if err != nil {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		// nothing reserved yet in this namespace
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
-	// walk through all ips in this network to get the ones which belong to a specific ID
-	for _, info := range infos {
-		if info.IsDir() {
+
+	var leases []Lease
+	for _, entry := range entries {
+		if entry.IsDir() {
 			continue
 		}
 
-		p, err := strconv.Atoi(info.Name())
+		port, err := strconv.Atoi(entry.Name())
 		if err != nil {
+			// not a port file (for example the last reserved port file)
+			continue
+		}
+
+		owner, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if os.IsNotExist(err) {
+			// released in the meantime
+			continue
+		} else if err != nil {
 			return nil, err
 		}
-		ports = append(ports, p)
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		leases = append(leases, Lease{
+			Port:    port,
+			Owner:   string(owner),
+			Created: info.ModTime(),
+		})
 	}
 
-	return ports, nil
+	return leases, nil
 }
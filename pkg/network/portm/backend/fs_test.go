@@ -16,22 +16,57 @@ func TestReserve(t *testing.T) {
 	store, err := NewFSStore(dir)
 	require.NoError(t, err)
 
-	reserved, err := store.Reserve(ns1, 1)
+	reserved, err := store.Reserve(ns1, 1, "owner")
 	require.NoError(t, err)
 	assert.True(t, reserved)
 
-	reserved, err = store.Reserve(ns1, 1)
+	reserved, err = store.Reserve(ns1, 1, "owner")
 	require.NoError(t, err)
 	assert.False(t, reserved, "should not be able to reserve the same port twice")
 
 	err = store.Release(ns1, 1)
 	require.NoError(t, err)
 
-	reserved, err = store.Reserve(ns1, 1)
+	reserved, err = store.Reserve(ns1, 1, "owner")
 	require.NoError(t, err)
 	assert.True(t, reserved, "should be able to reserve a released port")
 
-	reserved, err = store.Reserve(ns2, 1)
+	reserved, err = store.Reserve(ns2, 1, "owner")
 	require.NoError(t, err)
 	assert.True(t, reserved, "should be able to reserve same port in difference namespace")
 }
+
+func TestLeases(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFSStore(dir)
+	require.NoError(t, err)
+
+	leases, err := store.Leases("ns")
+	require.NoError(t, err)
+	assert.Empty(t, leases)
+
+	_, err = store.Reserve("ns", 1, "a")
+	require.NoError(t, err)
+	_, err = store.Reserve("ns", 2, "b")
+	require.NoError(t, err)
+
+	leases, err = store.Leases("ns")
+	require.NoError(t, err)
+	require.Len(t, leases, 2)
+
+	owners := map[int]string{}
+	for _, lease := range leases {
+		owners[lease.Port] = lease.Owner
+		assert.False(t, lease.Created.IsZero())
+	}
+	assert.Equal(t, map[int]string{1: "a", 2: "b"}, owners)
+
+	ports, err := store.GetByNS("ns")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{1, 2}, ports)
+
+	last, err := store.LastReserved("ns")
+	require.NoError(t, err)
+	assert.Equal(t, 2, last)
+}
@@ -1,13 +1,25 @@
 package backend
 
+import "time"
+
+// Lease is a port reserved in a namespace
+type Lease struct {
+	Port int
+	// Owner is the identifier of the owner of the port (usually a
+	// workload id). It's empty for ports reserved without an owner
+	Owner   string
+	Created time.Time
+}
+
 // Store define the interface to implement to be used
 // as a backend for a port Allocator
 type Store interface {
 	Lock() error
 	Unlock() error
-	Reserve(ns string, port int) (bool, error)
+	Reserve(ns string, port int, owner string) (bool, error)
 	Release(ns string, port int) error
 	LastReserved(ns string) (int, error)
 	GetByNS(ns string) ([]int, error)
+	Leases(ns string) ([]Lease, error)
 	Close() error
 }
@@ -1,9 +1,13 @@
 package portm
 
+import "github.com/threefoldtech/zos/pkg/network/portm/backend"
+
 // PortAllocator is the interface that defines
 // the behavior to reserve a port in a specific
 // network namespace
 type PortAllocator interface {
-	Reserve(ns string) (int, error)
+	Reserve(ns string, owner string) (int, error)
+	ReservePort(ns string, port int, owner string) error
 	Release(ns string, port int) error
+	Leases(ns string) ([]backend.Lease, error)
 }
@@ -0,0 +1,204 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/portm"
+	"github.com/threefoldtech/zos/pkg/network/portm/backend"
+)
+
+const (
+	wgPortsDir = "wireguard-ports"
+	// wgPortsNS is the allocator namespace of the wireguard listen ports
+	wgPortsNS = "wireguard"
+)
+
+// wgPortRange is the range of wireguard listen ports, the port is picked
+// by the user so the full range is allowed
+var wgPortRange = portm.PortRange{Start: 1, End: 65535}
+
+func newWGPortAllocator(root string) (*portm.Allocator, error) {
+	store, err := backend.NewFSStore(filepath.Join(root, wgPortsDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create wireguard ports store")
+	}
+
+	return portm.NewAllocator(wgPortRange, store), nil
+}
+
+// reservePort reserves the wireguard listen port of the network workload
+func (n *networker) reservePort(wl gridtypes.WorkloadID, port uint16) error {
+	log.Debug().Uint16("port", port).Stringer("workload", wl).Msg("reserve wireguard port")
+	err := n.portSet.Add(uint(port))
+	if err != nil {
+		return errors.Wrap(err, "wireguard listen port already in use, pick another one")
+	}
+
+	if err := n.wgPorts.ReservePort(wgPortsNS, int(port), wl.String()); err != nil {
+		n.portSet.Remove(uint(port))
+		return errors.Wrap(err, "failed to reserve wireguard listen port")
+	}
+
+	return nil
+}
+
+func (n *networker) releasePort(port uint16) error {
+	log.Debug().Uint16("port", port).Msg("release wireguard port")
+	n.portSet.Remove(uint(port))
+	if err := n.wgPorts.Release(wgPortsNS, int(port)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to release wireguard listen port")
+	}
+
+	return nil
+}
+
+// activeWGPorts returns the wireguard listen ports of all the network
+// workloads known to networkd
+func (n *networker) activeWGPorts() (map[int]gridtypes.WorkloadID, error) {
+	links, err := os.ReadDir(n.linkDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list networks")
+	}
+
+	ports := make(map[int]gridtypes.WorkloadID)
+	for _, link := range links {
+		if link.IsDir() {
+			continue
+		}
+
+		wl := gridtypes.WorkloadID(link.Name())
+		sym, err := os.Readlink(filepath.Join(n.linkDir, link.Name()))
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to get network name from workload link")
+			continue
+		}
+
+		network, err := n.networkOf(zos.NetID(filepath.Base(sym)))
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to load network")
+			continue
+		}
+
+		ports[int(network.WGListenPort)] = wl
+	}
+
+	return ports, nil
+}
+
+// reconcileWGPorts releases the wireguard port leases of network workloads
+// that do not exist anymore (for example a crash during provisioning) and
+// records the leases of running networks that have none. It also marks all
+// leased ports as used.
+func (n *networker) reconcileWGPorts() error {
+	active, err := n.activeWGPorts()
+	if err != nil {
+		return err
+	}
+
+	released, err := n.wgPorts.GC(wgPortsNS, func(lease backend.Lease) bool {
+		_, ok := active[lease.Port]
+		return ok
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to release orphaned wireguard ports")
+	}
+
+	for _, lease := range released {
+		log.Info().Int("port", lease.Port).Str("owner", lease.Owner).Msg("released orphaned wireguard port")
+	}
+
+	for port, wl := range active {
+		// the same network can be used by multiple workloads, a lease owned
+		// by any of them is fine
+		err := n.wgPorts.ReservePort(wgPortsNS, port, wl.String())
+		if err != nil && !errors.As(err, &portm.ErrPortInUse{}) {
+			log.Error().Err(err).Int("port", port).Stringer("workload", wl).Msg("failed to reserve wireguard port")
+		}
+	}
+
+	leases, err := n.wgPorts.Leases(wgPortsNS)
+	if err != nil {
+		return errors.Wrap(err, "failed to list wireguard ports")
+	}
+
+	for _, lease := range leases {
+		// skip error cause we don't care if there are some duplicate at this point
+		_ = n.portSet.Add(uint(lease.Port))
+	}
+
+	return nil
+}
+
+// reconcileForwardPorts releases the port forward leases that are not used
+// by any stored port forward and records the leases of the ones that have
+// none
+func (n *networker) reconcileForwardPorts(forwards map[gridtypes.WorkloadID]portForward) error {
+	ns := n.ndmz.Namespace()
+
+	active := make(map[int]gridtypes.WorkloadID)
+	for wl, forward := range forwards {
+		active[int(forward.PublicPort)] = wl
+	}
+
+	released, err := n.portForwards.GC(ns, func(lease backend.Lease) bool {
+		wl, ok := active[lease.Port]
+		return ok && (len(lease.Owner) == 0 || lease.Owner == wl.String())
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to release orphaned port forward ports")
+	}
+
+	for _, lease := range released {
+		log.Info().Int("port", lease.Port).Str("owner", lease.Owner).Msg("released orphaned port forward port")
+	}
+
+	for port, wl := range active {
+		if err := n.portForwards.ReservePort(ns, port, wl.String()); err != nil {
+			log.Error().Err(err).Int("port", port).Stringer("workload", wl).Msg("failed to reserve port forward port")
+		}
+	}
+
+	return nil
+}
+
+// PortLeases implements pkg.Networker interface
+func (n *networker) PortLeases() ([]pkg.PortLease, error) {
+	allocators := []struct {
+		typ       pkg.PortLeaseType
+		ns        string
+		allocator *portm.Allocator
+	}{
+		{pkg.PortLeaseWireguard, wgPortsNS, n.wgPorts},
+		{pkg.PortLeasePortForward, n.ndmz.Namespace(), n.portForwards},
+	}
+
+	leases := make([]pkg.PortLease, 0)
+	for _, alloc := range allocators {
+		reserved, err := alloc.allocator.Leases(alloc.ns)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s ports", alloc.typ)
+		}
+
+		for _, lease := range reserved {
+			leases = append(leases, pkg.PortLease{
+				Type:    alloc.typ,
+				Port:    uint16(lease.Port),
+				Owner:   lease.Owner,
+				Created: lease.Created,
+			})
+		}
+	}
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].Port < leases[j].Port
+	})
+
+	return leases, nil
+}
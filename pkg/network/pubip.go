@@ -0,0 +1,300 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"github.com/threefoldtech/zos/pkg/network/public"
+)
+
+const (
+	// pubIPFilterTable is the table (in the bridge family) where
+	// all public ip filters are installed
+	pubIPFilterTable = "filter"
+)
+
+// pubIPFilterChains returns the chains of the public ip filter. The pre chain
+// checks traffic coming from the vm, and the post chain checks traffic going
+// to the vm. Once the anti spoofing checks pass the traffic goes through the
+// user firewall chains (in and out)
+func pubIPFilterChains(name string, ipv4 net.IP, mac net.HardwareAddr) (pre nft.Chain, post nft.Chain) {
+	// ipv6 is not filtered yet, since the vm ipv6 is assigned over SLAAC
+
+	pre = nft.RegularChain(name+"-pre",
+		nft.NewRule("", nft.SAddrNot(ipv4), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.EtherType(nft.EtherTypeIPv4), nft.EtherSAddrNot(mac), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.ARPOperation(nft.ARPReply), nft.ARPSAddrIPNot(ipv4), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.ARPOperation(nft.ARPRequest), nft.ARPSAddrIPNot(ipv4), nft.Counter(), nft.Drop()),
+		jumpRule(name+"-out"),
+	)
+
+	post = nft.RegularChain(name+"-post",
+		nft.NewRule("", nft.DAddrNot(ipv4), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.EtherType(nft.EtherTypeIPv4), nft.EtherDAddrNot(mac), nft.Counter(), nft.Drop()),
+		jumpRule(name+"-in"),
+	)
+
+	return
+}
+
+// pubIPFloatingChains returns the chains of a public ip that is routed to
+// the public tap of another vm (floating ip). The chains only check traffic
+// of the floating ip, other traffic goes on to the filter of the tap itself.
+// The floating ip traffic still goes through the firewall chains of the
+// public ip.
+func pubIPFloatingChains(name string, ipv4 net.IP, mac net.HardwareAddr) (pre nft.Chain, post nft.Chain) {
+	pre = nft.RegularChain(name+"-float-pre",
+		nft.NewRule("", nft.SAddr(ipv4), nft.EtherSAddrNot(mac), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.SAddr(ipv4), nft.Jump(name+"-out")),
+		nft.NewRule("", nft.SAddr(ipv4), nft.Accept()),
+		nft.NewRule("", nft.ARPOperation(nft.ARPReply), nft.ARPSAddrIP(ipv4), nft.Accept()),
+		nft.NewRule("", nft.ARPOperation(nft.ARPRequest), nft.ARPSAddrIP(ipv4), nft.Accept()),
+	)
+
+	post = nft.RegularChain(name+"-float-post",
+		nft.NewRule("", nft.DAddr(ipv4), nft.EtherDAddrNot(mac), nft.Counter(), nft.Drop()),
+		nft.NewRule("", nft.DAddr(ipv4), nft.Jump(name+"-in")),
+		nft.NewRule("", nft.DAddr(ipv4), nft.Accept()),
+	)
+
+	return
+}
+
+// pubIPFirewallChains returns the user firewall chains of the public ip filter
+func pubIPFirewallChains(name string, firewall *zos.Firewall) (in nft.Chain, out nft.Chain) {
+	ingress := nft.FirewallRules(firewall, zos.FirewallIngress)
+	if len(ingress) != 0 {
+		ingress = append([]nft.Rule{
+			nft.NewRule("", nft.CtState(expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.EtherType(nft.EtherTypeARP), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.ICMPv6Type(nft.ICMPv6NeighborSolicitation), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.ICMPv6Type(nft.ICMPv6NeighborAdvertisement), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.ICMPv6Type(nft.ICMPv6RouterAdvertisement), nft.Counter(), nft.Return()),
+		}, ingress...)
+	}
+
+	egress := nft.FirewallRules(firewall, zos.FirewallEgress)
+	if len(egress) != 0 {
+		egress = append([]nft.Rule{
+			nft.NewRule("", nft.CtState(expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.EtherType(nft.EtherTypeARP), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.ICMPv6Type(nft.ICMPv6NeighborSolicitation), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.ICMPv6Type(nft.ICMPv6NeighborAdvertisement), nft.Counter(), nft.Return()),
+			nft.NewRule("", nft.ICMPv6Type(nft.ICMPv6RouterSolicitation), nft.Counter(), nft.Return()),
+		}, egress...)
+	}
+
+	return nft.RegularChain(name+"-in", ingress...), nft.RegularChain(name+"-out", egress...)
+}
+
+func jumpRule(chain string) nft.Rule {
+	return nft.NewRule(fmt.Sprintf("jump %s", chain), nft.Jump(chain))
+}
+
+// SetupPubIPFilter sets up filter for this public ip. If the filter already
+// exists the anti spoofing rules are updated, the firewall rules are kept.
+func (n *networker) SetupPubIPFilter(filterName string, iface string, ipv4 net.IP, ipv6 net.IP, mac string) error {
+	// if no ipv4 provided, we make sure to use zero ip
+	// so the user can't just assign an ip to his vm to use.
+	ipv4 = ipv4.To4()
+	if len(ipv4) == 0 {
+		ipv4 = net.IPv4zero.To4()
+	}
+
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return errors.Wrapf(err, "invalid mac address '%s'", mac)
+	}
+
+	// public ips of a pool need to be on the pool vlan
+	if err := public.SetTapVlan(iface, ipv4); err != nil {
+		return errors.Wrap(err, "failed to set public tap vlan")
+	}
+
+	pre, post := pubIPFilterChains(filterName, ipv4, hw)
+	chains := []nft.Chain{pre, post}
+
+	exists, err := nft.ChainExists("", nftables.TableFamilyBridge, pubIPFilterTable, filterName+"-in")
+	if err != nil {
+		return errors.Wrap(err, "failed to check public ip firewall chains")
+	}
+
+	if !exists {
+		in, out := pubIPFirewallChains(filterName, nil)
+		chains = append([]nft.Chain{in, out}, chains...)
+	}
+
+	if err := nft.ApplyChains("", nft.Table{
+		Family: nftables.TableFamilyBridge,
+		Name:   pubIPFilterTable,
+		Chains: chains,
+	}); err != nil {
+		return errors.Wrap(err, "could not setup firewall rules for public ip")
+	}
+
+	// make nft jump to vm chains
+	jumps := []struct {
+		chain string
+		rule  nft.Rule
+	}{
+		{chain: "prerouting", rule: nft.NewRule(fmt.Sprintf("jump %s", pre.Name), nft.IIfName(iface), nft.Jump(pre.Name))},
+		{chain: "postrouting", rule: nft.NewRule(fmt.Sprintf("jump %s", post.Name), nft.OIfName(iface), nft.Jump(post.Name))},
+	}
+
+	for _, jump := range jumps {
+		if err := nft.EnsureRule("", nftables.TableFamilyBridge, pubIPFilterTable, jump.chain, jump.rule); err != nil {
+			return errors.Wrap(err, "could not setup firewall rules for public ip")
+		}
+	}
+
+	return nil
+}
+
+// SetPubIPFloating routes the public ipv4 of the filter to the public tap
+// iface (with given mac) of another vm. Any previous routing of the ip is
+// removed first. If iface is empty the ip is only removed from the previous
+// tap.
+func (n *networker) SetPubIPFloating(filterName string, iface string, ipv4 net.IP, mac string) error {
+	if err := nft.DeleteChains("", nftables.TableFamilyBridge, pubIPFilterTable,
+		filterName+"-float-pre",
+		filterName+"-float-post",
+	); err != nil {
+		return errors.Wrap(err, "failed to remove previous floating ip rules")
+	}
+
+	if len(iface) == 0 {
+		return nil
+	}
+
+	if ipv4.To4() == nil {
+		return fmt.Errorf("floating ip requires an ipv4")
+	}
+
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return errors.Wrapf(err, "invalid mac address '%s'", mac)
+	}
+
+	pre, post := pubIPFloatingChains(filterName, ipv4.To4(), hw)
+	if err := nft.ApplyChains("", nft.Table{
+		Family: nftables.TableFamilyBridge,
+		Name:   pubIPFilterTable,
+		Chains: []nft.Chain{pre, post},
+	}); err != nil {
+		return errors.Wrap(err, "could not setup floating ip rules")
+	}
+
+	// the floating ip rules need to run before the filter of the tap itself
+	jumps := []struct {
+		chain string
+		rule  nft.Rule
+	}{
+		{chain: "prerouting", rule: nft.NewRule(fmt.Sprintf("jump %s", pre.Name), nft.IIfName(iface), nft.Jump(pre.Name))},
+		{chain: "postrouting", rule: nft.NewRule(fmt.Sprintf("jump %s", post.Name), nft.OIfName(iface), nft.Jump(post.Name))},
+	}
+
+	for _, jump := range jumps {
+		if err := nft.InsertRule("", nftables.TableFamilyBridge, pubIPFilterTable, jump.chain, jump.rule); err != nil {
+			return errors.Wrap(err, "could not setup floating ip rules")
+		}
+	}
+
+	return nil
+}
+
+// AnnouncePubIP sends a gratuitous arp for ipv4 and an unsolicited neighbor
+// advertisement for ipv6 from mac on the public bridge. This is used after
+// a public ip moved to another vm so the gateway learns the new mac
+func (n *networker) AnnouncePubIP(ipv4 net.IP, ipv6 net.IP, mac string) error {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return errors.Wrapf(err, "invalid mac address '%s'", mac)
+	}
+
+	for _, ip := range []net.IP{ipv4, ipv6} {
+		if len(ip) == 0 || ip.IsUnspecified() {
+			continue
+		}
+
+		vlan, err := public.IPVlan(ip)
+		if err != nil {
+			return err
+		}
+
+		if err := ifaceutil.Announce(public.PublicBridge, vlan, hw, ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetPubIPFirewall sets (or updates) the user firewall rules of the public ip
+// filter. The filter must already exist
+func (n *networker) SetPubIPFirewall(filterName string, firewall zos.Firewall) error {
+	in, out := pubIPFirewallChains(filterName, &firewall)
+
+	if err := nft.ApplyChains("", nft.Table{
+		Family: nftables.TableFamilyBridge,
+		Name:   pubIPFilterTable,
+		Chains: []nft.Chain{in, out},
+	}); err != nil {
+		return errors.Wrap(err, "could not apply firewall rules for public ip")
+	}
+
+	// filters created by older versions has no jumps to the firewall chains
+	jumps := map[string]string{
+		filterName + "-pre":  out.Name,
+		filterName + "-post": in.Name,
+	}
+
+	for chain, target := range jumps {
+		if err := nft.EnsureRule("", nftables.TableFamilyBridge, pubIPFilterTable, chain, jumpRule(target)); err != nil {
+			return errors.Wrapf(err, "failed to hook firewall chain '%s'", target)
+		}
+	}
+
+	return nil
+}
+
+// PubIPFilterExists checks if pub ip filter
+func (n *networker) PubIPFilterExists(filterName string) bool {
+	// the filterName chain is only checked for backward compatibility
+	for _, chain := range []string{filterName + "-pre", filterName} {
+		exists, err := nft.ChainExists("", nftables.TableFamilyBridge, pubIPFilterTable, chain)
+		if err == nil && exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemovePubIPFilter removes the filter setted up by SetupPubIPFilter
+func (n *networker) RemovePubIPFilter(filterName string) error {
+	// the filterName chain (in both bridge and arp tables) is only
+	// deleted for backward compatibility with older setups
+	if err := nft.DeleteChains("", nftables.TableFamilyBridge, pubIPFilterTable,
+		filterName+"-pre",
+		filterName+"-post",
+		filterName+"-in",
+		filterName+"-out",
+		filterName+"-float-pre",
+		filterName+"-float-post",
+		filterName,
+	); err != nil {
+		return errors.Wrap(err, "could not tear down firewall rules for public ip")
+	}
+
+	if err := nft.DeleteChains("", nftables.TableFamilyARP, pubIPFilterTable, filterName); err != nil {
+		return errors.Wrap(err, "could not tear down firewall rules for public ip")
+	}
+
+	return nil
+}
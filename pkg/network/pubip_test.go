@@ -0,0 +1,57 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+)
+
+func TestPubIPFilterChains(t *testing.T) {
+	require := require.New(t)
+
+	mac, err := net.ParseMAC("de:ad:be:ef:00:01")
+	require.NoError(err)
+
+	pre, post := pubIPFilterChains("r-test", net.ParseIP("185.69.166.10"), mac)
+	require.Equal("r-test-pre", pre.Name)
+	require.Equal("r-test-post", post.Name)
+	require.Nil(pre.Hook)
+
+	// the last rule of each chain jumps to the user firewall chains
+	require.Equal(jumpRule("r-test-out"), pre.Rules[len(pre.Rules)-1])
+	require.Equal(jumpRule("r-test-in"), post.Rules[len(post.Rules)-1])
+
+	// no firewall means empty firewall chains
+	in, out := pubIPFirewallChains("r-test", nil)
+	require.Equal("r-test-in", in.Name)
+	require.Equal("r-test-out", out.Name)
+	require.Empty(in.Rules)
+	require.Empty(out.Rules)
+
+	in, out = pubIPFirewallChains("r-test", &zos.Firewall{
+		IngressPolicy: zos.FirewallDrop,
+	})
+	require.Empty(out.Rules)
+	// replies, arp and neighbor discovery are always allowed before the policy
+	require.Len(in.Rules, 6)
+	require.Equal(nft.NewRule("ingress policy", nft.Counter(), nft.Drop()), in.Rules[5])
+}
+
+func TestPubIPFloatingChains(t *testing.T) {
+	require := require.New(t)
+
+	mac, err := net.ParseMAC("de:ad:be:ef:00:02")
+	require.NoError(err)
+
+	ip := net.ParseIP("185.69.166.11").To4()
+	pre, post := pubIPFloatingChains("r-test", ip, mac)
+	require.Equal("r-test-float-pre", pre.Name)
+	require.Equal("r-test-float-post", post.Name)
+
+	// floating ip traffic goes through the public ip firewall chains
+	require.Equal(nft.NewRule("", nft.SAddr(ip), nft.Jump("r-test-out")), pre.Rules[1])
+	require.Equal(nft.NewRule("", nft.DAddr(ip), nft.Jump("r-test-in")), post.Rules[1])
+}
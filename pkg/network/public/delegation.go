@@ -0,0 +1,184 @@
+package public
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/network/dhcp"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/types"
+	"github.com/threefoldtech/zos/pkg/zinit"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// prefixDelegationConf is the dhcpcd config in the public namespace, it
+	// only requests a delegated prefix. The public ipv6 itself is set from
+	// the public config (or slaac). The prefix is not assigned to any
+	// interface, it's routed to vms instead.
+	prefixDelegationConf = `ipv6only
+noipv6rs
+nohook resolv.conf
+ia_pd 1 -
+`
+)
+
+var (
+	// delegatedPrefixKey matches the prefix keys of the dhcpcd lease dump
+	// (for example dhcp6_ia_pd1_prefix1)
+	delegatedPrefixKey = regexp.MustCompile(`^dhcp6_ia_pd\d+_prefix\d+$`)
+)
+
+// prefixDelegationService returns the dhcp client service of the public
+// interface
+func prefixDelegationService() dhcp.ClientService {
+	return dhcp.NewService(types.PublicIface, PublicNamespace, zinit.Default())
+}
+
+// EnsurePrefixDelegation starts (or stops) requesting an ipv6 prefix over
+// DHCPv6 prefix delegation on the public interface
+func EnsurePrefixDelegation(enabled bool) error {
+	service := prefixDelegationService()
+	exists, err := zinit.Default().Exists(service.Name)
+	if err != nil {
+		return errors.Wrap(err, "failed to check prefix delegation service")
+	}
+
+	if !enabled {
+		if !exists {
+			return nil
+		}
+
+		return service.Destroy()
+	}
+
+	if !HasPublicSetup() {
+		return ErrNoPublicConfig
+	}
+
+	// files in /etc/netns/<ns> are bind mounted over /etc by `ip netns exec`
+	path := filepath.Join("/etc", "netns", PublicNamespace)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return errors.Wrap(err, "failed to create public netns directory")
+	}
+
+	if err := os.WriteFile(filepath.Join(path, "dhcpcd.conf"), []byte(prefixDelegationConf), 0644); err != nil {
+		return errors.Wrap(err, "failed to write prefix delegation config")
+	}
+
+	if exists {
+		return nil
+	}
+
+	if err := service.Create(); err != nil {
+		return errors.Wrap(err, "failed to create prefix delegation service")
+	}
+
+	return nil
+}
+
+// DelegatedPrefixes returns the prefixes delegated to the node over DHCPv6
+func DelegatedPrefixes() ([]net.IPNet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ip", "netns", "exec", PublicNamespace, "dhcpcd", "-6", "-U", types.PublicIface)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get delegated prefixes lease")
+	}
+
+	return parseDelegatedPrefixes(output), nil
+}
+
+// parseDelegatedPrefixes parses the dhcpcd lease dump and returns all the
+// delegated prefixes in it
+func parseDelegatedPrefixes(lease []byte) []net.IPNet {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(lease))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		values[key] = strings.Trim(value, `'"`)
+	}
+
+	var prefixes []net.IPNet
+	for key, value := range values {
+		if !delegatedPrefixKey.MatchString(key) {
+			continue
+		}
+
+		ip := net.ParseIP(value)
+		length, err := strconv.Atoi(values[key+"_length"])
+		if ip == nil || ip.To4() != nil || err != nil || length <= 0 || length > 128 {
+			log.Warn().Str("key", key).Str("value", value).Msg("ignoring invalid delegated prefix")
+			continue
+		}
+
+		prefixes = append(prefixes, net.IPNet{IP: ip.Mask(net.CIDRMask(length, 128)), Mask: net.CIDRMask(length, 128)})
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].String() < prefixes[j].String()
+	})
+
+	return prefixes
+}
+
+// SetPrefixRoute routes the prefix to nexthop over the public interface
+func SetPrefixRoute(prefix net.IPNet, nexthop net.IP) error {
+	return prefixRoute(prefix, func(route *netlink.Route) error {
+		route.Gw = nexthop
+		// the vm ipv6 is not necessarily in the subnet of the public ipv6
+		route.Flags = int(netlink.FLAG_ONLINK)
+		return netlink.RouteReplace(route)
+	})
+}
+
+// RemovePrefixRoute removes the route of the prefix
+func RemovePrefixRoute(prefix net.IPNet) error {
+	return prefixRoute(prefix, func(route *netlink.Route) error {
+		if err := netlink.RouteDel(route); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func prefixRoute(prefix net.IPNet, apply func(route *netlink.Route) error) error {
+	pubNS, err := namespace.GetByName(PublicNamespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to get public namespace")
+	}
+	defer pubNS.Close()
+
+	return pubNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(types.PublicIface)
+		if err != nil {
+			return errors.Wrap(err, "failed to get public interface")
+		}
+
+		return apply(&netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       &prefix,
+		})
+	})
+}
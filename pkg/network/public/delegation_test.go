@@ -0,0 +1,28 @@
+package public
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDelegatedPrefixes(t *testing.T) {
+	require := require.New(t)
+
+	lease := []byte(`dhcp6_server_id=000300015254001234
+dhcp6_ia_pd1_prefix1=2001:db8:100::
+dhcp6_ia_pd1_prefix1_length=56
+dhcp6_ia_pd1_prefix1_vltime=86400
+dhcp6_ia_pd1_prefix2='2001:db8:200:10::'
+dhcp6_ia_pd1_prefix2_length='60'
+dhcp6_ia_pd1_prefix3=10.0.0.0
+dhcp6_ia_pd1_prefix3_length=24
+`)
+
+	prefixes := parseDelegatedPrefixes(lease)
+	require.Len(prefixes, 2)
+	require.Equal("2001:db8:100::/56", prefixes[0].String())
+	require.Equal("2001:db8:200:10::/60", prefixes[1].String())
+
+	require.Empty(parseDelegatedPrefixes([]byte("dhcp6_server_id=0003\n")))
+}
@@ -269,8 +269,13 @@ func GetPublicSetup() (pkg.PublicConfig, error) {
 	if set, err := LoadPublicConfig(); err != nil {
 		return pkg.PublicConfig{}, errors.Wrap(err, "failed to load configuration")
 	} else {
-		// we only need the domain name from the config
+		// we only need the domain names, uplink, delegation and snat pools
+		// from the config
 		cfg.Domain = set.Domain
+		cfg.Uplink = set.Uplink
+		cfg.Delegation = set.Delegation
+		cfg.GatewayDomains = set.GatewayDomains
+		cfg.SNATPools = set.SNATPools
 	}
 	// everything else is loaded from the actual state of the node.
 	err = namespace.Do(func(_ ns.NetNS) error {
@@ -344,7 +349,14 @@ func EnsurePublicSetup(nodeID pkg.Identifier, vlan *uint16, inf *pkg.PublicConfi
 	}
 
 	_, err = GetCurrentPublicExitLink()
-	if os.IsNotExist(err) {
+	if inf != nil && inf.Uplink != nil && !inf.Uplink.IsEmpty() {
+		// bonds and vlan links do not survive a reboot, so a farmer defined
+		// uplink is always applied
+		log.Debug().Strs("nics", inf.Uplink.NICs).Msg("setting up public uplink")
+		if err := applyUplink(br, inf.Uplink); err != nil {
+			return nil, errors.Wrap(err, "failed to setup public uplink")
+		}
+	} else if os.IsNotExist(err) {
 		// bridge is not initialized, wire it.
 		log.Debug().Msg("no public bridge uplink found, setting up...")
 		if err := setupPublicBridge(br, vlan); err != nil {
@@ -362,7 +374,13 @@ func EnsurePublicSetup(nodeID pkg.Identifier, vlan *uint16, inf *pkg.PublicConfi
 		// we need to check if there is already a public config
 		// if yes! we need to make sure to delete it and also restart
 		// the node because that's the only way to properly unset public config
-		_ = DeletePublicConfig()
+		if inf != nil && inf.Uplink != nil {
+			// the uplink is kept since it's not part of the public config
+			_ = SavePublicConfig(pkg.PublicConfig{Uplink: inf.Uplink})
+		} else {
+			_ = DeletePublicConfig()
+		}
+
 		if HasPublicSetup() {
 			// full node reboot is needed unfortunately
 			// to many things depends on the public namespace
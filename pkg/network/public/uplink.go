@@ -0,0 +1,306 @@
+package public
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/bridge"
+	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
+	"github.com/threefoldtech/zos/pkg/network/options"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// publicBond is the bond of the uplink nics if more than one nic is used
+	publicBond = "bond-pub"
+	// defaultVlan is the default vlan of the bridge ports
+	defaultVlan = 1
+
+	uplinkVlanPrefix = "pubv"
+)
+
+// uplinkVlanName is the name of the vlan link of the uplink with the given tag
+func uplinkVlanName(vlan uint16) string {
+	return fmt.Sprintf("%s%d", uplinkVlanPrefix, vlan)
+}
+
+// uplinkPort is a link that is attached to the public bridge as part of
+// the uplink
+type uplinkPort struct {
+	// Vlan is the vlan tag of the port on the uplink, nil means untagged
+	Vlan *uint16
+	// Pvid is the vlan of the port inside the public bridge
+	Pvid uint16
+}
+
+// uplinkPorts returns the ports that need to be attached to the public
+// bridge for the given uplink. The main public traffic is always on the
+// default bridge vlan, each pool gets its own bridge vlan that is the same
+// as the pool vlan tag.
+func uplinkPorts(uplink *pkg.PublicUplink) []uplinkPort {
+	ports := []uplinkPort{{Vlan: uplink.Vlan, Pvid: defaultVlan}}
+	for _, pool := range uplink.Pools {
+		vlan := pool.Vlan
+		ports = append(ports, uplinkPort{Vlan: &vlan, Pvid: vlan})
+	}
+
+	return ports
+}
+
+// ensureUplinkVlan makes sure the vlan link with given tag exists on exit
+func ensureUplinkVlan(exit netlink.Link, vlan uint16) (netlink.Link, error) {
+	name := uplinkVlanName(vlan)
+	link, err := netlink.LinkByName(name)
+	if err == nil {
+		if link.Attrs().ParentIndex != exit.Attrs().Index {
+			// the uplink nics changed, recreate it
+			if err := netlink.LinkDel(link); err != nil {
+				return nil, errors.Wrapf(err, "failed to delete vlan link '%s'", name)
+			}
+		} else {
+			return link, nil
+		}
+	} else if _, ok := err.(netlink.LinkNotFoundError); !ok {
+		return nil, errors.Wrapf(err, "failed to get vlan link '%s'", name)
+	}
+
+	link = &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        name,
+			ParentIndex: exit.Attrs().Index,
+		},
+		VlanId: int(vlan),
+	}
+
+	if err := netlink.LinkAdd(link); err != nil {
+		return nil, errors.Wrapf(err, "failed to create vlan link '%s'", name)
+	}
+
+	return netlink.LinkByName(name)
+}
+
+// uplinkExit returns the link the uplink ports are created on. It's the nic
+// itself if the uplink has a single nic, otherwise the public bond
+func uplinkExit(uplink *pkg.PublicUplink) (netlink.Link, error) {
+	nics := make([]netlink.Link, 0, len(uplink.NICs))
+	for _, name := range uplink.NICs {
+		nic, err := netlink.LinkByName(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get nic '%s'", name)
+		}
+
+		if nic.Type() != "device" {
+			return nil, fmt.Errorf("uplink '%s' must be a physical nic", name)
+		}
+
+		nics = append(nics, nic)
+	}
+
+	if len(nics) == 1 {
+		return nics[0], nil
+	}
+
+	return ifaceutil.EnsureBond(publicBond, nics)
+}
+
+// attachUplinkPort attaches an uplink port to the public bridge
+func attachUplinkPort(br *netlink.Bridge, link netlink.Link, pvid uint16) error {
+	if err := netlink.LinkSetUp(link); err != nil {
+		return errors.Wrapf(err, "failed to set link '%s' up", link.Attrs().Name)
+	}
+
+	if err := options.Set(link.Attrs().Name, options.IPv6Disable(true)); err != nil {
+		return errors.Wrap(err, "failed to disable ipv6 on uplink")
+	}
+
+	if link.Attrs().MasterIndex == br.Index {
+		// already attached
+		return nil
+	}
+
+	if err := netlink.LinkSetMaster(link, br); err != nil {
+		return errors.Wrapf(err, "failed to attach link '%s' to public bridge", link.Attrs().Name)
+	}
+
+	if pvid == defaultVlan {
+		return nil
+	}
+
+	return setPortVlan(link, pvid)
+}
+
+// setPortVlan moves a public bridge port from the default vlan to the given vlan
+func setPortVlan(link netlink.Link, vlan uint16) error {
+	if err := netlink.BridgeVlanDel(link, defaultVlan, true, true, false, false); err != nil {
+		return errors.Wrapf(err, "failed to delete default vlan tag on device '%s'", link.Attrs().Name)
+	}
+
+	if err := netlink.BridgeVlanAdd(link, vlan, true, true, false, false); err != nil {
+		return errors.Wrapf(err, "failed to set vlan on device '%s'", link.Attrs().Name)
+	}
+
+	return nil
+}
+
+// detachUplinks disconnects the public bridge from all its current uplinks
+func detachUplinks(br *netlink.Bridge) error {
+	links, err := bridge.ListNics(br, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to list public bridge links")
+	}
+
+	for _, link := range links {
+		name := link.Attrs().Name
+		switch {
+		case link.Type() == "veth" && name == toZosVeth,
+			link.Type() == "vlan" && strings.HasPrefix(name, uplinkVlanPrefix):
+			// veth to zos bridge, or a vlan link of the uplink
+			if err := netlink.LinkDel(link); err != nil {
+				return errors.Wrapf(err, "failed to delete link '%s'", name)
+			}
+		case link.Type() == "device", link.Type() == "bond":
+			if err := netlink.LinkSetNoMaster(link); err != nil {
+				return errors.Wrapf(err, "failed to detach link '%s'", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// releaseBond deletes the public bond if it's not used anymore
+func releaseBond() {
+	link, err := netlink.LinkByName(publicBond)
+	if err != nil {
+		return
+	}
+
+	if err := netlink.LinkDel(link); err != nil {
+		log.Error().Err(err).Msg("failed to delete public bond")
+	}
+}
+
+// applyUplink wires the public bridge to the uplink
+func applyUplink(br *netlink.Bridge, uplink *pkg.PublicUplink) error {
+	exit, err := uplinkExit(uplink)
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.LinkSetUp(exit); err != nil {
+		return errors.Wrapf(err, "failed to set link '%s' up", exit.Attrs().Name)
+	}
+
+	for _, port := range uplinkPorts(uplink) {
+		link := exit
+		if port.Vlan != nil {
+			link, err = ensureUplinkVlan(exit, *port.Vlan)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := attachUplinkPort(br, link, port.Pvid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetPublicUplink rewires the public bridge to the given uplink. All
+// current uplinks of the public bridge are detached first
+func SetPublicUplink(uplink pkg.PublicUplink) error {
+	if err := uplink.Valid(); err != nil {
+		return err
+	}
+
+	for _, name := range uplink.NICs {
+		nic, err := netlink.LinkByName(name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get nic '%s'", name)
+		}
+
+		// the nic can only be used by the public bridge already or the bond
+		if master := nic.Attrs().MasterIndex; master != 0 {
+			masterLink, err := netlink.LinkByIndex(master)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get master of nic '%s'", name)
+			}
+
+			if name := masterLink.Attrs().Name; name != PublicBridge && name != publicBond {
+				return fmt.Errorf("nic '%s' is already used by '%s'", nic.Attrs().Name, name)
+			}
+		}
+	}
+
+	br, err := bridge.Get(PublicBridge)
+	if err != nil {
+		return err
+	}
+
+	if err := detachUplinks(br); err != nil {
+		return err
+	}
+
+	if len(uplink.NICs) == 1 {
+		releaseBond()
+	}
+
+	return applyUplink(br, &uplink)
+}
+
+// UnsetPublicUplink disconnects the public bridge from the uplink, the
+// public bridge needs to be wired again with SetPublicExitLink
+func UnsetPublicUplink() error {
+	br, err := bridge.Get(PublicBridge)
+	if err != nil {
+		return err
+	}
+
+	if err := detachUplinks(br); err != nil {
+		return err
+	}
+
+	releaseBond()
+	return nil
+}
+
+// IPVlan returns the vlan of the public ip pool the ip is part of. It
+// returns 0 if the ip is not part of any pool
+func IPVlan(ip net.IP) (uint16, error) {
+	cfg, err := LoadPublicConfig()
+	if err == ErrNoPublicConfig {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if cfg.Uplink == nil {
+		return 0, nil
+	}
+
+	vlan, _ := cfg.Uplink.PoolVlan(ip)
+	return vlan, nil
+}
+
+// SetTapVlan tags a public tap with the vlan of the public ip pool the ip
+// is part of. It's a no-op if the ip is not part of any pool
+func SetTapVlan(tap string, ip net.IP) error {
+	vlan, err := IPVlan(ip)
+	if err != nil || vlan == 0 {
+		return err
+	}
+
+	link, err := netlink.LinkByName(tap)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get tap '%s'", tap)
+	}
+
+	log.Debug().Str("tap", tap).Uint16("vlan", vlan).Msg("set public tap vlan")
+	return setPortVlan(link, vlan)
+}
@@ -0,0 +1,50 @@
+package public
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
+func TestUplinkPorts(t *testing.T) {
+	require := require.New(t)
+
+	vlan := uint16(100)
+	uplink := pkg.PublicUplink{
+		NICs: []string{"eth1", "eth2"},
+		Vlan: &vlan,
+		Pools: []pkg.PublicIPPool{
+			{Subnet: gridtypes.MustParseIPNet("185.69.166.0/24"), Vlan: 200},
+		},
+	}
+	require.NoError(uplink.Valid())
+
+	ports := uplinkPorts(&uplink)
+	require.Len(ports, 2)
+	// main public traffic is on the default bridge vlan
+	require.Equal(uint16(100), *ports[0].Vlan)
+	require.Equal(uint16(defaultVlan), ports[0].Pvid)
+	require.Equal(uint16(200), *ports[1].Vlan)
+	require.Equal(uint16(200), ports[1].Pvid)
+
+	pool, ok := uplink.PoolVlan(net.ParseIP("185.69.166.10"))
+	require.True(ok)
+	require.Equal(uint16(200), pool)
+	_, ok = uplink.PoolVlan(net.ParseIP("185.69.167.10"))
+	require.False(ok)
+
+	// untagged uplink
+	ports = uplinkPorts(&pkg.PublicUplink{NICs: []string{"eth1"}})
+	require.Len(ports, 1)
+	require.Nil(ports[0].Vlan)
+
+	uplink.Pools[0].Vlan = vlan
+	require.Error(uplink.Valid())
+	require.Error((&pkg.PublicUplink{}).Valid())
+
+	uplink.Pools[0].Vlan = defaultVlan
+	require.Error(uplink.Valid(), "default vlan is reserved")
+}
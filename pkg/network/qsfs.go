@@ -4,58 +4,57 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
 	"github.com/threefoldtech/zos/pkg/network/namespace"
 	"github.com/threefoldtech/zos/pkg/network/nft"
+	"golang.org/x/sys/unix"
 )
 
-var _nft = `
-flush ruleset
-
-table inet filter {
-
-  chain base_checks {
-    # allow established/related connections
-    ct state {established, related} accept
-    # early drop of invalid connections
-    ct state invalid drop
-  }
-
-  chain input {
-    type filter hook input priority 0; policy drop;
-    jump base_checks
-    # port for prometheus
-    tcp dport 9100 iifname ygg0 accept
-    # accept only locally generated packets
-    meta iif lo ct state new accept
-    ip6 nexthdr icmpv6 accept
-  }
-
-  chain forward {
-    type filter hook forward priority 0; policy drop;
-    # is there already an existing stream? (outgoing)
-    jump base_checks
-  }
+func qsfsRuleset() nft.Table {
+	return nft.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   "filter",
+		Chains: []nft.Chain{
+			nft.RegularChain("base_checks",
+				// allow established/related connections
+				nft.NewRule("", nft.CtState(expr.CtStateBitESTABLISHED|expr.CtStateBitRELATED), nft.Accept()),
+				// early drop of invalid connections
+				nft.NewRule("", nft.CtState(expr.CtStateBitINVALID), nft.Drop()),
+			),
+			nft.BaseChain("input", nftables.ChainTypeFilter, nftables.ChainHookInput, 0, nftables.ChainPolicyDrop,
+				nft.NewRule("", nft.Jump("base_checks")),
+				// port for prometheus
+				nft.NewRule("", nft.TCPDPort(9100, 0), nft.IIfName("ygg0"), nft.Accept()),
+				// accept only locally generated packets
+				nft.NewRule("", nft.IIfLoopback(), nft.CtState(expr.CtStateBitNEW), nft.Accept()),
+				nft.NewRule("", nft.L4Proto(unix.IPPROTO_ICMPV6), nft.Accept()),
+			),
+			nft.BaseChain("forward", nftables.ChainTypeFilter, nftables.ChainHookForward, 0, nftables.ChainPolicyDrop,
+				// is there already an existing stream? (outgoing)
+				nft.NewRule("", nft.Jump("base_checks")),
+			),
+		},
+	}
 }
 
-`
-
 func applyQSFSFirewall(netns string) error {
-	if err := nft.Apply(strings.NewReader(_nft), netns); err != nil {
+	if err := nft.ApplyRuleset(netns, qsfsRuleset()); err != nil {
 		return errors.Wrap(err, "failed to apply nft rule set")
 	}
 
 	return nil
 }
 
-func (n networker) QSFSNamespace(id string) string {
+func (n *networker) QSFSNamespace(id string) string {
 	netId := "qsfs:" + id
 	hw := ifaceutil.HardwareAddrFromInputBytes([]byte(netId))
 	return qsfsNamespacePrefix + strings.Replace(hw.String(), ":", "", -1)
 }
-func (n networker) QSFSYggIP(id string) (string, error) {
+func (n *networker) QSFSYggIP(id string) (string, error) {
 	hw := ifaceutil.HardwareAddrFromInputBytes([]byte("ygg:" + id))
 
 	ip, err := n.ygg.SubnetFor(hw)
@@ -64,7 +63,7 @@ func (n networker) QSFSYggIP(id string) (string, error) {
 	}
 	return ip.IP.String(), nil
 }
-func (n networker) QSFSPrepare(id string) (string, string, error) {
+func (n *networker) QSFSPrepare(id string) (string, string, error) {
 	netId := "qsfs:" + id
 	netNSName := n.QSFSNamespace(id)
 	netNs, err := createNetNS(netNSName)
@@ -91,7 +90,7 @@ func (n networker) QSFSPrepare(id string) (string, string, error) {
 	return netNSName, ip.IP.String(), err
 }
 
-func (n networker) QSFSDestroy(id string) error {
+func (n *networker) QSFSDestroy(id string) error {
 	netId := "qsfs:" + id
 
 	netNSName := n.QSFSNamespace(id)
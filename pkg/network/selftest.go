@@ -0,0 +1,34 @@
+package network
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/nr"
+)
+
+// CheckNR implements pkg.Networker interface
+func (n *networker) CheckNR(wl gridtypes.WorkloadID) (zos.NetworkResult, error) {
+	twin, _, name, err := wl.Parts()
+	if err != nil {
+		return zos.NetworkResult{}, err
+	}
+
+	netID := zos.NetworkID(twin, name)
+	network, err := n.networkOf(netID)
+	if err != nil {
+		return zos.NetworkResult{}, errors.Wrapf(err, "couldn't load network with id (%s)", netID)
+	}
+
+	peers, err := nr.New(network, n.myceliumKeyDir).CheckPeers()
+	if err != nil {
+		return zos.NetworkResult{}, errors.Wrapf(err, "failed to check peers of network '%s'", netID)
+	}
+
+	return zos.NetworkResult{
+		Peers:   peers,
+		Checked: gridtypes.Timestamp(time.Now().Unix()),
+	}, nil
+}
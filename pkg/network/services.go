@@ -0,0 +1,166 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/nr"
+)
+
+const (
+	servicesDir      = "nr-services"
+	servicesHostsDir = "hosts.d"
+)
+
+// servicesDirOf returns the directory of the services of a network resource
+func (n *networker) servicesDirOf(netID zos.NetID) string {
+	return filepath.Join(n.servicesDir, netID.String())
+}
+
+func (n *networker) networkHostPath(netID zos.NetID, wl gridtypes.WorkloadID) string {
+	return filepath.Join(n.servicesDirOf(netID), servicesHostsDir, wl.String())
+}
+
+// networkHosts lists all the hosts registered in a network resource sorted
+// by workload id
+func (n *networker) networkHosts(netID zos.NetID) ([]pkg.NetworkHost, error) {
+	_, hosts, err := n.networkHostsOf(netID)
+	return hosts, err
+}
+
+// networkHostsOf lists all the hosts registered in a network resource with
+// their workload ids, sorted by workload id
+func (n *networker) networkHostsOf(netID zos.NetID) ([]gridtypes.WorkloadID, []pkg.NetworkHost, error) {
+	dir := filepath.Join(n.servicesDirOf(netID), servicesHostsDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	var ids []gridtypes.WorkloadID
+	var hosts []pkg.NetworkHost
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var host pkg.NetworkHost
+		if err := json.Unmarshal(data, &host); err != nil {
+			log.Error().Err(err).Str("host", entry.Name()).Msg("failed to load network host")
+			continue
+		}
+
+		ids = append(ids, gridtypes.WorkloadID(entry.Name()))
+		hosts = append(hosts, host)
+	}
+
+	return ids, hosts, nil
+}
+
+// checkHostName makes sure the dns name of the host is not used by
+// another workload in the same network
+func (n *networker) checkHostName(wl gridtypes.WorkloadID, host pkg.NetworkHost) error {
+	ids, hosts, err := n.networkHostsOf(host.NetID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list network hosts")
+	}
+
+	name := nr.HostName(host.Name)
+	for i, other := range hosts {
+		if ids[i] != wl && nr.HostName(other.Name) == name {
+			return fmt.Errorf("host name '%s' is already used by workload '%s' in the network", name, ids[i])
+		}
+	}
+
+	return nil
+}
+
+// setServices starts or updates the dhcp and dns services of a network
+// resource with all the hosts registered in the network
+func (n *networker) setServices(wl gridtypes.WorkloadID, netr *nr.NetResource, netID zos.NetID) error {
+	_, _, name, err := wl.Parts()
+	if err != nil {
+		return err
+	}
+
+	hosts, err := n.networkHosts(netID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list network hosts")
+	}
+
+	return netr.SetServices(n.servicesDirOf(netID), nr.ServicesDomain(string(name)), hosts)
+}
+
+// reloadServices updates the hosts of the network resource services if the
+// network resource exists
+func (n *networker) reloadServices(netID zos.NetID) error {
+	network, err := n.networkOf(netID)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	hosts, err := n.networkHosts(netID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list network hosts")
+	}
+
+	return nr.New(network, n.myceliumKeyDir).ReloadServices(n.servicesDirOf(netID), hosts)
+}
+
+// SetNetworkHost implements pkg.Networker interface
+func (n *networker) SetNetworkHost(wl gridtypes.WorkloadID, host pkg.NetworkHost) error {
+	n.servicesLock.Lock()
+	defer n.servicesLock.Unlock()
+
+	if err := n.checkHostName(wl, host); err != nil {
+		return err
+	}
+
+	path := n.networkHostPath(host.NetID, wl)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to store network host")
+	}
+
+	return n.reloadServices(host.NetID)
+}
+
+// RemoveNetworkHost implements pkg.Networker interface
+func (n *networker) RemoveNetworkHost(wl gridtypes.WorkloadID, netID zos.NetID) error {
+	n.servicesLock.Lock()
+	defer n.servicesLock.Unlock()
+
+	err := os.Remove(n.networkHostPath(netID, wl))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to delete network host")
+	}
+
+	return n.reloadServices(netID)
+}
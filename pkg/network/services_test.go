@@ -0,0 +1,35 @@
+package network
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+)
+
+func TestCheckHostName(t *testing.T) {
+	require := require.New(t)
+
+	n := &networker{servicesDir: t.TempDir()}
+	netID := zos.NetID("net")
+
+	existing := gridtypes.WorkloadID("1-10-web_1")
+	data, err := json.Marshal(pkg.NetworkHost{NetID: netID, Name: "web_1"})
+	require.NoError(err)
+	path := n.networkHostPath(netID, existing)
+	require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(os.WriteFile(path, data, 0644))
+
+	// the same workload can update its host
+	require.NoError(n.checkHostName(existing, pkg.NetworkHost{NetID: netID, Name: "web_1"}))
+	// another deployment with a vm of the same dns name is rejected
+	require.Error(n.checkHostName("1-11-WEB-1", pkg.NetworkHost{NetID: netID, Name: "WEB-1"}))
+	require.NoError(n.checkHostName("1-11-web2", pkg.NetworkHost{NetID: netID, Name: "web2"}))
+	// names are only unique per network
+	require.NoError(n.checkHostName("1-11-web_1", pkg.NetworkHost{NetID: "other", Name: "web_1"}))
+}
@@ -0,0 +1,667 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/google/nftables"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"github.com/threefoldtech/zos/pkg/network/public"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	snatDir   = "snat"
+	snatTable = "snat"
+	snatChain = "postrouting"
+
+	// snatIface is the ndmz interface the snat traffic leaves from. It is the
+	// ndmz leg on the public bridge, so the pool gateways are reached over
+	// the public uplink and not over the management (zos) network
+	snatIface = "npub6"
+	// snatAddrLabel marks the snat addresses set on the snat interface
+	snatAddrLabel = snatIface + ":snat"
+	// snatLegacyIface is the interface the snat addresses used to be set on
+	snatLegacyIface = "npub4"
+	// snatRoutingTable is the routing table of the first snat pool, each pool
+	// has its own table with a default route over the pool gateway
+	snatRoutingTable = 1000
+	snatMaxTables    = 256
+	// snatRulePriority is the priority of the routing rules of the network
+	// resources that use a snat address
+	snatRulePriority = 500
+)
+
+// snatAssignment is the stored snat address of a twin
+type snatAssignment struct {
+	IP gridtypes.IPNet `json:"ip"`
+}
+
+// snatSource is a network resource that uses a snat address
+type snatSource struct {
+	// IP is the address of the network resource in the ndmz
+	IP   net.IP
+	Pool int
+}
+
+func snatTableOf(rules ...nft.Rule) nft.Table {
+	return nft.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   snatTable,
+		Chains: []nft.Chain{
+			// runs before the ndmz masquerade so the snat address is used
+			nft.BaseChain(snatChain, nftables.ChainTypeNAT, nftables.ChainHookPostrouting, 50, nftables.ChainPolicyAccept, rules...),
+		},
+	}
+}
+
+func (n *networker) snatPath(twin uint32) string {
+	return filepath.Join(n.snatDir, fmt.Sprint(twin))
+}
+
+func (n *networker) listSNAT() (map[uint32]gridtypes.IPNet, error) {
+	entries, err := os.ReadDir(n.snatDir)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[uint32]gridtypes.IPNet)
+	for _, entry := range entries {
+		twin, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(n.snatDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var assignment snatAssignment
+		if err := json.Unmarshal(data, &assignment); err != nil {
+			log.Error().Err(err).Uint64("twin", twin).Msg("failed to load snat assignment")
+			continue
+		}
+
+		assigned[uint32(twin)] = assignment.IP
+	}
+
+	return assigned, nil
+}
+
+func (n *networker) storeSNAT(twin uint32, ip gridtypes.IPNet) error {
+	data, err := json.Marshal(snatAssignment{IP: ip})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(n.snatPath(twin), data, 0644)
+}
+
+// twinNetworks returns the network resources of each twin
+func (n *networker) twinNetworks() (map[uint32][]zos.NetID, error) {
+	links, err := os.ReadDir(n.linkDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list networks")
+	}
+
+	networks := make(map[uint32][]zos.NetID)
+	seen := make(map[zos.NetID]struct{})
+	for _, link := range links {
+		if link.IsDir() {
+			continue
+		}
+
+		wl := gridtypes.WorkloadID(link.Name())
+		twin, _, _, err := wl.Parts()
+		if err != nil {
+			continue
+		}
+
+		sym, err := os.Readlink(filepath.Join(n.linkDir, link.Name()))
+		if err != nil {
+			log.Error().Err(err).Stringer("workload", wl).Msg("failed to get network name from workload link")
+			continue
+		}
+
+		netID := zos.NetID(filepath.Base(sym))
+		if _, ok := seen[netID]; ok {
+			continue
+		}
+		seen[netID] = struct{}{}
+		networks[twin] = append(networks[twin], netID)
+	}
+
+	return networks, nil
+}
+
+// snatPool returns the index of the pool of the ip, or -1 if the ip is not
+// in any of the pools
+func snatPool(pools []pkg.SNATPool, ip gridtypes.IPNet) int {
+	for i, pool := range pools {
+		for _, poolIP := range pool.IPs {
+			if poolIP.IP.Equal(ip.IP) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// assignSNAT returns the snat address of each of the twins. Current
+// assignments are kept if the address is still in a pool of the twin, other
+// twins get the first free address of the pools reserved for them then of
+// the shared pools. Twins are not assigned an address if all addresses are
+// used, their traffic uses the node address.
+func assignSNAT(pools []pkg.SNATPool, twins []uint32, current map[uint32]gridtypes.IPNet) map[uint32]gridtypes.IPNet {
+	assigned := make(map[uint32]gridtypes.IPNet)
+	used := make(map[string]struct{})
+	for _, twin := range twins {
+		ip, ok := current[twin]
+		if !ok {
+			continue
+		}
+
+		pool := snatPool(pools, ip)
+		if pool < 0 || !pools[pool].Allows(twin) {
+			continue
+		}
+
+		if _, ok := used[ip.IP.String()]; ok {
+			continue
+		}
+
+		// the prefix of the address is taken from the pool in case it changed
+		for _, poolIP := range pools[pool].IPs {
+			if poolIP.IP.Equal(ip.IP) {
+				ip = poolIP
+			}
+		}
+
+		assigned[twin] = ip
+		used[ip.IP.String()] = struct{}{}
+	}
+
+	for _, twin := range twins {
+		if _, ok := assigned[twin]; ok {
+			continue
+		}
+
+		// reserved pools first
+		candidates := make([]pkg.SNATPool, 0, len(pools))
+		for _, pool := range pools {
+			if len(pool.Twins) != 0 && pool.Allows(twin) {
+				candidates = append(candidates, pool)
+			}
+		}
+		for _, pool := range pools {
+			if len(pool.Twins) == 0 {
+				candidates = append(candidates, pool)
+			}
+		}
+
+	next:
+		for _, pool := range candidates {
+			for _, ip := range pool.IPs {
+				if _, ok := used[ip.IP.String()]; ok {
+					continue
+				}
+
+				assigned[twin] = ip
+				used[ip.IP.String()] = struct{}{}
+				break next
+			}
+		}
+	}
+
+	return assigned
+}
+
+// applySNAT assigns a snat address to each twin with network resources and
+// applies the snat rules and routing of their network resources in the ndmz.
+// Addresses of twins with no network resources left are released.
+func (n *networker) applySNAT() error {
+	n.snatLock.Lock()
+	defer n.snatLock.Unlock()
+
+	var pools []pkg.SNATPool
+	cfg, err := public.LoadPublicConfig()
+	if err == nil {
+		pools = cfg.SNATPools
+	} else if err != public.ErrNoPublicConfig {
+		return errors.Wrap(err, "failed to load public config")
+	}
+
+	networks, err := n.twinNetworks()
+	if err != nil {
+		return err
+	}
+
+	current, err := n.listSNAT()
+	if err != nil {
+		return errors.Wrap(err, "failed to list snat assignments")
+	}
+
+	twins := make([]uint32, 0, len(networks))
+	for twin := range networks {
+		twins = append(twins, twin)
+	}
+	sort.Slice(twins, func(i, j int) bool { return twins[i] < twins[j] })
+
+	assigned := assignSNAT(pools, twins, current)
+
+	for twin, ip := range current {
+		if _, ok := assigned[twin]; ok {
+			continue
+		}
+
+		log.Info().Uint32("twin", twin).Str("ip", ip.String()).Msg("releasing snat address")
+		if err := os.Remove(n.snatPath(twin)); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Uint32("twin", twin).Msg("failed to release snat address")
+		}
+	}
+
+	var rules []nft.Rule
+	var sources []snatSource
+	for twin, ip := range assigned {
+		if cur, ok := current[twin]; !ok || cur.String() != ip.String() {
+			log.Info().Uint32("twin", twin).Str("ip", ip.String()).Msg("assigning snat address")
+			if err := n.storeSNAT(twin, ip); err != nil {
+				return errors.Wrapf(err, "failed to store snat address of twin '%d'", twin)
+			}
+		}
+
+		for _, netID := range networks[twin] {
+			if !namespace.Exists(n.Namespace(netID)) {
+				continue
+			}
+
+			nrIP, err := n.nrPublicIPv4(netID)
+			if err != nil {
+				log.Error().Err(err).Str("network", string(netID)).Msg("failed to get snat network resource ip")
+				continue
+			}
+
+			rules = append(rules, nft.NewRule(fmt.Sprintf("snat %s", netID), nft.SAddr(nrIP), nft.OIfName(snatIface), nft.Counter(), nft.SNat(ip.IP)))
+			sources = append(sources, snatSource{IP: nrIP, Pool: snatPool(pools, ip)})
+		}
+	}
+
+	if err := n.setSNATRouting(pools, assigned, sources); err != nil {
+		return errors.Wrap(err, "failed to set snat routing")
+	}
+
+	sortRules(rules)
+	if err := nft.ApplyChains(n.ndmz.Namespace(), snatTableOf(rules...)); err != nil {
+		return errors.Wrap(err, "failed to apply snat rules")
+	}
+
+	return nil
+}
+
+// setSNATRouting sets the snat addresses on the ndmz public bridge interface and
+// routes the traffic of the network resources that use a snat address over
+// the gateway of its pool
+func (n *networker) setSNATRouting(pools []pkg.SNATPool, assigned map[uint32]gridtypes.IPNet, sources []snatSource) error {
+	netNS, err := namespace.GetByName(n.ndmz.Namespace())
+	if err != nil {
+		return err
+	}
+	defer netNS.Close()
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(snatIface)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get interface '%s'", snatIface)
+		}
+
+		if err := setSNATAddresses(link, assigned); err != nil {
+			return err
+		}
+
+		if err := cleanLegacySNATAddresses(); err != nil {
+			log.Error().Err(err).Msg("failed to clean snat addresses of the management interface")
+		}
+
+		tables := make(map[int]struct{})
+		for _, source := range sources {
+			tables[snatRoutingTable+source.Pool] = struct{}{}
+		}
+
+		if err := setSNATRoutes(link, pools, tables); err != nil {
+			return err
+		}
+
+		return setSNATRules(sources)
+	})
+}
+
+// snatAddrChanges returns the snat addresses that need to be added to and
+// removed from the snat interface given its current addresses
+func snatAddrChanges(current []netlink.Addr, assigned map[uint32]gridtypes.IPNet) (add, del []netlink.Addr) {
+	wanted := make(map[string]gridtypes.IPNet)
+	for _, ip := range assigned {
+		wanted[ip.String()] = ip
+	}
+
+	for _, addr := range current {
+		if _, ok := wanted[addr.IPNet.String()]; ok {
+			delete(wanted, addr.IPNet.String())
+			continue
+		}
+
+		if addr.Label != snatAddrLabel {
+			continue
+		}
+
+		del = append(del, addr)
+	}
+
+	for _, ip := range wanted {
+		ipNet := ip.IPNet
+		add = append(add, netlink.Addr{IPNet: &ipNet, Label: snatAddrLabel})
+	}
+
+	sort.Slice(add, func(i, j int) bool { return add[i].IPNet.String() < add[j].IPNet.String() })
+
+	return add, del
+}
+
+// snatRouteChanges returns the default routes of the snat tables that need
+// to be set, and the routes of the unused snat tables that need to be removed
+func snatRouteChanges(current []netlink.Route, link int, pools []pkg.SNATPool, tables map[int]struct{}) (set, del []netlink.Route) {
+	for _, route := range current {
+		if route.Table < snatRoutingTable || route.Table >= snatRoutingTable+snatMaxTables {
+			continue
+		}
+
+		if _, ok := tables[route.Table]; ok {
+			continue
+		}
+
+		del = append(del, route)
+	}
+
+	for table := range tables {
+		pool := table - snatRoutingTable
+		if pool < 0 || pool >= len(pools) || pool >= snatMaxTables {
+			continue
+		}
+
+		set = append(set, netlink.Route{
+			LinkIndex: link,
+			Table:     table,
+			Gw:        pools[pool].Gateway,
+			Flags:     int(netlink.FLAG_ONLINK),
+		})
+	}
+
+	sort.Slice(set, func(i, j int) bool { return set[i].Table < set[j].Table })
+
+	return set, del
+}
+
+// snatRuleChanges returns the routing rules that need to be added and
+// removed so the traffic of each source uses the table of its pool
+func snatRuleChanges(current []netlink.Rule, sources []snatSource) (add, del []*netlink.Rule) {
+	wanted := make(map[string]*netlink.Rule)
+	for _, source := range sources {
+		if source.Pool < 0 || source.Pool >= snatMaxTables {
+			continue
+		}
+
+		rule := netlink.NewRule()
+		rule.Priority = snatRulePriority
+		rule.Table = snatRoutingTable + source.Pool
+		rule.Src = &net.IPNet{IP: source.IP.To4(), Mask: net.CIDRMask(32, 32)}
+		wanted[fmt.Sprintf("%s-%d", rule.Src, rule.Table)] = rule
+	}
+
+	if len(wanted) != 0 {
+		// routes of the main table other than the default route (like the
+		// network resources and the local subnets) are still used
+		rule := netlink.NewRule()
+		rule.Priority = snatRulePriority - 1
+		rule.Table = unix.RT_TABLE_MAIN
+		rule.SuppressPrefixlen = 0
+		wanted["main"] = rule
+	}
+
+	for i := range current {
+		rule := &current[i]
+		if rule.Priority != snatRulePriority && rule.Priority != snatRulePriority-1 {
+			continue
+		}
+
+		key := "main"
+		if rule.Priority == snatRulePriority {
+			key = fmt.Sprintf("%s-%d", rule.Src, rule.Table)
+		}
+
+		if _, ok := wanted[key]; ok {
+			delete(wanted, key)
+			continue
+		}
+
+		del = append(del, rule)
+	}
+
+	for _, rule := range wanted {
+		add = append(add, rule)
+	}
+
+	sort.Slice(add, func(i, j int) bool {
+		if add[i].Priority != add[j].Priority {
+			return add[i].Priority < add[j].Priority
+		}
+		return add[i].Src.String() < add[j].Src.String()
+	})
+
+	return add, del
+}
+
+func setSNATAddresses(link netlink.Link, assigned map[uint32]gridtypes.IPNet) error {
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return errors.Wrap(err, "failed to list addresses")
+	}
+
+	add, del := snatAddrChanges(addrs, assigned)
+	for i := range del {
+		if err := netlink.AddrDel(link, &del[i]); err != nil {
+			return errors.Wrapf(err, "failed to remove snat address '%s'", del[i].IPNet.String())
+		}
+	}
+
+	for i := range add {
+		if err := netlink.AddrAdd(link, &add[i]); err != nil {
+			return errors.Wrapf(err, "failed to add snat address '%s'", add[i].IPNet.String())
+		}
+	}
+
+	return nil
+}
+
+// cleanLegacySNATAddresses removes the snat addresses that were set on the
+// management interface of the ndmz by older versions
+func cleanLegacySNATAddresses() error {
+	link, err := netlink.LinkByName(snatLegacyIface)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get interface '%s'", snatLegacyIface)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return errors.Wrap(err, "failed to list addresses")
+	}
+
+	for i := range addrs {
+		if addrs[i].Label != snatLegacyIface+":snat" {
+			continue
+		}
+
+		if err := netlink.AddrDel(link, &addrs[i]); err != nil {
+			return errors.Wrapf(err, "failed to remove snat address '%s'", addrs[i].IPNet.String())
+		}
+	}
+
+	return nil
+}
+
+func setSNATRoutes(link netlink.Link, pools []pkg.SNATPool, tables map[int]struct{}) error {
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: unix.RT_TABLE_UNSPEC}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return errors.Wrap(err, "failed to list routes")
+	}
+
+	set, del := snatRouteChanges(routes, link.Attrs().Index, pools, tables)
+	for i := range del {
+		if err := netlink.RouteDel(&del[i]); err != nil {
+			return errors.Wrapf(err, "failed to remove snat route of table '%d'", del[i].Table)
+		}
+	}
+
+	for i := range set {
+		if err := netlink.RouteReplace(&set[i]); err != nil {
+			return errors.Wrapf(err, "failed to set snat route of table '%d'", set[i].Table)
+		}
+	}
+
+	return nil
+}
+
+func setSNATRules(sources []snatSource) error {
+	rules, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		return errors.Wrap(err, "failed to list routing rules")
+	}
+
+	add, del := snatRuleChanges(rules, sources)
+	for _, rule := range del {
+		if err := netlink.RuleDel(rule); err != nil {
+			return errors.Wrap(err, "failed to remove snat routing rule")
+		}
+	}
+
+	for _, rule := range add {
+		if err := netlink.RuleAdd(rule); err != nil {
+			return errors.Wrapf(err, "failed to add snat routing rule of '%s'", rule.Src)
+		}
+	}
+
+	return nil
+}
+
+// validSNATPools checks the pools and makes sure their addresses don't
+// overlap with each other or with the node public ipv4
+func validSNATPools(pools []pkg.SNATPool, cfg *pkg.PublicConfig) error {
+	seen := make(map[string]struct{})
+	for i := range pools {
+		if err := pools[i].Valid(); err != nil {
+			return err
+		}
+
+		for _, ip := range pools[i].IPs {
+			if _, ok := seen[ip.IP.String()]; ok {
+				return fmt.Errorf("snat ip '%s' is set more than once", ip.IP)
+			}
+			seen[ip.IP.String()] = struct{}{}
+
+			if !cfg.IPv4.Nil() && cfg.IPv4.IP.Equal(ip.IP) {
+				return fmt.Errorf("snat ip '%s' is the node public ipv4", ip.IP)
+			}
+		}
+	}
+
+	if len(pools) > snatMaxTables {
+		return fmt.Errorf("too many snat pools, max is %d", snatMaxTables)
+	}
+
+	return nil
+}
+
+// SetSNATPools implements pkg.Networker interface
+func (n *networker) SetSNATPools(pools []pkg.SNATPool) error {
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig || (err == nil && current.IsEmpty()) {
+		return fmt.Errorf("snat pools require a public config")
+	} else if err != nil {
+		return errors.Wrap(err, "failed to load current public configuration")
+	}
+
+	if err := validSNATPools(pools, current); err != nil {
+		return err
+	}
+
+	current.SNATPools = pools
+	if len(pools) == 0 {
+		current.SNATPools = nil
+	}
+
+	if err := public.SavePublicConfig(*current); err != nil {
+		return errors.Wrap(err, "failed to store public config")
+	}
+
+	return n.applySNAT()
+}
+
+// GetSNATPools implements pkg.Networker interface
+func (n *networker) GetSNATPools() ([]pkg.SNATPool, error) {
+	current, err := public.LoadPublicConfig()
+	if err == public.ErrNoPublicConfig {
+		return []pkg.SNATPool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if current.SNATPools == nil {
+		return []pkg.SNATPool{}, nil
+	}
+
+	return current.SNATPools, nil
+}
+
+// SNATAssignments implements pkg.Networker interface
+func (n *networker) SNATAssignments() ([]pkg.SNATAssignment, error) {
+	n.snatLock.Lock()
+	defer n.snatLock.Unlock()
+
+	assigned, err := n.listSNAT()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list snat assignments")
+	}
+
+	networks, err := n.twinNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]pkg.SNATAssignment, 0, len(assigned))
+	for twin, ip := range assigned {
+		assignments = append(assignments, pkg.SNATAssignment{
+			Twin:     twin,
+			IP:       ip,
+			Networks: networks[twin],
+		})
+	}
+
+	sort.Slice(assignments, func(i, j int) bool {
+		return assignments[i].Twin < assignments[j].Twin
+	})
+
+	return assignments, nil
+}
@@ -0,0 +1,206 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestAssignSNAT(t *testing.T) {
+	require := require.New(t)
+
+	ips := func(values ...string) []gridtypes.IPNet {
+		var result []gridtypes.IPNet
+		for _, value := range values {
+			ip, err := gridtypes.ParseIPNet(value)
+			require.NoError(err)
+			result = append(result, ip)
+		}
+		return result
+	}
+
+	pools := []pkg.SNATPool{
+		{IPs: ips("185.69.166.10/24", "185.69.166.11/24"), Gateway: net.ParseIP("185.69.166.1")},
+		{IPs: ips("185.69.167.10/24"), Gateway: net.ParseIP("185.69.167.1"), Twins: []uint32{3}},
+	}
+
+	// twin 3 gets its reserved pool, others share the first pool
+	assigned := assignSNAT(pools, []uint32{1, 2, 3}, nil)
+	require.Equal("185.69.166.10/24", assigned[1].String())
+	require.Equal("185.69.166.11/24", assigned[2].String())
+	require.Equal("185.69.167.10/24", assigned[3].String())
+
+	// no free addresses left for twin 4
+	assigned = assignSNAT(pools, []uint32{1, 2, 3, 4}, assigned)
+	require.Len(assigned, 3)
+	require.NotContains(assigned, uint32(4))
+
+	// twin 1 is gone, its address goes to twin 4 while others keep theirs
+	current := map[uint32]gridtypes.IPNet{
+		2: assigned[2],
+		3: assigned[3],
+	}
+	assigned = assignSNAT(pools, []uint32{2, 3, 4}, current)
+	require.Equal("185.69.166.11/24", assigned[2].String())
+	require.Equal("185.69.167.10/24", assigned[3].String())
+	require.Equal("185.69.166.10/24", assigned[4].String())
+
+	// addresses removed from the pools are reassigned
+	assigned = assignSNAT(pools[:1], []uint32{3}, current)
+	require.Equal("185.69.166.10/24", assigned[3].String())
+}
+
+func TestSNATPoolValid(t *testing.T) {
+	require := require.New(t)
+
+	ip, err := gridtypes.ParseIPNet("185.69.166.10/24")
+	require.NoError(err)
+
+	pool := pkg.SNATPool{IPs: []gridtypes.IPNet{ip}, Gateway: net.ParseIP("185.69.166.1")}
+	require.NoError(pool.Valid())
+
+	pool.Gateway = net.ParseIP("185.69.167.1")
+	require.Error(pool.Valid())
+
+	pool.Gateway = net.ParseIP("2001:db8::1")
+	require.Error(pool.Valid())
+
+	pool = pkg.SNATPool{Gateway: net.ParseIP("185.69.166.1")}
+	require.Error(pool.Valid())
+
+	gw, err := gridtypes.ParseIPNet("185.69.166.1/24")
+	require.NoError(err)
+	pool = pkg.SNATPool{IPs: []gridtypes.IPNet{ip, gw}, Gateway: net.ParseIP("185.69.166.1")}
+	require.Error(pool.Valid(), "pool ip is the gateway")
+}
+
+func TestValidSNATPools(t *testing.T) {
+	require := require.New(t)
+
+	ip, err := gridtypes.ParseIPNet("185.69.166.10/24")
+	require.NoError(err)
+	pool := pkg.SNATPool{IPs: []gridtypes.IPNet{ip}, Gateway: net.ParseIP("185.69.166.1")}
+	require.True(pool.Has(net.ParseIP("185.69.166.10")))
+	require.False(pool.Has(net.ParseIP("185.69.166.11")))
+
+	cfg := pkg.PublicConfig{IPv4: gridtypes.MustParseIPNet("185.69.166.20/24")}
+	require.NoError(validSNATPools([]pkg.SNATPool{pool}, &cfg))
+	require.Error(validSNATPools([]pkg.SNATPool{pool, pool}, &cfg), "duplicate ip")
+
+	cfg.IPv4 = ip
+	require.Error(validSNATPools([]pkg.SNATPool{pool}, &cfg), "node public ip")
+}
+
+func TestSNATAddrChanges(t *testing.T) {
+	require := require.New(t)
+
+	addr := func(value, label string) netlink.Addr {
+		ip := gridtypes.MustParseIPNet(value)
+		return netlink.Addr{IPNet: &ip.IPNet, Label: label}
+	}
+
+	current := []netlink.Addr{
+		addr("192.168.1.20/24", snatIface),
+		addr("185.69.166.10/24", snatAddrLabel),
+		addr("185.69.166.11/24", snatAddrLabel),
+	}
+
+	assigned := map[uint32]gridtypes.IPNet{
+		1: gridtypes.MustParseIPNet("185.69.166.10/24"),
+		2: gridtypes.MustParseIPNet("185.69.166.12/24"),
+	}
+
+	add, del := snatAddrChanges(current, assigned)
+	require.Len(add, 1)
+	require.Equal("185.69.166.12/24", add[0].IPNet.String())
+	require.Equal(snatAddrLabel, add[0].Label)
+
+	// the interface own address is never removed
+	require.Len(del, 1)
+	require.Equal("185.69.166.11/24", del[0].IPNet.String())
+
+	add, del = snatAddrChanges(current, nil)
+	require.Empty(add)
+	require.Len(del, 2)
+}
+
+func TestSNATRouteChanges(t *testing.T) {
+	require := require.New(t)
+
+	pools := []pkg.SNATPool{
+		{IPs: []gridtypes.IPNet{gridtypes.MustParseIPNet("185.69.166.10/24")}, Gateway: net.ParseIP("185.69.166.1")},
+		{IPs: []gridtypes.IPNet{gridtypes.MustParseIPNet("185.69.167.10/24")}, Gateway: net.ParseIP("185.69.167.1")},
+	}
+
+	current := []netlink.Route{
+		{Table: unix.RT_TABLE_MAIN, Gw: net.ParseIP("192.168.1.1")},
+		{Table: snatRoutingTable, Gw: net.ParseIP("185.69.166.1")},
+		{Table: snatRoutingTable + 1, Gw: net.ParseIP("185.69.167.1")},
+	}
+
+	tables := map[int]struct{}{
+		snatRoutingTable + 1: {},
+		// pool was removed
+		snatRoutingTable + 2: {},
+	}
+
+	set, del := snatRouteChanges(current, 10, pools, tables)
+	require.Len(set, 1)
+	require.Equal(10, set[0].LinkIndex)
+	require.Equal(snatRoutingTable+1, set[0].Table)
+	require.True(set[0].Gw.Equal(net.ParseIP("185.69.167.1")))
+	require.Equal(int(netlink.FLAG_ONLINK), set[0].Flags)
+	require.Nil(set[0].Dst, "default route")
+
+	// only the unused snat table is cleaned
+	require.Len(del, 1)
+	require.Equal(snatRoutingTable, del[0].Table)
+}
+
+func TestSNATRuleChanges(t *testing.T) {
+	require := require.New(t)
+
+	sources := []snatSource{
+		{IP: net.ParseIP("100.127.0.2"), Pool: 0},
+		{IP: net.ParseIP("100.127.0.3"), Pool: 1},
+		// not in a pool
+		{IP: net.ParseIP("100.127.0.4"), Pool: -1},
+	}
+
+	add, del := snatRuleChanges(nil, sources)
+	require.Empty(del)
+	require.Len(add, 3)
+
+	require.Equal(snatRulePriority-1, add[0].Priority)
+	require.Equal(unix.RT_TABLE_MAIN, add[0].Table)
+	require.Equal(0, add[0].SuppressPrefixlen)
+
+	require.Equal(snatRulePriority, add[1].Priority)
+	require.Equal("100.127.0.2/32", add[1].Src.String())
+	require.Equal(snatRoutingTable, add[1].Table)
+
+	require.Equal("100.127.0.3/32", add[2].Src.String())
+	require.Equal(snatRoutingTable+1, add[2].Table)
+
+	// applied rules are kept, rules of other priorities are not touched
+	current := []netlink.Rule{*add[0], *add[1], *add[2]}
+	other := netlink.NewRule()
+	other.Priority = 100
+	other.Table = 10
+	current = append(current, *other)
+
+	add, del = snatRuleChanges(current, sources[:1])
+	require.Empty(add)
+	require.Len(del, 1)
+	require.Equal("100.127.0.3/32", del[0].Src.String())
+
+	// the main table rule is removed with the last source
+	add, del = snatRuleChanges(current, nil)
+	require.Empty(add)
+	require.Len(del, 3)
+}
@@ -0,0 +1,80 @@
+package static
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// zosBond is the bond of the zos nics if more than one nic is set
+	zosBond = "bond-zos"
+)
+
+// Uplink returns the link to attach to the zos bridge. It's the nic itself
+// if the config has a single nic, otherwise the zos bond
+func (c *Config) Uplink() (netlink.Link, error) {
+	nics := make([]netlink.Link, 0, len(c.NICs))
+	for _, name := range c.NICs {
+		nic, err := netlink.LinkByName(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get nic '%s'", name)
+		}
+
+		nics = append(nics, nic)
+	}
+
+	if len(nics) == 1 {
+		return nics[0], nil
+	}
+
+	return ifaceutil.EnsureBond(zosBond, nics)
+}
+
+// SetAddress sets address and a default route over gateway on the link
+// with given name in the current namespace
+func SetAddress(name string, address net.IPNet, gateway net.IP) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get link '%s'", name)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return errors.Wrapf(err, "failed to set link '%s' up", name)
+	}
+
+	if err := netlink.AddrReplace(link, &netlink.Addr{IPNet: &address}); err != nil {
+		return errors.Wrapf(err, "failed to set address '%s' on '%s'", address.String(), name)
+	}
+
+	route := netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst: &net.IPNet{
+			IP:   net.IPv4zero,
+			Mask: net.CIDRMask(0, 32),
+		},
+		Gw: gateway,
+	}
+
+	if err := netlink.RouteReplace(&route); err != nil {
+		return errors.Wrapf(err, "failed to set default route over '%s'", gateway.String())
+	}
+
+	return nil
+}
+
+// WriteResolv writes the dns servers of the config to the resolv.conf file
+// at path
+func (c *Config) WriteResolv(path string) error {
+	var buf strings.Builder
+	for _, server := range c.DNS {
+		fmt.Fprintf(&buf, "nameserver %s\n", server.String())
+	}
+
+	return os.WriteFile(path, []byte(buf.String()), 0644)
+}
@@ -0,0 +1,167 @@
+package static
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/kernel"
+)
+
+const (
+	// kernel params of the static configuration
+	paramNIC     = "zos:nic"
+	paramAddress = "zos:ip"
+	paramNDMZ    = "zos:ndmz"
+	paramGateway = "zos:gw"
+	paramDNS     = "zos:dns"
+)
+
+// Config is a static configuration of the node management (zos) network.
+// It's used by farms that have no DHCP server on the management network
+type Config struct {
+	// NICs are the nics attached to the zos bridge. If more than one nic is
+	// set they are bonded together using LACP (802.3ad)
+	NICs []string `json:"nics"`
+	// Vlan is an optional vlan tag of the management network, if not set
+	// the `vlan:priv` kernel param is used
+	Vlan *uint16 `json:"vlan,omitempty"`
+	// Address is the address of the zos bridge
+	Address gridtypes.IPNet `json:"address"`
+	// NDMZ is the address of the ndmz ipv4 interface, it must be in the
+	// same subnet as Address
+	NDMZ    gridtypes.IPNet `json:"ndmz"`
+	Gateway net.IP          `json:"gateway"`
+	DNS     []net.IP        `json:"dns"`
+}
+
+// Valid checks the static configuration
+func (c *Config) Valid() error {
+	if len(c.NICs) == 0 {
+		return fmt.Errorf("at least one nic is required")
+	}
+
+	if c.Address.Nil() || c.Address.IP.To4() == nil {
+		return fmt.Errorf("invalid address '%s' expecting an ipv4 cidr", c.Address)
+	}
+
+	if c.NDMZ.Nil() || c.NDMZ.IP.To4() == nil {
+		return fmt.Errorf("invalid ndmz address '%s' expecting an ipv4 cidr", c.NDMZ)
+	}
+
+	if !c.Address.Contains(c.NDMZ.IP) {
+		return fmt.Errorf("ndmz address '%s' is not in the address subnet '%s'", c.NDMZ.IP, c.Address)
+	}
+
+	if c.NDMZ.IP.Equal(c.Address.IP) {
+		return fmt.Errorf("ndmz address can't be the same as the address")
+	}
+
+	if c.Gateway.To4() == nil || !c.Address.Contains(c.Gateway) {
+		return fmt.Errorf("invalid gateway '%s' expecting an ipv4 in the address subnet '%s'", c.Gateway, c.Address)
+	}
+
+	if len(c.DNS) == 0 {
+		return fmt.Errorf("at least one dns server is required")
+	}
+
+	return nil
+}
+
+// FromParams loads the static configuration from the kernel params. It
+// returns nil if no static address is set
+func FromParams(params kernel.Params) (*Config, error) {
+	address, ok := params.GetOne(paramAddress)
+	if !ok {
+		return nil, nil
+	}
+
+	var (
+		cfg Config
+		err error
+	)
+
+	cfg.NICs, _ = params.Get(paramNIC)
+
+	if cfg.Address, err = gridtypes.ParseIPNet(address); err != nil {
+		return nil, errors.Wrapf(err, "invalid '%s' param", paramAddress)
+	}
+
+	if ndmz, ok := params.GetOne(paramNDMZ); ok {
+		if cfg.NDMZ, err = gridtypes.ParseIPNet(ndmz); err != nil {
+			return nil, errors.Wrapf(err, "invalid '%s' param", paramNDMZ)
+		}
+	}
+
+	if gw, ok := params.GetOne(paramGateway); ok {
+		cfg.Gateway = net.ParseIP(gw)
+	}
+
+	dns, _ := params.Get(paramDNS)
+	for _, server := range dns {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid '%s' param '%s'", paramDNS, server)
+		}
+		cfg.DNS = append(cfg.DNS, ip)
+	}
+
+	if err := cfg.Valid(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// FromFile loads the static configuration from a json file. It returns
+// nil if the file does not exist
+func FromFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read '%s'", path)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "invalid static network config '%s'", path)
+	}
+
+	if err := cfg.Valid(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Load loads the static configuration of the node. The kernel params are
+// checked first then the config partition of the boot usb. It returns nil
+// if the node has no static configuration (uses DHCP)
+func Load(ctx context.Context) (*Config, error) {
+	cfg, err := FromParams(kernel.GetParams())
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid static network config in kernel params")
+	}
+
+	if cfg != nil {
+		log.Info().Msg("using static network config from kernel params")
+		return cfg, nil
+	}
+
+	cfg, err = fromUSB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg != nil {
+		log.Info().Msg("using static network config from boot usb")
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,75 @@
+package static
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/kernel"
+)
+
+func TestFromParams(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := FromParams(kernel.Params{})
+	require.NoError(err)
+	require.Nil(cfg)
+
+	params := kernel.Params{
+		"zos:nic":  {"eth0", "eth1"},
+		"zos:ip":   {"10.10.0.5/24"},
+		"zos:ndmz": {"10.10.0.6/24"},
+		"zos:gw":   {"10.10.0.1"},
+		"zos:dns":  {"1.1.1.1", "8.8.8.8"},
+	}
+
+	cfg, err = FromParams(params)
+	require.NoError(err)
+	require.Equal([]string{"eth0", "eth1"}, cfg.NICs)
+	require.Equal("10.10.0.5/24", cfg.Address.String())
+	require.Equal("10.10.0.6/24", cfg.NDMZ.String())
+	require.True(net.ParseIP("10.10.0.1").Equal(cfg.Gateway))
+	require.Len(cfg.DNS, 2)
+
+	params["zos:gw"] = []string{"10.20.0.1"}
+	_, err = FromParams(params)
+	require.Error(err)
+
+	delete(params, "zos:ndmz")
+	params["zos:gw"] = []string{"10.10.0.1"}
+	_, err = FromParams(params)
+	require.Error(err)
+}
+
+func TestFromFile(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	cfg, err := FromFile(path)
+	require.NoError(err)
+	require.Nil(cfg)
+
+	require.NoError(os.WriteFile(path, []byte(`{
+		"nics": ["eth0"],
+		"vlan": 10,
+		"address": "10.10.0.5/24",
+		"ndmz": "10.10.0.6/24",
+		"gateway": "10.10.0.1",
+		"dns": ["1.1.1.1"]
+	}`), 0644))
+
+	cfg, err = FromFile(path)
+	require.NoError(err)
+	require.Equal([]string{"eth0"}, cfg.NICs)
+	require.NotNil(cfg.Vlan)
+	require.Equal(uint16(10), *cfg.Vlan)
+	require.Equal("10.10.0.5/24", cfg.Address.String())
+
+	resolv := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(cfg.WriteResolv(resolv))
+	data, err := os.ReadFile(resolv)
+	require.NoError(err)
+	require.Equal("nameserver 1.1.1.1\n", string(data))
+}
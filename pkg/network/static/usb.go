@@ -0,0 +1,64 @@
+package static
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ConfigLabel is the label of the config partition on the boot usb
+	ConfigLabel = "ZOSCONFIG"
+	// ConfigFile is the static network config file on the config partition
+	ConfigFile = "network.json"
+)
+
+// blkid runs blkid with the given args and returns the first line of the output
+func blkid(ctx context.Context, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, "blkid", args...).Output()
+	if err != nil {
+		return "", err
+	}
+
+	line, _, _ := strings.Cut(string(output), "\n")
+	return strings.TrimSpace(line), nil
+}
+
+// fromUSB loads the static configuration from the config partition of the
+// boot usb, if any
+func fromUSB(ctx context.Context) (*Config, error) {
+	device, err := blkid(ctx, "-L", ConfigLabel)
+	if err != nil || len(device) == 0 {
+		// blkid exits with an error if no device has the label
+		return nil, nil
+	}
+
+	fstype, err := blkid(ctx, "-o", "value", "-s", "TYPE", device)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get filesystem of config partition '%s'", device)
+	}
+
+	target, err := os.MkdirTemp("", "zos-config-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(target)
+
+	if err := syscall.Mount(device, target, fstype, syscall.MS_RDONLY, ""); err != nil {
+		return nil, errors.Wrapf(err, "failed to mount config partition '%s'", device)
+	}
+
+	defer func() {
+		if err := syscall.Unmount(target, 0); err != nil {
+			log.Error().Err(err).Str("device", device).Msg("failed to unmount config partition")
+		}
+	}()
+
+	return FromFile(filepath.Join(target, ConfigFile))
+}
@@ -0,0 +1,122 @@
+package tc
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// ingressHandle is the handle of the ingress qdisc
+	ingressHandle = netlink.MakeHandle(0xffff, 0)
+)
+
+// ifbName is the name of the ifb device the ingress traffic of the link is
+// redirected to, so it can be shaped as egress traffic of the ifb device.
+func ifbName(link netlink.Link) string {
+	return fmt.Sprintf("ifb%d", link.Attrs().Index)
+}
+
+// ingressRedirect builds the ingress qdisc and the filter that redirects all
+// the ingress traffic of the link with given index to the link with index ifb
+func ingressRedirect(index, ifb int) (*netlink.Ingress, *netlink.MatchAll) {
+	qdisc := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: index,
+			Handle:    ingressHandle,
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+
+	filter := &netlink.MatchAll{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: index,
+			Parent:    ingressHandle,
+			Priority:  1,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Actions: []netlink.Action{
+			netlink.NewMirredAction(ifb),
+		},
+	}
+
+	return qdisc, filter
+}
+
+// ensureIfb creates the ifb device with given name if it does not exist
+// and sets it up
+func ensureIfb(name string) (netlink.Link, error) {
+	link, err := netlink.LinkByName(name)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		if err := netlink.LinkAdd(&netlink.Ifb{
+			LinkAttrs: netlink.LinkAttrs{Name: name},
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to create ifb device '%s'", name)
+		}
+
+		link, err = netlink.LinkByName(name)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ifb device '%s'", name)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, errors.Wrapf(err, "failed to set ifb device '%s' up", name)
+	}
+
+	return link, nil
+}
+
+// SetIngressRateLimit limits the ingress traffic of the link to rate bytes
+// per second. Ingress traffic can't be shaped directly, so it's redirected
+// to an ifb device that has the rate limit on its egress. A zero rate removes
+// the limit.
+func SetIngressRateLimit(link netlink.Link, rate uint64) error {
+	if rate == 0 {
+		return ClearIngressRateLimit(link)
+	}
+
+	ifb, err := ensureIfb(ifbName(link))
+	if err != nil {
+		return err
+	}
+
+	qdisc, filter := ingressRedirect(link.Attrs().Index, ifb.Attrs().Index)
+	if err := netlink.QdiscReplace(qdisc); err != nil {
+		return errors.Wrapf(err, "failed to set ingress qdisc of '%s'", link.Attrs().Name)
+	}
+
+	if err := netlink.FilterReplace(filter); err != nil {
+		return errors.Wrapf(err, "failed to redirect ingress traffic of '%s'", link.Attrs().Name)
+	}
+
+	return SetRateLimit(ifb, rate)
+}
+
+// ClearIngressRateLimit removes the ingress rate limit of the link and the
+// ifb device used to shape it.
+func ClearIngressRateLimit(link netlink.Link) error {
+	qdisc, _ := ingressRedirect(link.Attrs().Index, 0)
+
+	err := netlink.QdiscDel(qdisc)
+	if err != nil && !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.EINVAL) && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to delete ingress qdisc of '%s'", link.Attrs().Name)
+	}
+
+	ifb, err := netlink.LinkByName(ifbName(link))
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get ifb device of '%s'", link.Attrs().Name)
+	}
+
+	if err := netlink.LinkDel(ifb); err != nil {
+		return errors.Wrapf(err, "failed to delete ifb device of '%s'", link.Attrs().Name)
+	}
+
+	return nil
+}
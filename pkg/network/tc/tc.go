@@ -0,0 +1,137 @@
+package tc
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// rootHandle is the handle of the htb root qdisc
+	rootHandle = netlink.MakeHandle(1, 0)
+	// limitClass is the htb class all traffic is sent to
+	limitClass = netlink.MakeHandle(1, 1)
+	// leafHandle is the handle of the fq_codel qdisc of the limit class
+	leafHandle = netlink.MakeHandle(10, 0)
+)
+
+// rateLimit builds the qdiscs and the class that shape the egress traffic
+// of the link with given index to rate (in bytes per second). All traffic
+// goes to a single htb class, that uses fq_codel so flows share the
+// bandwidth fairly.
+func rateLimit(index int, rate uint64) (root *netlink.Htb, class *netlink.HtbClass, leaf *netlink.FqCodel) {
+	root = netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    rootHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	// unclassified traffic goes to the limit class
+	root.Defcls = 1
+
+	class = netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: index,
+		Handle:    limitClass,
+		Parent:    rootHandle,
+	}, netlink.HtbClassAttrs{
+		Rate: rate * 8,
+	})
+
+	leaf = netlink.NewFqCodel(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    leafHandle,
+		Parent:    limitClass,
+	})
+
+	return
+}
+
+// hasRateLimit checks if the link egress is already limited to rate
+func hasRateLimit(link netlink.Link, rate uint64) (bool, error) {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to list qdiscs of '%s'", link.Attrs().Name)
+	}
+
+	var root, leaf bool
+	for _, qdisc := range qdiscs {
+		attrs := qdisc.Attrs()
+		switch {
+		case attrs.Parent == netlink.HANDLE_ROOT && attrs.Handle == rootHandle && qdisc.Type() == "htb":
+			root = true
+		case attrs.Parent == limitClass && attrs.Handle == leafHandle && qdisc.Type() == "fq_codel":
+			leaf = true
+		}
+	}
+
+	if !root || !leaf {
+		return false, nil
+	}
+
+	classes, err := netlink.ClassList(link, rootHandle)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to list classes of '%s'", link.Attrs().Name)
+	}
+
+	for _, class := range classes {
+		htb, ok := class.(*netlink.HtbClass)
+		if ok && htb.Handle == limitClass {
+			return htb.Rate == rate, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SetRateLimit limits the egress traffic of the link to rate bytes per
+// second. A zero rate removes the limit.
+func SetRateLimit(link netlink.Link, rate uint64) error {
+	if rate == 0 {
+		return ClearRateLimit(link)
+	}
+
+	ok, err := hasRateLimit(link, rate)
+	if err != nil || ok {
+		return err
+	}
+
+	// start from a clean state, so changing the rate of a link does not
+	// end up with a mix of old and new classes
+	if err := ClearRateLimit(link); err != nil {
+		return err
+	}
+
+	root, class, leaf := rateLimit(link.Attrs().Index, rate)
+	if err := netlink.QdiscReplace(root); err != nil {
+		return errors.Wrapf(err, "failed to set root qdisc of '%s'", link.Attrs().Name)
+	}
+
+	if err := netlink.ClassReplace(class); err != nil {
+		return errors.Wrapf(err, "failed to set rate limit class of '%s'", link.Attrs().Name)
+	}
+
+	if err := netlink.QdiscReplace(leaf); err != nil {
+		return errors.Wrapf(err, "failed to set leaf qdisc of '%s'", link.Attrs().Name)
+	}
+
+	return nil
+}
+
+// ClearRateLimit removes the egress rate limit of the link, the link gets
+// the default root qdisc back.
+func ClearRateLimit(link netlink.Link) error {
+	root := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    rootHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	})
+
+	err := netlink.QdiscDel(root)
+	if err == nil || errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EINVAL) || os.IsNotExist(err) {
+		// EINVAL is returned if the link has no root qdisc of its own
+		return nil
+	}
+
+	return errors.Wrapf(err, "failed to delete root qdisc of '%s'", link.Attrs().Name)
+}
@@ -0,0 +1,49 @@
+package tc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+func TestRateLimit(t *testing.T) {
+	require := require.New(t)
+
+	root, class, leaf := rateLimit(10, 1024*1024)
+
+	require.Equal(10, root.LinkIndex)
+	require.Equal(uint32(netlink.HANDLE_ROOT), root.Parent)
+	require.Equal(netlink.MakeHandle(1, 0), root.Handle)
+	require.Equal(uint32(1), root.Defcls)
+
+	// class rates are in bytes
+	require.Equal(netlink.MakeHandle(1, 1), class.Handle)
+	require.Equal(root.Handle, class.Parent)
+	require.Equal(uint64(1024*1024), class.Rate)
+	require.Equal(class.Rate, class.Ceil)
+
+	require.Equal(class.Handle, leaf.Parent)
+	require.Equal("fq_codel", leaf.Type())
+}
+
+func TestIngressRedirect(t *testing.T) {
+	require := require.New(t)
+
+	qdisc, filter := ingressRedirect(10, 20)
+
+	require.Equal(10, qdisc.LinkIndex)
+	require.Equal(uint32(netlink.HANDLE_INGRESS), qdisc.Parent)
+	require.Equal("ingress", qdisc.Type())
+
+	require.Equal(10, filter.LinkIndex)
+	require.Equal(qdisc.Handle, filter.Parent)
+	require.Len(filter.Actions, 1)
+
+	mirred, ok := filter.Actions[0].(*netlink.MirredAction)
+	require.True(ok)
+	require.Equal(20, mirred.Ifindex)
+	require.Equal(netlink.TCA_EGRESS_REDIR, mirred.MirredAction)
+
+	require.Equal("ifb10", ifbName(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: 10}}))
+}
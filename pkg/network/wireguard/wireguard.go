@@ -152,6 +152,91 @@ func (w *Wireguard) Configure(privateKey string, listentPort int, peers []*Peer)
 	return nil
 }
 
+// UpdatePeers applies the given peers to the wireguard interface without
+// bringing it down. Only the peers that are added, changed or removed are
+// configured so the traffic of the other peers is not interrupted
+func (w *Wireguard) UpdatePeers(peers []*Peer) error {
+	wc, err := wgctrl.New()
+	if err != nil {
+		return err
+	}
+	defer wc.Close()
+
+	device, err := wc.Device(w.attrs.Name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get wireguard device")
+	}
+
+	desired := make([]wgtypes.PeerConfig, 0, len(peers))
+	for _, peer := range peers {
+		p, err := newPeer(peer.PublicKey, peer.Endpoint, peer.AllowedIPs)
+		if err != nil {
+			return err
+		}
+		desired = append(desired, p)
+	}
+
+	changes := peersDiff(device.Peers, desired)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	log.Info().Int("changes", len(changes)).Msg("update wg device peers")
+
+	return wc.ConfigureDevice(w.attrs.Name, wgtypes.Config{Peers: changes})
+}
+
+// peersDiff returns the peer configs needed to go from the current peers
+// to the desired peers
+func peersDiff(current []wgtypes.Peer, desired []wgtypes.PeerConfig) []wgtypes.PeerConfig {
+	existing := make(map[wgtypes.Key]wgtypes.Peer, len(current))
+	for _, peer := range current {
+		existing[peer.PublicKey] = peer
+	}
+
+	var changes []wgtypes.PeerConfig
+	for _, peer := range desired {
+		cur, ok := existing[peer.PublicKey]
+		delete(existing, peer.PublicKey)
+		if ok && !peerChanged(cur, peer) {
+			continue
+		}
+
+		changes = append(changes, peer)
+	}
+
+	for key := range existing {
+		changes = append(changes, wgtypes.PeerConfig{PublicKey: key, Remove: true})
+	}
+
+	return changes
+}
+
+func peerChanged(current wgtypes.Peer, desired wgtypes.PeerConfig) bool {
+	// peers without an endpoint are roaming, the endpoint is learned
+	// from the peer traffic
+	if desired.Endpoint != nil && (current.Endpoint == nil || current.Endpoint.String() != desired.Endpoint.String()) {
+		return true
+	}
+
+	if len(current.AllowedIPs) != len(desired.AllowedIPs) {
+		return true
+	}
+
+	ips := make(map[string]struct{}, len(current.AllowedIPs))
+	for _, ip := range current.AllowedIPs {
+		ips[ip.String()] = struct{}{}
+	}
+
+	for _, ip := range desired.AllowedIPs {
+		if _, ok := ips[ip.String()]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func newPeer(pubkey, endpoint string, allowedIPs []string) (wgtypes.PeerConfig, error) {
 	peer := wgtypes.PeerConfig{
 		ReplaceAllowedIPs: true,
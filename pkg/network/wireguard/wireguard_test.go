@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 func TestNewPeer(t *testing.T) {
@@ -65,3 +66,32 @@ func TestConfigure(t *testing.T) {
 		assert.Equal(t, allowedIps, actual)
 	}
 }
+
+func TestPeersDiff(t *testing.T) {
+	require := require.New(t)
+
+	keep, err := newPeer("mR5fBXohKe2MZ6v+GLwlKwrvkFxo1VvV3bPNHDBhOAI=", "37.187.124.71:51820", []string{"10.1.2.0/24"})
+	require.NoError(err)
+	update, err := newPeer("kDd5mB6L4gkd3U5W287JeQu7urFzBYH51JQZUrJd8Hg=", "", []string{"10.1.3.0/24"})
+	require.NoError(err)
+	add, err := newPeer("4DwTbGRWECH8oqcTXdoWXGOaWWC952QKbFE1fMzBNmA=", "", []string{"10.1.4.0/24"})
+	require.NoError(err)
+	remove, err := newPeer("qGyyDJvzgc0R6iGHC8tU8LCOKGhgAo6ofQc7yc4h8nU=", "", []string{"10.1.5.0/24"})
+	require.NoError(err)
+
+	current := []wgtypes.Peer{
+		{PublicKey: keep.PublicKey, Endpoint: keep.Endpoint, AllowedIPs: keep.AllowedIPs},
+		// roaming peer endpoint is not part of the diff
+		{PublicKey: update.PublicKey, Endpoint: keep.Endpoint, AllowedIPs: keep.AllowedIPs},
+		{PublicKey: remove.PublicKey, AllowedIPs: remove.AllowedIPs},
+	}
+
+	changes := peersDiff(current, []wgtypes.PeerConfig{keep, update, add})
+	require.Equal([]wgtypes.PeerConfig{
+		update,
+		add,
+		{PublicKey: remove.PublicKey, Remove: true},
+	}, changes)
+
+	require.Empty(peersDiff(current[:1], []wgtypes.PeerConfig{keep}))
+}
@@ -20,6 +20,7 @@ import (
 type PowerServer struct {
 	consumer         *events.RedisConsumer
 	substrateGateway *stubs.SubstrateGatewayStub
+	vmd              *stubs.VMModuleStub
 
 	// enabled means the node can power off!
 	enabled bool
@@ -31,6 +32,7 @@ type PowerServer struct {
 
 func NewPowerServer(
 	substrateGateway *stubs.SubstrateGatewayStub,
+	vmd *stubs.VMModuleStub,
 	consumer *events.RedisConsumer,
 	enabled bool,
 	farm pkg.FarmID,
@@ -40,6 +42,7 @@ func NewPowerServer(
 
 	return &PowerServer{
 		substrateGateway: substrateGateway,
+		vmd:              vmd,
 		consumer:         consumer,
 		enabled:          enabled,
 		farm:             farm,
@@ -52,6 +55,12 @@ func NewPowerServer(
 const (
 	DefaultWolBridge = "zos"
 	PowerServerPort  = 8039
+
+	// vmsShutdownTimeout max time to wait for all vms
+	// to shutdown before the node is powered off. vms are stopped
+	// in parallel, each one is given at most 1 minute of grace
+	// period plus a few seconds to be forced down.
+	vmsShutdownTimeout = 2 * time.Minute
 )
 
 func EnsureWakeOnLan(ctx context.Context) (bool, error) {
@@ -166,6 +175,14 @@ func (p *PowerServer) shutdown() error {
 		log.Error().Err(err).Msg("failed to send uptime before shutting down")
 	}
 
+	// give the vms the chance to shutdown gracefully before
+	// the node goes down.
+	ctx, cancel := context.WithTimeout(context.Background(), vmsShutdownTimeout)
+	defer cancel()
+	if err := p.vmd.ShutdownAll(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to shutdown virtual machines")
+	}
+
 	// is down!
 	init := zinit.Default()
 	err := init.Shutdown()
@@ -30,12 +30,12 @@ func NewManager(zbus zbus.Client) *Manager {
 }
 
 // networkProvision is entry point to provision a network
-func (p *Manager) networkProvisionImpl(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+func (p *Manager) networkProvisionImpl(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
 	twin, _ := provision.GetDeploymentID(ctx)
 
 	var network zos.Network
 	if err := json.Unmarshal(wl.Data, &network); err != nil {
-		return fmt.Errorf("failed to unmarshal network from reservation: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal network from reservation: %w", err)
 	}
 
 	mgr := stubs.NewNetworkerStub(p.zbus)
@@ -47,18 +47,30 @@ func (p *Manager) networkProvisionImpl(ctx context.Context, wl *gridtypes.Worklo
 	})
 
 	if err != nil {
-		return errors.Wrapf(err, "failed to create network resource for network %s", wl.ID)
+		return nil, errors.Wrapf(err, "failed to create network resource for network %s", wl.ID)
 	}
 
-	return nil
+	if !network.SelfTest {
+		return nil, nil
+	}
+
+	// unreachable peers are reported in the result, they don't fail the
+	// network since the peers can be deployed later
+	result, err := mgr.CheckNR(ctx, wl.ID)
+	if err != nil {
+		log.Error().Err(err).Stringer("workload", wl.ID).Msg("failed to run network self test")
+		return nil, nil
+	}
+
+	return result, nil
 }
 
 func (p *Manager) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
-	return nil, p.networkProvisionImpl(ctx, wl)
+	return p.networkProvisionImpl(ctx, wl)
 }
 
 func (p *Manager) Update(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
-	return nil, p.networkProvisionImpl(ctx, wl)
+	return p.networkProvisionImpl(ctx, wl)
 }
 
 func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
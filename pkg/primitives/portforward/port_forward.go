@@ -0,0 +1,64 @@
+package portforward
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+var (
+	_ provision.Manager = (*Manager)(nil)
+	_ provision.Updater = (*Manager)(nil)
+)
+
+type Manager struct {
+	zbus zbus.Client
+}
+
+func NewManager(zbus zbus.Client) *Manager {
+	return &Manager{zbus}
+}
+
+func (p *Manager) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
+	return p.portForwardProvisionImpl(ctx, wl)
+}
+
+func (p *Manager) Update(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
+	return p.portForwardProvisionImpl(ctx, wl)
+}
+
+func (p *Manager) portForwardProvisionImpl(ctx context.Context, wl *gridtypes.WorkloadWithID) (result zos.PortForwardResult, err error) {
+	network := stubs.NewNetworkerStub(p.zbus)
+
+	var config zos.PortForward
+	if err := json.Unmarshal(wl.Data, &config); err != nil {
+		return result, errors.Wrap(err, "failed to decode port forward config")
+	}
+
+	twin, _ := provision.GetDeploymentID(ctx)
+	port, err := network.SetupPortForward(ctx, wl.ID, pkg.PortForward{
+		NetID:    zos.NetworkID(twin, config.Network),
+		Protocol: config.Protocol,
+		IP:       config.IP,
+		Port:     config.Port,
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "failed to setup port forward")
+	}
+
+	result.PublicPort = port
+	return result, nil
+}
+
+func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	network := stubs.NewNetworkerStub(p.zbus)
+
+	return network.RemovePortForward(ctx, wl.ID)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
 	"github.com/threefoldtech/zos/pkg/primitives/gateway"
 	"github.com/threefoldtech/zos/pkg/primitives/network"
+	"github.com/threefoldtech/zos/pkg/primitives/portforward"
 	"github.com/threefoldtech/zos/pkg/primitives/pubip"
 	"github.com/threefoldtech/zos/pkg/primitives/qsfs"
 	"github.com/threefoldtech/zos/pkg/primitives/vm"
@@ -30,6 +31,7 @@ func NewPrimitivesProvisioner(zbus zbus.Client) provision.Provisioner {
 		zos.VolumeType:           volume.NewManager(zbus),
 		zos.GatewayNameProxyType: gateway.NewNameManager(zbus),
 		zos.GatewayFQDNProxyType: gateway.NewFQDNManager(zbus),
+		zos.PortForwardType:      portforward.NewManager(zbus),
 	}
 
 	return provision.NewMapProvisioner(managers)
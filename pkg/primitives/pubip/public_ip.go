@@ -145,6 +145,22 @@ func (p *Manager) publicIPProvisionImpl(ctx context.Context, wl *gridtypes.Workl
 	fName := filterName(tapName)
 
 	if network.PubIPFilterExists(ctx, fName) {
+		// make sure firewall rules are up to date
+		if err := network.SetPubIPFirewall(ctx, fName, firewallOf(config)); err != nil {
+			return result, errors.Wrap(err, "failed to set public ip firewall")
+		}
+
+		// the public tap limits are not persisted by networkd
+		if err := network.SetPubIPBandwidth(ctx, tapName, config.Bandwidth); err != nil {
+			return result, errors.Wrap(err, "failed to set public ip bandwidth")
+		}
+
+		// flow logs are not persisted by networkd
+		if current, err := GetPubIPConfig(wl); err == nil {
+			if err := network.SetPubIPFlowLogs(ctx, wl.ID, current.IP.IP, current.IPv6.IP, config.FlowLogs); err != nil {
+				return result, errors.Wrap(err, "failed to set public ip flow logs")
+			}
+		}
 		return result, provision.ErrNoActionNeeded
 	}
 
@@ -170,18 +186,133 @@ func (p *Manager) publicIPProvisionImpl(ctx context.Context, wl *gridtypes.Workl
 		if err != nil {
 			return zos.PublicIPResult{}, err
 		}
+
+		pools, err := network.GetSNATPools(ctx)
+		if err != nil {
+			return zos.PublicIPResult{}, errors.Wrap(err, "failed to get snat pools")
+		}
+
+		for i := range pools {
+			if pools[i].Has(ipv4.IP) {
+				return zos.PublicIPResult{}, fmt.Errorf("public ip '%s' is used as a snat address by the node", ipv4.IP)
+			}
+		}
 	}
 
 	result.IP = ipv4
 	result.IPv6 = ipv6
 	result.Gateway = gw4
 
-	ifName := fmt.Sprintf("p-%s", tapName) // TODO: clean this up, needs to come form networkd
-	err = network.SetupPubIPFilter(ctx, fName, ifName, ipv4.IP, ipv6.IP, mac.String())
+	target, err := p.targetTap(ctx, wl, config, ipv4.IP)
+	if isNotExist(err) && !wl.Result.IsNil() && wl.Result.State == gridtypes.StateOk {
+		// the target was deleted while the node was down, the ip is
+		// routed back to the vm that owns it
+		log.Warn().Err(err).Stringer("target", config.Target).Msg("public ip target does not exist anymore")
+		target, err = "", nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// the ipv4 is not allowed on the tap of the public ip itself
+	// if it's routed to another vm
+	ownIPv4 := ipv4.IP
+	if len(target) != 0 {
+		ownIPv4 = nil
+	}
+
+	if err = network.SetupPubIPFilter(ctx, fName, pubIface(tapName), ownIPv4, ipv6.IP, mac.String()); err != nil {
+		return
+	}
+
+	if len(target) != 0 {
+		if err = p.route(ctx, fName, tapName, target, result); err != nil {
+			return
+		}
+	}
+
+	if config.V6Prefix {
+		prefix, err := network.DelegatePrefix(ctx, wl.ID, ipv6.IP)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to delegate ipv6 prefix")
+		}
+		result.IPv6Prefix = gridtypes.NewIPNet(prefix)
+	}
+
+	if err = network.SetPubIPFirewall(ctx, fName, firewallOf(config)); err != nil {
+		err = errors.Wrap(err, "failed to set public ip firewall")
+		return
+	}
+
+	if err = network.SetPubIPBandwidth(ctx, tapName, config.Bandwidth); err != nil {
+		err = errors.Wrap(err, "failed to set public ip bandwidth")
+		return
+	}
+
+	if err = network.SetPubIPFlowLogs(ctx, wl.ID, ipv4.IP, ipv6.IP, config.FlowLogs); err != nil {
+		err = errors.Wrap(err, "failed to set public ip flow logs")
+	}
 
 	return
 }
 
+// Update only allows updating the firewall rules, the bandwidth and the
+// target of the public ip. The ip selection can't be changed.
+func (p *Manager) Update(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
+	current, err := provision.GetWorkload(ctx, wl.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no public ip workload with name '%s' is deployed", wl.Name)
+	}
+
+	old, err := p.getPublicIPData(ctx, &current)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := p.getPublicIPData(ctx, wl)
+	if err != nil {
+		return nil, err
+	}
+
+	if old.V4 != config.V4 || old.V6 != config.V6 || old.V6Prefix != config.V6Prefix {
+		return nil, provision.UnChanged(fmt.Errorf("public ip selection can not be changed"))
+	}
+
+	result, err := GetPubIPConfig(&current)
+	if err != nil {
+		return nil, err
+	}
+
+	network := stubs.NewNetworkerStub(p.zbus)
+	tapName := wl.ID.Unique("pub")
+	fName := filterName(tapName)
+
+	if old.Target != config.Target {
+		target, err := p.targetTap(ctx, wl, config, result.IP.IP)
+		if err != nil {
+			return nil, provision.UnChanged(err)
+		}
+
+		if err := p.setTarget(ctx, tapName, target, result); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := network.SetPubIPFirewall(ctx, fName, firewallOf(config)); err != nil {
+		return nil, errors.Wrap(err, "failed to set public ip firewall")
+	}
+
+	if err := network.SetPubIPBandwidth(ctx, tapName, config.Bandwidth); err != nil {
+		return nil, errors.Wrap(err, "failed to set public ip bandwidth")
+	}
+
+	if err := network.SetPubIPFlowLogs(ctx, wl.ID, result.IP.IP, result.IPv6.IP, config.FlowLogs); err != nil {
+		return nil, errors.Wrap(err, "failed to set public ip flow logs")
+	}
+
+	return result, nil
+}
+
 func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
 	// Disconnect the public interface from the network if one exists
 	network := stubs.NewNetworkerStub(p.zbus)
@@ -190,9 +321,212 @@ func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID)
 	if err := network.RemovePubIPFilter(ctx, fName); err != nil {
 		log.Error().Err(err).Msg("could not remove filter rules")
 	}
+	if err := network.SetPubIPFlowLogs(ctx, wl.ID, nil, nil, false); err != nil {
+		log.Error().Err(err).Msg("could not disable flow logs")
+	}
+	if err := network.ReleasePrefix(ctx, wl.ID); err != nil {
+		log.Error().Err(err).Msg("could not release delegated ipv6 prefix")
+	}
+
+	// the ips that float to the vms using this public ip can't
+	// reach them anymore
+	if deployment, err := provision.GetDeployment(ctx); err == nil {
+		for _, machine := range deployment.ByType(zos.ZMachineType) {
+			var data zos.ZMachine
+			if err := json.Unmarshal(machine.Data, &data); err != nil || data.Network.PublicIP != wl.Name {
+				continue
+			}
+
+			if err := ReleaseTarget(ctx, p.zbus, machine.ID); err != nil {
+				log.Error().Err(err).Stringer("target", machine.ID).Msg("failed to release floating public ips")
+			}
+		}
+	}
+
 	return network.DisconnectPubTap(ctx, tapName)
 }
 
+// ReleaseTarget routes the public ips that are floating to the zmachine
+// with the given id back to the zmachines that own them. It must be
+// called when the zmachine (or its own public ip) is deleted.
+func ReleaseTarget(ctx context.Context, cl zbus.Client, target gridtypes.WorkloadID) error {
+	twin, _, _, err := target.Parts()
+	if err != nil {
+		return err
+	}
+
+	storage := provision.GetEngine(ctx).Storage()
+	// only ips of the same twin can float to the zmachine
+	ids, err := storage.ByTwin(twin)
+	if err != nil {
+		return errors.Wrap(err, "failed to list twin deployments")
+	}
+
+	manager := NewManager(cl)
+	for _, id := range ids {
+		deployment, err := storage.Get(twin, id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get deployment '%d'", id)
+		}
+
+		for _, wl := range deployment.ByType(zos.PublicIPType) {
+			config, err := manager.getPublicIPData(ctx, wl)
+			if err != nil || config.Target != target {
+				continue
+			}
+
+			result, err := GetPubIPConfig(wl)
+			if err != nil {
+				continue
+			}
+
+			log.Info().Stringer("ip", wl.ID).Stringer("target", target).Msg("route floating public ip back")
+			if err := manager.setTarget(ctx, wl.ID.Unique("pub"), "", result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// setTarget sets up the filter of the public ip for the given target tap
+// and routes the ipv4 to it, or back to the tap of the public ip itself
+// if target is empty
+func (p *Manager) setTarget(ctx context.Context, tapName, target string, result zos.PublicIPResult) error {
+	network := stubs.NewNetworkerStub(p.zbus)
+	fName := filterName(tapName)
+
+	ownIPv4 := result.IP.IP
+	if len(target) != 0 {
+		ownIPv4 = nil
+	}
+
+	mac := ifaceutil.HardwareAddrFromInputBytes([]byte(tapName))
+	if err := network.SetupPubIPFilter(ctx, fName, pubIface(tapName), ownIPv4, result.IPv6.IP, mac.String()); err != nil {
+		return errors.Wrap(err, "failed to set public ip filter")
+	}
+
+	return p.route(ctx, fName, tapName, target, result)
+}
+
+// isNotExist checks if the error is caused by a workload or deployment
+// that does not exist
+func isNotExist(err error) bool {
+	return errors.Is(err, provision.ErrWorkloadNotExist) || errors.Is(err, provision.ErrDeploymentNotExists)
+}
+
+// targetTap returns the name of the public tap of the zmachine the public
+// ip is routed to. It's empty if the ip has no target, or if the target is
+// the zmachine that uses the ip itself. The target public ip must be on the
+// same pool vlan as the ipv4, otherwise the ipv4 can't reach the target.
+func (p *Manager) targetTap(ctx context.Context, wl *gridtypes.WorkloadWithID, config zos.PublicIP, ipv4 net.IP) (string, error) {
+	if len(config.Target) == 0 {
+		return "", nil
+	}
+
+	twin, contract, name, err := config.Target.Parts()
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid public ip target '%s'", config.Target)
+	}
+
+	if owner, _ := provision.GetDeploymentID(ctx); owner != twin {
+		return "", fmt.Errorf("public ip target must be owned by the same twin")
+	}
+
+	machine, err := provision.GetEngine(ctx).Storage().Current(twin, contract, name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get public ip target '%s'", config.Target)
+	}
+
+	if machine.Type != zos.ZMachineType {
+		return "", fmt.Errorf("public ip target '%s' is not a zmachine", config.Target)
+	}
+
+	var data zos.ZMachine
+	if err := json.Unmarshal(machine.Data, &data); err != nil {
+		return "", errors.Wrap(err, "failed to load public ip target")
+	}
+
+	if len(data.Network.PublicIP) == 0 {
+		return "", fmt.Errorf("public ip target '%s' has no public interface", config.Target)
+	}
+
+	ipID := gridtypes.NewUncheckedWorkloadID(twin, contract, data.Network.PublicIP)
+	if ipID == wl.ID {
+		return "", nil
+	}
+
+	ipWl, err := provision.GetEngine(ctx).Storage().Current(twin, contract, data.Network.PublicIP)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get public ip of target '%s'", config.Target)
+	}
+
+	targetIP, err := GetPubIPConfig(&gridtypes.WorkloadWithID{Workload: &ipWl, ID: ipID})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get public ip of target '%s'", config.Target)
+	}
+
+	uplink, err := stubs.NewNetworkerStub(p.zbus).GetPublicUplink(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get public uplink")
+	}
+
+	// the tap of an ipv6 only public ip is on the default vlan
+	vlan, _ := uplink.PoolVlan(ipv4)
+	targetVlan, _ := uplink.PoolVlan(targetIP.IP.IP)
+	if vlan != targetVlan {
+		return "", fmt.Errorf("public ip target '%s' is on vlan %d while the ip is on vlan %d", config.Target, targetVlan, vlan)
+	}
+
+	return ipID.Unique("pub"), nil
+}
+
+// route routes the public ip to the public tap of the target vm, or back
+// to the tap of the public ip itself if target is empty. The ips that moved
+// are then announced with the mac of the vm that holds them now
+func (p *Manager) route(ctx context.Context, fName, tapName, target string, result zos.PublicIPResult) error {
+	network := stubs.NewNetworkerStub(p.zbus)
+
+	var iface string
+	mac := ifaceutil.HardwareAddrFromInputBytes([]byte(tapName))
+	ipv6 := result.IPv6.IP
+	if len(target) != 0 {
+		iface = pubIface(target)
+		mac = ifaceutil.HardwareAddrFromInputBytes([]byte(target))
+		// only the ipv4 is routed to the target, the ipv6 stays
+		// with the vm that owns the public ip
+		ipv6 = nil
+	}
+
+	if err := network.SetPubIPFloating(ctx, fName, iface, result.IP.IP, mac.String()); err != nil {
+		return errors.Wrap(err, "failed to route public ip to target")
+	}
+
+	if err := network.AnnouncePubIP(ctx, result.IP.IP, ipv6, mac.String()); err != nil {
+		// the gateway will learn the new mac anyway once the arp
+		// entry expires
+		log.Error().Err(err).Msg("failed to announce public ip")
+	}
+
+	return nil
+}
+
+// pubIface returns the name of the public tap interface
+func pubIface(tapName string) string {
+	return fmt.Sprintf("p-%s", tapName) // TODO: clean this up, needs to come form networkd
+}
+
+// firewallOf returns the firewall of the public ip config. If no firewall is set
+// an empty firewall (accept all) is returned so previous rules are cleared.
+func firewallOf(config zos.PublicIP) zos.Firewall {
+	if config.Firewall == nil {
+		return zos.Firewall{}
+	}
+
+	return *config.Firewall
+}
+
 func filterName(reservationID string) string {
 	return fmt.Sprintf("r-%s", reservationID)
 }
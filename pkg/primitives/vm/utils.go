@@ -72,6 +72,8 @@ func (p *Manager) prepVirtualMachine(
 		Path: info.Path,
 	}
 
+	machine.UserData = config.UserData
+
 	return p.vmMounts(ctx, deployment, config.Mounts[1:], false, machine)
 }
 
@@ -254,38 +256,87 @@ func (p *Manager) newPrivNetworkInterface(ctx context.Context, dl gridtypes.Depl
 		return pkg.VMIface{}, errors.Wrapf(err, "could not get network resource subnet")
 	}
 
-	inf.IP = inf.IP.To4()
-	if inf.IP == nil {
-		return pkg.VMIface{}, fmt.Errorf("invalid IPv4 supplied to wg interface")
+	subnet6, err := network.GetSubnetV6(ctx, netID)
+	if err != nil {
+		return pkg.VMIface{}, errors.Wrapf(err, "could not get network resource ipv6 subnet")
 	}
 
-	if !subnet.Contains(inf.IP) {
-		return pkg.VMIface{}, fmt.Errorf("IP %s is not part of local nr subnet %s", inf.IP.String(), subnet.String())
+	gw4, gw6, err := network.GetDefaultGwIP(ctx, netID)
+	if err != nil {
+		return pkg.VMIface{}, errors.Wrap(err, "could not get network resource default gateway")
 	}
 
-	// always the .1/24 ip is reserved
-	if inf.IP[3] == 1 {
-		return pkg.VMIface{}, fmt.Errorf("ip %s is reserved", inf.IP.String())
-	}
+	var (
+		ips    []net.IPNet
+		routes []pkg.Route
+	)
 
-	privNet, err := network.GetNet(ctx, netID)
-	if err != nil {
-		return pkg.VMIface{}, errors.Wrapf(err, "could not get network range")
-	}
+	if len(inf.IP) != 0 {
+		inf.IP = inf.IP.To4()
+		if inf.IP == nil {
+			return pkg.VMIface{}, fmt.Errorf("invalid IPv4 supplied to wg interface")
+		}
+
+		if gw4 == nil {
+			return pkg.VMIface{}, fmt.Errorf("network '%s' has no IPv4 range", inf.Network)
+		}
 
-	addrCIDR := net.IPNet{
-		IP:   inf.IP,
-		Mask: subnet.Mask,
+		if !subnet.Contains(inf.IP) {
+			return pkg.VMIface{}, fmt.Errorf("IP %s is not part of local nr subnet %s", inf.IP.String(), subnet.String())
+		}
+
+		// always the .1/24 ip is reserved
+		if inf.IP[3] == 1 {
+			return pkg.VMIface{}, fmt.Errorf("ip %s is reserved", inf.IP.String())
+		}
+
+		privNet, err := network.GetNet(ctx, netID)
+		if err != nil {
+			return pkg.VMIface{}, errors.Wrapf(err, "could not get network range")
+		}
+
+		ips = append(ips, net.IPNet{
+			IP:   inf.IP,
+			Mask: subnet.Mask,
+		})
+
+		routes = append(routes,
+			pkg.Route{Net: privNet, Gateway: gw4},
+			pkg.Route{Net: networkResourceNet, Gateway: gw4},
+		)
 	}
 
-	gw4, gw6, err := network.GetDefaultGwIP(ctx, netID)
-	if err != nil {
-		return pkg.VMIface{}, errors.Wrap(err, "could not get network resource default gateway")
+	if len(inf.IPv6) != 0 {
+		if len(subnet6.IP) == 0 {
+			return pkg.VMIface{}, fmt.Errorf("network '%s' has no IPv6 range", inf.Network)
+		}
+
+		if !subnet6.Contains(inf.IPv6) {
+			return pkg.VMIface{}, fmt.Errorf("IP %s is not part of local nr ipv6 subnet %s", inf.IPv6.String(), subnet6.String())
+		}
+
+		if inf.IPv6.Equal(gw6) {
+			return pkg.VMIface{}, fmt.Errorf("ip %s is reserved", inf.IPv6.String())
+		}
+
+		ips = append(ips, net.IPNet{IP: inf.IPv6, Mask: subnet6.Mask})
+	} else if len(inf.IP) != 0 {
+		// dual stack, the ipv6 is derived from the ipv4
+		privIP6, err := network.GetIPv6From4(ctx, netID, inf.IP)
+		if err != nil {
+			return pkg.VMIface{}, errors.Wrap(err, "could not convert private ipv4 to ipv6")
+		}
+
+		ips = append(ips, privIP6)
 	}
 
-	privIP6, err := network.GetIPv6From4(ctx, netID, inf.IP)
-	if err != nil {
-		return pkg.VMIface{}, errors.Wrap(err, "could not convert private ipv4 to ipv6")
+	if len(subnet6.IP) != 0 {
+		privNet6, err := network.GetNetV6(ctx, netID)
+		if err != nil {
+			return pkg.VMIface{}, errors.Wrapf(err, "could not get network ipv6 range")
+		}
+
+		routes = append(routes, pkg.Route{Net: privNet6, Gateway: gw6})
 	}
 
 	tapName := wl.ID.Unique(string(inf.Network))
@@ -298,17 +349,26 @@ func (p *Manager) newPrivNetworkInterface(ctx context.Context, dl gridtypes.Depl
 	// this needs to be the same as how we get it in the actual IP reservation
 	mac := ifaceutil.HardwareAddrFromInputBytes([]byte(tapName))
 
+	host := pkg.NetworkHost{
+		NetID: netID,
+		Name:  wl.Name.String(),
+		MAC:   mac.String(),
+	}
+	for _, ip := range ips {
+		host.IPs = append(host.IPs, ip.IP)
+	}
+
+	// register the vm in the network resource dhcp and dns services
+	if err := network.SetNetworkHost(ctx, wl.ID, host); err != nil {
+		return pkg.VMIface{}, errors.Wrap(err, "could not register vm in network services")
+	}
+
 	out := pkg.VMIface{
-		Tap: iface,
-		MAC: mac.String(),
-		IPs: []net.IPNet{
-			addrCIDR, privIP6,
-		},
-		Routes: []pkg.Route{
-			{Net: privNet, Gateway: gw4},
-			{Net: networkResourceNet, Gateway: gw4},
-		},
-		IP4DefaultGateway: net.IP(gw4),
+		Tap:               iface,
+		MAC:               mac.String(),
+		IPs:               ips,
+		Routes:            routes,
+		IP4DefaultGateway: gw4,
 		IP6DefaultGateway: gw6,
 		PublicIPv4:        false,
 		PublicIPv6:        false,
@@ -337,6 +397,17 @@ func (p *Manager) newPubNetworkInterface(ctx context.Context, deployment gridtyp
 		return pkg.VMIface{}, errors.Wrap(err, "could not set up tap device for public network")
 	}
 
+	// the public traffic of the vm is limited by the bandwidth of the
+	// public ip if set, otherwise by the first network the vm is attached to
+	var netID zos.NetID
+	if len(cfg.Network.Interfaces) != 0 {
+		netID = zos.NetworkID(deployment.TwinID, cfg.Network.Interfaces[0].Network)
+	}
+
+	if err := network.SetPubTapBandwidth(ctx, tapName, netID); err != nil {
+		return pkg.VMIface{}, errors.Wrap(err, "could not set public tap bandwidth")
+	}
+
 	// the mac address uses the global workload id
 	// this needs to be the same as how we get it in the actual IP reservation
 	mac := ifaceutil.HardwareAddrFromInputBytes([]byte(tapName))
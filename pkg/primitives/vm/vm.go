@@ -7,13 +7,16 @@ import (
 	"io"
 	"net"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/zbus"
 	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/environment"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
 	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/primitives/pubip"
 	"github.com/threefoldtech/zos/pkg/provision"
 	"github.com/threefoldtech/zos/pkg/stubs"
 )
@@ -139,12 +142,20 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		return result, fmt.Errorf("usage of GPU is not allowed unless node is rented")
 	}
 
+	qos, err := p.machineQoS(&config)
+	if err != nil {
+		return result, err
+	}
+
 	machine := pkg.VM{
 		Name:       wl.ID.String(),
 		CPU:        config.ComputeCapacity.CPU,
 		Memory:     config.ComputeCapacity.Memory,
 		Entrypoint: config.Entrypoint,
 		KernelArgs: pkg.KernelArgs{},
+		QoS:        qos,
+
+		ShutdownTimeout: time.Duration(config.ShutdownTimeout) * time.Second,
 	}
 
 	// expand GPUs
@@ -170,7 +181,9 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 	}
 
 	result.ID = wl.ID.String()
-	result.IP = netConfig.IP.String()
+	if len(netConfig.IP) != 0 {
+		result.IP = netConfig.IP.String()
+	}
 
 	deployment, err := provision.GetDeployment(ctx)
 	if err != nil {
@@ -201,6 +214,12 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		}
 		ifs = append(ifs, wl.ID.Unique(string(nic.Network)))
 		networkInfo.Ifaces = append(networkInfo.Ifaces, inf)
+
+		for _, ip := range inf.IPs {
+			if ip.IP.To4() == nil && len(result.IPv6) == 0 {
+				result.IPv6 = ip.IP.String()
+			}
+		}
 	}
 
 	if !config.Network.PublicIP.IsEmpty() {
@@ -294,6 +313,29 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 	return result, err
 }
 
+// machineQoS computes the io limits of the machine. The farmer defaults
+// for the machine capacity tier are used, unless the user requested
+// lower limits
+func (p *Manager) machineQoS(config *ZMachine) (pkg.VMQoS, error) {
+	env, err := environment.Get()
+	if err != nil {
+		return pkg.VMQoS{}, errors.Wrap(err, "failed to get node environment")
+	}
+
+	defaults := env.VMQoSDefaults(config.ComputeCapacity.CPU)
+	if config.QoS == nil {
+		return defaults, nil
+	}
+
+	requested := pkg.VMQoS{
+		DiskIOPS:      config.QoS.DiskIOPS,
+		DiskBandwidth: config.QoS.DiskBandwidth,
+		NetBandwidth:  config.QoS.NetBandwidth,
+	}
+
+	return requested.Bound(defaults), nil
+}
+
 func (p *Manager) copyFile(srcPath string, destPath string, permissions os.FileMode) error {
 	src, err := os.Open(srcPath)
 	if err != nil {
@@ -341,12 +383,22 @@ func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID)
 		log.Error().Err(err).Str("name", volName).Msg("failed to delete rootfs volume")
 	}
 
+	twin, _, _, err := wl.ID.Parts()
+	if err != nil {
+		return err
+	}
+
 	for _, inf := range cfg.Network.Interfaces {
 		tapName := wl.ID.Unique(string(inf.Network))
 
 		if err := network.RemoveTap(ctx, tapName); err != nil {
 			return errors.Wrap(err, "could not clean up tap device")
 		}
+
+		netID := zos.NetworkID(twin, inf.Network)
+		if err := network.RemoveNetworkHost(ctx, wl.ID, netID); err != nil {
+			log.Error().Err(err).Str("network", string(inf.Network)).Msg("failed to remove vm from network services")
+		}
 	}
 
 	if cfg.Network.Planetary {
@@ -370,6 +422,11 @@ func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID)
 		if err != nil {
 			return err
 		}
+		// the ips that float to this vm can't reach it anymore
+		if err := pubip.ReleaseTarget(ctx, p.zbus, wl.ID); err != nil {
+			log.Error().Err(err).Msg("failed to release floating public ips")
+		}
+
 		ifName := ipWl.ID.Unique("pub")
 		if err := network.RemovePubTap(ctx, ifName); err != nil {
 			return errors.Wrap(err, "could not clean up public tap device")
@@ -2,9 +2,11 @@ package pkg
 
 //go:generate zbusc -module provision -version 0.0.1 -name provision -package stubs github.com/threefoldtech/zos/pkg+Provision stubs/provision_stub.go
 //go:generate zbusc -module provision -version 0.0.1 -name statistics -package stubs github.com/threefoldtech/zos/pkg+Statistics stubs/statistics_stub.go
+//go:generate zbusc -module provision -version 0.0.1 -name usage -package stubs github.com/threefoldtech/zos/pkg+VMUsage stubs/vm_usage_stub.go
 
 import (
 	"context"
+	"time"
 
 	"github.com/threefoldtech/zos/pkg/gridtypes"
 )
@@ -20,6 +22,10 @@ type Provision interface {
 	Changes(twin uint32, contractID uint64) ([]gridtypes.Workload, error)
 	ListPublicIPs() ([]string, error)
 	ListPrivateIPs(twin uint32, network gridtypes.Name) ([]string, error)
+	// UpdateResultData sets the data of the result of a deployed workload
+	// without changing its state, for results that are refreshed after the
+	// workload is deployed
+	UpdateResultData(id string, data []byte) error
 }
 
 type Statistics interface {
@@ -31,6 +37,38 @@ type Statistics interface {
 	ListGPUs() ([]GPUInfo, error)
 }
 
+// VMUsage gives access to the machines usage collected by the node
+type VMUsage interface {
+	// MachineUsage returns the usage of the machine with given workload id
+	// over the given window. The window is bounded by the retention of the
+	// collected metrics (24 hours)
+	MachineUsage(id string, window time.Duration) (MachineUsageReport, error)
+}
+
+// MachineUsageReport usage of a machine over a time window
+type MachineUsageReport struct {
+	// Window in seconds over which the counters are computed
+	Window uint64 `json:"window"`
+	// CPUTime consumed cpu time in seconds during the window
+	CPUTime float64 `json:"cpu_time"`
+	// MemoryRSS last reported resident memory in bytes
+	MemoryRSS uint64 `json:"memory_rss"`
+	// MemoryActual last reported memory size in bytes as adjusted by the balloon
+	MemoryActual uint64 `json:"memory_actual"`
+	// DiskReadBytes bytes read during the window
+	DiskReadBytes uint64 `json:"disk_read_bytes"`
+	// DiskWriteBytes bytes written during the window
+	DiskWriteBytes uint64 `json:"disk_write_bytes"`
+	// DiskReadOps read operations during the window
+	DiskReadOps uint64 `json:"disk_read_ops"`
+	// DiskWriteOps write operations during the window
+	DiskWriteOps uint64 `json:"disk_write_ops"`
+	// NetRxBytes received bytes on all machine nics during the window
+	NetRxBytes uint64 `json:"net_rx_bytes"`
+	// NetTxBytes transmitted bytes on all machine nics during the window
+	NetTxBytes uint64 `json:"net_tx_bytes"`
+}
+
 type Counters struct {
 	// Total system capacity
 	Total gridtypes.Capacity `json:"total"`
@@ -1159,15 +1159,45 @@ func (n *NativeEngine) ListPrivateIPs(twin uint32, network gridtypes.Name) ([]st
 			}
 			zmachine := data.(*zos.ZMachine)
 			for _, inf := range zmachine.Network.Interfaces {
-				if inf.Network == network {
+				if inf.Network != network {
+					continue
+				}
+				if len(inf.IP) != 0 {
 					ips = append(ips, inf.IP.String())
 				}
+				if len(inf.IPv6) != 0 {
+					ips = append(ips, inf.IPv6.String())
+				}
 			}
 		}
 	}
 	return ips, nil
 }
 
+// UpdateResultData implements pkg.Provision interface
+func (e *NativeEngine) UpdateResultData(id string, data []byte) error {
+	globalID := gridtypes.WorkloadID(id)
+	twin, dlID, name, err := globalID.Parts()
+	if err != nil {
+		return err
+	}
+
+	wl, err := e.storage.Current(twin, dlID, name)
+	if err != nil {
+		return err
+	}
+
+	if wl.Result.State != gridtypes.StateOk {
+		return fmt.Errorf("workload '%s' is not deployed", id)
+	}
+
+	result := wl.Result
+	result.Data = data
+	result.Created = gridtypes.Timestamp(time.Now().Unix())
+
+	return e.storage.Transaction(twin, dlID, wl.WithResults(result))
+}
+
 func isNotFoundError(err error) bool {
 	if errors.Is(err, ErrWorkloadNotExist) || errors.Is(err, ErrDeploymentNotExists) {
 		return true
@@ -36,6 +36,10 @@ type Slot interface {
 	// Counter sets (or overrides) the current stored value for this key,
 	// with value
 	Counter(key string, value float64) error
+	// Gauge sets the last value for this key. Unlike counters
+	// gauges are not accumulated in the slot, and can only be
+	// retrieved with Last
+	Gauge(key string, value float64) error
 	// Key return the key of the slot which is the window timestamp
 	Key() uint64
 }
@@ -266,6 +270,12 @@ func (r *rrdSlot) Counter(key string, value float64) error {
 	})
 }
 
+func (r *rrdSlot) Gauge(key string, value float64) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return setLast(tx, key, value)
+	})
+}
+
 func (r *rrdSlot) Key() uint64 {
 	return r.key
 }
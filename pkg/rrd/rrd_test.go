@@ -251,3 +251,32 @@ func TestCountersMultipleReports(t *testing.T) {
 
 	require.EqualValues(24, total)
 }
+
+func TestGauge(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(os.TempDir(), fmt.Sprint(rand.Int63()))
+	defer os.RemoveAll(path)
+
+	window := 1 * time.Minute
+	db, err := newRRDBolt(path, window, 10*time.Minute)
+	require.NoError(err)
+
+	now := time.Now()
+	slot1, err := db.slotAt(uint64(now.Add(-time.Minute).Unix()))
+	require.NoError(err)
+
+	slotNow, err := db.slotAt(uint64(now.Unix()))
+	require.NoError(err)
+
+	require.NoError(slot1.Gauge("test-1", 100))
+	require.NoError(slotNow.Gauge("test-1", 20))
+
+	counters, err := db.Counters(now.Add(-5 * time.Minute))
+	require.NoError(err)
+	require.Len(counters, 0)
+
+	last, ok, err := db.Last("test-1")
+	require.NoError(err)
+	require.True(ok)
+	require.EqualValues(20, last)
+}
@@ -91,3 +91,18 @@ func (s *GatewayStub) SetNamedProxy(ctx context.Context, arg0 string, arg1 zos.G
 	}
 	return
 }
+
+func (s *GatewayStub) UpdateDomains(ctx context.Context) (ret0 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "UpdateDomains", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
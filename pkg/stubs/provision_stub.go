@@ -159,3 +159,18 @@ func (s *ProvisionStub) ListPublicIPs(ctx context.Context) (ret0 []string, ret1
 	}
 	return
 }
+
+func (s *ProvisionStub) UpdateResultData(ctx context.Context, arg0 string, arg1 []uint8) (ret0 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "UpdateResultData", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
@@ -0,0 +1,46 @@
+// GENERATED CODE
+// --------------
+// please do not edit manually instead use the "zbusc" to regenerate
+
+package stubs
+
+import (
+	"context"
+	zbus "github.com/threefoldtech/zbus"
+	pkg "github.com/threefoldtech/zos/pkg"
+	"time"
+)
+
+type VMUsageStub struct {
+	client zbus.Client
+	module string
+	object zbus.ObjectID
+}
+
+func NewVMUsageStub(client zbus.Client) *VMUsageStub {
+	return &VMUsageStub{
+		client: client,
+		module: "provision",
+		object: zbus.ObjectID{
+			Name:    "usage",
+			Version: "0.0.1",
+		},
+	}
+}
+
+func (s *VMUsageStub) MachineUsage(ctx context.Context, arg0 string, arg1 time.Duration) (ret0 pkg.MachineUsageReport, ret1 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "MachineUsage", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
@@ -58,6 +58,53 @@ func (s *VMModuleStub) Exists(ctx context.Context, arg0 string) (ret0 bool) {
 	return
 }
 
+func (s *VMModuleStub) GuestInfo(ctx context.Context, arg0 string) (ret0 pkg.GuestInfo, ret1 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "GuestInfo", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *VMModuleStub) GuestPasswordSet(ctx context.Context, arg0 string, arg1 string, arg2 string) (ret0 error) {
+	args := []interface{}{arg0, arg1, arg2}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "GuestPasswordSet", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *VMModuleStub) GuestShutdown(ctx context.Context, arg0 string) (ret0 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "GuestShutdown", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) Inspect(ctx context.Context, arg0 string) (ret0 pkg.VMInfo, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Inspect", args...)
@@ -158,6 +205,21 @@ func (s *VMModuleStub) Run(ctx context.Context, arg0 pkg.VM) (ret0 pkg.MachineIn
 	return
 }
 
+func (s *VMModuleStub) ShutdownAll(ctx context.Context) (ret0 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "ShutdownAll", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) StreamCreate(ctx context.Context, arg0 string, arg1 pkg.Stream) (ret0 error) {
 	args := []interface{}{arg0, arg1}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "StreamCreate", args...)
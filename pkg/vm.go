@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"path/filepath"
+	"time"
 
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/threefoldtech/zos/pkg/gridtypes"
@@ -111,6 +112,33 @@ func (s KernelArgs) Extend(k KernelArgs) {
 	}
 }
 
+// VMQoS IO limits of a vm. A zero value means no limit
+type VMQoS struct {
+	// DiskIOPS max number of operations per second per disk
+	DiskIOPS uint64
+	// DiskBandwidth max bytes per second per disk
+	DiskBandwidth gridtypes.Unit
+	// NetBandwidth max bytes per second per network interface
+	NetBandwidth gridtypes.Unit
+}
+
+// Bound returns the qos limited by the given limits. A limit
+// is only applied if set (not zero)
+func (q VMQoS) Bound(limits VMQoS) VMQoS {
+	min := func(a, b uint64) uint64 {
+		if a == 0 || (b != 0 && b < a) {
+			return b
+		}
+		return a
+	}
+
+	return VMQoS{
+		DiskIOPS:      min(q.DiskIOPS, limits.DiskIOPS),
+		DiskBandwidth: gridtypes.Unit(min(uint64(q.DiskBandwidth), uint64(limits.DiskBandwidth))),
+		NetBandwidth:  gridtypes.Unit(min(uint64(q.NetBandwidth), uint64(limits.NetBandwidth))),
+	}
+}
+
 // VM config structure
 type VM struct {
 	// virtual machine name, or ID
@@ -146,6 +174,14 @@ type VM struct {
 	NoKeepAlive bool
 	// Hostname for the vm
 	Hostname string
+	// QoS io limits applied on the vm disks and nics
+	QoS VMQoS
+	// ShutdownTimeout grace period given to the vm to shutdown
+	// before it's killed. If not set the module default is used
+	ShutdownTimeout time.Duration
+	// UserData is an optional cloud-init `#cloud-config` document
+	// provided by the user. It's merged with the generated config
+	UserData string
 
 	// extra PCI devices to be attached to
 	// the virtual machine the strings
@@ -210,6 +246,32 @@ type VMInfo struct {
 
 	// Number of vCPUs (either 1 or an even number)
 	CPU int64
+
+	// Guest information as reported by the guest agent
+	// nil if the agent is not running inside the vm. It's
+	// not filled by Inspect, use GuestInfo instead.
+	Guest *GuestInfo
+}
+
+// GuestFilesystem usage of a filesystem inside the vm
+type GuestFilesystem struct {
+	Device     string `json:"device"`
+	Mountpoint string `json:"mountpoint"`
+	// Total size in bytes
+	Total uint64 `json:"total"`
+	// Used size in bytes
+	Used uint64 `json:"used"`
+}
+
+// GuestInfo information reported by the guest agent
+type GuestInfo struct {
+	Hostname string `json:"hostname"`
+	// IPs of all guest interfaces
+	IPs []string `json:"ips"`
+	// Uptime of the guest in seconds
+	Uptime uint64 `json:"uptime"`
+	// Filesystems usage
+	Filesystems []GuestFilesystem `json:"filesystems"`
 }
 
 // NetMetric aggregated metrics from a single network
@@ -238,11 +300,34 @@ func (n *NetMetric) Nu() float64 {
 	return nu
 }
 
+// MachineUsage resource usage of a single machine. Counters
+// are accumulated since the machine was started
+type MachineUsage struct {
+	// CPUTime consumed cpu time in seconds (counter)
+	CPUTime float64 `json:"cpu_time"`
+	// MemoryRSS memory used by the machine process in bytes, without
+	// the page cache
+	MemoryRSS uint64 `json:"memory_rss"`
+	// MemoryActual memory size of the machine in bytes
+	// as adjusted by the balloon device
+	MemoryActual uint64 `json:"memory_actual"`
+	// DiskReadBytes total bytes read from all machine disks (counter)
+	DiskReadBytes uint64 `json:"disk_read_bytes"`
+	// DiskWriteBytes total bytes written to all machine disks (counter)
+	DiskWriteBytes uint64 `json:"disk_write_bytes"`
+	// DiskReadOps total read operations on all machine disks (counter)
+	DiskReadOps uint64 `json:"disk_read_ops"`
+	// DiskWriteOps total write operations on all machine disks (counter)
+	DiskWriteOps uint64 `json:"disk_write_ops"`
+}
+
 // MachineMetric is a container for metrics from multiple networks
 // currently only grouped as private (wireguard + yggdrasil), and public (public Ips)
+// plus the machine resources usage
 type MachineMetric struct {
 	Private NetMetric
 	Public  NetMetric
+	Usage   MachineUsage
 }
 type MachineInfo struct {
 	ConsoleURL string
@@ -280,6 +365,15 @@ type VMModule interface {
 	Metrics() (MachineMetrics, error)
 	// Lock set lock on VM (pause,resume)
 	Lock(name string, lock bool) error
+	// ShutdownAll gracefully shutdown all running vms, this is used
+	// before the node is powered off.
+	ShutdownAll() error
+	// GuestShutdown gracefully shutdown the vm using the guest agent
+	GuestShutdown(name string) error
+	// GuestInfo queries the guest agent for information about the vm
+	GuestInfo(name string) (GuestInfo, error)
+	// GuestPasswordSet sets a user password inside the vm using the guest agent
+	GuestPasswordSet(name, user, password string) error
 	// VM Log streams
 
 	// StreamCreate creates a stream for vm `name`
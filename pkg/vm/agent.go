@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// vsockCID is the context id of the guest. Cloud-hypervisor uses
+	// a hybrid vsock (a unix socket on the host side) so the cid
+	// does not need to be unique across machines.
+	vsockCID = 3
+	// agentPort is the vsock port the guest agent listens on
+	agentPort = 7070
+
+	// agentTimeout default timeout of an agent call if the
+	// context has no deadline
+	agentTimeout = 5 * time.Second
+)
+
+// agentSocket returns the vsock unix socket path given the
+// machine api socket
+func agentSocket(socket string) string {
+	return socket + ".vsock"
+}
+
+// AgentClient is a client to the guest agent running inside the vm. The
+// agent is reachable over the machine vsock device.
+//
+// The protocol is line based, the host sends a single json encoded request
+// per connection and the agent replies with a single json encoded response.
+type AgentClient struct {
+	socket string
+}
+
+type agentRequest struct {
+	Command string      `json:"command"`
+	Args    interface{} `json:"args,omitempty"`
+}
+
+type agentResponse struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// NewAgentClient creates a new guest agent client over the given vsock unix socket
+func NewAgentClient(socket string) *AgentClient {
+	return &AgentClient{socket: socket}
+}
+
+func (c *AgentClient) connect(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	con, err := dialer.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to vm vsock")
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(agentTimeout)
+	}
+
+	if err := con.SetDeadline(deadline); err != nil {
+		con.Close()
+		return nil, err
+	}
+
+	// hybrid vsock handshake
+	if _, err := fmt.Fprintf(con, "CONNECT %d\n", agentPort); err != nil {
+		con.Close()
+		return nil, errors.Wrap(err, "failed to send vsock connect")
+	}
+
+	return con, nil
+}
+
+func (c *AgentClient) call(ctx context.Context, command string, args interface{}, result interface{}) error {
+	con, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer con.Close()
+
+	reader := bufio.NewReader(con)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return errors.Wrap(err, "failed to read vsock connect response")
+	}
+
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("guest agent is not reachable: %s", strings.TrimSpace(line))
+	}
+
+	if err := json.NewEncoder(con).Encode(agentRequest{Command: command, Args: args}); err != nil {
+		return errors.Wrap(err, "failed to send agent request")
+	}
+
+	var response agentResponse
+	if err := json.NewDecoder(reader).Decode(&response); err != nil {
+		return errors.Wrap(err, "failed to read agent response")
+	}
+
+	if len(response.Error) != 0 {
+		return fmt.Errorf("guest agent error: %s", response.Error)
+	}
+
+	if result == nil || len(response.Data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(response.Data, result)
+}
+
+// Info gets guest information from the agent
+func (c *AgentClient) Info(ctx context.Context) (info pkg.GuestInfo, err error) {
+	err = c.call(ctx, "info", nil, &info)
+	return
+}
+
+// Shutdown asks the guest agent to shutdown the machine
+func (c *AgentClient) Shutdown(ctx context.Context) error {
+	return c.call(ctx, "shutdown", nil, nil)
+}
+
+// SetPassword sets the password of a user inside the guest
+func (c *AgentClient) SetPassword(ctx context.Context, user, password string) error {
+	return c.call(ctx, "set_password", map[string]string{
+		"user":     user,
+		"password": password,
+	}, nil)
+}
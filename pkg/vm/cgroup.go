@@ -0,0 +1,290 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// cgroupRoot is where the cgroup hierarchy is mounted
+	cgroupRoot = "/sys/fs/cgroup"
+	// procRoot is where procfs is mounted
+	procRoot = "/proc"
+	// fsMagic returns the filesystem type of the given path
+	fsMagic = func(path string) (int64, error) {
+		var st unix.Statfs_t
+		if err := unix.Statfs(path, &st); err != nil {
+			return 0, err
+		}
+
+		return int64(st.Type), nil
+	}
+)
+
+// vmsCgroup is the parent cgroup of all virtual machines
+const vmsCgroup = "vms"
+
+const (
+	cgroupV1 = 1
+	cgroupV2 = 2
+)
+
+// cgroupV1Controllers are the v1 hierarchies used for the vms accounting
+var cgroupV1Controllers = []string{"cpuacct", "memory"}
+
+// cgroupVersion detects the cgroup hierarchy mounted at cgroupRoot. On
+// a hybrid setup the controllers are only available in the v1 hierarchies
+// so v1 is used.
+func cgroupVersion() (int, error) {
+	magic, err := fsMagic(cgroupRoot)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to stat cgroup root")
+	}
+
+	if magic == unix.CGROUP2_SUPER_MAGIC {
+		return cgroupV2, nil
+	}
+
+	for _, controller := range cgroupV1Controllers {
+		magic, err := fsMagic(filepath.Join(cgroupRoot, controller))
+		if err != nil || magic != unix.CGROUP_SUPER_MAGIC {
+			return 0, fmt.Errorf("cgroup controller '%s' is not mounted at '%s'", controller, cgroupRoot)
+		}
+	}
+
+	return cgroupV1, nil
+}
+
+// cgroupPaths returns the paths of the cgroup of the vm with the given name,
+// that is one path on v2 and one path per controller on v1.
+func cgroupPaths(version int, name string) []string {
+	if version == cgroupV2 {
+		return []string{filepath.Join(cgroupRoot, vmsCgroup, name)}
+	}
+
+	var paths []string
+	for _, controller := range cgroupV1Controllers {
+		paths = append(paths, filepath.Join(cgroupRoot, controller, vmsCgroup, name))
+	}
+
+	return paths
+}
+
+// joinCgroup moves the process with the given pid into its own vm cgroup
+// so the machine resources usage can be accounted separately.
+func joinCgroup(name string, pid int) error {
+	version, err := cgroupVersion()
+	if err != nil {
+		return err
+	}
+
+	if version == cgroupV2 {
+		// enable cpu and memory accounting for the children. this is best effort
+		// since the controllers can be already enabled or not available.
+		parent := filepath.Join(cgroupRoot, vmsCgroup)
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return errors.Wrap(err, "failed to create vms cgroup")
+		}
+
+		for _, dir := range []string{cgroupRoot, parent} {
+			_ = os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+		}
+	}
+
+	for _, path := range cgroupPaths(version, name) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return errors.Wrapf(err, "failed to create cgroup for vm '%s'", name)
+		}
+
+		// the file must exist, this makes sure it's never created
+		// if the directory is not a cgroup
+		file, err := os.OpenFile(filepath.Join(path, "cgroup.procs"), os.O_WRONLY, 0)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open cgroup procs of vm '%s'", name)
+		}
+
+		_, err = file.WriteString(fmt.Sprint(pid))
+		file.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to move process '%d' to vm cgroup", pid)
+		}
+	}
+
+	return nil
+}
+
+// removeCgroup removes the vm cgroup, it only succeeds if the cgroup has no
+// more processes.
+func removeCgroup(name string) error {
+	version, err := cgroupVersion()
+	if err != nil {
+		// no cgroup could have been created
+		return nil
+	}
+
+	for _, path := range cgroupPaths(version, name) {
+		err := syscall.Rmdir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processCgroup is the accounting cgroup of a process
+type processCgroup struct {
+	version int
+	// cpu is the path of the cgroup with cpu accounting
+	cpu string
+	// memory is the path of the cgroup with memory accounting
+	memory string
+}
+
+// getProcessCgroup returns the cgroup of the given process. The process
+// must be in the cgroup of the vm with the given name, otherwise (for example
+// for machines started before the vms cgroups were used) the accounting would
+// be of the whole system or service cgroup.
+func getProcessCgroup(name string, pid int) (processCgroup, error) {
+	version, err := cgroupVersion()
+	if err != nil {
+		return processCgroup{}, err
+	}
+
+	file, err := os.Open(filepath.Join(procRoot, fmt.Sprint(pid), "cgroup"))
+	if err != nil {
+		return processCgroup{}, err
+	}
+	defer file.Close()
+
+	expected := filepath.Join("/", vmsCgroup, name)
+	cgroup := processCgroup{version: version}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 0::/vms/<name> on v2
+		// 4:cpu,cpuacct:/vms/<name> on v1
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if version == cgroupV2 {
+			if parts[0] == "0" && parts[1] == "" {
+				if filepath.Clean(parts[2]) != expected {
+					return processCgroup{}, fmt.Errorf("process '%d' is in cgroup '%s' not in the cgroup of vm '%s'", pid, parts[2], name)
+				}
+				path := filepath.Join(cgroupRoot, parts[2])
+				return processCgroup{version: version, cpu: path, memory: path}, nil
+			}
+			continue
+		}
+
+		for _, controller := range strings.Split(parts[1], ",") {
+			if (controller == "cpuacct" || controller == "memory") && filepath.Clean(parts[2]) != expected {
+				return processCgroup{}, fmt.Errorf("process '%d' is in %s cgroup '%s' not in the cgroup of vm '%s'", pid, controller, parts[2], name)
+			}
+
+			switch controller {
+			case "cpuacct":
+				cgroup.cpu = filepath.Join(cgroupRoot, controller, parts[2])
+			case "memory":
+				cgroup.memory = filepath.Join(cgroupRoot, controller, parts[2])
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return processCgroup{}, err
+	}
+
+	if len(cgroup.cpu) == 0 || len(cgroup.memory) == 0 {
+		return processCgroup{}, fmt.Errorf("process '%d' has no accounting cgroup", pid)
+	}
+
+	return cgroup, nil
+}
+
+// readStat returns the value of the given key from a cgroup stat file
+// (like cpu.stat or memory.stat) where each line is `<key> <value>`
+func readStat(path, key string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != key {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid %s value", key)
+		}
+
+		return value, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("%s not found in '%s'", key, path)
+}
+
+// CPUTime returns the total (user + system) cpu time in seconds
+// consumed by all processes of the cgroup
+func (c *processCgroup) CPUTime() (float64, error) {
+	if c.version == cgroupV1 {
+		value, err := readFileUint64(filepath.Join(c.cpu, "cpuacct.usage"))
+		if err != nil {
+			return 0, err
+		}
+
+		return float64(value) / 1e9, nil
+	}
+
+	value, err := readStat(filepath.Join(c.cpu, "cpu.stat"), "usage_usec")
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(value) / 1e6, nil
+}
+
+// MemoryRSS returns the memory in bytes currently used by the cgroup without
+// the page cache, since the cache of the vm disk files can be reclaimed and
+// is not used by the machine itself
+func (c *processCgroup) MemoryRSS() (uint64, error) {
+	usageFile, cacheKey := "memory.current", "file"
+	if c.version == cgroupV1 {
+		usageFile, cacheKey = "memory.usage_in_bytes", "total_cache"
+	}
+
+	usage, err := readFileUint64(filepath.Join(c.memory, usageFile))
+	if err != nil {
+		return 0, err
+	}
+
+	cache, err := readStat(filepath.Join(c.memory, "memory.stat"), cacheKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if cache > usage {
+		return 0, nil
+	}
+
+	return usage - cache, nil
+}
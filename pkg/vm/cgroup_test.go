@@ -0,0 +1,239 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// fixtureCgroupRoot creates fake cgroup and proc roots where the given
+// paths (relative to the cgroup root) are reported as mounted with
+// the given filesystem magic. Everything else is a tmpfs.
+func fixtureCgroupRoot(t *testing.T, mounts map[string]int64) {
+	root := t.TempDir()
+	cgroupRoot = filepath.Join(root, "cgroup")
+	procRoot = filepath.Join(root, "proc")
+	magic := fsMagic
+	fsMagic = func(path string) (int64, error) {
+		rel, err := filepath.Rel(cgroupRoot, path)
+		if err != nil {
+			return 0, err
+		}
+		if value, ok := mounts[rel]; ok {
+			return value, nil
+		}
+		return unix.TMPFS_MAGIC, nil
+	}
+
+	t.Cleanup(func() {
+		cgroupRoot = "/sys/fs/cgroup"
+		procRoot = "/proc"
+		fsMagic = magic
+	})
+
+	require.NoError(t, os.MkdirAll(cgroupRoot, 0755))
+}
+
+func fixtureProcCgroup(t *testing.T, pid string, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, pid), 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(procRoot, pid, "cgroup"),
+		[]byte(content),
+		0644,
+	))
+}
+
+func fixtureCgroupFiles(t *testing.T, path string, files map[string]string) {
+	require.NoError(t, os.MkdirAll(path, 0755))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(path, name), []byte(content), 0644))
+	}
+}
+
+func fixtureCgroup(t *testing.T, pid string, files map[string]string) {
+	fixtureCgroupRoot(t, map[string]int64{".": unix.CGROUP2_SUPER_MAGIC})
+	fixtureProcCgroup(t, pid, "0::/vms/test\n")
+	fixtureCgroupFiles(t, filepath.Join(cgroupRoot, "vms", "test"), files)
+}
+
+func fixtureCgroupV1(t *testing.T, pid string, files map[string]string) {
+	fixtureCgroupRoot(t, map[string]int64{
+		"cpuacct": unix.CGROUP_SUPER_MAGIC,
+		"memory":  unix.CGROUP_SUPER_MAGIC,
+		"unified": unix.CGROUP2_SUPER_MAGIC,
+	})
+	fixtureProcCgroup(t, pid, "12:memory:/vms/test\n11:cpu,cpuacct:/vms/test\n0::/\n")
+	for _, controller := range cgroupV1Controllers {
+		fixtureCgroupFiles(t, filepath.Join(cgroupRoot, controller, "vms", "test"), files)
+	}
+}
+
+func TestCgroupVersion(t *testing.T) {
+	fixtureCgroup(t, "100", nil)
+	version, err := cgroupVersion()
+	require.NoError(t, err)
+	require.Equal(t, cgroupV2, version)
+
+	fixtureCgroupV1(t, "100", nil)
+	version, err = cgroupVersion()
+	require.NoError(t, err)
+	require.Equal(t, cgroupV1, version)
+
+	fixtureCgroupRoot(t, nil)
+	_, err = cgroupVersion()
+	require.Error(t, err)
+}
+
+func TestProcessCgroup(t *testing.T) {
+	fixtureCgroup(t, "100", nil)
+
+	cgroup, err := getProcessCgroup("test", 100)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(cgroupRoot, "vms", "test"), cgroup.cpu)
+	require.Equal(t, filepath.Join(cgroupRoot, "vms", "test"), cgroup.memory)
+
+	_, err = getProcessCgroup("test", 200)
+	require.Error(t, err)
+}
+
+func TestProcessCgroupV1(t *testing.T) {
+	fixtureCgroupV1(t, "100", nil)
+
+	cgroup, err := getProcessCgroup("test", 100)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(cgroupRoot, "cpuacct", "vms", "test"), cgroup.cpu)
+	require.Equal(t, filepath.Join(cgroupRoot, "memory", "vms", "test"), cgroup.memory)
+}
+
+func TestProcessCgroupNotVM(t *testing.T) {
+	fixtureCgroup(t, "100", nil)
+
+	// the vm was started before it had its own cgroup
+	fixtureProcCgroup(t, "100", "0::/system.slice\n")
+	_, err := getProcessCgroup("test", 100)
+	require.Error(t, err)
+
+	fixtureProcCgroup(t, "100", "0::/vms/other\n")
+	_, err = getProcessCgroup("test", 100)
+	require.Error(t, err)
+
+	fixtureCgroupV1(t, "100", nil)
+	fixtureProcCgroup(t, "100", "12:memory:/vms/test\n11:cpu,cpuacct:/\n0::/\n")
+	_, err = getProcessCgroup("test", 100)
+	require.Error(t, err)
+}
+
+func TestProcessCgroupV1Only(t *testing.T) {
+	fixtureCgroup(t, "100", nil)
+	fixtureProcCgroup(t, "100", "12:memory:/vms/test\n11:cpu,cpuacct:/vms/test\n")
+
+	_, err := getProcessCgroup("test", 100)
+	require.Error(t, err)
+}
+
+func TestCgroupUsage(t *testing.T) {
+	fixtureCgroup(t, "100", map[string]string{
+		"cpu.stat": `usage_usec 2500000
+user_usec 2000000
+system_usec 500000
+nr_periods 0
+nr_throttled 0
+throttled_usec 0
+`,
+		"memory.current": "104857600\n",
+		"memory.stat":    "anon 73400320\nfile 31457280\n",
+	})
+
+	cgroup, err := getProcessCgroup("test", 100)
+	require.NoError(t, err)
+
+	cpu, err := cgroup.CPUTime()
+	require.NoError(t, err)
+	require.Equal(t, 2.5, cpu)
+
+	mem, err := cgroup.MemoryRSS()
+	require.NoError(t, err)
+	require.Equal(t, uint64(73400320), mem)
+}
+
+func TestCgroupUsageV1(t *testing.T) {
+	fixtureCgroupV1(t, "100", map[string]string{
+		"cpuacct.usage":         "2500000000\n",
+		"memory.usage_in_bytes": "104857600\n",
+		"memory.stat":           "cache 1048576\nrss 73400320\ntotal_cache 31457280\ntotal_rss 73400320\n",
+	})
+
+	cgroup, err := getProcessCgroup("test", 100)
+	require.NoError(t, err)
+
+	cpu, err := cgroup.CPUTime()
+	require.NoError(t, err)
+	require.Equal(t, 2.5, cpu)
+
+	mem, err := cgroup.MemoryRSS()
+	require.NoError(t, err)
+	require.Equal(t, uint64(73400320), mem)
+}
+
+func TestCgroupUsageInvalid(t *testing.T) {
+	fixtureCgroup(t, "100", map[string]string{
+		"cpu.stat":       "user_usec 2000000\n",
+		"memory.current": "max\n",
+	})
+
+	cgroup, err := getProcessCgroup("test", 100)
+	require.NoError(t, err)
+
+	_, err = cgroup.CPUTime()
+	require.Error(t, err)
+
+	_, err = cgroup.MemoryRSS()
+	require.Error(t, err)
+}
+
+func TestJoinCgroup(t *testing.T) {
+	fixtureCgroup(t, "100", nil)
+	// a real cgroup has the procs file as soon as it's created
+	fixtureCgroupFiles(t, filepath.Join(cgroupRoot, "vms", "vm1"), map[string]string{
+		"cgroup.procs": "",
+	})
+
+	require.NoError(t, joinCgroup("vm1", 100))
+
+	procs, err := os.ReadFile(filepath.Join(cgroupRoot, "vms", "vm1", "cgroup.procs"))
+	require.NoError(t, err)
+	require.Equal(t, "100", string(procs))
+
+	// a real cgroup can only be removed once empty, this is
+	// a regular directory so it has files in it
+	require.Error(t, removeCgroup("vm1"))
+	require.NoError(t, removeCgroup("vm2"))
+}
+
+func TestJoinCgroupV1(t *testing.T) {
+	fixtureCgroupV1(t, "100", nil)
+	for _, controller := range cgroupV1Controllers {
+		fixtureCgroupFiles(t, filepath.Join(cgroupRoot, controller, "vms", "vm1"), map[string]string{
+			"cgroup.procs": "",
+		})
+	}
+
+	require.NoError(t, joinCgroup("vm1", 100))
+
+	for _, controller := range cgroupV1Controllers {
+		procs, err := os.ReadFile(filepath.Join(cgroupRoot, controller, "vms", "vm1", "cgroup.procs"))
+		require.NoError(t, err)
+		require.Equal(t, "100", string(procs))
+	}
+}
+
+func TestJoinCgroupNotMounted(t *testing.T) {
+	fixtureCgroupRoot(t, nil)
+
+	require.Error(t, joinCgroup("vm1", 100))
+	require.NoFileExists(t, filepath.Join(cgroupRoot, "vms", "vm1", "cgroup.procs"))
+	require.NoError(t, removeCgroup("vm1"))
+}
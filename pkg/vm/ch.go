@@ -64,6 +64,7 @@ func (m *Machine) startCloudConsole(ctx context.Context, namespace string, netwo
 // Run run the machine with cloud-hypervisor
 func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo, error) {
 	_ = os.Remove(socket)
+	_ = os.Remove(agentSocket(socket))
 
 	// build command line
 	args := map[string][]string{
@@ -76,6 +77,8 @@ func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo
 		"--console":    {"off"},
 		"--serial":     {"pty"}, // we use pty here for the cloud console to be able to read the vm console, in case of debuging or we need stdout logging we use tty
 		"--api-socket": {socket},
+		// vsock device used to talk to the guest agent
+		"--vsock": {fmt.Sprintf("cid=%d,socket=%s", vsockCID, agentSocket(socket))},
 	}
 
 	var devices []string
@@ -214,6 +217,14 @@ func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo
 		return pkg.MachineInfo{}, errors.Wrap(err, "failed to start cloud-hypervisor")
 	}
 
+	// a leftover cgroup from a previous run of the same machine
+	_ = removeCgroup(m.ID)
+	if err = joinCgroup(m.ID, cmd.Process.Pid); err != nil {
+		// usage accounting is not critical for the vm to run
+		log.Error().Err(err).Str("vm", m.ID).Msg("failed to create vm cgroup")
+		err = nil
+	}
+
 	if err = m.release(cmd.Process); err != nil {
 		return pkg.MachineInfo{}, err
 	}
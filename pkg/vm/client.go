@@ -21,8 +21,15 @@ type VMData struct {
 	CPU     CPU
 	Memory  MemMib
 	PTYPath string
+	// MemoryActual is the actual memory size in bytes of the
+	// machine (as adjusted by the balloon device)
+	MemoryActual uint64
 }
 
+// Counters is a map of device name to device counters as
+// reported by cloud-hypervisor
+type Counters map[string]map[string]uint64
+
 // NewClient creates a new instance of client
 func NewClient(unix string) *Client {
 	httpClient := retryablehttp.NewClient()
@@ -58,6 +65,26 @@ func (c *Client) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// PowerButton sends an ACPI power button event to the machine
+// giving the guest os the chance to shutdown gracefully
+func (c *Client) PowerButton(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.power-button", nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return errors.Wrap(err, "error calling machine power button")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("got unexpected http code '%s' on machine power button", response.Status)
+	}
+
+	return nil
+}
+
 func (c *Client) Pause(ctx context.Context) error {
 	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.pause", nil)
 	if err != nil {
@@ -117,7 +144,8 @@ func (c *Client) Inspect(ctx context.Context) (VMData, error) {
 	}
 
 	var data struct {
-		Config struct {
+		MemoryActualSize uint64 `json:"memory_actual_size"`
+		Config           struct {
 			CPU struct {
 				Boot uint8 `json:"boot_vcpus"`
 			} `json:"cpus"`
@@ -137,6 +165,35 @@ func (c *Client) Inspect(ctx context.Context) (VMData, error) {
 		CPU:     CPU(data.Config.CPU.Boot),
 		Memory:  MemMib(data.Config.Memory.Size / (1024 * 1024)),
 		PTYPath: data.Config.Serial.PTYPath,
+
+		MemoryActual: data.MemoryActualSize,
 	}
 	return vmData, nil
 }
+
+// Counters return the machine devices counters
+func (c *Client) Counters(ctx context.Context) (Counters, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix/api/v1/vm.counters", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calling machine counters")
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(response.Body)
+		return nil, fmt.Errorf("got unexpected http code '%s' on machine counters, Response: %s", response.Status, string(body))
+	}
+
+	var counters Counters
+	if err := json.NewDecoder(response.Body).Decode(&counters); err != nil {
+		return nil, errors.Wrap(err, "failed to parse machine counters")
+	}
+
+	return counters, nil
+}
@@ -87,4 +87,7 @@ type Configuration struct {
 	Users     []User
 	Mounts    []Mount
 	Extension Extension
+	// UserData is an optional user provided `#cloud-config`
+	// document that is merged with the generated user-data
+	UserData string
 }
@@ -76,10 +76,12 @@ func CreateImage(file string, cfg Configuration) error {
 		return err
 	}
 
-	if err := write("/user-data", marsh{
-		"users":  cfg.Users,
-		"mounts": cfg.Mounts,
-	}); err != nil {
+	userData, err := cfg.userData()
+	if err != nil {
+		return err
+	}
+
+	if err := write("/user-data", userData); err != nil {
 		return err
 	}
 
@@ -93,6 +95,51 @@ func CreateImage(file string, cfg Configuration) error {
 	return cfg.Extension.write(rc)
 }
 
+// userData builds the user-data object. If the user provided extra user data
+// it's used as a base, and the generated users and mounts are appended to the
+// ones defined by the user.
+func (c *Configuration) userData() (marsh, error) {
+	data := marsh{}
+	if len(c.UserData) != 0 {
+		if err := yaml.Unmarshal([]byte(c.UserData), &data); err != nil {
+			return nil, errors.Wrap(err, "invalid user data")
+		}
+	}
+
+	extend := func(key string, values ...interface{}) error {
+		var current []interface{}
+		if value, ok := data[key]; ok && value != nil {
+			current, ok = value.([]interface{})
+			if !ok {
+				return fmt.Errorf("invalid user data: '%s' must be a list", key)
+			}
+		}
+
+		data[key] = append(current, values...)
+		return nil
+	}
+
+	var users []interface{}
+	for _, user := range c.Users {
+		users = append(users, user)
+	}
+
+	if err := extend("users", users...); err != nil {
+		return nil, err
+	}
+
+	var mounts []interface{}
+	for _, mount := range c.Mounts {
+		mounts = append(mounts, mount)
+	}
+
+	if err := extend("mounts", mounts...); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
 // transpiled from https://github.com/python/cpython/blob/3.10/Lib/shlex.py#L325
 func quote(s string) string {
 	if s == "" {
@@ -0,0 +1,117 @@
+package cloudinit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestUserData(t *testing.T) {
+	cfg := Configuration{
+		Users:  []User{{Name: "root", Keys: []string{"ssh-ed25519 key"}}},
+		Mounts: []Mount{{Source: "vda", Target: "/data"}},
+	}
+
+	cases := []struct {
+		name     string
+		userData string
+		expected string
+		err      string
+	}{
+		{
+			name: "generated only",
+			expected: `mounts:
+- - vda
+  - /data
+  - auto
+  - defaults
+  - "0"
+  - "0"
+users:
+- name: root
+  ssh_authorized_keys:
+  - ssh-ed25519 key
+`,
+		},
+		{
+			name: "merged with user cloud-config",
+			userData: `#cloud-config
+packages:
+- nginx
+runcmd:
+- systemctl enable --now nginx
+`,
+			expected: `mounts:
+- - vda
+  - /data
+  - auto
+  - defaults
+  - "0"
+  - "0"
+packages:
+- nginx
+runcmd:
+- systemctl enable --now nginx
+users:
+- name: root
+  ssh_authorized_keys:
+  - ssh-ed25519 key
+`,
+		},
+		{
+			name: "user lists are extended",
+			userData: `#cloud-config
+users:
+- default
+mounts:
+- [vdb, /backup]
+`,
+			expected: `mounts:
+- - vdb
+  - /backup
+- - vda
+  - /data
+  - auto
+  - defaults
+  - "0"
+  - "0"
+users:
+- default
+- name: root
+  ssh_authorized_keys:
+  - ssh-ed25519 key
+`,
+		},
+		{
+			name: "conflicting key type",
+			userData: `#cloud-config
+users: root
+`,
+			err: "invalid user data: 'users' must be a list",
+		},
+		{
+			name: "not a cloud-config",
+			userData: `#!/bin/sh
+echo hello
+`,
+			err: "invalid user data",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg.UserData = c.userData
+			data, err := cfg.userData()
+			if len(c.err) != 0 {
+				require.ErrorContains(t, err, c.err)
+				return
+			}
+
+			require.NoError(t, err)
+			out, err := yaml.Marshal(data)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, string(out))
+		})
+	}
+}
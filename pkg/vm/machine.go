@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -23,12 +24,44 @@ type Boot struct {
 	Args   string `json:"boot_args"`
 }
 
+// RateLimiter io limits of a device. Limits are
+// per second, a zero value means no limit.
+type RateLimiter struct {
+	// Ops max number of operations per second
+	Ops uint64 `json:"ops"`
+	// Bandwidth max number of bytes per second
+	Bandwidth uint64 `json:"bandwidth"`
+}
+
+// rateLimiterRefillTime is the time in ms in which the rate limiter
+// bucket is refilled.
+const rateLimiterRefillTime = 1000
+
+// String builds the rate limiter command line arguments
+func (r *RateLimiter) String() string {
+	if r == nil {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if r.Bandwidth > 0 {
+		buf.WriteString(fmt.Sprintf(",bw_size=%d,bw_refill_time=%d", r.Bandwidth, rateLimiterRefillTime))
+	}
+
+	if r.Ops > 0 {
+		buf.WriteString(fmt.Sprintf(",ops_size=%d,ops_refill_time=%d", r.Ops, rateLimiterRefillTime))
+	}
+
+	return buf.String()
+}
+
 // Disk struct
 type Disk struct {
-	ID         string `json:"drive_id"`
-	Path       string `json:"path_on_host"`
-	RootDevice bool   `json:"is_root_device"`
-	ReadOnly   bool   `json:"is_read_only"`
+	ID         string       `json:"drive_id"`
+	Path       string       `json:"path_on_host"`
+	RootDevice bool         `json:"is_root_device"`
+	ReadOnly   bool         `json:"is_read_only"`
+	Limiter    *RateLimiter `json:"limiter,omitempty"`
 }
 
 func (d Disk) String() string {
@@ -37,7 +70,7 @@ func (d Disk) String() string {
 		on = "on"
 	}
 
-	return fmt.Sprintf(`path=%s,readonly=%s`, d.Path, on)
+	return fmt.Sprintf(`path=%s,readonly=%s%s`, d.Path, on, d.Limiter.String())
 }
 
 // Disks is a list of vm disks
@@ -63,6 +96,8 @@ type Interface struct {
 	Tap     string   `json:"host_dev_name"`
 	Mac     string   `json:"guest_mac,omitempty"`
 	Console *Console `json:"console,omitempty"`
+	// Limiter io limits on the nic
+	Limiter *RateLimiter `json:"limiter,omitempty"`
 }
 
 // asTap returns the command line argument for this interface as a tap device
@@ -73,6 +108,8 @@ func (i Interface) asTap() string {
 		buf.WriteString(fmt.Sprintf(",mac=%s", i.Mac))
 	}
 
+	buf.WriteString(i.Limiter.String())
+
 	return buf.String()
 }
 
@@ -135,6 +172,9 @@ type Machine struct {
 	// NoKeepAlive is not used by firecracker, but instead a marker
 	// for the vm  mananger to not restart the machine when it stops
 	NoKeepAlive bool `json:"no-keep-alive"`
+	// ShutdownTimeout grace period given to the machine to shutdown
+	// after the power button is pressed, before it's killed
+	ShutdownTimeout time.Duration `json:"shutdown-timeout,omitempty"`
 }
 
 // Save saves a machine into a file
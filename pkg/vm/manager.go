@@ -33,6 +33,23 @@ const (
 
 	// cloud-init directory
 	cloudInitDir = "cloud-init"
+
+	// defaultShutdownTimeout grace period given to machines to
+	// shutdown if not configured
+	defaultShutdownTimeout = 10 * time.Second
+	// maxShutdownTimeout max grace period a machine can ask for. Delete
+	// blocks for the grace period (and up to 20 more seconds to force the
+	// stop), so this must stay well below the 3 minutes provisiond gives to
+	// the full deprovision of a workload.
+	maxShutdownTimeout = 1 * time.Minute
+
+	// shutdownHold is how long machines stopped by ShutdownAll are
+	// not revived by the monitor, it gives the node time to power off.
+	shutdownHold = 5 * time.Minute
+
+	// guestAgentTimeout bounds guest agent commands. zbus does not carry
+	// the caller context so an unresponsive agent must not block vmd.
+	guestAgentTimeout = 10 * time.Second
 )
 
 var (
@@ -90,7 +107,32 @@ func NewVMModule(cl zbus.Client, root, config string) (*Module, error) {
 	return mod, nil
 }
 
+// diskLimiter returns the rate limiter for vm disks, nil if no limits are set
+func (m *Module) diskLimiter(vm *pkg.VM) *RateLimiter {
+	if vm.QoS.DiskIOPS == 0 && vm.QoS.DiskBandwidth == 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		Ops:       vm.QoS.DiskIOPS,
+		Bandwidth: uint64(vm.QoS.DiskBandwidth),
+	}
+}
+
+// netLimiter returns the rate limiter for vm nics, nil if no limits are set
+func (m *Module) netLimiter(vm *pkg.VM) *RateLimiter {
+	if vm.QoS.NetBandwidth == 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		Bandwidth: uint64(vm.QoS.NetBandwidth),
+	}
+}
+
 func (m *Module) makeDiskDevices(vm *pkg.VM) ([]Disk, error) {
+	limiter := m.diskLimiter(vm)
+
 	var drives []Disk
 	if vm.Boot.Type == pkg.BootDisk {
 		drives = append(drives, Disk{
@@ -98,6 +140,7 @@ func (m *Module) makeDiskDevices(vm *pkg.VM) ([]Disk, error) {
 			Path:       vm.Boot.Path,
 			RootDevice: true,
 			ReadOnly:   false,
+			Limiter:    limiter,
 		})
 	}
 	for _, disk := range vm.Disks {
@@ -107,6 +150,7 @@ func (m *Module) makeDiskDevices(vm *pkg.VM) ([]Disk, error) {
 			ID:       id,
 			ReadOnly: false,
 			Path:     disk.Path,
+			Limiter:  limiter,
 		})
 	}
 
@@ -130,6 +174,10 @@ func (m *Module) socketPath(name string) string {
 	return filepath.Join(socketDir, name)
 }
 
+func (m *Module) agentSocketPath(name string) string {
+	return agentSocket(m.socketPath(name))
+}
+
 func (m *Module) configPath(name string) string {
 	return filepath.Join(m.cfg, name)
 }
@@ -157,6 +205,12 @@ func (m *Module) getConsoleConfig(ctx context.Context, ifc pkg.VMIface) (*Consol
 		return nil, errors.Wrapf(err, "failed to get network '%s'", ifc.NetID)
 	}
 
+	if len(networkAddr.IP) == 0 {
+		// the console is only served over ipv4, hence not available
+		// on ipv6 only networks
+		return nil, nil
+	}
+
 	networkAddr.IP = networkAddr.IP.To4()
 
 	if len(networkAddr.IP) != net.IPv4len {
@@ -195,12 +249,15 @@ func (m *Module) makeNetwork(ctx context.Context, vm *pkg.VM, cfg *cloudinit.Con
 		hasPubIpv6 = ifcfg.PublicIPv6 || hasPubIpv6
 	}
 
+	limiter := m.netLimiter(vm)
+
 	nics := make([]Interface, 0, len(vm.Network.Ifaces))
 	for i, ifcfg := range vm.Network.Ifaces {
 		nic := Interface{
-			ID:  fmt.Sprintf("eth%d", i),
-			Tap: ifcfg.Tap,
-			Mac: ifcfg.MAC,
+			ID:      fmt.Sprintf("eth%d", i),
+			Tap:     ifcfg.Tap,
+			Mac:     ifcfg.MAC,
+			Limiter: limiter,
 		}
 		if ifcfg.NetID != "" && len(ifcfg.IPs) > 0 {
 			// if NetID is set on this interface means it is a private network so we add console config to it.
@@ -405,6 +462,7 @@ func (m *Module) Run(vm pkg.VM) (pkg.MachineInfo, error) {
 			Entrypoint:  vm.Entrypoint,
 			Environment: vm.Environment,
 		},
+		UserData: vm.UserData,
 	}
 
 	// TODO: user config should be added as another property on the
@@ -510,11 +568,12 @@ func (m *Module) Run(vm pkg.VM) (pkg.MachineInfo, error) {
 			Mem:       MemMib(vm.Memory / gridtypes.Megabyte),
 			HTEnabled: false,
 		},
-		FS:          fs,
-		Interfaces:  nics,
-		Disks:       disks,
-		Devices:     vm.Devices,
-		NoKeepAlive: vm.NoKeepAlive,
+		FS:              fs,
+		Interfaces:      nics,
+		Disks:           disks,
+		Devices:         vm.Devices,
+		NoKeepAlive:     vm.NoKeepAlive,
+		ShutdownTimeout: vm.ShutdownTimeout,
 	}
 
 	log.Debug().Str("name", vm.Name).Msg("saving machine")
@@ -558,11 +617,53 @@ func (m *Module) Inspect(name string) (pkg.VMInfo, error) {
 		return pkg.VMInfo{}, errors.Wrap(err, "failed to get machine configuration")
 	}
 
-	return pkg.VMInfo{
+	info := pkg.VMInfo{
 		CPU:       int64(vmdata.CPU),
 		Memory:    int64(vmdata.Memory),
 		HtEnabled: false,
-	}, nil
+	}
+
+	return info, nil
+}
+
+// GuestInfo queries the guest agent running inside the machine
+func (m *Module) GuestInfo(name string) (pkg.GuestInfo, error) {
+	if !m.Exists(name) {
+		return pkg.GuestInfo{}, fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return NewAgentClient(m.agentSocketPath(name)).Info(ctx)
+}
+
+// GuestShutdown asks the guest agent to gracefully shutdown the machine
+func (m *Module) GuestShutdown(name string) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), guestAgentTimeout)
+	defer cancel()
+
+	return NewAgentClient(m.agentSocketPath(name)).Shutdown(ctx)
+}
+
+// GuestPasswordSet sets the password of a user inside the machine using the guest agent
+func (m *Module) GuestPasswordSet(name, user, password string) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	if len(user) == 0 {
+		return fmt.Errorf("user is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), guestAgentTimeout)
+	defer cancel()
+
+	return NewAgentClient(m.agentSocketPath(name)).SetPassword(ctx, user, password)
 }
 
 func (m *Module) removeConfig(name string) {
@@ -575,6 +676,8 @@ func (m *Module) removeConfig(name string) {
 	_ = os.Remove(m.cloudInitImage(name))
 
 	_ = os.Remove(m.logsPath(name))
+
+	_ = os.Remove(m.agentSocketPath(name))
 }
 
 // Delete deletes a machine by name (id)
@@ -596,14 +699,64 @@ func (m *Module) Delete(name string) error {
 	ps, err := Find(name)
 	if err != nil {
 		// machine already gone
+		_ = removeCgroup(name)
 		return nil
 	}
 
+	m.stop(name, ps, m.shutdownTimeout(name))
+	if err := removeCgroup(name); err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed to remove vm cgroup")
+	}
+
+	return nil
+}
+
+// shutdownTimeout gets the configured shutdown grace period of
+// the machine, bounded by the max allowed timeout.
+func (m *Module) shutdownTimeout(name string) time.Duration {
+	timeout := defaultShutdownTimeout
+	if machine, err := MachineFromFile(m.configPath(name)); err == nil && machine.ShutdownTimeout > 0 {
+		timeout = machine.ShutdownTimeout
+	}
+
+	if timeout > maxShutdownTimeout {
+		timeout = maxShutdownTimeout
+	}
+
+	return timeout
+}
+
+// stop a running machine. The machine is first given the chance to shutdown
+// gracefully by pressing the power button, if it's still running after the
+// grace period the machine is forced to stop.
+func (m *Module) stop(name string, ps Process, grace time.Duration) {
 	client := NewClient(m.socketPath(name))
 
+	wait := func(timeout time.Duration) bool {
+		deadline := time.Now().Add(timeout)
+		for time.Now().Before(deadline) {
+			if !m.Exists(name) {
+				return true
+			}
+			<-time.After(1 * time.Second)
+		}
+
+		return !m.Exists(name)
+	}
+
+	log.Debug().Str("name", name).Dur("grace", grace).Msg("shutting vm down [power-button]")
 	// timeout is request timeout, not machine timeout to shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := client.PowerButton(ctx)
+	cancel()
 
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed to press machine power button")
+	} else if wait(grace) {
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	now := time.Now()
@@ -620,7 +773,7 @@ func (m *Module) Delete(name string) error {
 
 	for {
 		if !m.Exists(name) {
-			return nil
+			return
 		}
 
 		log.Debug().Str("name", name).Msg("shutting vm down [sigterm]")
@@ -636,6 +789,32 @@ func (m *Module) Delete(name string) error {
 
 		<-time.After(1 * time.Second)
 	}
+}
+
+// ShutdownAll gracefully shutdown all running machines. Machines are
+// not restarted by the monitor for shutdownHold after this call, if the
+// node is still up by then the monitor brings them back. This is meant
+// to be called before the node is powered off.
+func (m *Module) ShutdownAll() error {
+	machines, err := FindAll()
+	if err != nil {
+		return errors.Wrap(err, "failed to list running machines")
+	}
+
+	var wg sync.WaitGroup
+	for name, ps := range machines {
+		// prevent the monitor from trying to revive this machine
+		// while the node is going down
+		m.failures.Set(name, halted, shutdownHold)
+
+		wg.Add(1)
+		go func(name string, ps Process) {
+			defer wg.Done()
+			m.stop(name, ps, m.shutdownTimeout(name))
+		}(name, ps)
+	}
+
+	wg.Wait()
 
 	return nil
 }
@@ -1,11 +1,13 @@
 package vm
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -25,6 +27,15 @@ func (m *Module) Metrics() (pkg.MachineMetrics, error) {
 			log.Error().Err(err).Int("pid", ps.Pid).Msg("failed to get metrics for CH process")
 			continue
 		}
+
+		metric.Usage, err = m.usage(name, ps)
+		if err != nil {
+			// usage is not used for billing, so we still
+			// report the network metrics
+			log.Error().Err(err).Str("name", name).Msg("failed to get usage for CH process")
+			metric.Usage = pkg.MachineUsage{}
+		}
+
 		result[name] = metric
 	}
 
@@ -94,6 +105,54 @@ func (m *Module) metrics(ps Process) (pkg.MachineMetric, error) {
 	return metrics, nil
 }
 
+// usage collects the machine resources usage from the vm cgroup accounting
+// and the cloud-hypervisor counters
+func (m *Module) usage(name string, ps Process) (usage pkg.MachineUsage, err error) {
+	cgroup, err := getProcessCgroup(name, ps.Pid)
+	if err != nil {
+		return usage, errors.Wrap(err, "failed to get process cgroup")
+	}
+
+	usage.CPUTime, err = cgroup.CPUTime()
+	if err != nil {
+		return usage, errors.Wrap(err, "failed to get vm cpu time")
+	}
+
+	usage.MemoryRSS, err = cgroup.MemoryRSS()
+	if err != nil {
+		return usage, errors.Wrap(err, "failed to get vm memory usage")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClient(m.socketPath(name))
+	info, err := client.Inspect(ctx)
+	if err != nil {
+		return usage, err
+	}
+
+	usage.MemoryActual = info.MemoryActual
+
+	counters, err := client.Counters(ctx)
+	if err != nil {
+		return usage, err
+	}
+
+	for device, values := range counters {
+		if !strings.HasPrefix(device, "_disk") {
+			continue
+		}
+
+		usage.DiskReadBytes += values["read_bytes"]
+		usage.DiskWriteBytes += values["write_bytes"]
+		usage.DiskReadOps += values["read_ops"]
+		usage.DiskWriteOps += values["write_ops"]
+	}
+
+	return usage, nil
+}
+
 func readFileUint64(p string) (uint64, error) {
 	bytes, err := os.ReadFile(p)
 	if err != nil {
@@ -29,6 +29,11 @@ var (
 	// when it detects that it is down.
 	permanent = struct{}{}
 
+	// if the failures marker is set to halted it means the machine
+	// was stopped because the node is powering off. the monitoring
+	// leaves it alone (and keeps its config) until the marker expires.
+	halted = struct{ halted bool }{true}
+
 	rotator = rotate.NewRotator(
 		rotate.MaxSize(8*rotate.Megabytes),
 		rotate.TailSize(4*rotate.Megabytes),
@@ -180,6 +185,10 @@ func (m *Module) monitorID(ctx context.Context, running map[string]Process, id s
 		m.failures.Set(id, int(0), cache.DefaultExpiration)
 	}
 
+	if marker == halted {
+		return nil
+	}
+
 	if marker == permanent {
 		// if the marker is permanent. it means that this vm
 		// is being deleted or not monitored. we don't need to take any more action here